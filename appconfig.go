@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+const (
+	// keyVaultRefContentType marks the key-values the configuration provider resolves
+	// from Key Vault
+	keyVaultRefContentType = "application/vnd.microsoft.appconfig.keyvaultref+json;charset=utf-8"
+	// appConfigReserved prefixes the keys of feature flags and other App Configuration
+	// features, which push appconfig never touches
+	appConfigReserved = ".appconfig."
+)
+
+// appConfigAPI is the part of the App Configuration client used by push appconfig
+type appConfigAPI interface {
+	NewListSettingsPager(selector azappconfig.SettingSelector, options *azappconfig.ListSettingsOptions) *runtime.Pager[azappconfig.ListSettingsPageResponse]
+	SetSetting(ctx context.Context, key string, value *string, options *azappconfig.SetSettingOptions) (azappconfig.SetSettingResponse, error)
+	DeleteSetting(ctx context.Context, key string, options *azappconfig.DeleteSettingOptions) (azappconfig.DeleteSettingResponse, error)
+}
+
+// appConfigClient writes the key-values of a store
+type appConfigClient struct {
+	client appConfigAPI
+	// store names the store in the output
+	store string
+}
+
+// appConfigSetting is a key-value of a store
+type appConfigSetting struct {
+	Key         string
+	Value       string
+	ContentType string
+	Locked      bool
+}
+
+// labelFilter returns the filter selecting label, where "" is the null label
+func labelFilter(label string) *string {
+	if label == "" {
+		return to.Ptr("\x00")
+	}
+	return &label
+}
+
+// labelOption returns the label of a write, nil for the null label
+func labelOption(label string) *string {
+	if label == "" {
+		return nil
+	}
+	return &label
+}
+
+// settings returns the key-values of label whose key starts with prefix, feature
+// flags excluded unless prefix selects them
+func (c *appConfigClient) settings(ctx context.Context, prefix, label string) (map[string]appConfigSetting, error) {
+	settings := make(map[string]appConfigSetting)
+	pager := c.client.NewListSettingsPager(azappconfig.SettingSelector{
+		KeyFilter:   to.Ptr(prefix + "*"),
+		LabelFilter: labelFilter(label),
+		Fields:      []azappconfig.SettingFields{azappconfig.SettingFieldsKey, azappconfig.SettingFieldsValue, azappconfig.SettingFieldsContentType, azappconfig.SettingFieldsIsReadOnly},
+	}, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("list key-values: %w", err)
+		}
+		for _, s := range page.Settings {
+			key := deref(s.Key)
+			if strings.HasPrefix(key, appConfigReserved) && !strings.HasPrefix(prefix, appConfigReserved) {
+				continue
+			}
+			settings[key] = appConfigSetting{Key: key, Value: deref(s.Value), ContentType: deref(s.ContentType), Locked: s.IsReadOnly != nil && *s.IsReadOnly}
+		}
+	}
+	return settings, nil
+}
+
+// put creates or replaces the key-value s in label
+func (c *appConfigClient) put(ctx context.Context, s appConfigSetting, label string) error {
+	value := s.Value
+	options := &azappconfig.SetSettingOptions{ContentType: &s.ContentType, Label: labelOption(label)}
+	if _, err := c.client.SetSetting(ctx, s.Key, &value, options); err != nil {
+		return fmt.Errorf("set %s: %w", s.Key, err)
+	}
+	return nil
+}
+
+// remove deletes the key-value key of label
+func (c *appConfigClient) remove(ctx context.Context, key, label string) error {
+	if _, err := c.client.DeleteSetting(ctx, key, &azappconfig.DeleteSettingOptions{Label: labelOption(label)}); err != nil {
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// appConfigValue returns the value and content type under which the configuration
+// provider reads value: Key Vault references become Key Vault reference key-values,
+// and the feature flags of -feature-flags keep their content type
+func appConfigValue(key, value, contentType string) (string, string) {
+	if strings.HasPrefix(key, appsettingsenv.FeatureFlagPrefix) {
+		return value, appsettingsenv.FeatureFlagContentType
+	}
+	ref, ok := parseKeyVaultRef(key, value)
+	if !ok {
+		return value, contentType
+	}
+	uri := "https://" + ref.vault + ".vault.azure.net/secrets/" + ref.secret
+	if ref.version != "" {
+		uri += "/" + ref.version
+	}
+	data, _ := json.Marshal(map[string]string{"uri": uri})
+	return string(data), keyVaultRefContentType
+}
+
+// runPushAppConfig implements push appconfig. It writes the variables as key-values
+// of an App Configuration store, after printing what changes.
+func runPushAppConfig(args []string) int {
+	store := flag.String("store", "", "Store name, or its endpoint URL for sovereign clouds (required)")
+	label := flag.String("label", "", "Label of the key-values (default: no label)")
+	keyPrefix := flag.String("key-prefix", "", "Prefix prepended to every key, e.g. MyApp: (also limits -delete)")
+	contentType := flag.String("content-type", "", "Content type of the key-values")
+	deleteMissing := flag.Bool("delete", false, "Delete the key-values of the label and key prefix that the configuration no longer defines")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push appconfig [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Key Vault references are written as Key Vault reference key-values. Authentication")
+		fmt.Fprintln(flag.CommandLine.Output(), "uses the azidentity default credential chain, as for push azure, and needs the")
+		fmt.Fprintln(flag.CommandLine.Output(), "App Configuration Data Owner role.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	r, code := renderOutput("push appconfig", "appconfig", args)
+	if r == nil {
+		return code
+	}
+
+	if *store == "" {
+		errorf("-store is required")
+		return exitUsage
+	}
+	endpoint := *store
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint + ".azconfig.io"
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		logError(fmt.Errorf("azure credential: %w", err))
+		return exitUsage
+	}
+	sdk, err := azappconfig.NewClient(endpoint, cred, nil)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	client := &appConfigClient{client: sdk, store: strings.TrimPrefix(endpoint, "https://")}
+
+	p := appConfigPush{label: *label, keyPrefix: *keyPrefix, contentType: *contentType, deleteMissing: *deleteMissing, featureFlags: *featureFlagsOut}
+	if err := client.push(context.Background(), os.Stdout, p, r, *dryRun); err != nil {
+		logError(err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// appConfigPush holds the options of push appconfig
+type appConfigPush struct {
+	label         string
+	keyPrefix     string
+	contentType   string
+	deleteMissing bool
+	// featureFlags manages the feature flags of the label too, as -feature-flags
+	// writes them
+	featureFlags bool
+}
+
+// push writes the changed variables of r to the store, and with deleteMissing
+// deletes the key-values of the label and prefix the configuration does not define
+func (c *appConfigClient) push(ctx context.Context, w io.Writer, p appConfigPush, r *rendered, dryRun bool) error {
+	current, err := c.settings(ctx, p.keyPrefix, p.label)
+	if err != nil {
+		return err
+	}
+	if p.featureFlags {
+		flags, err := c.settings(ctx, appsettingsenv.FeatureFlagPrefix, p.label)
+		if err != nil {
+			return err
+		}
+		maps.Copy(current, flags)
+	}
+
+	var changed []appConfigSetting
+	currentValues := make(map[string]string, len(current))
+	for k, s := range current {
+		currentValues[k] = s.Value
+	}
+	wanted := make(map[string]string, len(r.named))
+	secret := make(map[string]bool)
+	var typeOnly []string
+	for _, kv := range appsettingsenv.Sorted(r.named) {
+		// Feature flags live under their own reserved prefix
+		key := p.keyPrefix + kv.Name
+		if strings.HasPrefix(kv.Name, appConfigReserved) {
+			key = kv.Name
+		}
+		value, contentType := appConfigValue(kv.Name, kv.Value, p.contentType)
+		wanted[key] = value
+		secret[key] = r.secret[kv.Name]
+
+		old, exists := current[key]
+		if exists && old.Value == value && old.ContentType == contentType {
+			continue
+		}
+		if old.Locked {
+			return fmt.Errorf("%s is locked in the store", key)
+		}
+		changed = append(changed, appConfigSetting{Key: key, Value: value, ContentType: contentType})
+		if exists && old.Value == value {
+			typeOnly = append(typeOnly, key)
+		}
+	}
+
+	d := diffVariables(currentValues, wanted)
+	if !p.deleteMissing {
+		clear(d.Removed)
+	}
+	for key := range d.Removed {
+		if current[key].Locked {
+			return fmt.Errorf("%s is locked in the store", key)
+		}
+	}
+	// Key-values whose content type alone changes are listed too
+	for _, key := range typeOnly {
+		d.Changed[key] = valueChange{From: wanted[key], To: wanted[key]}
+	}
+
+	target := "key-values of " + c.store
+	if p.label != "" {
+		target += " labeled " + p.label
+	}
+	if done, err := writePreview(w, d, secret, target, dryRun); done || err != nil {
+		return err
+	}
+
+	for _, s := range changed {
+		if err := c.put(ctx, s, p.label); err != nil {
+			return err
+		}
+	}
+	for _, key := range appsettingsenv.SortedKeys(d.Removed) {
+		if err := c.remove(ctx, key, p.label); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(w, "%d key-value(s) written, %d deleted\n", len(changed), len(d.Removed))
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azappconfig/v2"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// fakeAppConfig is an in-memory store holding the key-values of one label, listed
+// two per page
+type fakeAppConfig struct {
+	label  string
+	store  map[string]appConfigSetting
+	writes []string
+}
+
+func (f *fakeAppConfig) NewListSettingsPager(selector azappconfig.SettingSelector, _ *azappconfig.ListSettingsOptions) *runtime.Pager[azappconfig.ListSettingsPageResponse] {
+	var settings []azappconfig.Setting
+	if deref(selector.LabelFilter) == deref(labelFilter(f.label)) {
+		for _, k := range slices.Sorted(maps.Keys(f.store)) {
+			if s := f.store[k]; strings.HasPrefix(k, strings.TrimSuffix(deref(selector.KeyFilter), "*")) {
+				settings = append(settings, azappconfig.Setting{Key: to.Ptr(k), Value: to.Ptr(s.Value), ContentType: to.Ptr(s.ContentType), IsReadOnly: to.Ptr(s.Locked)})
+			}
+		}
+	}
+	pages := slices.Collect(slices.Chunk(settings, 2))
+	return runtime.NewPager(runtime.PagingHandler[azappconfig.ListSettingsPageResponse]{
+		More: func(azappconfig.ListSettingsPageResponse) bool { return len(pages) > 0 },
+		Fetcher: func(context.Context, *azappconfig.ListSettingsPageResponse) (azappconfig.ListSettingsPageResponse, error) {
+			var page azappconfig.ListSettingsPageResponse
+			if len(pages) > 0 {
+				page.Settings, pages = pages[0], pages[1:]
+			}
+			return page, nil
+		},
+	})
+}
+
+func (f *fakeAppConfig) SetSetting(_ context.Context, key string, value *string, options *azappconfig.SetSettingOptions) (azappconfig.SetSettingResponse, error) {
+	if deref(options.Label) != f.label {
+		return azappconfig.SetSettingResponse{}, fmt.Errorf("unexpected label %q", deref(options.Label))
+	}
+	f.store[key] = appConfigSetting{Key: key, Value: *value, ContentType: deref(options.ContentType)}
+	f.writes = append(f.writes, "put "+key)
+	return azappconfig.SetSettingResponse{}, nil
+}
+
+func (f *fakeAppConfig) DeleteSetting(_ context.Context, key string, options *azappconfig.DeleteSettingOptions) (azappconfig.DeleteSettingResponse, error) {
+	if deref(options.Label) != f.label {
+		return azappconfig.DeleteSettingResponse{}, fmt.Errorf("unexpected label %q", deref(options.Label))
+	}
+	delete(f.store, key)
+	f.writes = append(f.writes, "delete "+key)
+	return azappconfig.DeleteSettingResponse{}, nil
+}
+
+func TestAppConfigPush(t *testing.T) {
+	store := map[string]appConfigSetting{
+		"Api:Logging:Level": {Key: "Api:Logging:Level", Value: "Information"},
+		"Api:Unchanged":     {Key: "Api:Unchanged", Value: "same"},
+		"Api:Retired":       {Key: "Api:Retired", Value: "old"},
+		"Api:Typed":         {Key: "Api:Typed", Value: "1", ContentType: "text/plain"},
+	}
+	fake := &fakeAppConfig{label: "prod", store: store}
+	fake.store[".appconfig.featureflag/Beta"] = appConfigSetting{Key: ".appconfig.featureflag/Beta"}
+	client := &appConfigClient{client: fake, store: "api.azconfig.io"}
+	r := &rendered{
+		named: map[string]string{
+			"Logging:Level": "Warning",
+			"Unchanged":     "same",
+			"Typed":         "1",
+			"Db:Password":   "s3cret",
+			"Jwt:Key":       "@Microsoft.KeyVault(VaultName=kv-api;SecretName=JwtKey)",
+		},
+		secret: map[string]bool{"Db:Password": true},
+	}
+	p := appConfigPush{label: "prod", keyPrefix: "Api:", deleteMissing: true}
+
+	var out bytes.Buffer
+	if err := client.push(context.Background(), &out, p, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "added   Api:Db:Password: \"(secret)\"\n" +
+		"added   Api:Jwt:Key: \"{\\\"uri\\\":\\\"https://kv-api.vault.azure.net/secrets/JwtKey\\\"}\"\n" +
+		"changed Api:Logging:Level: \"Information\" -> \"Warning\"\n" +
+		"removed Api:Retired: \"old\"\n" +
+		"changed Api:Typed: \"1\" -> \"1\"\n"
+	if out.String() != want || len(fake.writes) != 0 {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := client.push(context.Background(), &out, p, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if strings.Join(fake.writes, ",") != "put Api:Db:Password,put Api:Jwt:Key,put Api:Logging:Level,put Api:Typed,delete Api:Retired" {
+		t.Fatalf("unexpected writes: %v", fake.writes)
+	}
+	if store["Api:Jwt:Key"].ContentType != keyVaultRefContentType || store["Api:Typed"].ContentType != "" {
+		t.Fatalf("unexpected content types: %+v", store)
+	}
+	if !strings.HasSuffix(out.String(), "4 key-value(s) written, 1 deleted\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+}
+
+func TestAppConfigPushFeatureFlags(t *testing.T) {
+	store := map[string]appConfigSetting{
+		"Api:Url":                     {Key: "Api:Url", Value: "https://api"},
+		".appconfig.featureflag/Beta": {Key: ".appconfig.featureflag/Beta", Value: `{"id":"Beta","enabled":false}`},
+		".appconfig.featureflag/Old":  {Key: ".appconfig.featureflag/Old", Value: `{"id":"Old","enabled":true}`},
+	}
+	fake := &fakeAppConfig{store: store}
+	client := &appConfigClient{client: fake, store: "api.azconfig.io"}
+	beta := `{"id":"Beta","enabled":true,"conditions":{"client_filters":[]}}`
+	r := &rendered{named: map[string]string{"Url": "https://api", ".appconfig.featureflag/Beta": beta}}
+	p := appConfigPush{keyPrefix: "Api:", deleteMissing: true, featureFlags: true}
+
+	var out bytes.Buffer
+	if err := client.push(context.Background(), &out, p, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if strings.Join(fake.writes, ",") != "put .appconfig.featureflag/Beta,delete .appconfig.featureflag/Old" {
+		t.Fatalf("unexpected writes: %v", fake.writes)
+	}
+	if s := store[".appconfig.featureflag/Beta"]; s.Value != beta || s.ContentType != appsettingsenv.FeatureFlagContentType {
+		t.Fatalf("unexpected feature flag: %+v", s)
+	}
+}
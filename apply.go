@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+)
+
+// kubectlPlugin is the executable name kubectl runs for `kubectl appsettings`
+const kubectlPlugin = "kubectl-appsettings"
+
+// isKubectlPlugin reports whether the program was started as a kubectl plugin
+func isKubectlPlugin(arg0 string) bool {
+	name := strings.TrimSuffix(filepath.Base(arg0), ".exe")
+	return name == kubectlPlugin
+}
+
+// runApply implements the apply subcommand. It converts the configuration to a
+// ConfigMap, and a Secret for the -secret-keys, and creates or replaces them in the
+// cluster.
+func runApply(args []string) int {
+	namespace := flag.String("namespace", "", "Namespace (default: from the kubeconfig context)")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flag.String("context", "", "Kubeconfig context (default: current context)")
+	dryRun := flag.Bool("dry-run", false, "Print the ConfigMap and Secret instead of applying them")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s apply [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	manifests, code := renderManifests("apply", args)
+	if manifests == nil {
+		return code
+	}
+
+	if *dryRun {
+		if err := writeStdout(func(w io.Writer) error { return writeManifests(w, manifests) }); err != nil {
+			logError(ioError(err))
+			return exitIO
+		}
+		return exitOK
+	}
+
+	client, err := newKubeClient(*kubeconfig, *kubeContext, *namespace)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	for _, m := range manifests {
+		var obj kubeObject
+		if err := yaml.Unmarshal(m, &obj); err != nil {
+			logError(fmt.Errorf("parse generated manifest: %w", err))
+			return exitFailure
+		}
+		result, err := client.replace(ctx, &obj)
+		if err != nil {
+			logError(err)
+			return exitIO
+		}
+		fmt.Fprintf(os.Stdout, "%s/%s %s\n", strings.ToLower(obj.Kind), obj.Metadata.Name, result)
+	}
+	return exitOK
+}
+
+// renderManifests runs the conversion of command, which writes a ConfigMap and a
+// Secret for the -secret-keys, and returns the manifests. On failure it returns nil
+// and the exit code.
+func renderManifests(command string, args []string) ([][]byte, int) {
+	r, code := renderOutput(command, "configmap", args)
+	if r == nil {
+		return nil, code
+	}
+
+	// -split writes several ConfigMaps as one YAML stream
+	manifests := yamlDocuments(r.output)
+	if r.secrets != nil {
+		manifests = append(manifests, r.secrets)
+	}
+	return manifests, exitOK
+}
+
+// documentSeparator separates the documents of a YAML stream
+var documentSeparator = regexp.MustCompile(`(?m)^---\r?\n`)
+
+// yamlDocuments splits a multi-document YAML stream at its --- separators
+func yamlDocuments(stream []byte) [][]byte {
+	var docs [][]byte
+	start := 0
+	for _, loc := range documentSeparator.FindAllIndex(stream, -1) {
+		docs = append(docs, stream[start:loc[0]])
+		start = loc[1]
+	}
+	return append(docs, stream[start:])
+}
+
+// renderOutput runs the conversion of command, whose only output type is outType,
+// and returns the result. On failure it returns nil and the exit code.
+func renderOutput(command, outType string, args []string) (*rendered, int) {
+	outputTypes = typeList{types: []string{outType}}
+	cv, code := prepareConversion(args)
+	if cv == nil {
+		return nil, code
+	}
+	for _, name := range []string{"type", "out", "secret-out", "watch", "check", "interactive"} {
+		if isFlagSet(name) {
+			errorf("-%s cannot be used with %s", name, command)
+			return nil, exitUsage
+		}
+	}
+
+	outputs, err := cv.render()
+	if err != nil {
+		logError(err)
+		return nil, exitCode(err, exitFailure)
+	}
+	if *strict && warnings > 0 {
+		errorf("%d warning(s) treated as errors (-strict)", warnings)
+		return nil, exitValidation
+	}
+
+	return outputs[0], exitOK
+}
+
+// writeManifests writes manifests to w as a multi-document YAML stream
+func writeManifests(w io.Writer, manifests [][]byte) error {
+	for i, m := range manifests {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replace creates a ConfigMap or Secret in the client's namespace, or replaces it
+// when it exists, and returns what was done as kubectl reports it
+func (c *kubeClient) replace(ctx context.Context, obj *kubeObject) (string, error) {
+	meta := metav1.ObjectMeta{Name: obj.Metadata.Name, Namespace: c.namespace, Annotations: obj.Metadata.Annotations}
+	core := c.clientset.CoreV1()
+	if obj.Kind == "Secret" {
+		secret := &corev1.Secret{ObjectMeta: meta, Type: corev1.SecretTypeOpaque, StringData: obj.StringData}
+		return replaceObject(ctx, core.Secrets(c.namespace), obj.Kind, secret)
+	}
+	return replaceObject(ctx, core.ConfigMaps(c.namespace), obj.Kind, &corev1.ConfigMap{ObjectMeta: meta, Data: obj.Data})
+}
+
+// objectClient is the part of the typed client of a kind replaceObject uses
+type objectClient[T metav1.Object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Create(ctx context.Context, obj T, opts metav1.CreateOptions) (T, error)
+	Update(ctx context.Context, obj T, opts metav1.UpdateOptions) (T, error)
+}
+
+// replaceObject creates obj, or replaces the existing object of its name
+func replaceObject[T metav1.Object](ctx context.Context, client objectClient[T], kind string, obj T) (string, error) {
+	name := obj.GetName()
+	existing, err := client.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := client.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			return "", fmt.Errorf("create %s %s: %w", kind, name, err)
+		}
+		return "created", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get %s %s: %w", kind, name, err)
+	}
+
+	// The resource version makes the update fail if the object changed meanwhile
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	if _, err := client.Update(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("replace %s %s: %w", kind, name, err)
+	}
+	return "replaced", nil
+}
+
+// serverSideApply applies a ConfigMap or Secret to the client's namespace with
+// server-side apply, owned by fieldManager. force takes over fields owned by other
+// managers, and dryRun has the API server validate the change without persisting it.
+func (c *kubeClient) serverSideApply(ctx context.Context, obj *kubeObject, fieldManager string, force, dryRun bool) error {
+	opts := metav1.ApplyOptions{FieldManager: fieldManager, Force: force}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	var err error
+	core := c.clientset.CoreV1()
+	if obj.Kind == "Secret" {
+		// stringData is write-only, so the applied configuration owns data instead
+		data := make(map[string][]byte, len(obj.StringData))
+		for k, v := range obj.StringData {
+			data[k] = []byte(v)
+		}
+		secret := corev1ac.Secret(obj.Metadata.Name, c.namespace).WithAnnotations(obj.Metadata.Annotations).
+			WithType(corev1.SecretTypeOpaque).WithData(data)
+		_, err = core.Secrets(c.namespace).Apply(ctx, secret, opts)
+	} else {
+		configMap := corev1ac.ConfigMap(obj.Metadata.Name, c.namespace).WithAnnotations(obj.Metadata.Annotations).WithData(obj.Data)
+		_, err = core.ConfigMaps(c.namespace).Apply(ctx, configMap, opts)
+	}
+	if err != nil {
+		return fmt.Errorf("apply %s %s: %w", obj.Kind, obj.Metadata.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/client-go/rest"
+)
+
+func TestIsKubectlPlugin(t *testing.T) {
+	cases := map[string]bool{
+		"/usr/local/bin/kubectl-appsettings": true,
+		"kubectl-appsettings.exe":            true,
+		"kubectl-appsettings":                true,
+		"./dotnet-appsettings-env":           false,
+	}
+	for arg0, want := range cases {
+		if got := isKubectlPlugin(arg0); got != want {
+			t.Fatalf("%s: want %v got %v", arg0, want, got)
+		}
+	}
+}
+
+func TestYAMLDocuments(t *testing.T) {
+	docs := yamlDocuments([]byte("# header\na: 1\n---\nb: |-\n  ---\n---\r\nc: 3\n"))
+	if len(docs) != 3 || string(docs[0]) != "# header\na: 1\n" || string(docs[1]) != "b: |-\n  ---\n" || string(docs[2]) != "c: 3\n" {
+		t.Fatalf("unexpected documents: %q", docs)
+	}
+}
+
+// failingWriter fails every write, as a closed pipe does
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestWriteManifests(t *testing.T) {
+	manifests := [][]byte{[]byte("a: 1\n"), []byte("b: 2\n")}
+	var buf bytes.Buffer
+	if err := writeManifests(&buf, manifests); err != nil || buf.String() != "a: 1\n---\nb: 2\n" {
+		t.Fatalf("unexpected manifests %q: %v", buf.String(), err)
+	}
+	if err := writeManifests(failingWriter{}, manifests); !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
+
+// testKubeClient returns a client of the API server srv serves, in namespace prod.
+// It sends JSON rather than protobuf for the handlers to decode.
+func testKubeClient(t *testing.T, srv *httptest.Server) *kubeClient {
+	t.Helper()
+	config := &rest.Config{Host: srv.URL, ContentConfig: rest.ContentConfig{ContentType: "application/json"}}
+	client, err := kubeClientFor(config, "prod")
+	if err != nil {
+		t.Fatalf("kubeClientFor failed: %v", err)
+	}
+	return client
+}
+
+func TestKubeClientReplace(t *testing.T) {
+	stored := map[string]map[string]any{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodGet:
+			obj, ok := stored[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"kind":"Status","message":"not found"}`))
+				return
+			}
+			obj["metadata"].(map[string]any)["resourceVersion"] = "42"
+			json.NewEncoder(w).Encode(obj)
+		case http.MethodPost, http.MethodPut:
+			var obj map[string]any
+			body, _ := io.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &obj); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			path := r.URL.Path
+			if r.Method == http.MethodPost {
+				path += "/" + obj["metadata"].(map[string]any)["name"].(string)
+			} else if obj["metadata"].(map[string]any)["resourceVersion"] != "42" {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			stored[path] = obj
+			w.Write(body)
+		}
+	}))
+	defer srv.Close()
+
+	client := testKubeClient(t, srv)
+	var obj *kubeObject
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: api\n  annotations:\n    checksum/config: \"abc\"\ndata:\n  Api__Url: https://api\n"
+	if err := yaml.Unmarshal([]byte(manifest), &obj); err != nil {
+		t.Fatalf("parse manifest: %v", err)
+	}
+
+	ctx := context.Background()
+	if result, err := client.replace(ctx, obj); err != nil || result != "created" {
+		t.Fatalf("first apply: want created, got %q, %v", result, err)
+	}
+	if result, err := client.replace(ctx, obj); err != nil || result != "replaced" {
+		t.Fatalf("second apply: want replaced, got %q, %v", result, err)
+	}
+
+	got := stored["/api/v1/namespaces/prod/configmaps/api"]
+	if got["data"].(map[string]any)["Api__Url"] != "https://api" {
+		t.Fatalf("unexpected ConfigMap: %v", got)
+	}
+	if got["metadata"].(map[string]any)["annotations"].(map[string]any)["checksum/config"] != "abc" {
+		t.Fatalf("the annotations of the manifest should be sent: %v", got)
+	}
+
+	secret := &kubeObject{Kind: "Secret", StringData: map[string]string{"Db__Password": "s3cret"}}
+	secret.Metadata.Name = "api-secrets"
+	if _, err := client.replace(ctx, secret); err != nil {
+		t.Fatalf("apply secret: %v", err)
+	}
+	if got := stored["/api/v1/namespaces/prod/secrets/api-secrets"]; got["type"] != "Opaque" || got["stringData"] == nil {
+		t.Fatalf("unexpected Secret: %v", got)
+	}
+}
+
+func TestKubeClientServerSideApply(t *testing.T) {
+	var method, contentType string
+	var query url.Values
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		method, contentType, query = r.Method, r.Header.Get("Content-Type"), r.URL.Query()
+		if r.URL.Path != "/api/v1/namespaces/prod/secrets/api-secrets" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client := testKubeClient(t, srv)
+	secret := &kubeObject{Kind: "Secret", StringData: map[string]string{"Db__Password": "s3cret"}}
+	secret.Metadata.Name = "api-secrets"
+	secret.Metadata.Annotations = map[string]string{"checksum/config": "abc"}
+	if err := client.serverSideApply(context.Background(), secret, "ci", true, true); err != nil {
+		t.Fatalf("server-side apply: %v", err)
+	}
+
+	if method != http.MethodPatch || contentType != "application/apply-patch+yaml" {
+		t.Fatalf("unexpected request: %s %s", method, contentType)
+	}
+	if query.Get("fieldManager") != "ci" || query.Get("force") != "true" || query.Get("dryRun") != "All" {
+		t.Fatalf("unexpected query: %v", query)
+	}
+	if body["data"].(map[string]any)["Db__Password"] != "czNjcmV0" || body["stringData"] != nil {
+		t.Fatalf("secret values should be applied as data: %v", body)
+	}
+	if body["metadata"].(map[string]any)["annotations"].(map[string]any)["checksum/config"] != "abc" {
+		t.Fatalf("the annotations of the manifest should be applied: %v", body)
+	}
+}
@@ -0,0 +1,64 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/internal/synth"
+)
+
+// benchShape is a configuration of about 6000 variables and 180 KB
+var benchShape = synth.Options{Width: 20, Depth: 4, ArraySize: 10}
+
+func BenchmarkParse(b *testing.B) {
+	data := synth.Generate(benchShape)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Parse(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseStream(b *testing.B) {
+	data := synth.Generate(benchShape)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := ParseStream(bytes.NewReader(data), StreamOptions{Duplicate: func(string, int, int) {}}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSorted(b *testing.B) {
+	doc, err := Parse(synth.Generate(benchShape))
+	if err != nil {
+		b.Fatal(err)
+	}
+	vars := Flatten(doc, FlattenOptions{Separator: "__"})
+	b.ReportAllocs()
+	for b.Loop() {
+		Sorted(vars)
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	doc, err := Parse(synth.Generate(benchShape))
+	if err != nil {
+		b.Fatal(err)
+	}
+	list := Sorted(Flatten(doc, FlattenOptions{Separator: "__"}))
+	for _, typ := range []string{"k8s", "configmap", "docker", "bicep", "appconfig"} {
+		b.Run(typ, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := Format(io.Discard, list, FormatOptions{Type: typ}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
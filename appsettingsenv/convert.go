@@ -0,0 +1,39 @@
+package appsettingsenv
+
+import "io"
+
+// Options control a conversion
+type Options struct {
+	// Flatten controls the keys. An empty separator uses the default separator of
+	// the output type: __ for the environment formats, : for the others.
+	Flatten FlattenOptions
+	Format  FormatOptions
+}
+
+// Convert reads an appsettings.json document from r and writes its variables to w
+// in the output type of opts, sorted by name
+func Convert(r io.Reader, w io.Writer, opts Options) error {
+	f, err := lookup(&opts.Format)
+	if err != nil {
+		return err
+	}
+	if opts.Flatten.Separator == "" {
+		opts.Flatten.Separator = describe(f).Separator
+	}
+
+	vars, err := Read(r, opts.Flatten)
+	if err != nil {
+		return err
+	}
+	bw, flush := buffered(w)
+	return flush(f.Write(bw, Sorted(vars)))
+}
+
+// Read parses the appsettings.json document read from r and flattens it
+func Read(r io.Reader, opts FlattenOptions) (map[string]string, error) {
+	doc, err := ParseReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return Flatten(doc, opts), nil
+}
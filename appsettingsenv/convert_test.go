@@ -0,0 +1,35 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	src := `{"Logging": {"LogLevel": {"Default": "Information"}}, "Hosts": ["a"]}`
+
+	var buf bytes.Buffer
+	if err := Convert(strings.NewReader(src), &buf, Options{Format: FormatOptions{Type: "docker"}}); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	want := "Hosts__0=\"a\"\nLogging__LogLevel__Default=\"Information\"\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected docker output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Convert(strings.NewReader(src), &buf, Options{Format: FormatOptions{Type: "user-secrets"}}); err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"Logging:LogLevel:Default": "Information"`) {
+		t.Fatalf("colon-separated types should use : by default:\n%s", buf.String())
+	}
+
+	if err := Convert(strings.NewReader(src), &buf, Options{Format: FormatOptions{Type: "xml"}}); err == nil {
+		t.Fatalf("an unknown output type should fail")
+	}
+	if err := Convert(strings.NewReader(`{"a":`), &buf, Options{}); err == nil {
+		t.Fatalf("invalid JSON should fail")
+	}
+}
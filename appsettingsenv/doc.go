@@ -0,0 +1,14 @@
+// Package appsettingsenv converts .NET appsettings.json configuration into
+// environment variables and the deployment formats that carry them, the same way
+// the dotnet-appsettings-env command does.
+//
+// A conversion parses a file, flattens it into keys joined by a separator and
+// formats the result:
+//
+//	doc, err := appsettingsenv.Parse(data)
+//	if err != nil {
+//		return err
+//	}
+//	vars := appsettingsenv.Flatten(doc, appsettingsenv.FlattenOptions{})
+//	return appsettingsenv.Format(os.Stdout, appsettingsenv.Sorted(vars), appsettingsenv.FormatOptions{Type: "k8s"})
+package appsettingsenv
@@ -0,0 +1,257 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FlattenOptions control how nested values become flat keys. The zero value
+// flattens like the .NET configuration binder: keys joined with ":", one key per
+// array element and nulls emitted as empty strings.
+type FlattenOptions struct {
+	// Separator joins the segments of a key (default ":")
+	Separator string
+	// ArrayMode emits arrays as one key per element (indexed, the default), as a
+	// single JSON value (json) or, for arrays of scalars, joined by ArrayDelimiter (join)
+	ArrayMode      string
+	ArrayDelimiter string
+	// MaxDepth emits objects and arrays nested this many levels deep as a single
+	// JSON value (0: unlimited)
+	MaxDepth int
+	// PadIndex zero-pads array indices to this many digits
+	PadIndex int
+	// Nulls emits null values as empty strings (empty, the default), the literal
+	// null (null) or skips them (skip)
+	Nulls string
+	// Literal, when set, is called for every key whose value was a JSON number,
+	// boolean or emitted null
+	Literal func(key, value string)
+}
+
+func (o FlattenOptions) separator() string {
+	if o.Separator == "" {
+		return ":"
+	}
+	return o.Separator
+}
+
+// Flatten flattens a parsed document into keys joined by the separator
+func Flatten(doc map[string]any, opts FlattenOptions) map[string]string {
+	out := make(map[string]string)
+	flatten(doc, &opts, false, func(key, value, _ string, literal bool) {
+		out[key] = value
+		if literal && opts.Literal != nil {
+			opts.Literal(key, value)
+		}
+	})
+	return out
+}
+
+// emitFunc receives a flattened key and its value, with the JSONPath of the value and
+// whether it was a JSON number, boolean or emitted null
+type emitFunc func(key, value, path string, literal bool)
+
+// node is a value of the document waiting to be flattened. Its key and path are
+// immutable strings, so siblings never share the storage of their parent's key.
+type node struct {
+	value     any
+	key, path string
+	depth     int
+}
+
+// child returns the node of value, found under segment in n
+func (n node) child(value any, segment, sep string) node {
+	key := segment
+	if n.depth > 0 {
+		key = n.key + sep + segment
+	}
+	return node{value: value, key: key, depth: n.depth + 1}
+}
+
+// flatten walks doc depth-first with an explicit stack, emitting its scalars and
+// the containers emitted as a single value. JSONPaths are only built, and passed to
+// emit, when paths is set.
+func flatten(doc map[string]any, o *FlattenOptions, paths bool, emit emitFunc) {
+	sep := o.separator()
+	stack := []node{{value: doc, path: "$"}}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// Containers below MaxDepth stay a single JSON-encoded value
+		if o.MaxDepth > 0 && n.depth >= o.MaxDepth {
+			switch n.value.(type) {
+			case []any, map[string]any:
+				emit(n.key, EncodeJSON(n.value), n.path, false)
+				continue
+			}
+		}
+
+		switch v := n.value.(type) {
+		case []any:
+			switch o.ArrayMode {
+			case "json":
+				emit(n.key, EncodeJSON(v), n.path, false)
+				continue
+			case "join":
+				if joined, ok := joinScalars(v, o.ArrayDelimiter, o.Nulls); ok {
+					emit(n.key, joined, n.path, false)
+					continue
+				}
+			}
+			// Pushed backwards to be emitted in order
+			for idx := len(v) - 1; idx >= 0; idx-- {
+				child := n.child(v[idx], Index(idx, o.PadIndex), sep)
+				if paths {
+					child.path = n.path + "[" + strconv.Itoa(idx) + "]"
+				}
+				stack = append(stack, child)
+			}
+		case map[string]any:
+			for key, item := range v {
+				child := n.child(item, key, sep)
+				if paths {
+					child.path = memberPath(n.path, key)
+				}
+				stack = append(stack, child)
+			}
+		default:
+			if value, ok := scalarValue(v, o.Nulls); ok {
+				emit(n.key, value, n.path, isJSONLiteral(v, value))
+			}
+		}
+	}
+}
+
+// memberPath returns the JSONPath of the member key of the object at path, in dot
+// notation for identifiers and bracket notation otherwise
+func memberPath(path, key string) string {
+	plain := key != ""
+	for i, r := range key {
+		if !isLetter(r) && r != '_' && (i == 0 || !isDigit(r)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		return path + "." + key
+	}
+	return path + "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(key) + "']"
+}
+
+// Empty is an empty JSON object or array, which produces no key when flattened
+type Empty struct {
+	Key string
+	// Kind is object or array
+	Kind string
+}
+
+// EmptyContainers lists the empty objects and arrays of doc, keyed like Flatten does
+func EmptyContainers(doc map[string]any, opts FlattenOptions) []Empty {
+	sep := opts.separator()
+	var found []Empty
+	var walk func(n node)
+	walk = func(n node) {
+		// Values below MaxDepth are JSON-encoded as a whole
+		if opts.MaxDepth > 0 && n.depth >= opts.MaxDepth {
+			return
+		}
+
+		switch v := n.value.(type) {
+		case map[string]any:
+			if len(v) == 0 && n.depth > 0 {
+				found = append(found, Empty{Key: n.key, Kind: "object"})
+			}
+			for k, item := range v {
+				walk(n.child(item, k, sep))
+			}
+		case []any:
+			// Arrays emitted as a single value are never empty keys
+			if _, joinable := joinScalars(v, "", opts.Nulls); opts.ArrayMode == "json" || (opts.ArrayMode == "join" && joinable) {
+				return
+			}
+			if len(v) == 0 {
+				found = append(found, Empty{Key: n.key, Kind: "array"})
+			}
+			for idx, item := range v {
+				walk(n.child(item, Index(idx, opts.PadIndex), sep))
+			}
+		}
+	}
+	walk(node{value: doc})
+	return found
+}
+
+// Index formats an array index as a key segment, zero-padded to pad digits
+func Index(idx, pad int) string {
+	s := strconv.Itoa(idx)
+	if len(s) < pad {
+		s = strings.Repeat("0", pad-len(s)) + s
+	}
+	return s
+}
+
+// joinScalars joins the elements of an array of scalars with delimiter. It reports
+// false when the array holds objects or arrays, which cannot be joined.
+func joinScalars(items []any, delimiter, nulls string) (string, bool) {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		switch item.(type) {
+		case []any, map[string]any:
+			return "", false
+		}
+		if value, ok := scalarValue(item, nulls); ok {
+			parts = append(parts, value)
+		}
+	}
+	return strings.Join(parts, delimiter), true
+}
+
+// scalarValue formats a JSON scalar. It reports false for null values skipped by nulls.
+func scalarValue(v any, nulls string) (string, bool) {
+	if v == nil {
+		switch nulls {
+		case "skip":
+			return "", false
+		case "null":
+			return "null", true
+		default:
+			return "", true
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return fmt.Sprint(v), true
+}
+
+// isJSONLiteral reports whether the decoded scalar v, formatted as value, is a
+// number, a boolean or a null emitted as the literal null
+func isJSONLiteral(v any, value string) bool {
+	switch v.(type) {
+	case json.Number, bool:
+		return true
+	case nil:
+		return value == "null"
+	}
+	return false
+}
+
+// EncodeJSON returns the compact JSON encoding of v without HTML escaping
+func EncodeJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,207 @@
+package appsettingsenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFlatten_ArrayModes(t *testing.T) {
+	var in map[string]any
+	decoder := json.NewDecoder(strings.NewReader(`{
+  "Using": ["Serilog.Sinks.Console", "Serilog.Sinks.File"],
+  "Ports": [80, 443],
+  "WriteTo": [{"Name": "Console"}]
+}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&in); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	out := Flatten(in, FlattenOptions{Separator: "__", ArrayMode: "json"})
+	if out["Using"] != `["Serilog.Sinks.Console","Serilog.Sinks.File"]` || out["Ports"] != "[80,443]" || out["WriteTo"] != `[{"Name":"Console"}]` {
+		t.Fatalf("unexpected json mode output: %v", out)
+	}
+
+	out = Flatten(in, FlattenOptions{Separator: "__", ArrayMode: "join", ArrayDelimiter: ";"})
+	if out["Using"] != "Serilog.Sinks.Console;Serilog.Sinks.File" || out["Ports"] != "80;443" {
+		t.Fatalf("unexpected join mode output: %v", out)
+	}
+	if out["WriteTo__0__Name"] != "Console" {
+		t.Fatalf("arrays of objects should stay indexed in join mode: %v", out)
+	}
+}
+
+func TestFlatten_Nulls(t *testing.T) {
+	in := map[string]any{"Optional": nil, "List": []any{"a", nil}}
+	cases := map[string]map[string]string{
+		"empty": {"Optional": "", "List__0": "a", "List__1": ""},
+		"null":  {"Optional": "null", "List__0": "a", "List__1": "null"},
+		"skip":  {"List__0": "a"},
+	}
+
+	for mode, want := range cases {
+		out := Flatten(in, FlattenOptions{Separator: "__", Nulls: mode})
+		if len(out) != len(want) {
+			t.Fatalf("%s: unexpected output %v", mode, out)
+		}
+		for k, v := range want {
+			if got, ok := out[k]; !ok || got != v {
+				t.Fatalf("%s: key %q want %q got %q", mode, k, v, got)
+			}
+		}
+	}
+}
+
+func TestFlatten_PadIndex(t *testing.T) {
+	out := Flatten(map[string]any{"Hosts": []any{"a", map[string]any{"Name": "b"}}}, FlattenOptions{Separator: "__", PadIndex: 2})
+	if out["Hosts__00"] != "a" || out["Hosts__01__Name"] != "b" {
+		t.Fatalf("unexpected padded keys: %v", out)
+	}
+}
+
+func TestFlatten_MaxDepth(t *testing.T) {
+	in := map[string]any{
+		"Serilog": map[string]any{
+			"MinimumLevel": "Information",
+			"WriteTo":      []any{map[string]any{"Name": "Console"}},
+			"Enrich":       map[string]any{"With": []any{"FromLogContext"}},
+		},
+		"Name": "app",
+	}
+	out := Flatten(in, FlattenOptions{Separator: "__", MaxDepth: 2})
+
+	want := map[string]string{
+		"Serilog__MinimumLevel": "Information",
+		"Serilog__WriteTo":      `[{"Name":"Console"}]`,
+		"Serilog__Enrich":       `{"With":["FromLogContext"]}`,
+		"Name":                  "app",
+	}
+	if len(out) != len(want) {
+		t.Fatalf("unexpected output: %v", out)
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Fatalf("key %q want %q got %q", k, v, out[k])
+		}
+	}
+}
+
+func TestFlatten_Literals(t *testing.T) {
+	in := map[string]any{"Port": json.Number("8080"), "Name": "8080", "Hosts": []any{true}, "Optional": nil}
+	literals := map[string]string{}
+	out := Flatten(in, FlattenOptions{Nulls: "null", Literal: func(key, value string) { literals[key] = value }})
+
+	if out["Port"] != "8080" || out["Hosts:0"] != "true" {
+		t.Fatalf("unexpected output: %v", out)
+	}
+	if len(literals) != 3 || literals["Port"] != "8080" || literals["Hosts:0"] != "true" || literals["Optional"] != "null" {
+		t.Fatalf("unexpected literals: %v", literals)
+	}
+}
+
+func TestEmptyContainers(t *testing.T) {
+	in := map[string]any{"Features": map[string]any{}, "Hosts": []any{}, "Tags": []any{}, "Name": "api"}
+
+	got := EmptyContainers(in, FlattenOptions{Separator: "__"})
+	if len(got) != 3 {
+		t.Fatalf("unexpected empty containers: %v", got)
+	}
+
+	// Arrays written as a single value are never empty keys
+	got = EmptyContainers(in, FlattenOptions{ArrayMode: "json"})
+	if len(got) != 1 || got[0] != (Empty{Key: "Features", Kind: "object"}) {
+		t.Fatalf("unexpected empty containers: %v", got)
+	}
+}
+
+// FuzzFlatten checks that every flattened key leads back to its value in the document,
+// and that no leaf is lost, whatever the shape of the document
+func FuzzFlatten(f *testing.F) {
+	f.Add([]byte(`{"a": {"b": [1, {"c": null}], "d": "e"}, "f": [[true], []], "g": {}}`))
+	f.Add([]byte(`{"Logging": {"LogLevel": {"Default": "Information", "Microsoft": "Warning", "System": "Error"}}}`))
+	f.Add([]byte(`{"a": [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11], "b": [{"x": 1}, {"x": 2}, {"x": 3}]}`))
+
+	const sep = "\x00"
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := Parse(data)
+		if err != nil || strings.Contains(EncodeJSON(doc), `\u0000`) {
+			t.Skip()
+		}
+
+		out := Flatten(doc, FlattenOptions{Separator: sep})
+		if leaves := countLeaves(doc); leaves != len(out) {
+			t.Fatalf("%d leaves but %d keys: %v", leaves, len(out), out)
+		}
+		for key, value := range out {
+			var node any = doc
+			for _, segment := range strings.Split(key, sep) {
+				switch n := node.(type) {
+				case map[string]any:
+					node = n[segment]
+				case []any:
+					idx, err := strconv.Atoi(segment)
+					if err != nil || idx >= len(n) {
+						t.Fatalf("key %q: bad index %q", key, segment)
+					}
+					node = n[idx]
+				default:
+					t.Fatalf("key %q goes past a scalar", key)
+				}
+			}
+			if want, _ := scalarValue(node, ""); want != value {
+				t.Fatalf("key %q: want %q got %q", key, want, value)
+			}
+		}
+	})
+}
+
+// countLeaves counts the scalars of a decoded document
+func countLeaves(value any) int {
+	switch v := value.(type) {
+	case map[string]any:
+		n := 0
+		for _, item := range v {
+			n += countLeaves(item)
+		}
+		return n
+	case []any:
+		n := 0
+		for _, item := range v {
+			n += countLeaves(item)
+		}
+		return n
+	}
+	return 1
+}
+
+func BenchmarkFlatten(b *testing.B) {
+	// A large file: wide sections with nested objects and arrays
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i := range 200 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `"Section%d": {"Name": "section %d", "Enabled": true, "Limits": {"Max": %d, "Min": 0}, "Hosts": [`, i, i, i)
+		for j := range 20 {
+			if j > 0 {
+				sb.WriteString(",")
+			}
+			fmt.Fprintf(&sb, `{"Host": "host%d.example.com", "Port": %d, "Tags": ["a", "b", "c"]}`, j, 8000+j)
+		}
+		sb.WriteString("]}")
+	}
+	sb.WriteString("}")
+	doc, err := Parse([]byte(sb.String()))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		Flatten(doc, FlattenOptions{Separator: "__"})
+	}
+}
@@ -0,0 +1,448 @@
+package appsettingsenv
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// KV is a single output entry
+type KV struct {
+	Name  string
+	Value string
+	// Secret marks entries written to the secret output by FormatSplit
+	Secret bool
+	// Literal marks values written unquoted by typed output types
+	Literal bool
+}
+
+// FormatOptions control how entries are written. The zero value writes k8s
+// output with the command's defaults.
+type FormatOptions struct {
+	// Type is the output type (default k8s), see Types
+	Type string
+	// Name names the generated Kubernetes resources (default appsettings)
+	Name string
+	// SecretName names the generated Secret (default <Name>-secrets)
+	SecretName string
+	// Indent is the indentation width in spaces of YAML, JSON and Bicep output
+	// (0: 2 spaces, none for bicep)
+	Indent int
+	// Quote is the quoting of k8s, configmap, compose and docker output (default
+	// always), see QuoteStyles
+	Quote string
+	// SplitSize, when set, shards configmap output across ConfigMaps named <Name>-0,
+	// <Name>-1, ... holding at most SplitSize bytes of data each (see DataSize),
+	// followed by a comment listing them as container envFrom sources
+	SplitSize int
+	// Checksum annotates ConfigMap and Secret manifests with the ChecksumAnnotation
+	// of their data
+	Checksum bool
+}
+
+func (o *FormatOptions) outputType() string {
+	if o.Type == "" {
+		return "k8s"
+	}
+	return o.Type
+}
+
+func (o *FormatOptions) name() string {
+	if o.Name == "" {
+		return "appsettings"
+	}
+	return o.Name
+}
+
+func (o *FormatOptions) secretName() string {
+	if o.SecretName != "" {
+		return o.SecretName
+	}
+	return o.name() + "-secrets"
+}
+
+func (o *FormatOptions) quote() string {
+	if o.Quote == "" {
+		return "always"
+	}
+	return o.Quote
+}
+
+// indentation returns the Indent unit, or def spaces when Indent is not set
+func (o *FormatOptions) indentation(def int) string {
+	if o.Indent > 0 {
+		return strings.Repeat(" ", o.Indent)
+	}
+	return strings.Repeat(" ", def)
+}
+
+// TypeInfo describes an output type
+type TypeInfo struct {
+	// Separator is the key separator the type uses by default
+	Separator string
+	// Typed types can write numbers, booleans and nulls as native literals
+	Typed bool
+	// Comment starts a line comment; empty for types without comments
+	Comment string
+}
+
+// writeFunc renders entries in one output type
+type writeFunc func(w io.Writer, vars []KV, o *FormatOptions) error
+
+// builtin is an output type of the command
+type builtin struct {
+	name  string
+	info  TypeInfo
+	write writeFunc
+	// writeSecrets renders the secret part of a split output; nil uses write
+	writeSecrets writeFunc
+	// keepSecrets keeps the secret entries in the main part of a split output,
+	// which references them
+	keepSecrets bool
+	opts        FormatOptions
+}
+
+var builtins = []*builtin{
+	{name: "k8s", info: TypeInfo{Separator: "__", Comment: "#"}, write: writeK8s, writeSecrets: writeSecretManifest, keepSecrets: true},
+	{name: "configmap", info: TypeInfo{Separator: "__", Comment: "#"}, write: writeConfigMap, writeSecrets: writeSecretManifest},
+	{name: "docker", info: TypeInfo{Separator: "__", Comment: "#"}, write: lineFormat("%s=%s\n", dotenvSyntax)},
+	{name: "compose", info: TypeInfo{Separator: "__", Comment: "#"}, write: lineFormat("%s: %s\n", yamlSyntax)},
+	{name: "bicep", info: TypeInfo{Separator: "__", Comment: "//"}, write: writeBicep},
+	{name: "appconfig", info: TypeInfo{Separator: ":"}, write: writeAppConfig},
+	{name: "user-secrets", info: TypeInfo{Separator: ":", Typed: true}, write: writeUserSecrets},
+	{name: "launchsettings", info: TypeInfo{Separator: ":"}, write: writeLaunchSettings},
+}
+
+func (b *builtin) Name() string       { return b.name }
+func (b *builtin) Describe() TypeInfo { return b.info }
+
+func (b *builtin) WithOptions(opts FormatOptions) Formatter {
+	c := *b
+	c.opts = opts
+	return &c
+}
+
+func (b *builtin) Write(w io.Writer, vars []KV) error {
+	return b.write(w, vars, &b.opts)
+}
+
+func (b *builtin) WriteSplit(w, secretW io.Writer, vars []KV) error {
+	regular, secret := splitSecrets(vars)
+	if b.keepSecrets {
+		regular = vars
+	}
+	if err := b.write(w, regular, &b.opts); err != nil {
+		return err
+	}
+
+	writeSecrets := b.writeSecrets
+	if writeSecrets == nil {
+		writeSecrets = b.write
+	}
+	return writeSecrets(secretW, secret, &b.opts)
+}
+
+// Format writes vars to w in the output type of opts, in the given order. The
+// names are written as they are: apply the separator of the type beforehand.
+// Unbuffered writers, such as os.Stdout, are written through a buffer.
+func Format(w io.Writer, vars []KV, opts FormatOptions) error {
+	f, err := lookup(&opts)
+	if err != nil {
+		return err
+	}
+	bw, flush := buffered(w)
+	return flush(f.Write(bw, vars))
+}
+
+// FormatSplit writes the regular entries of vars to w and the secret ones to
+// secretW, in the secret flavor of the output type: a Secret manifest for k8s and
+// configmap. The k8s env list keeps every entry, referencing the Secret for the
+// secret ones. Formatters without a SplitWriter write each part with Write.
+func FormatSplit(w, secretW io.Writer, vars []KV, opts FormatOptions) error {
+	f, err := lookup(&opts)
+	if err != nil {
+		return err
+	}
+	w, flush := buffered(w)
+	secretW, flushSecrets := buffered(secretW)
+	return flush(flushSecrets(formatSplit(f, w, secretW, vars)))
+}
+
+func formatSplit(f Formatter, w, secretW io.Writer, vars []KV) error {
+	if s, ok := f.(SplitWriter); ok {
+		return s.WriteSplit(w, secretW, vars)
+	}
+
+	regular, secret := splitSecrets(vars)
+	if err := f.Write(w, regular); err != nil {
+		return err
+	}
+	return f.Write(secretW, secret)
+}
+
+// outputBuffer is the size of the buffer of the writers formatters write to
+const outputBuffer = 64 << 10
+
+// buffered returns w through a buffer unless it is in memory or buffered already,
+// so that formatters writing an entry at a time make a write per outputBuffer
+// bytes. flush writes out the buffer and returns err, or the error of the write.
+func buffered(w io.Writer) (io.Writer, func(err error) error) {
+	switch w.(type) {
+	case *bytes.Buffer, *strings.Builder, *bufio.Writer:
+		return w, func(err error) error { return err }
+	}
+	bw := bufio.NewWriterSize(w, outputBuffer)
+	return bw, func(err error) error {
+		if ferr := bw.Flush(); err == nil {
+			err = ferr
+		}
+		return err
+	}
+}
+
+// splitSecrets partitions vars into regular and secret entries
+func splitSecrets(vars []KV) (regular, secret []KV) {
+	for _, v := range vars {
+		if v.Secret {
+			secret = append(secret, v)
+		} else {
+			regular = append(regular, v)
+		}
+	}
+	return regular, secret
+}
+
+// lineFormat writes one printf-formatted entry (name, value) per variable, the value
+// quoted for syntax. Multi-line YAML values are written as block scalars.
+func lineFormat(format string, syntax quoteSyntax) writeFunc {
+	return func(w io.Writer, vars []KV, o *FormatOptions) error {
+		for _, v := range vars {
+			value := quoteValue(v.Value, syntax, o.quote())
+			if syntax == yamlSyntax {
+				value = yamlValue(v.Value, o.quote(), "", o.indentation(2))
+			}
+			if _, err := fmt.Fprintf(w, format, v.Name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// writeBicep writes one name/value object per variable. App Service appSettings and
+// container env values are strings, so literals are quoted as well.
+func writeBicep(w io.Writer, vars []KV, o *FormatOptions) error {
+	in := o.indentation(0)
+	for _, v := range vars {
+		if _, err := fmt.Fprintf(w, "{\n%sname: %s\n%svalue: %s\n}\n", in, bicepString(v.Name), in, bicepString(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeK8s writes a container env list. Secret variables reference the generated Secret.
+func writeK8s(w io.Writer, vars []KV, o *FormatOptions) error {
+	// Keys of a list item align after the dash, which takes at least "- "
+	in := o.indentation(2)
+	item := "-" + strings.Repeat(" ", max(len(in)-1, 1))
+	cont := strings.Repeat(" ", len(item))
+	style := o.quote()
+	for _, v := range vars {
+		var err error
+		if v.Secret {
+			name := quoteName(v.Name, yamlSyntax, style)
+			_, err = fmt.Fprintf(w, "%sname: %s\n%svalueFrom:\n%s%ssecretKeyRef:\n%s%s%sname: %s\n%s%s%skey: %s\n",
+				item, name, cont, cont, in, cont, in, in, quoteName(o.secretName(), yamlSyntax, style), cont, in, in, name)
+		} else {
+			_, err = fmt.Fprintf(w, "%sname: %s\n%svalue: %s\n", item, quoteName(v.Name, yamlSyntax, style), cont, yamlValue(v.Value, style, cont, in))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeConfigMap writes a ConfigMap manifest named after Name, or with SplitSize the
+// shards of the data and the envFrom list referencing them
+func writeConfigMap(w io.Writer, vars []KV, o *FormatOptions) error {
+	if o.SplitSize <= 0 {
+		return writeManifest(w, "ConfigMap", o.name(), "", "data", vars, o)
+	}
+
+	in := o.indentation(2)
+	envFrom := "# envFrom:\n"
+	for i, shard := range splitBySize(vars, o.SplitSize) {
+		name := o.name() + "-" + strconv.Itoa(i)
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if err := writeManifest(w, "ConfigMap", name, "", "data", shard, o); err != nil {
+			return err
+		}
+		envFrom += fmt.Sprintf("# - configMapRef:\n#   %sname: %s\n", in, quoteName(name, yamlSyntax, o.quote()))
+	}
+	_, err := io.WriteString(w, envFrom)
+	return err
+}
+
+// DataSize returns the size Kubernetes counts against the 1 MiB limit of a ConfigMap
+// or Secret holding vars: the bytes of their names and values
+func DataSize(vars []KV) int {
+	size := 0
+	for _, v := range vars {
+		size += len(v.Name) + len(v.Value)
+	}
+	return size
+}
+
+// splitBySize groups vars, in order, into shards of at most size bytes of data. An
+// entry larger than size gets a shard of its own.
+func splitBySize(vars []KV, size int) [][]KV {
+	shards := [][]KV{nil}
+	used := 0
+	for _, v := range vars {
+		n := DataSize([]KV{v})
+		if used > 0 && used+n > size {
+			shards = append(shards, nil)
+			used = 0
+		}
+		shards[len(shards)-1] = append(shards[len(shards)-1], v)
+		used += n
+	}
+	return shards
+}
+
+// writeSecretManifest writes a Secret manifest holding the secret variables
+func writeSecretManifest(w io.Writer, vars []KV, o *FormatOptions) error {
+	return writeManifest(w, "Secret", o.secretName(), "Opaque", "stringData", vars, o)
+}
+
+// ChecksumAnnotation is the annotation holding the Checksum of a configuration, which
+// restarts the pods of a workload when set on its pod template and changed
+const ChecksumAnnotation = "checksum/config"
+
+// Checksum returns the hex SHA-256 of vars in name order, each name and value being
+// followed by a NUL byte. It does not depend on the order or format of the output.
+func Checksum(vars []KV) string {
+	sorted := slices.Clone(vars)
+	slices.SortFunc(sorted, func(a, b KV) int { return strings.Compare(a.Name, b.Name) })
+	h := sha256.New()
+	for _, v := range sorted {
+		io.WriteString(h, v.Name+"\x00"+v.Value+"\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeManifest(w io.Writer, kind, name, typ, field string, vars []KV, o *FormatOptions) error {
+	in := o.indentation(2)
+	style := o.quote()
+	var b strings.Builder
+	fmt.Fprintf(&b, "apiVersion: v1\nkind: %s\nmetadata:\n%sname: %s\n", kind, in, quoteName(name, yamlSyntax, style))
+	if o.Checksum {
+		// Quoted, as hex digits alone may read as a number
+		fmt.Fprintf(&b, "%sannotations:\n%s%s%s: %q\n", in, in, in, ChecksumAnnotation, Checksum(vars))
+	}
+	if typ != "" {
+		fmt.Fprintf(&b, "type: %s\n", typ)
+	}
+	if len(vars) == 0 {
+		fmt.Fprintf(&b, "%s: {}\n", field)
+	} else {
+		fmt.Fprintf(&b, "%s:\n", field)
+	}
+	for _, v := range vars {
+		fmt.Fprintf(&b, "%s%s: %s\n", in, quoteName(v.Name, yamlSyntax, style), yamlValue(v.Value, style, in, in))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeUserSecrets writes a flat secrets.json as used by `dotnet user-secrets`
+func writeUserSecrets(w io.Writer, vars []KV, o *FormatOptions) error {
+	return writeJSONObject(w, vars, "", o)
+}
+
+// writeLaunchSettings writes the environmentVariables object of a launchSettings.json profile
+func writeLaunchSettings(w io.Writer, vars []KV, o *FormatOptions) error {
+	in := o.indentation(2)
+	if _, err := io.WriteString(w, "{\n"+in+"\"environmentVariables\": "); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := writeJSONObject(&buf, vars, in, o); err != nil {
+		return err
+	}
+	if _, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n}\n")
+	return err
+}
+
+// App Configuration stores feature flags as key-values under FeatureFlagPrefix, with
+// the FeatureFlagContentType content type and a JSON value
+const (
+	FeatureFlagPrefix      = ".appconfig.featureflag/"
+	FeatureFlagContentType = "application/vnd.microsoft.appconfig.ff+json;charset=utf-8"
+)
+
+// writeAppConfig writes the key-value set format accepted by
+// `az appconfig kv import --format json --profile appconfig/kvset`. Entries named
+// after FeatureFlagPrefix are written as feature flags.
+func writeAppConfig(w io.Writer, vars []KV, o *FormatOptions) error {
+	type item struct {
+		Key         string            `json:"key"`
+		Value       string            `json:"value"`
+		Label       *string           `json:"label"`
+		ContentType *string           `json:"content_type"`
+		Tags        map[string]string `json:"tags"`
+	}
+
+	items := make([]item, 0, len(vars))
+	for _, v := range vars {
+		it := item{Key: v.Name, Value: v.Value, Tags: map[string]string{}}
+		if strings.HasPrefix(v.Name, FeatureFlagPrefix) {
+			contentType := FeatureFlagContentType
+			it.ContentType = &contentType
+		}
+		items = append(items, it)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", o.indentation(2))
+	return enc.Encode(map[string]any{"items": items})
+}
+
+// writeJSONObject writes vars as a JSON object keeping their order, every line
+// prefixed with prefix
+func writeJSONObject(w io.Writer, vars []KV, prefix string, o *FormatOptions) error {
+	in := o.indentation(2)
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, v := range vars {
+		value := EncodeJSON(v.Value)
+		if v.Literal {
+			value = v.Value
+		}
+		b.WriteString(prefix + in + EncodeJSON(v.Name) + ": " + value)
+		if i < len(vars)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(prefix + "}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,303 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestJSONFormats(t *testing.T) {
+	vars := map[string]string{"Logging:Level": "Debug", "Url": "http://a?b=1&c=<2>"}
+	list := Sorted(vars)
+
+	var buf bytes.Buffer
+	if err := writeUserSecrets(&buf, list, &FormatOptions{}); err != nil {
+		t.Fatalf("writeUserSecrets failed: %v", err)
+	}
+	want := "{\n  \"Logging:Level\": \"Debug\",\n  \"Url\": \"http://a?b=1&c=<2>\"\n}\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected user-secrets output:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeLaunchSettings(&buf, list, &FormatOptions{}); err != nil {
+		t.Fatalf("writeLaunchSettings failed: %v", err)
+	}
+	var launch struct {
+		EnvironmentVariables map[string]string `json:"environmentVariables"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &launch); err != nil {
+		t.Fatalf("launchsettings output is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if launch.EnvironmentVariables["Logging:Level"] != "Debug" {
+		t.Fatalf("unexpected launchsettings output: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeAppConfig(&buf, list, &FormatOptions{}); err != nil {
+		t.Fatalf("writeAppConfig failed: %v", err)
+	}
+	var kvset struct {
+		Items []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &kvset); err != nil {
+		t.Fatalf("appconfig output is not valid JSON: %v", err)
+	}
+	if len(kvset.Items) != 2 || kvset.Items[0].Key != "Logging:Level" {
+		t.Fatalf("unexpected appconfig output: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeAppConfig(&buf, []KV{{Name: FeatureFlagPrefix + "Beta", Value: `{"id":"Beta","enabled":true}`}}, &FormatOptions{}); err != nil {
+		t.Fatalf("writeAppConfig failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"content_type": "`+FeatureFlagContentType+`"`) {
+		t.Fatalf("feature flags should have their content type: %s", buf.String())
+	}
+}
+
+func TestTypedFormats(t *testing.T) {
+	list := []KV{
+		{Name: "Enabled", Value: "true", Literal: true},
+		{Name: "Port", Value: "8080", Literal: true},
+		{Name: "Zip", Value: "01234"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBicep(&buf, list, &FormatOptions{}); err != nil {
+		t.Fatalf("writeBicep failed: %v", err)
+	}
+	want := "{\nname: 'Enabled'\nvalue: 'true'\n}\n{\nname: 'Port'\nvalue: '8080'\n}\n{\nname: 'Zip'\nvalue: '01234'\n}\n"
+	if buf.String() != want {
+		t.Fatalf("bicep values should stay strings:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeUserSecrets(&buf, list, &FormatOptions{}); err != nil {
+		t.Fatalf("writeUserSecrets failed: %v", err)
+	}
+	want = "{\n  \"Enabled\": true,\n  \"Port\": 8080,\n  \"Zip\": \"01234\"\n}\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected user-secrets output:\n%s", buf.String())
+	}
+}
+
+func TestIndent(t *testing.T) {
+	vars := []KV{{Name: "A", Value: "1"}, {Name: "B", Value: "2", Secret: true}}
+	cases := map[string]string{
+		"k8s": "-   name: \"A\"\n    value: \"1\"\n" +
+			"-   name: \"B\"\n    valueFrom:\n        secretKeyRef:\n            name: \"appsettings-secrets\"\n            key: \"B\"\n",
+		"bicep":        "{\n    name: 'A'\n    value: '1'\n}\n{\n    name: 'B'\n    value: '2'\n}\n",
+		"user-secrets": "{\n    \"A\": \"1\",\n    \"B\": \"2\"\n}\n",
+	}
+	for outType, want := range cases {
+		var buf bytes.Buffer
+		if err := Format(&buf, vars, FormatOptions{Type: outType, Indent: 4}); err != nil {
+			t.Fatalf("%s: write failed: %v", outType, err)
+		}
+		if buf.String() != want {
+			t.Fatalf("%s: unexpected output:\n%s", outType, buf.String())
+		}
+	}
+}
+
+func TestFormatSplit_K8s(t *testing.T) {
+	vars := []KV{
+		{Name: "Api__Url", Value: "https://api"},
+		{Name: "Db__Password", Value: "s3cret", Secret: true},
+	}
+
+	var buf, secret bytes.Buffer
+	if err := FormatSplit(&buf, &secret, vars, FormatOptions{Type: "k8s"}); err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	wantEnv := `- name: "Api__Url"
+  value: "https://api"
+- name: "Db__Password"
+  valueFrom:
+    secretKeyRef:
+      name: "appsettings-secrets"
+      key: "Db__Password"
+`
+	if buf.String() != wantEnv {
+		t.Fatalf("unexpected env output:\n%s", buf.String())
+	}
+
+	wantSecret := `apiVersion: v1
+kind: Secret
+metadata:
+  name: "appsettings-secrets"
+type: Opaque
+stringData:
+  "Db__Password": "s3cret"
+`
+	if secret.String() != wantSecret {
+		t.Fatalf("unexpected secret output:\n%s", secret.String())
+	}
+}
+
+func TestFormatSplit_Docker(t *testing.T) {
+	vars := []KV{
+		{Name: "Api__Url", Value: "https://api"},
+		{Name: "Db__Password", Value: "s3cret", Secret: true},
+	}
+
+	var buf, secret bytes.Buffer
+	if err := FormatSplit(&buf, &secret, vars, FormatOptions{Type: "docker"}); err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+
+	if buf.String() != "Api__Url=\"https://api\"\n" || secret.String() != "Db__Password=\"s3cret\"\n" {
+		t.Fatalf("unexpected split: %q / %q", buf.String(), secret.String())
+	}
+}
+
+func TestMultilineValues(t *testing.T) {
+	values := []string{
+		"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----",
+		"{\n  \"a\": 1\n}\n",
+		"  indented\n\tline\n\n",
+		"crlf\r\nline",
+	}
+	var vars []KV
+	for i, v := range values {
+		vars = append(vars, KV{Name: "V" + Index(i, 0), Value: v})
+	}
+
+	var compose bytes.Buffer
+	if err := Format(&compose, vars, FormatOptions{Type: "compose", Quote: "as-needed"}); err != nil {
+		t.Fatalf("compose: %v", err)
+	}
+	if !strings.HasPrefix(compose.String(), "V0: |-\n  -----BEGIN CERTIFICATE-----\n  MIIB\n  -----END CERTIFICATE-----\nV1: |\n  {\n") ||
+		!strings.Contains(compose.String(), "V2: |2+\n    indented\n  \tline\n\nV3: \"crlf\\r\\nline\"\n") {
+		t.Fatalf("unexpected compose output:\n%s", compose.String())
+	}
+
+	for _, outType := range []string{"compose", "k8s", "configmap"} {
+		for _, indent := range []int{0, 4} {
+			var buf bytes.Buffer
+			if err := Format(&buf, vars, FormatOptions{Type: outType, Indent: indent}); err != nil {
+				t.Fatalf("%s: %v", outType, err)
+			}
+
+			var doc any
+			if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatalf("%s: invalid YAML: %v\n%s", outType, err, buf.String())
+			}
+			got := make(map[string]string)
+			switch doc := doc.(type) {
+			case []any:
+				for _, item := range doc {
+					m := item.(map[string]any)
+					got[m["name"].(string)] = m["value"].(string)
+				}
+			case map[string]any:
+				if data, ok := doc["data"].(map[string]any); ok {
+					doc = data
+				}
+				for k, v := range doc {
+					got[k] = v.(string)
+				}
+			}
+			for _, v := range vars {
+				if got[v.Name] != v.Value {
+					t.Fatalf("%s: %s reads back as %q, want %q\n%s", outType, v.Name, got[v.Name], v.Value, buf.String())
+				}
+			}
+		}
+	}
+}
+
+func TestConfigMapSplit(t *testing.T) {
+	vars := []KV{{Name: "A", Value: "1234"}, {Name: "B", Value: "5678"}, {Name: "C", Value: "9"}}
+	var buf bytes.Buffer
+	if err := Format(&buf, vars, FormatOptions{Type: "configmap", Name: "api", SplitSize: 9}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: \"api-0\"\ndata:\n  \"A\": \"1234\"\n" +
+		"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: \"api-1\"\ndata:\n  \"B\": \"5678\"\n  \"C\": \"9\"\n" +
+		"# envFrom:\n# - configMapRef:\n#     name: \"api-0\"\n# - configMapRef:\n#     name: \"api-1\"\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	if DataSize(vars) != 12 {
+		t.Fatalf("unexpected data size: %d", DataSize(vars))
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	vars := []KV{{Name: "B", Value: "2"}, {Name: "A", Value: "1"}}
+	sum := Checksum(vars)
+	if sum != Checksum([]KV{vars[1], vars[0]}) || len(sum) != 64 {
+		t.Fatalf("checksum should be a SHA-256 independent of the order: %s", sum)
+	}
+	if sum == Checksum([]KV{{Name: "A", Value: "12"}, {Name: "B", Value: ""}}) {
+		t.Fatalf("entries moving bytes between them should change the checksum")
+	}
+
+	var buf bytes.Buffer
+	if err := Format(&buf, vars, FormatOptions{Type: "configmap", Name: "api", Checksum: true}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	want := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: \"api\"\n  annotations:\n    checksum/config: \"" + sum + "\"\ndata:\n"
+	if !strings.HasPrefix(buf.String(), want) {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+// countingWriter counts the writes it receives, failing them with err when set
+type countingWriter struct {
+	buf    bytes.Buffer
+	writes int
+	err    error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+func TestFormat_Buffered(t *testing.T) {
+	vars := make([]KV, 10000)
+	for i := range vars {
+		vars[i] = KV{Name: "Key" + Index(i, 0), Value: "value", Secret: i%2 == 0}
+	}
+
+	var want bytes.Buffer
+	if err := Format(&want, vars, FormatOptions{Type: "docker"}); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	var w countingWriter
+	if err := Format(&w, vars, FormatOptions{Type: "docker"}); err != nil {
+		t.Fatalf("Format failed: %v", err)
+	}
+	if w.buf.String() != want.String() || w.writes > want.Len()/outputBuffer+1 {
+		t.Fatalf("output not buffered: %d writes for %d bytes", w.writes, w.buf.Len())
+	}
+
+	var regular, secret countingWriter
+	if err := FormatSplit(&regular, &secret, vars, FormatOptions{Type: "k8s"}); err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+	if regular.writes > regular.buf.Len()/outputBuffer+1 || secret.writes > secret.buf.Len()/outputBuffer+1 ||
+		!strings.Contains(secret.buf.String(), "kind: Secret") {
+		t.Fatalf("split output not buffered: %d and %d writes", regular.writes, secret.writes)
+	}
+
+	// Errors of the final write are returned
+	failing := countingWriter{err: io.ErrShortWrite}
+	if err := Format(&failing, vars[:1], FormatOptions{Type: "docker"}); err != io.ErrShortWrite {
+		t.Fatalf("expected the write error, got %v", err)
+	}
+}
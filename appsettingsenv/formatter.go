@@ -0,0 +1,117 @@
+package appsettingsenv
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// Formatter writes entries in one output type
+type Formatter interface {
+	// Name is the output type, as given to FormatOptions.Type
+	Name() string
+	// Write writes vars in the given order
+	Write(w io.Writer, vars []KV) error
+}
+
+// Configurable is implemented by formatters that honor FormatOptions. Format
+// calls WithOptions before writing.
+type Configurable interface {
+	WithOptions(opts FormatOptions) Formatter
+}
+
+// Describer is implemented by formatters that describe their output type.
+// Formatters without it use the __ separator, no typed values and no comments.
+type Describer interface {
+	Describe() TypeInfo
+}
+
+// SplitWriter is implemented by formatters with a dedicated layout for split
+// output, such as a Secret manifest for the secret entries
+type SplitWriter interface {
+	WriteSplit(w, secretW io.Writer, vars []KV) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Formatter)
+)
+
+func init() {
+	for _, b := range builtins {
+		RegisterFormatter(b)
+	}
+}
+
+// RegisterFormatter makes an output type available to Format, FormatSplit and
+// Convert under its name. As other registries of the standard library, it is meant
+// to be called from init functions and panics when f is nil or its name is empty
+// or already registered.
+func RegisterFormatter(f Formatter) {
+	if f == nil {
+		panic("appsettingsenv: RegisterFormatter formatter is nil")
+	}
+	name := f.Name()
+	if name == "" {
+		panic("appsettingsenv: RegisterFormatter formatter has no name")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("appsettingsenv: RegisterFormatter called twice for " + name)
+	}
+	registry[name] = f
+}
+
+// LookupFormatter returns the registered formatter of an output type
+func LookupFormatter(name string) (Formatter, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+// Types returns the names of the registered output types, sorted
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Lookup returns the description of an output type
+func Lookup(typ string) (TypeInfo, bool) {
+	f, ok := LookupFormatter(typ)
+	if !ok {
+		return TypeInfo{}, false
+	}
+	return describe(f), true
+}
+
+func describe(f Formatter) TypeInfo {
+	if d, ok := f.(Describer); ok {
+		return d.Describe()
+	}
+	return TypeInfo{Separator: "__"}
+}
+
+// lookup returns the formatter of the output type of o, configured with o
+func lookup(o *FormatOptions) (Formatter, error) {
+	f, ok := LookupFormatter(o.outputType())
+	if !ok {
+		return nil, fmt.Errorf("invalid output type: %q", o.Type)
+	}
+	if !slices.Contains(QuoteStyles, o.quote()) {
+		return nil, fmt.Errorf("invalid quote style: %q", o.Quote)
+	}
+	if c, ok := f.(Configurable); ok {
+		f = c.WithOptions(*o)
+	}
+	return f, nil
+}
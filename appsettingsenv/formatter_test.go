@@ -0,0 +1,78 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// propertiesFormatter writes Java-style .properties files
+type propertiesFormatter struct{}
+
+func (propertiesFormatter) Name() string { return "test-properties" }
+
+func (propertiesFormatter) Write(w io.Writer, vars []KV) error {
+	for _, v := range vars {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", v.Name, v.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (propertiesFormatter) Describe() TypeInfo { return TypeInfo{Separator: ".", Comment: "#"} }
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter(propertiesFormatter{})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "test-properties")
+		registryMu.Unlock()
+	}()
+
+	if !slices.Contains(Types(), "test-properties") {
+		t.Fatalf("registered type not listed: %v", Types())
+	}
+	if info, ok := Lookup("test-properties"); !ok || info.Separator != "." {
+		t.Fatalf("unexpected type info: %+v %v", info, ok)
+	}
+
+	var buf bytes.Buffer
+	err := Convert(strings.NewReader(`{"Logging": {"Level": "Debug"}}`), &buf, Options{Format: FormatOptions{Type: "test-properties"}})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if buf.String() != "Logging.Level=Debug\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// Without a SplitWriter each part is written with Write
+	var regular, secret bytes.Buffer
+	vars := []KV{{Name: "A", Value: "1"}, {Name: "B", Value: "2", Secret: true}}
+	if err := FormatSplit(&regular, &secret, vars, FormatOptions{Type: "test-properties"}); err != nil {
+		t.Fatalf("FormatSplit failed: %v", err)
+	}
+	if regular.String() != "A=1\n" || secret.String() != "B=2\n" {
+		t.Fatalf("unexpected split: %q / %q", regular.String(), secret.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("registering a type twice should panic")
+		}
+	}()
+	RegisterFormatter(propertiesFormatter{})
+}
+
+func TestBuiltinsRegistered(t *testing.T) {
+	want := []string{"appconfig", "bicep", "compose", "configmap", "docker", "k8s", "launchsettings", "user-secrets"}
+	for _, name := range want {
+		f, ok := LookupFormatter(name)
+		if !ok || f.Name() != name {
+			t.Fatalf("builtin %s not registered", name)
+		}
+	}
+}
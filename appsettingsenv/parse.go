@@ -0,0 +1,69 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SyntaxError describes invalid JSON, with the position of the problem
+type SyntaxError struct {
+	Line, Column int
+	// Snippet is the content around the problem
+	Snippet string
+	Err     error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error: %v (line %d, column %d) ... %s", e.Err, e.Line, e.Column, e.Snippet)
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// Parse decodes an appsettings.json document. As the .NET JSON configuration
+// provider, it accepts a byte order mark, // and /* */ comments and trailing commas.
+// Numbers are decoded as json.Number to keep their text.
+func Parse(data []byte) (map[string]any, error) {
+	return ParseStream(bytes.NewReader(data), StreamOptions{})
+}
+
+// ParseStrict decodes an appsettings.json document that must be RFC 8259 JSON:
+// comments, a byte order mark, invalid UTF-8 and content after the document are
+// syntax errors.
+func ParseStrict(data []byte) (map[string]any, error) {
+	return ParseStream(bytes.NewReader(data), StreamOptions{Strict: true})
+}
+
+// utf8BOM is the byte order mark some editors write at the start of JSON files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// ParseReader parses the appsettings.json document read from r, see ParseStream
+func ParseReader(r io.Reader) (map[string]any, error) {
+	return ParseStream(r, StreamOptions{})
+}
+
+// Clean turns the content of an appsettings.json document into RFC 8259 JSON: it
+// blanks the byte order mark, the comments and the trailing commas closing objects
+// and arrays with spaces. Line breaks and byte offsets are kept, so that positions
+// found in the result are those of the original file. Unterminated strings and
+// comments are left for the decoder to report.
+func Clean(content []byte) []byte {
+	return clean(content, true)
+}
+
+// StripComments blanks the byte order mark and the single-line (//) and multi-line
+// (/* */) comments of JSON content with spaces. Line breaks and byte offsets are
+// kept, so that positions match the original content.
+func StripComments(content []byte) []byte {
+	return clean(content, false)
+}
+
+// clean passes content through the sourceReader of the parser, which blanks the
+// byte order mark, the comments and, when commas is set, the trailing commas
+func clean(content []byte, commas bool) []byte {
+	s := newSourceReader(bytes.NewReader(content), true)
+	s.keepCommas = !commas
+	// Syntax errors are found after the content is read, which is all returned
+	out, _ := io.ReadAll(s)
+	return out
+}
@@ -0,0 +1,170 @@
+package appsettingsenv
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	src := []byte(`{
+  // line comment
+  "a": "value", /* block comment */
+  "b": 123
+}`)
+
+	cleaned := StripComments(src)
+
+	var out map[string]any
+	if err := json.Unmarshal(cleaned, &out); err != nil {
+		t.Fatalf("cleaned JSON should unmarshal: %v\ncleaned: %s", err, string(cleaned))
+	}
+
+	if out["a"] != "value" {
+		t.Fatalf("expected a=value, got %v", out["a"])
+	}
+}
+
+func TestStripComments_CommentLikeInString(t *testing.T) {
+	src := []byte(`{"text":"contains // and /* not a comment */ and \\\"quotes\\\""}`)
+	cleaned := StripComments(src)
+	var out map[string]any
+	if err := json.Unmarshal(cleaned, &out); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	s, _ := out["text"].(string)
+	if !strings.Contains(s, "//") || !strings.Contains(s, "/*") {
+		t.Fatalf("string lost comment-like sequences: %q", s)
+	}
+	if !strings.Contains(s, "quotes") || !strings.Contains(s, `"`) {
+		t.Fatalf("escaped quotes missing or lost: %q", s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	src := append([]byte{0xEF, 0xBB, 0xBF}, []byte(`{
+  // comment
+  "Port": 8080,
+  "Name": "api"
+}`)...)
+
+	doc, err := Parse(src)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if doc["Port"] != json.Number("8080") || doc["Name"] != "api" {
+		t.Fatalf("unexpected document: %v", doc)
+	}
+}
+
+func TestParse_SyntaxError(t *testing.T) {
+	_, err := Parse([]byte("{\n  \"a\": \"b\",\n  \"c\": [1,2,\n}"))
+
+	var synErr *SyntaxError
+	if !errors.As(err, &synErr) {
+		t.Fatalf("expected a SyntaxError, got %v", err)
+	}
+	if synErr.Line != 4 {
+		t.Fatalf("unexpected line: %d", synErr.Line)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	if doc, err := ParseStrict([]byte("{\"Port\": 8080}\n")); err != nil || doc["Port"] != json.Number("8080") {
+		t.Fatalf("valid JSON should parse: %v %v", doc, err)
+	}
+
+	cases := map[string]struct {
+		src  string
+		line int
+	}{
+		"bom":      {"\xEF\xBB\xBF{}", 1},
+		"comment":  {"{\n  // comment\n  \"a\": 1\n}", 2},
+		"trailing": {"{}\n{}", 2},
+		"utf8":     {"{\n\"a\": \"\xff\"}", 2},
+	}
+	for name, c := range cases {
+		_, err := ParseStrict([]byte(c.src))
+		var synErr *SyntaxError
+		if !errors.As(err, &synErr) || synErr.Line != c.line {
+			t.Fatalf("%s: expected a SyntaxError on line %d, got %v", name, c.line, err)
+		}
+		if _, err := Parse([]byte(c.src)); err != nil {
+			t.Fatalf("%s: Parse should accept the document: %v", name, err)
+		}
+	}
+}
+
+func TestClean_TrailingCommas(t *testing.T) {
+	src := "{\n  \"Hosts\": [\"a\", \"b\",],\n  \"Text\": \",}\", // comment\n  \"Empty\": {},\n}"
+	got := string(Clean([]byte(src)))
+	want := "{\n  \"Hosts\": [\"a\", \"b\" ],\n  \"Text\": \",}\",           \n  \"Empty\": {} \n}"
+	if got != want {
+		t.Fatalf("unexpected content:\n%q\nwant:\n%q", got, want)
+	}
+
+	doc, err := Parse([]byte(src))
+	if err != nil || len(doc["Hosts"].([]any)) != 2 || doc["Text"] != ",}" {
+		t.Fatalf("trailing commas should be accepted: %v %v", doc, err)
+	}
+	if _, err := ParseStrict([]byte("{\"a\": [1,]}")); err == nil {
+		t.Fatalf("ParseStrict should reject trailing commas")
+	}
+	if _, err := Parse([]byte("{\"a\": [1,,]}")); err == nil {
+		t.Fatalf("Parse should reject empty elements")
+	}
+
+	// A comma is only trailing after a member or element
+	for _, src := range []string{"{,}", "[,]", "{\"a\":[,]}", "{\"a\": { , }}", "{\"a\": 1,,}"} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Fatalf("Parse should reject %s", src)
+		}
+		if got := string(Clean([]byte(src))); got != src {
+			t.Fatalf("Clean should keep the comma of %s: %s", src, got)
+		}
+	}
+	if _, err := Parse([]byte("{\"a\": [ /* c */ , ]}")); err == nil {
+		t.Fatalf("Parse should reject a comma after a comment opening an array")
+	}
+}
+
+func TestClean_Tokens(t *testing.T) {
+	src := "\xEF\xBB\xBF{\"a\": \"\\u0022/* x */\", /* é\n */ \"b\": 1 // c\r\n}"
+	got := string(Clean([]byte(src)))
+	want := "   {\"a\": \"\\u0022/* x */\",      \n    \"b\": 1     \r\n}"
+	if got != want {
+		t.Fatalf("unexpected content:\n%q\nwant:\n%q", got, want)
+	}
+
+	// Unterminated strings and comments are left to the decoder, offsets kept
+	for src, want := range map[string]string{"{\"a\": \"x, // c": "{\"a\": \"x, // c", "{\"a\": 1 /* c\n": "{\"a\": 1     \n"} {
+		if got := string(Clean([]byte(src))); got != want {
+			t.Fatalf("unexpected content:\n%q\nwant:\n%q", got, want)
+		}
+	}
+
+	// Positions are those of the original file, comments included
+	_, err := Parse([]byte("{\n  /* comment */ \"a\": @\n}"))
+	var synErr *SyntaxError
+	if !errors.As(err, &synErr) || synErr.Line != 2 || synErr.Column != 23 || !strings.Contains(synErr.Snippet, "/* comment */") {
+		t.Fatalf("unexpected error: %#v", err)
+	}
+
+	cases := map[string]struct {
+		src          string
+		line, column int
+	}{
+		"string":  {"{\n  \"a\": \"b\\\"}", 2, 8},
+		"comment": {"{\n  \"a\": 1 /* }", 2, 10},
+		"eof":     {"{\n  \"a\": 1,", 2, 10},
+		"empty":   {"", 1, 1},
+	}
+	for name, c := range cases {
+		_, err := Parse([]byte(c.src))
+		var synErr *SyntaxError
+		if !errors.As(err, &synErr) || synErr.Line != c.line || synErr.Column != c.column {
+			t.Fatalf("%s: expected a SyntaxError at %d:%d, got %v", name, c.line, c.column, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package appsettingsenv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// quoteSyntax is the language a quoted scalar is written in
+type quoteSyntax int
+
+const (
+	yamlSyntax quoteSyntax = iota
+	// dotenvSyntax is the KEY=value syntax of env files
+	dotenvSyntax
+)
+
+// QuoteStyles are the accepted FormatOptions.Quote values. always quotes names and
+// values, double and single quote every value but leave names plain where possible,
+// and as-needed quotes only what would otherwise be misread.
+var QuoteStyles = []string{"always", "as-needed", "single", "double"}
+
+// yamlSpecialWords resolve to booleans or null in YAML 1.1, which Kubernetes still uses
+var yamlSpecialWords = map[string]bool{
+	"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true,
+	"true": true, "false": true, "null": true, "~": true,
+}
+
+// isPlain reports whether s reads back as the same string without quotes. The check
+// is conservative: a value outside its character set is quoted even when a parser
+// would accept it.
+func isPlain(s string, syntax quoteSyntax) bool {
+	if s == "" {
+		return false
+	}
+
+	if syntax == dotenvSyntax {
+		for _, r := range s {
+			if !isLetter(r) && !isDigit(r) && !strings.ContainsRune("_./:@=+,-", r) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Numbers, dates and indicators all start with something other than a letter
+	if r := rune(s[0]); !isLetter(r) && r != '_' && r != '/' {
+		return false
+	}
+	if yamlSpecialWords[strings.ToLower(s)] || strings.HasSuffix(s, ":") || strings.HasSuffix(s, " ") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return false
+	}
+	for _, r := range s {
+		if !isLetter(r) && !isDigit(r) && !strings.ContainsRune("_./:@=+,;()- ", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteValue quotes a value in the given style
+func quoteValue(s string, syntax quoteSyntax, style string) string {
+	switch style {
+	case "as-needed":
+		if isPlain(s, syntax) {
+			return s
+		}
+	case "single":
+		// Single quotes cannot hold escapes, nor a quote in env files
+		if !strings.ContainsFunc(s, unicode.IsControl) && utf8.ValidString(s) && (syntax == yamlSyntax || !strings.Contains(s, "'")) {
+			return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+		}
+	}
+	return strconv.Quote(s)
+}
+
+// quoteName quotes a name in the given style: always quoted by always, otherwise
+// only when needed
+func quoteName(s string, syntax quoteSyntax, style string) string {
+	if style != "always" && isPlain(s, syntax) {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// yamlValue writes a YAML value in the given style. Multi-line values become literal
+// block scalars whose lines are indented by base and unit, unit being the indentation
+// relative to the key; values a block cannot hold exactly are quoted instead.
+func yamlValue(s, style, base, unit string) string {
+	if block, ok := yamlBlock(s, base, unit); ok {
+		return block
+	}
+	return quoteValue(s, yamlSyntax, style)
+}
+
+// yamlBlock returns s as a literal block scalar, with the chomping indicator keeping
+// its trailing line breaks, or false when s is a single line or holds characters a
+// block cannot (carriage returns, other control characters, invalid UTF-8)
+func yamlBlock(s, base, unit string) (string, bool) {
+	if !strings.Contains(s, "\n") || strings.TrimSpace(s) == "" || !utf8.ValidString(s) ||
+		strings.ContainsFunc(s, func(r rune) bool { return unicode.IsControl(r) && r != '\n' && r != '\t' || r == '\uFEFF' }) {
+		return "", false
+	}
+
+	header := "|"
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	// The indentation is detected from the first non-empty line, unless it starts
+	// with spaces of its own
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' {
+			if len(unit) > 9 {
+				return "", false
+			}
+			header += strconv.Itoa(len(unit))
+		}
+		break
+	}
+	switch {
+	case !strings.HasSuffix(s, "\n"):
+		header += "-"
+	case strings.HasSuffix(s, "\n\n"):
+		header += "+"
+	}
+
+	var b strings.Builder
+	b.WriteString(header)
+	for _, line := range lines {
+		b.WriteString("\n")
+		if line != "" {
+			b.WriteString(base + unit + line)
+		}
+	}
+	return b.String(), true
+}
+
+// bicepString returns s as a single-quoted Bicep string. Quotes, backslashes and
+// interpolation starts are escaped, and control characters such as newlines are
+// written as escapes so that multi-line values stay on one line, which keeps the
+// value exact wherever the entry is indented.
+func bicepString(s string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i, r := range s {
+		switch {
+		case r == '\'' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '$' && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteString(`\$`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case unicode.IsControl(r):
+			fmt.Fprintf(&b, `\u{%X}`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package appsettingsenv
+
+import "testing"
+
+func TestIsPlain(t *testing.T) {
+	cases := []struct {
+		value  string
+		syntax quoteSyntax
+		want   bool
+	}{
+		{"Information", yamlSyntax, true},
+		{"hello world", yamlSyntax, true},
+		{"http://api:8080/v1", yamlSyntax, true},
+		{"80", yamlSyntax, false},
+		{"yes", yamlSyntax, false},
+		{"Null", yamlSyntax, false},
+		{"a: b", yamlSyntax, false},
+		{"a #b", yamlSyntax, false},
+		{"-debug", yamlSyntax, false},
+		{"", yamlSyntax, false},
+		{"80", dotenvSyntax, true},
+		{"hello world", dotenvSyntax, false},
+		{"$HOME", dotenvSyntax, false},
+	}
+	for _, c := range cases {
+		if got := isPlain(c.value, c.syntax); got != c.want {
+			t.Fatalf("isPlain(%q, %d): want %v got %v", c.value, c.syntax, c.want, got)
+		}
+	}
+}
+
+func TestQuoteValue(t *testing.T) {
+	cases := []struct {
+		style, value string
+		syntax       quoteSyntax
+		want         string
+	}{
+		{"always", "Debug", yamlSyntax, `"Debug"`},
+		{"double", "Debug", yamlSyntax, `"Debug"`},
+		{"as-needed", "Debug", yamlSyntax, `Debug`},
+		{"as-needed", "true", yamlSyntax, `"true"`},
+		{"single", "it's", yamlSyntax, `'it''s'`},
+		{"single", "it's", dotenvSyntax, `"it's"`},
+		{"single", "a\nb", yamlSyntax, `"a\nb"`},
+	}
+	for _, c := range cases {
+		if got := quoteValue(c.value, c.syntax, c.style); got != c.want {
+			t.Fatalf("%s: quoteValue(%q): want %s got %s", c.style, c.value, c.want, got)
+		}
+	}
+
+	if got := quoteName("Logging__Level", yamlSyntax, "double"); got != "Logging__Level" {
+		t.Fatalf("names should stay plain: %s", got)
+	}
+	if got := quoteName("Logging__Level", yamlSyntax, "always"); got != `"Logging__Level"` {
+		t.Fatalf("names should be quoted: %s", got)
+	}
+}
+
+func TestBicepString(t *testing.T) {
+	cases := map[string]string{
+		"Information":               `'Information'`,
+		"it's":                      `'it\'s'`,
+		`C:\data`:                   `'C:\\data'`,
+		"${secret} $HOME":           `'\${secret} $HOME'`,
+		"-----BEGIN-----\nAB==\r\n": `'-----BEGIN-----\nAB==\r\n'`,
+		"a\tb\x00":                  `'a\tb\u{0}'`,
+	}
+	for value, want := range cases {
+		if got := bicepString(value); got != want {
+			t.Fatalf("bicepString(%q): want %s got %s", value, want, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package appsettingsenv
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// SortedKeys returns the keys of vars sorted case-insensitively, runs of digits
+// compared by value so that Items__10 follows Items__9
+func SortedKeys(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := strings.ToLower(keys[i]), strings.ToLower(keys[j])
+		if a == b {
+			return keys[i] < keys[j]
+		}
+		return naturalLess(a, b)
+	})
+	return keys
+}
+
+// Sorted returns vars as output entries in SortedKeys order
+func Sorted(vars map[string]string) []KV {
+	out := make([]KV, 0, len(vars))
+	for _, k := range SortedKeys(vars) {
+		out = append(out, KV{Name: k, Value: vars[k]})
+	}
+	return out
+}
+
+// naturalLess compares a and b bytewise except for runs of digits, which compare
+// numerically. Equal numbers with different zero padding fall back to bytewise order.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(rune(a[i])) && isDigit(rune(b[j])) {
+			si, sj := i, j
+			for i < len(a) && isDigit(rune(a[i])) {
+				i++
+			}
+			for j < len(b) && isDigit(rune(b[j])) {
+				j++
+			}
+			na, nb := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			continue
+		}
+		if a[i] != b[j] {
+			return a[i] < b[j]
+		}
+		i++
+		j++
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return a < b
+}
+
+func isLetter(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }
+func isDigit(r rune) bool  { return r >= '0' && r <= '9' }
+
+// SourceOrder returns the keys Flatten gives the appsettings.json document data in
+// the order their values appear in the document
+func SourceOrder(data []byte, opts FlattenOptions) ([]string, error) {
+	index, n := make(map[string]int), 0
+	doc, err := ParseStream(bytes.NewReader(data), StreamOptions{Value: func(path string, _ int) {
+		index[path] = n
+		n++
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return KeysInOrder(doc, index, opts), nil
+}
+
+// KeysInOrder returns the keys Flatten gives doc ordered by the rank of their values
+// in index, keyed by the JSONPaths StreamOptions.Value reports. Decoded maps lose the
+// order of the document, which ParseStream reports on the way.
+func KeysInOrder(doc map[string]any, index map[string]int, opts FlattenOptions) []string {
+	rank := make(map[string]int)
+	flatten(doc, &opts, true, func(key, _, path string, _ bool) {
+		rank[key] = index[path]
+	})
+
+	keys := make([]string, 0, len(rank))
+	for k := range rank {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank[keys[i]] != rank[keys[j]] {
+			return rank[keys[i]] < rank[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,39 @@
+package appsettingsenv
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSortedKeys_NumericIndices(t *testing.T) {
+	vars := map[string]string{}
+	for i := 0; i < 12; i++ {
+		vars[fmt.Sprintf("Items__%d", i)] = ""
+	}
+	vars["items__2__Name"] = ""
+	vars["Other"] = ""
+
+	got := SortedKeys(vars)
+	want := []string{"Items__0", "Items__1", "Items__2", "items__2__Name", "Items__3", "Items__4", "Items__5",
+		"Items__6", "Items__7", "Items__8", "Items__9", "Items__10", "Items__11", "Other"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected order:\n%v", got)
+	}
+}
+
+func TestSourceOrder(t *testing.T) {
+	src := "{\n  // first\n  \"Zeta\": 1,\n  \"Alpha\": {\"B\": 2, \"A\": [3, 4]},\n  \"Mid\": null,\n}"
+	keys, err := SourceOrder([]byte(src), FlattenOptions{})
+	if err != nil {
+		t.Fatalf("SourceOrder failed: %v", err)
+	}
+	if got := strings.Join(keys, " "); got != "Zeta Alpha:B Alpha:A:0 Alpha:A:1 Mid" {
+		t.Fatalf("unexpected order: %s", got)
+	}
+
+	keys, _ = SourceOrder([]byte(src), FlattenOptions{ArrayMode: "json", Separator: "__"})
+	if got := strings.Join(keys, " "); got != "Zeta Alpha__B Alpha__A Mid" {
+		t.Fatalf("unexpected order with json arrays: %s", got)
+	}
+}
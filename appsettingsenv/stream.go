@@ -0,0 +1,656 @@
+package appsettingsenv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// StreamOptions control ParseStream
+type StreamOptions struct {
+	// Strict parses RFC 8259 JSON as ParseStrict does
+	Strict bool
+	// Flatten joins the keys passed to Duplicate, as Flatten would
+	Flatten FlattenOptions
+	// Duplicate, when set, is called for every member whose name repeats the name of
+	// an earlier member of the same object case-insensitively, which the .NET JSON
+	// configuration provider rejects, with its flattened key and the lines of both
+	Duplicate func(key string, line, firstLine int)
+	// Value, when set, is called at the start of every value, in document order,
+	// with its JSONPath and line
+	Value func(path string, line int)
+	// Limits bound the document, DefaultLimits when zero
+	Limits Limits
+}
+
+// Limits bound the documents ParseStream decodes, so that a hostile or corrupted
+// input cannot exhaust the stack or the memory of the process decoding it. Zero
+// fields take the value of DefaultLimits and negative ones disable the limit.
+type Limits struct {
+	// Nesting is the number of levels of objects and arrays, the root included
+	Nesting int
+	// Keys is the number of members and elements, those of nested values included
+	Keys int
+	// ValueBytes is the total size of the member names, strings and numbers
+	ValueBytes int64
+}
+
+// DefaultLimits are the limits of Parse, ParseStrict and ParseReader, far above
+// those of real configurations
+var DefaultLimits = Limits{Nesting: 1000, Keys: 1 << 20, ValueBytes: 256 << 20}
+
+// orDefault returns l with its zero fields set from DefaultLimits
+func (l Limits) orDefault() Limits {
+	if l.Nesting == 0 {
+		l.Nesting = DefaultLimits.Nesting
+	}
+	if l.Keys == 0 {
+		l.Keys = DefaultLimits.Keys
+	}
+	if l.ValueBytes == 0 {
+		l.ValueBytes = DefaultLimits.ValueBytes
+	}
+	return l
+}
+
+// LimitError reports a document exceeding one of its Limits, at the value past it
+type LimitError struct {
+	// Limit is the limit exceeded: "nesting", "keys" or "value bytes"
+	Limit        string
+	Max          int64
+	Line, Column int
+}
+
+func (e *LimitError) Error() string {
+	var what string
+	switch e.Limit {
+	case "nesting":
+		what = fmt.Sprintf("more than %d levels of nesting", e.Max)
+	case "keys":
+		what = fmt.Sprintf("more than %d keys", e.Max)
+	default:
+		what = fmt.Sprintf("more than %d bytes of names and values", e.Max)
+	}
+	return fmt.Sprintf("%s (line %d, column %d)", what, e.Line, e.Column)
+}
+
+// ParseStream decodes the appsettings.json document read from r as Parse, or
+// ParseStrict, does. The document is decoded token by token while it is read, so
+// that its content is never held in memory besides the decoded values: comments
+// and trailing commas are blanked on the fly, and errors are located from the
+// line breaks counted and the end of the content kept on the way.
+func ParseStream(r io.Reader, o StreamOptions) (map[string]any, error) {
+	src := newSourceReader(r, !o.Strict)
+	dec := json.NewDecoder(src)
+	dec.UseNumber()
+	p := &streamParser{dec: dec, src: src, o: &o, sep: o.Flatten.separator(), limits: o.Limits.orDefault()}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, p.error(err)
+	}
+	var doc map[string]any
+	switch tok {
+	case json.Delim('{'):
+		p.value("$")
+		if doc, err = p.object("", "$", 0); err != nil {
+			return nil, p.error(err)
+		}
+	case nil:
+		// As json.Unmarshal, a null document decodes to a nil map
+	default:
+		return nil, errors.New("failed to decode JSON: the document is not an object")
+	}
+
+	if o.Strict {
+		if err := p.trailing(); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// streamParser builds the values of a document from the tokens of dec
+type streamParser struct {
+	dec *json.Decoder
+	src *sourceReader
+	o   *StreamOptions
+	sep string
+
+	// keys and size count the members and elements and their bytes, for limits
+	limits Limits
+	keys   int
+	size   int64
+}
+
+// limit returns a LimitError for limit when n is over max, a positive limit
+func (p *streamParser) limit(limit string, n, max int64) error {
+	if max <= 0 || n <= max {
+		return nil
+	}
+	line, column := p.src.position(p.dec.InputOffset())
+	return &LimitError{Limit: limit, Max: max, Line: line, Column: column}
+}
+
+// count counts a member or element, named by size bytes, against the limits
+func (p *streamParser) count(size int) error {
+	p.keys++
+	p.size += int64(size)
+	if err := p.limit("keys", int64(p.keys), int64(p.limits.Keys)); err != nil {
+		return err
+	}
+	return p.limit("value bytes", p.size, p.limits.ValueBytes)
+}
+
+// value reports the value at path, when asked to, once its first token was read
+func (p *streamParser) value(path string) {
+	if p.o.Value != nil {
+		p.o.Value(path, p.src.line(p.dec.InputOffset()))
+	}
+}
+
+// decode decodes the value starting with tok, found under key at path and depth
+// levels deep. Keys are only built for duplicate detection and paths for the Value
+// callback.
+func (p *streamParser) decode(tok json.Token, key, path string, depth int) (any, error) {
+	p.value(path)
+	switch t := tok.(type) {
+	case json.Delim:
+		// The containers count as a level of nesting each, the root one included
+		if err := p.limit("nesting", int64(depth+1), int64(p.limits.Nesting)); err != nil {
+			return nil, err
+		}
+		if t == '{' {
+			return p.object(key, path, depth)
+		}
+		return p.array(key, path, depth)
+	case string:
+		p.size += int64(len(t))
+	case json.Number:
+		p.size += int64(len(t))
+	}
+	return tok, p.limit("value bytes", p.size, p.limits.ValueBytes)
+}
+
+// child returns the key of segment in the container at key and depth
+func (p *streamParser) child(key, segment string, depth int) string {
+	if depth == 0 {
+		return segment
+	}
+	return key + p.sep + segment
+}
+
+// object decodes the members of the object at key once its { was read
+func (p *streamParser) object(key, path string, depth int) (map[string]any, error) {
+	m := make(map[string]any)
+	var seen map[string]int
+	if p.o.Duplicate != nil {
+		seen = make(map[string]int)
+	}
+	for p.dec.More() {
+		tok, err := p.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tok.(string)
+		if err := p.count(len(name)); err != nil {
+			return nil, err
+		}
+
+		var childKey, childPath string
+		if seen != nil {
+			childKey = p.child(key, name, depth)
+			line := p.src.line(p.dec.InputOffset())
+			lower := strings.ToLower(name)
+			if first, dup := seen[lower]; dup {
+				p.o.Duplicate(childKey, line, first)
+			} else {
+				seen[lower] = line
+			}
+		}
+		if p.o.Value != nil {
+			childPath = memberPath(path, name)
+		}
+
+		if tok, err = p.dec.Token(); err != nil {
+			return nil, err
+		}
+		if m[name], err = p.decode(tok, childKey, childPath, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	_, err := p.dec.Token()
+	return m, err
+}
+
+// array decodes the elements of the array at key once its [ was read
+func (p *streamParser) array(key, path string, depth int) ([]any, error) {
+	items := []any{}
+	for i := 0; p.dec.More(); i++ {
+		tok, err := p.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.count(0); err != nil {
+			return nil, err
+		}
+		var childKey, childPath string
+		if p.o.Duplicate != nil {
+			childKey = p.child(key, Index(i, p.o.Flatten.PadIndex), depth)
+		}
+		if p.o.Value != nil {
+			childPath = path + "[" + strconv.Itoa(i) + "]"
+		}
+		item, err := p.decode(tok, childKey, childPath, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	_, err := p.dec.Token()
+	return items, err
+}
+
+// trailing rejects content after the document
+func (p *streamParser) trailing() error {
+	offset := p.dec.InputOffset()
+	rest := bufio.NewReader(io.MultiReader(p.dec.Buffered(), p.src))
+	for {
+		c, err := rest.ReadByte()
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return p.error(err)
+		case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+			return p.src.syntaxError(offset, errors.New("content after the document"))
+		}
+		offset++
+	}
+}
+
+// error locates the decoding error err in the source
+func (p *streamParser) error(err error) error {
+	var located *SyntaxError
+	var limit *LimitError
+	var synErr *json.SyntaxError
+	switch {
+	case errors.As(err, &located):
+		return located
+	case errors.As(err, &limit):
+		return limit
+	case errors.As(err, &synErr):
+		return p.src.syntaxError(synErr.Offset, synErr)
+	case errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF):
+		return p.src.syntaxError(p.src.offset, errors.New("unexpected end of document"))
+	case errors.Is(err, errRead):
+		return err
+	}
+	return fmt.Errorf("failed to decode JSON: %w", err)
+}
+
+// errRead marks the errors of the reader of the document
+var errRead = errors.New("read failed")
+
+// Sizes of the reads of sourceReader and of the end of the content it keeps. The
+// decoder only goes back a read to report errors, within the kept content.
+const (
+	sourceChunk  = 16 << 10
+	sourceWindow = 64 << 10
+)
+
+// Scanning states of sourceReader
+const (
+	inValue = iota
+	inString
+	inEscape
+	inSlash
+	inLineComment
+	inBlockComment
+	inBlockStar
+)
+
+// sourceReader reads a document for the decoder. When clean is set, it blanks the
+// byte order mark, the comments and the trailing commas as Clean does, holding
+// back the bytes that may still change; otherwise it rejects a byte order mark and
+// invalid UTF-8 as ParseStrict does. Byte offsets are those of the source.
+type sourceReader struct {
+	r     io.Reader
+	clean bool
+	// keepCommas leaves the trailing commas in clean mode, for StripComments
+	keepCommas bool
+	err        error
+	chunk      []byte
+
+	// out holds the processed bytes not read yet. comma and slash are the offsets in
+	// out of a comma that may be trailing and of a slash that may start a comment,
+	// or -1.
+	out          []byte
+	comma, slash int
+	state        int
+	// last is the last byte of the values and punctuation scanned, or 0
+	last byte
+	// start is the offset of the string or comment being scanned, at startLine and
+	// startColumn: its line breaks may leave the window before it ends
+	start                  int64
+	startLine, startColumn int
+	// offset counts the bytes processed, carry holds an incomplete UTF-8 sequence or
+	// the first bytes until begun
+	offset int64
+	carry  []byte
+	begun  bool
+
+	// window holds the end of the source, from windowStart. newlines holds the
+	// offsets of the line breaks from there, counted the ones before it, and
+	// lastDropped is the offset of the last line break dropped, or -1.
+	window      []byte
+	windowStart int64
+	newlines    []int64
+	counted     int
+	lastDropped int64
+}
+
+func newSourceReader(r io.Reader, clean bool) *sourceReader {
+	return &sourceReader{r: r, clean: clean, chunk: make([]byte, sourceChunk), comma: -1, slash: -1, lastDropped: -1}
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	for {
+		if ready := s.ready(); ready > 0 {
+			n := copy(p, s.out[:ready])
+			// What is left is mostly the bytes held back, moved to reuse the buffer
+			s.out = s.out[:copy(s.out, s.out[n:])]
+			if s.comma >= 0 {
+				s.comma -= n
+			}
+			if s.slash >= 0 {
+				s.slash -= n
+			}
+			return n, nil
+		}
+		if s.err != nil {
+			return 0, s.err
+		}
+
+		n, err := s.r.Read(s.chunk)
+		s.feed(s.chunk[:n])
+		switch {
+		case errors.Is(err, io.EOF):
+			s.finish()
+		case err != nil:
+			s.err = fmt.Errorf("%w: %w", errRead, err)
+		}
+	}
+}
+
+// ready returns the number of bytes of out that no later input can change
+func (s *sourceReader) ready() int {
+	ready := len(s.out)
+	for _, held := range []int{s.comma, s.slash} {
+		if held >= 0 {
+			ready = min(ready, held)
+		}
+	}
+	return ready
+}
+
+// feed processes a chunk of the source
+func (s *sourceReader) feed(chunk []byte) {
+	s.remember(chunk)
+	if !s.begun {
+		// The first bytes are held until they tell whether a byte order mark starts
+		// the source
+		chunk = append(s.carry, chunk...)
+		s.carry = nil
+		if len(chunk) < len(utf8BOM) && bytes.HasPrefix(utf8BOM, chunk) {
+			s.carry = chunk
+			return
+		}
+		s.begin(chunk)
+		return
+	}
+	s.process(chunk)
+}
+
+// begin processes the first bytes of the source, from its byte order mark
+func (s *sourceReader) begin(chunk []byte) {
+	s.begun = true
+	if bytes.HasPrefix(chunk, utf8BOM) {
+		if !s.clean {
+			s.err = s.syntaxError(0, errors.New("byte order mark"))
+			return
+		}
+		s.out = append(s.out, "   "...)
+		s.offset += int64(len(utf8BOM))
+		chunk = chunk[len(utf8BOM):]
+	}
+	s.process(chunk)
+}
+
+// process processes a chunk of the source past its byte order mark
+func (s *sourceReader) process(chunk []byte) {
+	if !s.clean {
+		s.check(chunk)
+		return
+	}
+	for _, c := range chunk {
+		s.scan(c)
+		s.offset++
+	}
+}
+
+// scan processes the byte c at offset in clean mode
+func (s *sourceReader) scan(c byte) {
+	blanked := byte(' ')
+	if c == '\n' || c == '\r' {
+		blanked = c
+	}
+	switch s.state {
+	case inString:
+		switch c {
+		case '\\':
+			s.state = inEscape
+		case '"', '\n':
+			// A line break ends the string early so that comments past it are found
+			s.state = inValue
+		}
+	case inEscape:
+		s.state = inString
+	case inSlash:
+		switch c {
+		case '/':
+			s.out[s.slash] = ' '
+			s.slash, s.state = -1, inLineComment
+			s.out = append(s.out, ' ')
+			return
+		case '*':
+			s.out[s.slash] = ' '
+			s.slash, s.state = -1, inBlockComment
+			s.startAt(s.offset - 1)
+			s.out = append(s.out, ' ')
+			return
+		}
+		// A lone slash is a value the decoder rejects
+		s.slash, s.comma, s.state, s.last = -1, -1, inValue, '/'
+		s.scan(c)
+		return
+	case inLineComment:
+		if c == '\n' {
+			s.state = inValue
+		}
+		s.out = append(s.out, blanked)
+		return
+	case inBlockComment, inBlockStar:
+		switch {
+		case c == '/' && s.state == inBlockStar:
+			s.state = inValue
+		case c == '*':
+			s.state = inBlockStar
+		default:
+			s.state = inBlockComment
+		}
+		s.out = append(s.out, blanked)
+		return
+	default:
+		switch c {
+		case '"':
+			s.comma, s.state = -1, inString
+			s.startAt(s.offset)
+		case '/':
+			s.slash, s.state = len(s.out), inSlash
+		case ',':
+			// Only a comma after a member or element may be trailing: in {,} or [1,,]
+			// the decoder rejects it
+			s.comma = -1
+			if !s.keepCommas && s.last != 0 && s.last != '{' && s.last != '[' && s.last != ',' {
+				s.comma = len(s.out)
+			}
+		case '}', ']':
+			if s.comma >= 0 {
+				s.out[s.comma] = ' '
+			}
+			s.comma = -1
+		case ' ', '\t', '\r', '\n':
+		default:
+			s.comma = -1
+		}
+		if c != '/' && c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			s.last = c
+		}
+	}
+	s.out = append(s.out, c)
+}
+
+// check passes a chunk of the source through in strict mode, up to a byte order
+// mark or invalid UTF-8, which set the error
+func (s *sourceReader) check(chunk []byte) {
+	data := append(s.carry, chunk...)
+	s.carry = nil
+	i := 0
+	for i < len(data) {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			if !utf8.FullRune(data[i:]) {
+				s.carry = append(s.carry, data[i:]...)
+				break
+			}
+			s.out = append(s.out, data[:i]...)
+			s.offset += int64(i)
+			s.err = s.syntaxError(s.offset, errors.New("invalid UTF-8"))
+			return
+		}
+		i += size
+	}
+	s.out = append(s.out, data[:i]...)
+	s.offset += int64(i)
+}
+
+// finish processes the end of the source: bytes held back are released, and an
+// unterminated string or comment is an error located at its start
+func (s *sourceReader) finish() {
+	if !s.begun {
+		chunk := s.carry
+		s.carry = nil
+		if s.begin(chunk); s.err != nil {
+			return
+		}
+	}
+	s.err = io.EOF
+	if len(s.carry) > 0 {
+		s.err = s.syntaxError(s.offset, errors.New("invalid UTF-8"))
+		return
+	}
+	switch s.state {
+	case inString, inEscape:
+		s.err = s.startError(errors.New("unterminated string"))
+	case inBlockComment, inBlockStar:
+		s.err = s.startError(errors.New("unterminated comment"))
+	}
+	s.comma, s.slash = -1, -1
+}
+
+// startAt records offset, within the window as it was just scanned, as the start of
+// a string or comment
+func (s *sourceReader) startAt(offset int64) {
+	s.start = offset
+	s.startLine, s.startColumn = s.position(offset)
+}
+
+// startError returns a SyntaxError at the start of the string or comment scanned
+func (s *sourceReader) startError(err error) *SyntaxError {
+	synErr := s.syntaxError(s.start, err)
+	synErr.Line, synErr.Column = s.startLine, s.startColumn
+	return synErr
+}
+
+// remember keeps the end of the source and the offsets of its line breaks
+func (s *sourceReader) remember(chunk []byte) {
+	end := s.windowStart + int64(len(s.window))
+	for i, c := range chunk {
+		if c == '\n' {
+			s.newlines = append(s.newlines, end+int64(i))
+		}
+	}
+	s.window = append(s.window, chunk...)
+	if drop := len(s.window) - sourceWindow; drop > sourceWindow {
+		s.window = append(s.window[:0], s.window[drop:]...)
+		s.windowStart += int64(drop)
+		n := sort.Search(len(s.newlines), func(i int) bool { return s.newlines[i] >= s.windowStart })
+		if n > 0 {
+			s.lastDropped = s.newlines[n-1]
+			s.counted += n
+			s.newlines = append(s.newlines[:0], s.newlines[n:]...)
+		}
+	}
+}
+
+// line returns the line of offset
+func (s *sourceReader) line(offset int64) int {
+	line, _ := s.position(offset)
+	return line
+}
+
+// position returns the line and column of offset, which must not be before the window
+func (s *sourceReader) position(offset int64) (line, column int) {
+	n := sort.Search(len(s.newlines), func(i int) bool { return s.newlines[i] >= offset })
+	prev := s.lastDropped
+	if n > 0 {
+		prev = s.newlines[n-1]
+	}
+	return s.counted + n + 1, int(offset - prev)
+}
+
+// syntaxError returns a SyntaxError at offset, with the content around it that is
+// still kept
+func (s *sourceReader) syntaxError(offset int64, err error) *SyntaxError {
+	// The content past offset completes the snippet
+	for s.err == nil && s.windowStart+int64(len(s.window)) < offset+60 {
+		chunk := make([]byte, 60)
+		n, readErr := s.r.Read(chunk)
+		s.window = append(s.window, chunk[:n]...)
+		if readErr != nil {
+			break
+		}
+	}
+
+	offset = min(max(offset, 0), s.windowStart+int64(len(s.window)))
+	line, column := s.position(offset)
+	from := max(offset-60, s.windowStart) - s.windowStart
+	to := min(offset+60, s.windowStart+int64(len(s.window))) - s.windowStart
+	var snippet string
+	if from < to {
+		snippet = string(s.window[from:to])
+	}
+	return &SyntaxError{Line: line, Column: column, Snippet: snippet, Err: err}
+}
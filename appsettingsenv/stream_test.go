@@ -0,0 +1,140 @@
+package appsettingsenv
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseStream_Duplicates(t *testing.T) {
+	src := `{
+  "Logging": {
+    "Level": "Info",
+    "level": "Debug"
+  },
+  "Hosts": [
+    { "Name": "a", "Name": "b" }
+  ],
+  "Other": "x",
+  "": 1,
+  "": "y"
+}`
+	type duplicate struct {
+		key             string
+		line, firstLine int
+	}
+	var got []duplicate
+	o := StreamOptions{Duplicate: func(key string, line, firstLine int) { got = append(got, duplicate{key, line, firstLine}) }}
+	if _, err := ParseStream(strings.NewReader(src), o); err != nil {
+		t.Fatalf("ParseStream failed: %v", err)
+	}
+	want := []duplicate{{"Logging:level", 4, 3}, {"Hosts:0:Name", 7, 7}, {"", 11, 10}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v got %v", want, got)
+	}
+
+	got = nil
+	if _, err := ParseStream(strings.NewReader(`{"a": {"b": 1}, "c": {"b": 2}}`), o); err != nil || len(got) != 0 {
+		t.Fatalf("keys in different objects are not duplicates: %v %v", got, err)
+	}
+}
+
+func TestParseStream_Chunks(t *testing.T) {
+	// Comments, strings and trailing commas split across reads of one byte
+	src := "\xEF\xBB\xBF{\n  // a comment, }\n  \"Url\": \"http://x/*y*/\", /* block\n */ \"List\": [1, 2, /**/ ],\n  \"Esc\": \"a\\\"//b\",\n}"
+	want, err := Parse([]byte(src))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got, err := ParseStream(iotest.OneByteReader(strings.NewReader(src)), StreamOptions{})
+	if err != nil || !reflect.DeepEqual(got, want) || got["Url"] != "http://x/*y*/" || got["Esc"] != `a"//b` {
+		t.Fatalf("unexpected document: %v %v", got, err)
+	}
+
+	if _, err := ParseStream(iotest.OneByteReader(strings.NewReader(src)), StreamOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "byte order mark") {
+		t.Fatalf("strict decoding should reject the byte order mark: %v", err)
+	}
+	if got, err := ParseStream(strings.NewReader("{}"), StreamOptions{}); err != nil || len(got) != 0 {
+		t.Fatalf("unexpected document: %v %v", got, err)
+	}
+
+	var lines []int
+	o := StreamOptions{Value: func(path string, line int) { lines = append(lines, line) }}
+	if _, err := ParseStream(iotest.HalfReader(strings.NewReader(src)), o); err != nil || !reflect.DeepEqual(lines, []int{1, 3, 4, 4, 4, 5}) {
+		t.Fatalf("unexpected value lines: %v %v", lines, err)
+	}
+}
+
+func TestParseStream_Large(t *testing.T) {
+	// Errors past the content kept are still located by line
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i := 0; i < 20000; i++ {
+		b.WriteString(`  "Key` + Index(i, 5) + `": "` + strings.Repeat("x", 20) + "\", // comment\n")
+	}
+	b.WriteString("  \"Bad\": @\n}")
+	_, err := ParseStream(strings.NewReader(b.String()), StreamOptions{})
+	var synErr *SyntaxError
+	if !errors.As(err, &synErr) || synErr.Line != 20002 || synErr.Column != 11 || !strings.Contains(synErr.Snippet, `"Bad": @`) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ParseStream(iotest.ErrReader(errors.New("disk")), StreamOptions{}); err == nil || !strings.Contains(err.Error(), "read failed: disk") {
+		t.Fatalf("read errors should be reported: %v", err)
+	}
+}
+
+func TestParseStream_Limits(t *testing.T) {
+	// Nesting deeper than the default is refused before it exhausts the stack
+	deep := strings.Repeat(`{"a":`, 1000) + "[1]" + strings.Repeat("}", 1000)
+	_, err := Parse([]byte(deep))
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != "nesting" || limitErr.Max != 1000 || limitErr.Column != 5002 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ParseStream(strings.NewReader(deep), StreamOptions{Limits: Limits{Nesting: -1}}); err != nil {
+		t.Fatalf("a negative limit should disable it: %v", err)
+	}
+
+	src := "{\n  \"Hosts\": [\"a\", \"b\"],\n  \"Name\": \"value\"\n}"
+	tests := []struct {
+		limits Limits
+		want   string
+	}{
+		{Limits{Nesting: 1}, "more than 1 levels of nesting (line 2, column 13)"},
+		{Limits{Keys: 3}, "more than 3 keys (line 3, column 9)"},
+		{Limits{ValueBytes: 11}, "more than 11 bytes of names and values (line 3, column 18)"},
+		{Limits{Nesting: 2, Keys: 4, ValueBytes: 16}, ""},
+	}
+	for _, tt := range tests {
+		_, err := ParseStream(strings.NewReader(src), StreamOptions{Limits: tt.limits})
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || err.Error() != tt.want) {
+			t.Fatalf("%+v: want %q got %v", tt.limits, tt.want, err)
+		}
+	}
+}
+
+func TestParseStream_LargeToken(t *testing.T) {
+	// Tokens longer than the content kept do not shift the positions past or at them
+	long := strings.Repeat("x", 300<<10)
+	src := "{\n  \"Long\": \"" + long + "\", \"Bad\": @\n}"
+	_, err := ParseStream(strings.NewReader(src), StreamOptions{})
+	var synErr *SyntaxError
+	if !errors.As(err, &synErr) || synErr.Line != 2 || synErr.Column != len(long)+23 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src = "{\n  \"A\": 1 /* " + strings.Repeat("comment\n", 40<<10) + "}"
+	_, err = ParseStream(strings.NewReader(src), StreamOptions{})
+	if !errors.As(err, &synErr) || synErr.Line != 2 || synErr.Column != 10 || !strings.Contains(err.Error(), "unterminated comment") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	src = "{\n  \"A\": \"" + long
+	_, err = ParseStream(strings.NewReader(src), StreamOptions{})
+	if !errors.As(err, &synErr) || synErr.Line != 2 || synErr.Column != 8 || !strings.Contains(err.Error(), "unterminated string") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
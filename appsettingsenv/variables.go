@@ -0,0 +1,66 @@
+package appsettingsenv
+
+import "io"
+
+// Variable is a flattened key with where its value comes from
+type Variable struct {
+	Name  string
+	Value string
+	// SourceFile is the file the value was read from, as given to Variables
+	SourceFile string
+	// SourceLine is the line of the value in SourceFile, starting at 1
+	SourceLine int
+	// JSONPath locates the value in the document, e.g. $.Logging.LogLevel.Default
+	// or $.Rules[0]
+	JSONPath string
+	// Secret is set for the names matched by VariableOptions.Secret
+	Secret bool
+	// Literal marks values that were JSON numbers, booleans or emitted nulls
+	Literal bool
+}
+
+// VariableOptions control Variables
+type VariableOptions struct {
+	Flatten FlattenOptions
+	// Secret, when set, reports whether the variable called name is a secret
+	Secret func(name string) bool
+}
+
+// Variables reads the appsettings.json document of file from r and flattens it like
+// Flatten, returning the variables in SortedKeys order with the location of their values
+func Variables(file string, r io.Reader, opts VariableOptions) ([]Variable, error) {
+	lines := make(map[string]int)
+	doc, err := ParseStream(r, StreamOptions{Value: func(path string, line int) { lines[path] = line }})
+	if err != nil {
+		return nil, err
+	}
+
+	fo := opts.Flatten
+	byName := make(map[string]Variable)
+	values := make(map[string]string)
+	flatten(doc, &fo, true, func(key, value, path string, literal bool) {
+		v := Variable{Name: key, Value: value, SourceFile: file, SourceLine: lines[path], JSONPath: path, Literal: literal}
+		if opts.Secret != nil {
+			v.Secret = opts.Secret(key)
+		}
+		byName[key], values[key] = v, value
+		if literal && fo.Literal != nil {
+			fo.Literal(key, value)
+		}
+	})
+
+	out := make([]Variable, 0, len(byName))
+	for _, k := range SortedKeys(values) {
+		out = append(out, byName[k])
+	}
+	return out, nil
+}
+
+// KVs returns vars as output entries for Format and FormatSplit
+func KVs(vars []Variable) []KV {
+	out := make([]KV, 0, len(vars))
+	for _, v := range vars {
+		out = append(out, KV{Name: v.Name, Value: v.Value, Secret: v.Secret, Literal: v.Literal})
+	}
+	return out
+}
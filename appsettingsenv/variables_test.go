@@ -0,0 +1,79 @@
+package appsettingsenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariables(t *testing.T) {
+	src := `{
+  // comment
+  "Logging": {
+    "LogLevel": { "Default": "Information" }
+  },
+  "Rules": [
+    "first",
+    { "Name": "second", "Enabled": true }
+  ],
+  "my key": "x",
+  "Port":
+    8080
+}`
+
+	vars, err := Variables("appsettings.json", strings.NewReader(src), VariableOptions{
+		Flatten: FlattenOptions{Separator: "__"},
+		Secret:  func(name string) bool { return name == "my key" },
+	})
+	if err != nil {
+		t.Fatalf("Variables failed: %v", err)
+	}
+
+	want := []Variable{
+		{Name: "Logging__LogLevel__Default", Value: "Information", SourceLine: 4, JSONPath: "$.Logging.LogLevel.Default"},
+		{Name: "my key", Value: "x", SourceLine: 10, JSONPath: "$['my key']", Secret: true},
+		{Name: "Port", Value: "8080", SourceLine: 12, JSONPath: "$.Port", Literal: true},
+		{Name: "Rules__0", Value: "first", SourceLine: 7, JSONPath: "$.Rules[0]"},
+		{Name: "Rules__1__Enabled", Value: "true", SourceLine: 8, JSONPath: "$.Rules[1].Enabled", Literal: true},
+		{Name: "Rules__1__Name", Value: "second", SourceLine: 8, JSONPath: "$.Rules[1].Name"},
+	}
+	if len(vars) != len(want) {
+		t.Fatalf("want %d variables, got %+v", len(want), vars)
+	}
+	for i, w := range want {
+		w.SourceFile = "appsettings.json"
+		if vars[i] != w {
+			t.Fatalf("variable %d: want %+v got %+v", i, w, vars[i])
+		}
+	}
+
+	if kvs := KVs(vars); kvs[1] != (KV{Name: "my key", Value: "x", Secret: true}) || !kvs[2].Literal {
+		t.Fatalf("unexpected entries: %+v", kvs)
+	}
+}
+
+func TestVariables_CollapsedValues(t *testing.T) {
+	src := "{\n\"A\": {\n\"B\": [1, 2]\n}\n}"
+	vars, err := Variables("a.json", strings.NewReader(src), VariableOptions{Flatten: FlattenOptions{ArrayMode: "json"}})
+	if err != nil {
+		t.Fatalf("Variables failed: %v", err)
+	}
+	if len(vars) != 1 || vars[0].Value != "[1,2]" || vars[0].JSONPath != "$.A.B" || vars[0].SourceLine != 3 {
+		t.Fatalf("unexpected variables: %+v", vars)
+	}
+}
+
+func TestMemberPath(t *testing.T) {
+	cases := map[string]string{
+		"Logging": "$.Logging",
+		"_x1":     "$._x1",
+		"1st":     "$['1st']",
+		"a.b":     "$['a.b']",
+		`it's`:    `$['it\'s']`,
+		"":        "$['']",
+	}
+	for key, want := range cases {
+		if got := memberPath("$", key); got != want {
+			t.Fatalf("%q: want %s got %s", key, want, got)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appcontainers/armappcontainers/v3"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice/v2"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// armClient pushes to App Service and Container Apps through the Azure Resource
+// Manager SDK clients
+type armClient struct {
+	webApps       *armappservice.WebAppsClient
+	containerApps *armappcontainers.ContainerAppsClient
+}
+
+// newARMClient authenticates with the default credential chain of azidentity:
+// environment variables, workload identity, managed identity, then the Azure CLI
+// and Azure Developer CLI logins
+func newARMClient(subscription string) (*armClient, error) {
+	if subscription == "" {
+		subscription = os.Getenv("AZURE_SUBSCRIPTION_ID")
+	}
+	if subscription == "" {
+		return nil, errors.New("-subscription is required when AZURE_SUBSCRIPTION_ID is not set")
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure credential: %w", err)
+	}
+	return armClientFor(subscription, cred, nil)
+}
+
+// armClientFor returns the clients of the subscription, options choosing the cloud
+// and transport
+func armClientFor(subscription string, cred azcore.TokenCredential, options *arm.ClientOptions) (*armClient, error) {
+	webApps, err := armappservice.NewWebAppsClient(subscription, cred, options)
+	if err != nil {
+		return nil, err
+	}
+	containerApps, err := armappcontainers.NewContainerAppsClient(subscription, cred, options)
+	if err != nil {
+		return nil, err
+	}
+	return &armClient{webApps: webApps, containerApps: containerApps}, nil
+}
+
+// runPushAzure implements push azure. It merges the converted variables into the app
+// settings of an App Service, or the env and secrets of a Container App container,
+// after printing what changes.
+func runPushAzure(args []string) int {
+	appService := flag.String("app-service", "", "Name of the App Service (or Functions) app to update")
+	slot := flag.String("slot", "", "Deployment slot of the App Service app (default: production)")
+	containerApp := flag.String("container-app", "", "Name of the Container App to update")
+	containerName := flag.String("container", "", "Container of the Container App (default: the first container)")
+	group := flag.String("resource-group", "", "Resource group of the app (required)")
+	subscription := flag.String("subscription", "", "Subscription ID (default: $AZURE_SUBSCRIPTION_ID)")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push azure [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Authentication uses the azidentity default credential chain: AZURE_* environment")
+		fmt.Fprintln(flag.CommandLine.Output(), "variables, workload or managed identity, then an Azure CLI or azd login.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// App settings and Container App variables follow the naming rules of the bicep type
+	r, code := renderOutput("push azure", "bicep", args)
+	if r == nil {
+		return code
+	}
+
+	switch {
+	case (*appService == "") == (*containerApp == ""):
+		errorf("exactly one of -app-service and -container-app is required")
+		return exitUsage
+	case *group == "":
+		errorf("-resource-group is required")
+		return exitUsage
+	case *slot != "" && *appService == "":
+		errorf("-slot requires -app-service")
+		return exitUsage
+	case *containerName != "" && *containerApp == "":
+		errorf("-container requires -container-app")
+		return exitUsage
+	}
+
+	client, err := newARMClient(*subscription)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	if *appService != "" {
+		err = client.pushAppService(ctx, os.Stdout, *group, *appService, *slot, r, *dryRun)
+	} else {
+		err = client.pushContainerApp(ctx, os.Stdout, *group, *containerApp, *containerName, r, *dryRun)
+	}
+	if err != nil {
+		logError(err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// pushAppService merges the variables of r into the app settings of an App Service
+// app. Settings absent from the configuration are kept.
+func (c *armClient) pushAppService(ctx context.Context, w io.Writer, group, name, slot string, r *rendered, dryRun bool) error {
+	var settings armappservice.StringDictionary
+	if slot != "" {
+		resp, err := c.webApps.ListApplicationSettingsSlot(ctx, group, name, slot, nil)
+		if err != nil {
+			return fmt.Errorf("list app settings of %s: %w", name, err)
+		}
+		settings = resp.StringDictionary
+	} else {
+		resp, err := c.webApps.ListApplicationSettings(ctx, group, name, nil)
+		if err != nil {
+			return fmt.Errorf("list app settings of %s: %w", name, err)
+		}
+		settings = resp.StringDictionary
+	}
+
+	current := make(map[string]string, len(settings.Properties))
+	for k, v := range settings.Properties {
+		current[k] = deref(v)
+	}
+
+	// App setting names are case-insensitive
+	updated := make(map[string]string, len(current)+len(r.named))
+	mergeVariables(updated, current)
+	mergeVariables(updated, r.named)
+
+	d := diffVariables(current, updated)
+	if done, err := writePreview(w, d, r.secret, "app settings of "+name, dryRun); done || err != nil {
+		return err
+	}
+
+	body := armappservice.StringDictionary{Properties: make(map[string]*string, len(updated))}
+	for k, v := range updated {
+		body.Properties[k] = to.Ptr(v)
+	}
+	var err error
+	if slot != "" {
+		_, err = c.webApps.UpdateApplicationSettingsSlot(ctx, group, name, slot, body, nil)
+	} else {
+		_, err = c.webApps.UpdateApplicationSettings(ctx, group, name, body, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("update app settings of %s: %w", name, err)
+	}
+	_, err = fmt.Fprintf(w, "app settings of %s updated\n", name)
+	return err
+}
+
+// pushContainerApp merges the variables of r into the env of a Container App
+// container. Variables matched by -secret-keys become Container App secrets that the
+// env references. Other variables and secrets are kept.
+func (c *armClient) pushContainerApp(ctx context.Context, w io.Writer, group, name, container string, r *rendered, dryRun bool) error {
+	app, err := c.containerApps.Get(ctx, group, name, nil)
+	if err != nil {
+		return fmt.Errorf("get container app %s: %w", name, err)
+	}
+
+	// Secret values are only returned by ListSecrets
+	listed, err := c.containerApps.ListSecrets(ctx, group, name, nil)
+	if err != nil {
+		return fmt.Errorf("list secrets of %s: %w", name, err)
+	}
+
+	var containers []*armappcontainers.Container
+	if app.Properties != nil && app.Properties.Template != nil {
+		containers = app.Properties.Template.Containers
+	}
+	idx := -1
+	for i, ct := range containers {
+		if container == "" || deref(ct.Name) == container {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if container == "" {
+			return fmt.Errorf("container app %s has no containers", name)
+		}
+		return fmt.Errorf("container %q not found in container app %s", container, name)
+	}
+
+	secrets := make([]*armappcontainers.Secret, 0, len(listed.Value))
+	secretValues := make(map[string]string, len(listed.Value))
+	for _, s := range listed.Value {
+		secrets = append(secrets, &armappcontainers.Secret{Name: s.Name, Value: s.Value, KeyVaultURL: s.KeyVaultURL, Identity: s.Identity})
+		secretValues[deref(s.Name)] = deref(s.Value)
+	}
+
+	before := containerAppEnv(containers[idx].Env, secretValues)
+	env, secrets := mergeContainerAppEnv(containers[idx].Env, secrets, r.named, r.secret)
+	for _, s := range secrets {
+		secretValues[deref(s.Name)] = deref(s.Value)
+	}
+
+	d := diffVariables(before, containerAppEnv(env, secretValues))
+	if done, err := writePreview(w, d, r.secret, "env of "+name, dryRun); done || err != nil {
+		return err
+	}
+
+	containers[idx].Env = env
+	patch := armappcontainers.ContainerApp{
+		Location: app.Location,
+		Properties: &armappcontainers.ContainerAppProperties{
+			Configuration: &armappcontainers.Configuration{Secrets: secrets},
+			Template:      &armappcontainers.Template{Containers: containers},
+		},
+	}
+	poller, err := c.containerApps.BeginUpdate(ctx, group, name, patch, nil)
+	if err == nil {
+		_, err = poller.PollUntilDone(ctx, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("update container app %s: %w", name, err)
+	}
+	_, err = fmt.Fprintf(w, "env of %s updated\n", name)
+	return err
+}
+
+// deref returns the value of p, or "" when nil
+func deref(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
+
+// containerAppSecretName converts a variable name to a Container App secret name,
+// which only allows lowercase alphanumerics and '-'
+func containerAppSecretName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// mergeContainerAppEnv sets the variables in env, secret ones through a secret reference
+func mergeContainerAppEnv(env []*armappcontainers.EnvironmentVar, secrets []*armappcontainers.Secret, named map[string]string, secret map[string]bool) ([]*armappcontainers.EnvironmentVar, []*armappcontainers.Secret) {
+	index := make(map[string]int, len(env))
+	for i, e := range env {
+		index[deref(e.Name)] = i
+	}
+	secretIndex := make(map[string]int, len(secrets))
+	for i, s := range secrets {
+		secretIndex[deref(s.Name)] = i
+	}
+
+	for _, kv := range appsettingsenv.Sorted(named) {
+		e := &armappcontainers.EnvironmentVar{Name: to.Ptr(kv.Name), Value: to.Ptr(kv.Value)}
+		if secret[kv.Name] {
+			secretName := containerAppSecretName(kv.Name)
+			s := &armappcontainers.Secret{Name: to.Ptr(secretName), Value: to.Ptr(kv.Value)}
+			e = &armappcontainers.EnvironmentVar{Name: to.Ptr(kv.Name), SecretRef: to.Ptr(secretName)}
+			if i, ok := secretIndex[secretName]; ok {
+				secrets[i] = s
+			} else {
+				secretIndex[secretName] = len(secrets)
+				secrets = append(secrets, s)
+			}
+		}
+
+		if i, ok := index[kv.Name]; ok {
+			env[i] = e
+		} else {
+			index[kv.Name] = len(env)
+			env = append(env, e)
+		}
+	}
+	return env, secrets
+}
+
+// containerAppEnv resolves env to name/value pairs for the preview
+func containerAppEnv(env []*armappcontainers.EnvironmentVar, secretValues map[string]string) map[string]string {
+	out := make(map[string]string, len(env))
+	for _, e := range env {
+		if e.SecretRef != nil {
+			out[deref(e.Name)] = secretValues[*e.SecretRef]
+			continue
+		}
+		out[deref(e.Name)] = deref(e.Value)
+	}
+	return out
+}
+
+// writePreview prints the changes d makes to target, masking the values of secret
+// names. It reports true when there is nothing left to do: no change, or dryRun.
+func writePreview(w io.Writer, d diffResult, secret map[string]bool, target string, dryRun bool) (bool, error) {
+	if d.empty() {
+		_, err := fmt.Fprintf(w, "%s up to date\n", target)
+		return true, err
+	}
+
+	for name := range secret {
+		if !secret[name] {
+			continue
+		}
+		if _, ok := d.Added[name]; ok {
+			d.Added[name] = "(secret)"
+		}
+		if _, ok := d.Changed[name]; ok {
+			d.Changed[name] = valueChange{From: "(secret)", To: "(secret)"}
+		}
+	}
+
+	if err := writeDiff(w, d, "text", "", "", useColor(os.Stdout)); err != nil {
+		return true, err
+	}
+	return dryRun, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appcontainers/armappcontainers/v3"
+	armappcontainersfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appcontainers/armappcontainers/v3/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice/v2"
+	armappservicefake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/appservice/armappservice/v2/fake"
+)
+
+// armTransport routes the requests of the ARM clients to the fake servers of their
+// resource provider
+type armTransport struct {
+	web  *armappservicefake.WebAppsServerTransport
+	apps *armappcontainersfake.ContainerAppsServerTransport
+}
+
+func (t armTransport) Do(req *http.Request) (*http.Response, error) {
+	if strings.Contains(req.URL.Path, "/Microsoft.App/") {
+		return t.apps.Do(req)
+	}
+	return t.web.Do(req)
+}
+
+func newTestARMClient(t *testing.T, web *armappservicefake.WebAppsServer, apps *armappcontainersfake.ContainerAppsServer) *armClient {
+	transport := armTransport{armappservicefake.NewWebAppsServerTransport(web), armappcontainersfake.NewContainerAppsServerTransport(apps)}
+	client, err := armClientFor("sub", &azfake.TokenCredential{}, &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Transport: transport}})
+	if err != nil {
+		t.Fatalf("arm client: %v", err)
+	}
+	return client
+}
+
+func TestPushAppService(t *testing.T) {
+	var updated map[string]*string
+	client := newTestARMClient(t, &armappservicefake.WebAppsServer{
+		ListApplicationSettingsSlot: func(_ context.Context, group, name, slot string, _ *armappservice.WebAppsClientListApplicationSettingsSlotOptions) (resp azfake.Responder[armappservice.WebAppsClientListApplicationSettingsSlotResponse], errResp azfake.ErrorResponder) {
+			if group != "rg" || name != "api" || slot != "staging" {
+				errResp.SetResponseError(http.StatusNotFound, "ResourceNotFound")
+				return
+			}
+			resp.SetResponse(http.StatusOK, armappservice.WebAppsClientListApplicationSettingsSlotResponse{StringDictionary: armappservice.StringDictionary{
+				Properties: map[string]*string{"WEBSITE_RUN_FROM_PACKAGE": to.Ptr("1"), "api__url": to.Ptr("https://old")},
+			}}, nil)
+			return
+		},
+		UpdateApplicationSettingsSlot: func(_ context.Context, _, _, _ string, settings armappservice.StringDictionary, _ *armappservice.WebAppsClientUpdateApplicationSettingsSlotOptions) (resp azfake.Responder[armappservice.WebAppsClientUpdateApplicationSettingsSlotResponse], errResp azfake.ErrorResponder) {
+			updated = settings.Properties
+			resp.SetResponse(http.StatusOK, armappservice.WebAppsClientUpdateApplicationSettingsSlotResponse{StringDictionary: settings}, nil)
+			return
+		},
+	}, &armappcontainersfake.ContainerAppsServer{})
+
+	r := &rendered{
+		named:  map[string]string{"Api__Url": "https://new", "Db__Password": "s3cret"},
+		secret: map[string]bool{"Db__Password": true},
+	}
+
+	var out bytes.Buffer
+	if err := client.pushAppService(context.Background(), &out, "rg", "api", "staging", r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if updated != nil {
+		t.Fatalf("dry run should not update")
+	}
+	want := "changed api__url: \"https://old\" -> \"https://new\"\nadded   Db__Password: \"(secret)\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := client.pushAppService(context.Background(), &out, "rg", "api", "staging", r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if len(updated) != 3 || *updated["WEBSITE_RUN_FROM_PACKAGE"] != "1" || *updated["api__url"] != "https://new" {
+		t.Fatalf("unexpected app settings: %v", updated)
+	}
+	if !strings.HasSuffix(out.String(), "app settings of api updated\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+}
+
+func TestPushContainerApp(t *testing.T) {
+	var patch *armappcontainers.ContainerApp
+	client := newTestARMClient(t, &armappservicefake.WebAppsServer{}, &armappcontainersfake.ContainerAppsServer{
+		Get: func(_ context.Context, _, _ string, _ *armappcontainers.ContainerAppsClientGetOptions) (resp azfake.Responder[armappcontainers.ContainerAppsClientGetResponse], errResp azfake.ErrorResponder) {
+			resp.SetResponse(http.StatusOK, armappcontainers.ContainerAppsClientGetResponse{ContainerApp: armappcontainers.ContainerApp{
+				Location: to.Ptr("westeurope"),
+				Properties: &armappcontainers.ContainerAppProperties{Template: &armappcontainers.Template{Containers: []*armappcontainers.Container{{
+					Name:  to.Ptr("web"),
+					Image: to.Ptr("api:1"),
+					Env: []*armappcontainers.EnvironmentVar{
+						{Name: to.Ptr("KEEP"), Value: to.Ptr("x")},
+						{Name: to.Ptr("Api__Url"), Value: to.Ptr("https://old")},
+					},
+				}}}},
+			}}, nil)
+			return
+		},
+		ListSecrets: func(_ context.Context, _, _ string, _ *armappcontainers.ContainerAppsClientListSecretsOptions) (resp azfake.Responder[armappcontainers.ContainerAppsClientListSecretsResponse], errResp azfake.ErrorResponder) {
+			resp.SetResponse(http.StatusOK, armappcontainers.ContainerAppsClientListSecretsResponse{SecretsCollection: armappcontainers.SecretsCollection{
+				Value: []*armappcontainers.ContainerAppSecret{{Name: to.Ptr("registry"), Value: to.Ptr("pw")}},
+			}}, nil)
+			return
+		},
+		BeginUpdate: func(_ context.Context, _, _ string, app armappcontainers.ContainerApp, _ *armappcontainers.ContainerAppsClientBeginUpdateOptions) (resp azfake.PollerResponder[armappcontainers.ContainerAppsClientUpdateResponse], errResp azfake.ErrorResponder) {
+			patch = &app
+			resp.SetTerminalResponse(http.StatusOK, armappcontainers.ContainerAppsClientUpdateResponse{ContainerApp: app}, nil)
+			return
+		},
+	})
+
+	r := &rendered{
+		named:  map[string]string{"Api__Url": "https://new", "Db__Password": "s3cret"},
+		secret: map[string]bool{"Db__Password": true},
+	}
+	var out bytes.Buffer
+	if err := client.pushContainerApp(context.Background(), &out, "rg", "api", "", r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+
+	secrets, _ := json.Marshal(patch.Properties.Configuration.Secrets)
+	if string(secrets) != `[{"name":"registry","value":"pw"},{"name":"db--password","value":"s3cret"}]` {
+		t.Fatalf("unexpected secrets: %s", secrets)
+	}
+	container := patch.Properties.Template.Containers[0]
+	env, _ := json.Marshal(container.Env)
+	want := `[{"name":"KEEP","value":"x"},{"name":"Api__Url","value":"https://new"},{"name":"Db__Password","secretRef":"db--password"}]`
+	if *container.Image != "api:1" || string(env) != want {
+		t.Fatalf("unexpected container: %v, env %s", container, env)
+	}
+
+	if err := client.pushContainerApp(context.Background(), &out, "rg", "api", "worker", r, false); err == nil {
+		t.Fatalf("missing container should fail")
+	}
+}
+
+func TestContainerAppSecretName(t *testing.T) {
+	cases := map[string]string{
+		"ConnectionStrings__Db": "connectionstrings--db",
+		"API_KEY":               "api-key",
+		"_x_":                   "x",
+	}
+	for name, want := range cases {
+		if got := containerAppSecretName(name); got != want {
+			t.Fatalf("%s: want %s got %s", name, want, got)
+		}
+	}
+}
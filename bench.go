@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"github.com/dassump/dotnet-appsettings-env/internal/synth"
+)
+
+// benchPhase is the cost of one step of the conversion, per run
+type benchPhase struct {
+	Name        string `json:"name"`
+	NsPerOp     int64  `json:"nsPerOp"`
+	BytesPerOp  uint64 `json:"bytesPerOp"`
+	AllocsPerOp uint64 `json:"allocsPerOp"`
+}
+
+// benchResult is the report of the bench subcommand
+type benchResult struct {
+	Width     int          `json:"width"`
+	Depth     int          `json:"depth"`
+	ArraySize int          `json:"arraySize"`
+	Type      string       `json:"type"`
+	Runs      int          `json:"runs"`
+	Bytes     int          `json:"bytes"`
+	Variables int          `json:"variables"`
+	Phases    []benchPhase `json:"phases"`
+}
+
+// runBench implements the bench subcommand. It generates a configuration of the
+// given shape and reports the time and allocations of parsing, flattening and
+// formatting it, so that performance changes are measurable without a checkout.
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	width := fs.Int("width", 20, "Members of every object of the generated configuration")
+	depth := fs.Int("depth", 4, "Levels of nested objects of the generated configuration")
+	arraySize := fs.Int("array-size", 10, "Elements of every array of the generated configuration")
+	outType := fs.String("type", "k8s", "Output type formatted: "+strings.Join(appsettingsenv.Types(), "|"))
+	runs := fs.Int("runs", 10, "Runs of every phase, reported as the average")
+	outFormat := fs.String("format", "text", "Report format: text|json")
+	save := fs.String("save", "", "Also write the generated configuration to this file, e.g. to time convert with it")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s bench [flags]:\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	outFmt := strings.ToLower(strings.TrimSpace(*outFormat))
+	if outFmt != "text" && outFmt != "json" {
+		errorf("invalid bench format: %q", *outFormat)
+		return exitUsage
+	}
+	if *width < 0 || *depth < 1 || *arraySize < 0 || *runs < 1 {
+		errorf("-width and -array-size cannot be negative, -depth and -runs must be at least 1")
+		return exitUsage
+	}
+	info, ok := appsettingsenv.Lookup(*outType)
+	if !ok {
+		errorf("invalid output type: %q", *outType)
+		return exitUsage
+	}
+
+	shape := synth.Options{Width: *width, Depth: *depth, ArraySize: *arraySize}
+	data := synth.Generate(shape)
+	if *save != "" {
+		if err := writeFileAtomic(*save, data, 0o644); err != nil {
+			logError(ioError(err))
+			return exitIO
+		}
+	}
+
+	result, err := bench(data, shape, *outType, info.Separator, *runs)
+	if err != nil {
+		logError(err)
+		return exitFailure
+	}
+	if err := writeBench(os.Stdout, result, outFmt); err != nil {
+		logError(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// bench times every phase of the conversion of data on its own, runs times
+func bench(data []byte, shape synth.Options, outType, sep string, runs int) (*benchResult, error) {
+	result := &benchResult{Width: shape.Width, Depth: shape.Depth, ArraySize: shape.ArraySize, Type: outType, Runs: runs, Bytes: len(data)}
+
+	var doc map[string]any
+	var vars map[string]string
+	var list []appsettingsenv.KV
+	phases := []struct {
+		name string
+		run  func() error
+	}{
+		{"parse", func() (err error) {
+			doc, err = appsettingsenv.Parse(data)
+			return err
+		}},
+		{"flatten", func() error {
+			vars = appsettingsenv.Flatten(doc, appsettingsenv.FlattenOptions{Separator: sep})
+			return nil
+		}},
+		{"sort", func() error {
+			list = appsettingsenv.Sorted(vars)
+			return nil
+		}},
+		{"format", func() error {
+			return appsettingsenv.Format(io.Discard, list, appsettingsenv.FormatOptions{Type: outType})
+		}},
+	}
+
+	for _, p := range phases {
+		phase, err := measure(p.name, runs, p.run)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		result.Phases = append(result.Phases, phase)
+	}
+	result.Variables = len(vars)
+	return result, nil
+}
+
+// measure runs f runs times and returns its average time and allocations
+func measure(name string, runs int, f func() error) (benchPhase, error) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for range runs {
+		if err := f(); err != nil {
+			return benchPhase{}, err
+		}
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	n := uint64(runs)
+	return benchPhase{
+		Name:        name,
+		NsPerOp:     elapsed.Nanoseconds() / int64(runs),
+		BytesPerOp:  (after.TotalAlloc - before.TotalAlloc) / n,
+		AllocsPerOp: (after.Mallocs - before.Mallocs) / n,
+	}, nil
+}
+
+// writeBench writes the report as a table, or as JSON for tracking it over time
+func writeBench(w io.Writer, r *benchResult, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "configuration: %d bytes, %d variables (width %d, depth %d, arrays of %d)\n", r.Bytes, r.Variables, r.Width, r.Depth, r.ArraySize)
+	fmt.Fprintf(tw, "output: %s, average of %d run(s)\n", r.Type, r.Runs)
+	fmt.Fprintln(tw, "  phase\ttime/op\tbytes/op\tallocs/op")
+	var total benchPhase
+	for _, p := range r.Phases {
+		fmt.Fprintf(tw, "  %s\t%s\t%d\t%d\n", p.Name, time.Duration(p.NsPerOp).Round(time.Microsecond), p.BytesPerOp, p.AllocsPerOp)
+		total.NsPerOp += p.NsPerOp
+		total.BytesPerOp += p.BytesPerOp
+		total.AllocsPerOp += p.AllocsPerOp
+	}
+	fmt.Fprintf(tw, "  total\t%s\t%d\t%d\n", time.Duration(total.NsPerOp).Round(time.Microsecond), total.BytesPerOp, total.AllocsPerOp)
+	return tw.Flush()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/internal/synth"
+)
+
+func TestBench(t *testing.T) {
+	shape := synth.Options{Width: 5, Depth: 2, ArraySize: 2}
+	r, err := bench(synth.Generate(shape), shape, "docker", "__", 2)
+	if err != nil {
+		t.Fatalf("bench failed: %v", err)
+	}
+	if r.Variables != 15 || len(r.Phases) != 4 || r.Phases[0].Name != "parse" || r.Phases[0].AllocsPerOp == 0 {
+		t.Fatalf("unexpected result: %+v", r)
+	}
+
+	var text bytes.Buffer
+	if err := writeBench(&text, r, "text"); err != nil {
+		t.Fatalf("writeBench failed: %v", err)
+	}
+	for _, want := range []string{"15 variables (width 5, depth 2, arrays of 2)", "output: docker, average of 2 run(s)", "  format ", "  total "} {
+		if !strings.Contains(text.String(), want) {
+			t.Fatalf("report without %q:\n%s", want, text.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeBench(&out, r, "json"); err != nil {
+		t.Fatalf("writeBench failed: %v", err)
+	}
+	var decoded benchResult
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil || decoded.Phases[3].Name != "format" {
+		t.Fatalf("unexpected JSON report: %v\n%s", err, out.String())
+	}
+
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+	if code := runBench([]string{"-depth", "0"}); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+}
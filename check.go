@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// checkOutput compares a rendered output with its existing -out and -secret-out files
+// and prints a diff for each that differs. It returns the number of stale files.
+func checkOutput(w io.Writer, r *rendered) (int, error) {
+	paths := []string{outputPath(*outPath, r.outType)}
+	contents := [][]byte{r.output}
+	if r.secrets != nil {
+		paths = append(paths, outputPath(*secretOut, r.outType))
+		contents = append(contents, r.secrets)
+	}
+
+	stale := 0
+	for i, path := range paths {
+		ok, err := checkFile(w, path, contents[i])
+		if err != nil {
+			return stale, err
+		}
+		if !ok {
+			stale++
+		}
+	}
+	return stale, nil
+}
+
+// checkFile compares path with data, printing a line diff when they differ. A missing
+// file differs from any output.
+func checkFile(w io.Writer, path string, data []byte) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, ioError(err)
+	}
+	if err == nil && bytes.Equal(existing, data) {
+		debug("output up to date", "file", path)
+		return true, nil
+	}
+
+	color := useColor(os.Stdout)
+	fmt.Fprintf(w, "--- %s\n+++ %s (generated)\n", path, path)
+	for _, l := range lineDiff(splitLines(existing), splitLines(data)) {
+		switch l[0] {
+		case '-':
+			fmt.Fprintln(w, colorize(color, colorRed, l))
+		case '+':
+			fmt.Fprintln(w, colorize(color, colorGreen, l))
+		}
+	}
+	return false, nil
+}
+
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.SplitAfter(string(data), "\n")
+}
+
+// lineDiff returns the removed ("-") and added ("+") lines turning a into b, in order,
+// from their longest common subsequence. Lines keep no trailing newline.
+func lineDiff(a, b []string) []string {
+	// lcs[i][j] is the common subsequence length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "-"+strings.TrimSuffix(a[i], "\n"))
+			i++
+		default:
+			out = append(out, "+"+strings.TrimSuffix(b[j], "\n"))
+			j++
+		}
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLineDiff(t *testing.T) {
+	a := splitLines([]byte("A=1\nB=2\nC=3\n"))
+	b := splitLines([]byte("A=1\nB=4\nC=3\nD=5\n"))
+
+	got := strings.Join(lineDiff(a, b), "|")
+	if want := "-B=2|+B=4|+D=5"; got != want {
+		t.Fatalf("want %q got %q", want, got)
+	}
+	if d := lineDiff(a, a); len(d) != 0 {
+		t.Fatalf("equal inputs should not differ: %v", d)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "env.txt")
+	if err := os.WriteFile(path, []byte("A=1\n"), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	var buf bytes.Buffer
+	ok, err := checkFile(&buf, path, []byte("A=1\n"))
+	if err != nil || !ok || buf.Len() != 0 {
+		t.Fatalf("identical file should be up to date: %v %v %q", ok, err, buf.String())
+	}
+
+	ok, err = checkFile(&buf, path, []byte("A=2\n"))
+	if err != nil || ok || !strings.Contains(buf.String(), "-A=1\n+A=2\n") {
+		t.Fatalf("changed file should differ: %v %v %q", ok, err, buf.String())
+	}
+
+	buf.Reset()
+	ok, err = checkFile(&buf, filepath.Join(filepath.Dir(path), "missing.txt"), []byte("A=1\n"))
+	if err != nil || ok || !strings.Contains(buf.String(), "+A=1\n") {
+		t.Fatalf("missing file should differ: %v %v %q", ok, err, buf.String())
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import "os"
+
+// ANSI colors of the diagnostics and diffs
+const (
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColor reports whether output to f is colored: f is a terminal, and neither
+// -no-color, NO_COLOR (https://no-color.org) nor TERM=dumb turn colors off.
+func useColor(f *os.File) bool {
+	if *noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in color when enabled
+func colorize(enabled bool, color, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	defer f.Close()
+
+	if useColor(f) {
+		t.Fatalf("regular files should not be colored")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if useColor(os.Stdout) {
+		t.Fatalf("NO_COLOR should disable colors")
+	}
+}
+
+func TestWriteDiffColor(t *testing.T) {
+	d := diffVariables(map[string]string{"A": "1", "B": "2"}, map[string]string{"B": "3", "C": "4"})
+
+	var buf bytes.Buffer
+	if err := writeDiff(&buf, d, "text", "a", "b", true); err != nil {
+		t.Fatalf("writeDiff failed: %v", err)
+	}
+	want := colorRed + "removed" + colorReset + " A: \"1\"\n" +
+		colorYellow + "changed" + colorReset + " B: \"2\" -> \"3\"\n" +
+		colorGreen + "added" + colorReset + "   C: \"4\"\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected colored diff:\n%q", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeDiff(&buf, d, "text", "a", "b", false); err != nil {
+		t.Fatalf("writeDiff failed: %v", err)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Fatalf("uncolored diff contains escape sequences: %q", buf.String())
+	}
+}
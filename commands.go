@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of the CLI
+type command struct {
+	name string
+	// args is the argument synopsis shown in the usage
+	args    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands. Arguments not starting with a command name are
+// the flags of convert, the default command.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"convert", "[flags]", "Convert appsettings files to the -type outputs (default command)", runConvert},
+		{"validate", "[flags]", "Run every check of convert without writing any output", runValidate},
+		{"verify", "[flags]", "Check that the variables read back by .NET reproduce the configuration", runVerify},
+		{"diff", "[flags] <a> <b>", "Compare two configurations", runDiff},
+		{"explain", "[flags] <key>", "Show the value of a key in every layer and which one wins", runExplain},
+		{"drift", "[flags]", "Compare the configuration with a Kubernetes workload", runDrift},
+		{"apply", "[flags]", "Create or replace the ConfigMap and Secret in a Kubernetes cluster", runApply},
+		{"push", "<target> [flags]", "Write the configuration directly to a cluster or service", runPush},
+		{"inject", "<target> [flags]", "Write the variables into an existing compose file, manifest or template", runInject},
+		{"gen", "<target> [flags]", "Generate C# options classes and other artifacts from the configuration", runGen},
+		{"mcp", "", "Serve convert, diff and explain as Model Context Protocol tools on stdio", runMCP},
+		{"bench", "[flags]", "Time parsing, flattening and formatting a generated configuration", runBench},
+		{"docs", "", "Print a Markdown reference of the commands and conversion flags", runDocs},
+		{"help", "[command]", "Show the usage of a command", runHelp},
+	}
+}
+
+// lookupCommand returns the command called name
+func lookupCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// usage prints the main usage: the commands, then the conversion flags
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "%s (%s)\n\n%s\n%s\n\n", app, version, description, site)
+	fmt.Fprintf(w, "Usage:\n  %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nFlags of convert (see %s help <command> for the others):\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+// runHelp implements the help subcommand
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		usage()
+		return exitOK
+	}
+
+	c, ok := lookupCommand(args[0])
+	if !ok {
+		errorf("unknown command: %q", args[0])
+		return exitUsage
+	}
+	if c.name == "help" || c.name == "convert" {
+		usage()
+		return exitOK
+	}
+	return c.run([]string{"-h"})
+}
+
+// runDocs implements the docs subcommand
+func runDocs(args []string) int {
+	if len(args) > 0 {
+		errorf("Usage of %s docs: no arguments expected", os.Args[0])
+		return exitUsage
+	}
+	if err := writeDocs(os.Stdout, flag.CommandLine); err != nil {
+		logError(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// writeDocs writes the Markdown reference of the commands and of the flags of fs
+func writeDocs(w io.Writer, fs *flag.FlagSet) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%s\n\n## Commands\n\n", app, description)
+	b.WriteString("| Command | Description |\n|---------|-------------|\n")
+	for _, c := range commands {
+		synopsis := strings.TrimSpace(c.name + " " + c.args)
+		fmt.Fprintf(&b, "| `%s` | %s |\n", synopsis, c.summary)
+	}
+
+	b.WriteString("\n## Conversion flags\n\n| Flag | Default | Description |\n|------|---------|-------------|\n")
+	fs.VisitAll(func(f *flag.Flag) {
+		def := ""
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+			def = "`" + f.DefValue + "`"
+		}
+		usage := strings.ReplaceAll(f.Usage, "|", "\\|")
+		fmt.Fprintf(&b, "| `-%s` | %s | %s |\n", f.Name, def, usage)
+	})
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestLookupCommand(t *testing.T) {
+	for _, name := range []string{"convert", "diff", "explain", "drift", "docs", "help"} {
+		if _, ok := lookupCommand(name); !ok {
+			t.Fatalf("command %q not found", name)
+		}
+	}
+	if _, ok := lookupCommand("-type"); ok {
+		t.Fatalf("flags are not commands")
+	}
+}
+
+func TestWriteDocs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("case", "preserve", "Variable name casing: preserve|upper")
+	fs.Bool("redact", false, "Mask secret values")
+
+	var buf bytes.Buffer
+	if err := writeDocs(&buf, fs); err != nil {
+		t.Fatalf("writeDocs failed: %v", err)
+	}
+
+	for _, want := range []string{
+		"| `diff [flags] <a> <b>` | Compare two configurations |",
+		"| `-case` | `preserve` | Variable name casing: preserve\\|upper |",
+		"| `-redact` |  | Mask secret values |",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("missing %q in:\n%s", want, buf.String())
+		}
+	}
+}
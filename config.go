@@ -0,0 +1,187 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the tool configuration file, looked up from the working
+// directory upwards
+const configFileName = ".appsettings-env.yaml"
+
+// toolConfig is the content of the tool configuration file. Its settings are the
+// defaults of the matching command line flags.
+type toolConfig struct {
+	File       string   `yaml:"file"`
+	Env        string   `yaml:"env"`
+	Type       yamlList `yaml:"type"`
+	Separator  string   `yaml:"separator"`
+	Out        string   `yaml:"out"`
+	SecretKeys yamlList `yaml:"secret-keys"`
+	SecretOut  string   `yaml:"secret-out"`
+	Include    yamlList `yaml:"include"`
+	Exclude    yamlList `yaml:"exclude"`
+	Preset     yamlList `yaml:"preset"`
+	// Flags holds defaults for any other flag, by flag name
+	Flags map[string]string `yaml:"flags"`
+
+	// Presets defines custom -preset names as lists of sections to exclude
+	Presets map[string][]string `yaml:"presets"`
+}
+
+// yamlList is a list of strings that may also be written as a single string
+type yamlList []string
+
+func (l *yamlList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = yamlList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// projectConfig is the configuration applied by applyToolConfig, read from projectConfigPath
+var (
+	projectConfig     = &toolConfig{}
+	projectConfigPath string
+)
+
+// loadToolConfig reads the tool configuration file. A missing file is an empty configuration.
+func loadToolConfig(path string) (*toolConfig, error) {
+	cfg := &toolConfig{}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// findToolConfig returns the configuration file of dir or of its closest parent
+func findToolConfig(dir string) (string, bool) {
+	for {
+		path := filepath.Join(dir, configFileName)
+		if fileExists(path) {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// runsProgram reports whether the flag name set to value runs an external program
+func runsProgram(name, value string) bool {
+	return name == "transform" && strings.HasPrefix(value, "cmd:")
+}
+
+// applyToolConfig loads the -config file, or the one discovered from the working
+// directory, and sets the flags not given on the command line to its values.
+// Relative paths of the file are relative to its directory. A discovered file may
+// come with a cloned repository, so it cannot set flags that run programs.
+func applyToolConfig(fs *flag.FlagSet) error {
+	path := *configPath
+	discovered := path == ""
+	if discovered {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if path, ok = findToolConfig(wd); !ok {
+			return nil
+		}
+	} else if !fileExists(path) {
+		return fmt.Errorf("config file not found: %s", path)
+	}
+
+	cfg, err := loadToolConfig(path)
+	if err != nil {
+		return err
+	}
+	projectConfig, projectConfigPath = cfg, path
+
+	dir := filepath.Dir(path)
+	relative := func(p string) string {
+		if p == "" || filepath.IsAbs(p) || strings.HasPrefix(p, "@") {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	apply := func(name string, values ...string) error {
+		if set[name] || fs.Lookup(name) == nil {
+			return nil
+		}
+		for _, v := range values {
+			if v == "" {
+				continue
+			}
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s: invalid %s: %w", path, name, err)
+			}
+		}
+		return nil
+	}
+
+	// Flags of other commands are ignored
+	for name, value := range cfg.Flags {
+		if discovered && runsProgram(name, value) {
+			return fmt.Errorf("%s: %s %q runs a program, which only a file given with -config may set", path, name, value)
+		}
+		if err := apply(name, value); err != nil {
+			return err
+		}
+	}
+
+	secretKeys := make([]string, len(cfg.SecretKeys))
+	for i, k := range cfg.SecretKeys {
+		if file, ok := strings.CutPrefix(k, "@"); ok && !filepath.IsAbs(file) {
+			k = "@" + filepath.Join(dir, file)
+		}
+		secretKeys[i] = k
+	}
+
+	for _, a := range []struct {
+		name   string
+		values []string
+	}{
+		{"file", []string{relative(cfg.File)}},
+		{"env", []string{cfg.Env}},
+		{"type", cfg.Type},
+		{"separator", []string{cfg.Separator}},
+		{"out", []string{relative(cfg.Out)}},
+		{"secret-keys", secretKeys},
+		{"secret-out", []string{relative(cfg.SecretOut)}},
+		{"include", cfg.Include},
+		{"exclude", cfg.Exclude},
+		{"preset", cfg.Preset},
+	} {
+		if err := apply(a.name, a.values...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyToolConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFileName)
+	content := `file: src/appsettings.json
+type: k8s,compose
+secret-keys:
+  - "Db:*"
+  - "@secret-keys.txt"
+flags:
+  case: upper
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(p string, cfg *toolConfig) { *configPath, projectConfig = p, cfg }(*configPath, projectConfig)
+	*configPath = path
+
+	var types typeList
+	var keys stringList
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	file := fs.String("file", "./appsettings.json", "")
+	keyCase := fs.String("case", "preserve", "")
+	fs.Var(&types, "type", "")
+	fs.Var(&keys, "secret-keys", "")
+	if err := fs.Parse([]string{"-case", "lower"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyToolConfig(fs); err != nil {
+		t.Fatalf("applyToolConfig failed: %v", err)
+	}
+	if *file != filepath.Join(dir, "src", "appsettings.json") {
+		t.Fatalf("file should be relative to the config file: %s", *file)
+	}
+	if *keyCase != "lower" {
+		t.Fatalf("command line flags must win over the config file: %s", *keyCase)
+	}
+	if types.String() != "k8s,compose" {
+		t.Fatalf("unexpected types: %v", types.types)
+	}
+	if len(keys) != 2 || keys[1] != "@"+filepath.Join(dir, "secret-keys.txt") {
+		t.Fatalf("unexpected secret keys: %v", keys)
+	}
+}
+
+func TestApplyToolConfig_Programs(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFileName)
+	if err := os.WriteFile(path, []byte("flags:\n  transform: \"cmd:touch pwned\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	defer func(p string, cfg *toolConfig) { *configPath, projectConfig = p, cfg }(*configPath, projectConfig)
+
+	newFlags := func() (*flag.FlagSet, *stringList) {
+		var transforms stringList
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(&transforms, "transform", "")
+		return fs, &transforms
+	}
+
+	// A file found in the working directory cannot run programs
+	*configPath = ""
+	fs, transforms := newFlags()
+	if err := applyToolConfig(fs); err == nil || len(*transforms) != 0 {
+		t.Fatalf("a discovered file should not set a cmd: transform: %v %v", *transforms, err)
+	}
+
+	// The file given with -config is trusted
+	*configPath = path
+	fs, transforms = newFlags()
+	if err := applyToolConfig(fs); err != nil || len(*transforms) != 1 {
+		t.Fatalf("an explicit file should set a cmd: transform: %v %v", *transforms, err)
+	}
+}
+
+func TestFindToolConfig(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, configFileName), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, ok := findToolConfig(nested)
+	if !ok || path != filepath.Join(dir, configFileName) {
+		t.Fatalf("config not found upwards: %q %v", path, ok)
+	}
+}
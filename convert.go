@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// typeList is the -type flag value: comma-separated and repeatable, the first
+// occurrence replacing the default
+type typeList struct {
+	types []string
+	set   bool
+}
+
+func (l *typeList) String() string {
+	return strings.Join(l.types, ",")
+}
+
+func (l *typeList) Set(value string) error {
+	if !l.set {
+		l.types, l.set = nil, true
+	}
+	for _, t := range strings.Split(value, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			l.types = append(l.types, t)
+		}
+	}
+	return nil
+}
+
+// first returns the first output type, or "" when none is given
+func (l *typeList) first() string {
+	if len(l.types) == 0 {
+		return ""
+	}
+	return l.types[0]
+}
+
+// converter renders merged variables in one output type
+type converter struct {
+	outType string
+	format  appsettingsenv.TypeInfo
+	sep     string
+
+	filter       *keyFilter
+	redactFilter *keyFilter
+	// secretFilter selects the -secret-keys, nil without patterns
+	secretFilter *keyFilter
+	// secrets is set when -secret-keys splits the output
+	secrets bool
+	// encryptFilter selects the values encrypted for the -age-recipient recipients
+	encryptFilter *keyFilter
+	recipients    []age.Recipient
+}
+
+// rendered is the output of one converter
+type rendered struct {
+	outType string
+	// named holds the emitted variables by output name
+	named map[string]string
+	// secret marks the names matched by -secret-keys
+	secret map[string]bool
+	// encrypted marks the names whose value is encrypted for -age-recipient
+	encrypted map[string]bool
+	// report lists what the converter did with the keys, for -report
+	report  []reportEntry
+	output  []byte
+	secrets []byte
+}
+
+// newConverter validates the output type and compiles the patterns for its separator
+func newConverter(outType string, secretPatterns []string) (*converter, error) {
+	f, ok := appsettingsenv.Lookup(outType)
+	if !ok {
+		return nil, fmt.Errorf("invalid output type: %q", outType)
+	}
+
+	sep, err := keySeparator(outType)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detected secrets alone split the output when -secret-out receives them
+	secrets := len(secretPatterns) > 0 || *detectSecrets == "classify" && *secretOut != ""
+	c := &converter{outType: outType, format: f, sep: sep, secrets: secrets}
+	if c.filter, err = outputFilter(sep); err != nil {
+		return nil, err
+	}
+	if len(secretPatterns) > 0 {
+		if c.secretFilter, err = newKeyFilter(secretPatterns, nil, sep); err != nil {
+			return nil, err
+		}
+	}
+	if c.redactFilter, err = newRedactFilter(*redact, redactKeys, sep); err != nil {
+		return nil, err
+	}
+	// Encryption selects the -secret-keys, or else the keys -redact would mask
+	switch {
+	case len(ageRecipients) == 0:
+	case c.secretFilter != nil:
+		c.encryptFilter = c.secretFilter
+	default:
+		if c.encryptFilter, err = newRedactFilter(true, redactKeys, sep); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// render names, checks and formats variables, keyed by canonical ":" keys
+func (c *converter) render(variables map[string]string) (*rendered, error) {
+	sep := c.sep
+	if *serilogMode == "json" {
+		variables = serilogJSON(variables)
+	}
+
+	// Values still holding the JSON literal of a file stay unquoted in typed outputs
+	literal := make(map[string]bool)
+	if *typed && c.format.Typed {
+		for k, v := range variables {
+			if isLiteral(k, v) {
+				literal[strings.ReplaceAll(k, keySep, sep)] = true
+			}
+		}
+	}
+
+	// -sort source ranks the keys as the configuration files list them
+	rank := make(map[string]int)
+	if *sortMode == "source" {
+		for k := range variables {
+			if r, ok := sourceRank(k); ok {
+				rank[strings.ReplaceAll(k, keySep, sep)] = r
+			}
+		}
+	}
+
+	// Host variables and Kestrel checks look at the whole configuration, before filtering
+	host := make(map[string]string)
+	if *hostVars {
+		host = hostVariables(variables, *environment)
+	}
+	validateKestrel(c.outType, variables)
+	if *kestrelURLs && envTargets[c.outType] {
+		if translated, urls, ok := translateEndpoints(variables); ok {
+			variables, host["ASPNETCORE_URLS"] = translated, urls
+		}
+	}
+
+	variables, err := checkSeparatorKeys(variables, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	// Secret patterns, like filters, match names before casing and prefix are applied
+	variables = withSeparator(variables, sep)
+	var report []reportEntry
+	if *reportPath != "" {
+		for _, k := range appsettingsenv.SortedKeys(variables) {
+			if !c.filter.match(k) {
+				report = append(report, reportEntry{Key: strings.ReplaceAll(k, sep, keySep), Action: reportExcluded, Reason: c.filter.reason(k)})
+			}
+		}
+	}
+	variables = c.filter.apply(variables)
+	var flags map[string]string
+	if *featureFlagsOut && c.outType == "appconfig" {
+		flags, variables = featureFlags(variables, sep)
+	}
+	if *serilogMode == "check" {
+		for _, p := range serilogProblems(variables, sep) {
+			warnf("%s", p)
+		}
+	}
+	if *failOnEmpty && len(variables) == 0 {
+		return nil, validationError(fmt.Errorf("no variables left for %s output (-fail-on-empty)", c.outType))
+	}
+	named := make(map[string]string, len(variables))
+	secret := make(map[string]bool)
+	encryptedName := make(map[string]bool)
+	typedName := make(map[string]bool)
+	nameRank := make(map[string]int)
+	renamed := make(map[string]string)
+	for _, k := range appsettingsenv.SortedKeys(variables) {
+		v := formatBool(variables[k], *boolFormat)
+		if *stripNewlines {
+			v = removeNewlines(v)
+		}
+		typedValue := literal[k]
+		// Values that look like credentials count as secret keys with -detect-secrets classify
+		suspect := ""
+		if *detectSecrets != "" {
+			suspect = secretValueKind(v)
+		}
+		classified := suspect != "" && *detectSecrets == "classify"
+		isSecret := c.secrets && (c.secretFilter != nil && c.secretFilter.match(k) || classified)
+		// Key Vault references hold no secret and are resolved by the platform
+		redacted := c.redactFilter != nil && (c.redactFilter.match(k) || classified) && !isKeyVaultRef(v)
+		encrypted := c.encryptFilter != nil && (c.encryptFilter.match(k) || classified) && !redacted && !isKeyVaultRef(v)
+		switch {
+		case redacted:
+			v = *redactWith
+			typedValue = false
+		case encrypted:
+			if v, err = encryptValue(v, c.recipients); err != nil {
+				return nil, fmt.Errorf("encrypt %s: %w", k, err)
+			}
+			typedValue = false
+		}
+		if *reportPath != "" {
+			if redacted {
+				report = append(report, newReportEntry(k, sep, reportRedacted, c.redactFilter, suspect))
+			}
+			if encrypted {
+				report = append(report, newReportEntry(k, sep, reportEncrypted, c.encryptFilter, suspect))
+			}
+			if isSecret {
+				report = append(report, newReportEntry(k, sep, reportSecret, c.secretFilter, suspect))
+			}
+		}
+		if suspect != "" && !redacted && !isSecret && !encrypted {
+			warnf("%s: value looks like %s and is written in plain text to %s output (see -detect-secrets)", k, suspect, c.outType)
+		}
+
+		for _, name := range notationNames(k, sep) {
+			name = checkName(c.outType, name, renamed)
+			if _, ok := named[name]; ok {
+				warnf("%s: several keys produce this variable name, only the last one is kept", name)
+			}
+			typedName[name] = typedValue
+			if r, ok := rank[k]; ok {
+				nameRank[name] = r
+			}
+			named[name] = v
+			secret[name] = isSecret
+			encryptedName[name] = encrypted
+		}
+	}
+	writeRenamed(os.Stderr, renamed)
+
+	// Environment variables are case-insensitive on Windows and in .NET configuration
+	for _, names := range caseCollisions(named) {
+		warnf("%s differ only by case and collide where names are case-insensitive", strings.Join(names, ", "))
+	}
+	for _, name := range reservedCollisions(c.outType, named) {
+		warnf("%s overwrites a reserved host or runtime variable", name)
+	}
+	if envTargets[c.outType] {
+		for _, name := range appsettingsenv.SortedKeys(host) {
+			if _, ok := named[name]; ok {
+				warnf("%s is set by the configuration, the generated host variable is not emitted", name)
+				continue
+			}
+			named[name] = host[name]
+		}
+	}
+
+	for name, v := range flags {
+		named[name] = v
+	}
+
+	list := appsettingsenv.Sorted(named)
+	if *sortMode == "source" {
+		slices.SortStableFunc(list, bySource(nameRank))
+	}
+	for i := range list {
+		list[i].Secret = secret[list[i].Name]
+		list[i].Literal = typedName[list[i].Name]
+	}
+	validateValues(c.outType, list)
+	validateManifestSizes(c.outType, list, c.secrets)
+	validateEnvSize(c.outType, list, *envBudget)
+	debug("variables emitted", "type", c.outType, "count", len(list))
+
+	r := &rendered{outType: c.outType, named: named, secret: secret, encrypted: encryptedName, report: report}
+	var out, secrets bytes.Buffer
+	if c.secrets {
+		err = appsettingsenv.FormatSplit(&out, &secrets, list, formatOptions(c.outType))
+		r.secrets = secrets.Bytes()
+	} else {
+		err = appsettingsenv.Format(&out, list, formatOptions(c.outType))
+	}
+	r.output = out.Bytes()
+	return r, err
+}
+
+// bySource orders entries by their rank in the files, the entries without one, such
+// as overrides and overlaid environment variables, last in name order
+func bySource(rank map[string]int) func(a, b appsettingsenv.KV) int {
+	return func(a, b appsettingsenv.KV) int {
+		ra, aok := rank[a.Name]
+		rb, bok := rank[b.Name]
+		switch {
+		case aok && bok:
+			return cmp.Compare(ra, rb)
+		case aok:
+			return -1
+		case bok:
+			return 1
+		}
+		return 0
+	}
+}
+
+// conversion is a prepared conversion: a converter per output type and the inputs
+// shared by all of them
+type conversion struct {
+	converters []*converter
+	setVars    map[string]string
+	transforms []transform
+	// schema validates the configuration when -schema is set
+	schema *jsonschema.Schema
+
+	// summary holds the statistics of the last render
+	summary *summary
+	// variables holds the configuration of the last render, as given to the
+	// converters
+	variables map[string]string
+}
+
+// render loads the configuration once and renders it in every output type. Warnings,
+// recorded literals and the source order are reset, so that it can run again in
+// watch mode.
+func (cv *conversion) render() ([]*rendered, error) {
+	warnings = 0
+	clear(literals)
+	clear(sourceOrder)
+
+	layers, err := configurationLayers(cv.setVars)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := expandValues(mergeLayers(layers))
+	if err != nil {
+		return nil, err
+	}
+	if cv.schema != nil {
+		if err := validateSchema(cv.schema, layers, variables); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keep only what the layers changed relative to the plain files
+	if *onlyOverrides {
+		base, err := loadFiles(*file, "", keySep)
+		if err != nil {
+			return nil, err
+		}
+		if base, err = expandValues(base); err != nil {
+			return nil, err
+		}
+		variables = overriddenVariables(base, variables)
+	}
+
+	if variables, err = applyTransforms(variables, cv.transforms); err != nil {
+		return nil, err
+	}
+
+	cv.variables = variables
+
+	sources := make([]string, 0, len(layers))
+	for _, l := range layers {
+		sources = append(sources, l.source)
+	}
+
+	// Every output type is rendered from the same parse before anything is written
+	outputs := make([]*rendered, 0, len(cv.converters))
+	for _, c := range cv.converters {
+		r, err := c.render(variables)
+		if err != nil {
+			return nil, err
+		}
+		if !*noHeader {
+			// Hashing the secrets would help guessing weak ones
+			r.output = withHeader(r.output, c.format.Comment, sources, true)
+			if r.secrets != nil {
+				r.secrets = withHeader(r.secrets, c.format.Comment, sources, false)
+			}
+		}
+		if *newline == "crlf" {
+			r.output = crlf(r.output)
+			if r.secrets != nil {
+				r.secrets = crlf(r.secrets)
+			}
+		}
+		outputs = append(outputs, r)
+	}
+
+	if cv.summary, err = newSummary(layers, variables, cv.converters[0], outputs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// convert renders the configuration and writes it in every output type
+func convert(cv *conversion) error {
+	outputs, err := cv.render()
+	if err != nil {
+		return err
+	}
+
+	if refs := keyVaultRefs(outputs[0].named); *keyVaultSummary && len(refs) > 0 {
+		writeKeyVaultSummary(os.Stderr, refs)
+	}
+
+	if *summaryFormat != "" {
+		cv.summary.write(os.Stderr, *summaryFormat)
+	}
+
+	if *reportPath != "" {
+		if err := writeReport(*reportPath, outputs); err != nil {
+			return err
+		}
+	}
+
+	if *strict && warnings > 0 {
+		return validationError(fmt.Errorf("%d warning(s) treated as errors (-strict)", warnings))
+	}
+
+	if *check {
+		stale := 0
+		for _, r := range outputs {
+			n, err := checkOutput(os.Stdout, r)
+			if err != nil {
+				return err
+			}
+			stale += n
+		}
+		if stale > 0 {
+			return &exitError{code: exitFailure, err: fmt.Errorf("%d output file(s) out of date, run without -check to update them", stale)}
+		}
+		return nil
+	}
+
+	// Print using requested format, or replace the -out files once complete
+	for _, r := range outputs {
+		if err := writeOutput(r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
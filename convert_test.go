@@ -0,0 +1,112 @@
+package main
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestTypeList(t *testing.T) {
+	l := typeList{types: []string{"k8s"}}
+	if err := l.Set("Compose, bicep"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Set("docker"); err != nil {
+		t.Fatal(err)
+	}
+	if l.String() != "compose,bicep,docker" {
+		t.Fatalf("the first -type should replace the default: %v", l.types)
+	}
+}
+
+func TestConverterRender(t *testing.T) {
+	vars := map[string]string{"Api:Url": "http://api", "Db:Password": "s3cret"}
+
+	want := map[string]string{
+		"docker":       "Api__Url=\"http://api\"\nDb__Password=\"s3cret\"\n",
+		"user-secrets": "{\n  \"Api:Url\": \"http://api\",\n  \"Db:Password\": \"s3cret\"\n}\n",
+	}
+	for outType, output := range want {
+		c, err := newConverter(outType, nil)
+		if err != nil {
+			t.Fatalf("newConverter(%s) failed: %v", outType, err)
+		}
+		r, err := c.render(vars)
+		if err != nil {
+			t.Fatalf("render(%s) failed: %v", outType, err)
+		}
+		if string(r.output) != output || r.secrets != nil {
+			t.Fatalf("%s: unexpected output:\n%s", outType, r.output)
+		}
+	}
+
+	c, err := newConverter("compose", []string{"Db:*"})
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	r, err := c.render(vars)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if strings.Contains(string(r.output), "s3cret") || string(r.secrets) != "Db__Password: \"s3cret\"\n" {
+		t.Fatalf("unexpected split:\n%s\n%s", r.output, r.secrets)
+	}
+
+	if _, err := newConverter("yaml", nil); err == nil {
+		t.Fatalf("expected invalid output type error")
+	}
+}
+
+func TestConverterRender_SortSource(t *testing.T) {
+	defer func(m string) { *sortMode = m }(*sortMode)
+	defer clear(sourceOrder)
+	*sortMode = "source"
+	for _, k := range []string{"Zeta", "Alpha:B", "Alpha:A"} {
+		recordSource(k)
+	}
+
+	c, err := newConverter("docker", nil)
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	r, err := c.render(map[string]string{"Alpha:A": "1", "zeta": "2", "Alpha:B": "3", "Extra": "4", "Added": "5"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	// Keys the files do not hold come last, by name
+	if want := "zeta=\"2\"\nAlpha__B=\"3\"\nAlpha__A=\"1\"\nAdded=\"5\"\nExtra=\"4\"\n"; string(r.output) != want {
+		t.Fatalf("unexpected output:\n%s", r.output)
+	}
+}
+
+func TestConverterRender_FailOnEmpty(t *testing.T) {
+	defer func(b bool) { *failOnEmpty = b }(*failOnEmpty)
+	defer func(e []string) { excludes = e }(excludes)
+	*failOnEmpty, excludes = true, []string{"Api:*"}
+
+	c, err := newConverter("docker", nil)
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	if _, err := c.render(map[string]string{"Api:Url": "http://api"}); exitCode(err, exitFailure) != exitValidation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestConverterRender_DualNotation(t *testing.T) {
+	defer func(b bool) { *dualNotation = b }(*dualNotation)
+	*dualNotation = true
+
+	c, err := newConverter("user-secrets", []string{"Db:*"})
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	r, err := c.render(map[string]string{"Api:Url": "http://api", "Db:Password": "s3cret", "AllowedHosts": "*"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := map[string]string{"AllowedHosts": "*", "Api:Url": "http://api", "Api__Url": "http://api", "Db:Password": "s3cret", "Db__Password": "s3cret"}
+	if !maps.Equal(r.named, want) || !r.secret["Db:Password"] || !r.secret["Db__Password"] || r.secret["Api__Url"] {
+		t.Fatalf("unexpected variables: %v %v", r.named, r.secret)
+	}
+}
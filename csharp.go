@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// shapeKind is the type of a configuration value, as inferred from its JSON
+type shapeKind int
+
+const (
+	shapeNull shapeKind = iota
+	shapeBool
+	shapeInt
+	shapeLong
+	shapeDouble
+	shapeString
+	shapeObject
+	shapeArray
+	// shapeDict is an object whose keys are data, such as Logging:LogLevel
+	shapeDict
+)
+
+// shape is the inferred type of a configuration value
+type shape struct {
+	kind shapeKind
+	// nullable is set when some occurrence of the value is null
+	nullable bool
+	// fields holds the members of objects, elem the elements of arrays and dictionaries
+	fields map[string]*shape
+	elem   *shape
+}
+
+// inferShape infers the type of a value of a configuration tree. Objects whose keys
+// are not all identifiers are dictionaries.
+func inferShape(v any) *shape {
+	switch v := v.(type) {
+	case nil:
+		return &shape{kind: shapeNull, nullable: true}
+	case bool:
+		return &shape{kind: shapeBool}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return &shape{kind: shapeInt}
+			}
+			return &shape{kind: shapeLong}
+		}
+		return &shape{kind: shapeDouble}
+	case []any:
+		s := &shape{kind: shapeArray}
+		for _, item := range v {
+			s.elem = mergeShapes(s.elem, inferShape(item))
+		}
+		return s
+	case map[string]any:
+		fields := make(map[string]*shape, len(v))
+		identifiers := true
+		for k, item := range v {
+			fields[k] = inferShape(item)
+			identifiers = identifiers && isIdentifier(k)
+		}
+		if identifiers {
+			return &shape{kind: shapeObject, fields: fields}
+		}
+		s := &shape{kind: shapeDict}
+		for _, f := range fields {
+			s.elem = mergeShapes(s.elem, f)
+		}
+		return s
+	}
+	return &shape{kind: shapeString}
+}
+
+// mergeShapes returns the type holding the values of both a and b: numbers widen,
+// objects merge their fields and mismatches fall back to strings
+func mergeShapes(a, b *shape) *shape {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.kind == shapeNull:
+		merged := *b
+		merged.nullable = true
+		return &merged
+	case b.kind == shapeNull:
+		return mergeShapes(b, a)
+	}
+
+	nullable := a.nullable || b.nullable
+	switch {
+	case a.kind == b.kind && a.kind == shapeObject:
+		fields := make(map[string]*shape, len(a.fields))
+		for k, f := range a.fields {
+			fields[k] = f
+		}
+		for k, f := range b.fields {
+			fields[k] = mergeShapes(fields[k], f)
+		}
+		return &shape{kind: shapeObject, nullable: nullable, fields: fields}
+	case a.kind == b.kind && (a.kind == shapeArray || a.kind == shapeDict):
+		return &shape{kind: a.kind, nullable: nullable, elem: mergeShapes(a.elem, b.elem)}
+	case a.kind == b.kind:
+		return &shape{kind: a.kind, nullable: nullable}
+	case isNumber(a.kind) && isNumber(b.kind):
+		return &shape{kind: max(a.kind, b.kind), nullable: nullable}
+	case a.kind == shapeObject && b.kind == shapeDict, a.kind == shapeDict && b.kind == shapeObject:
+		s := &shape{kind: shapeDict, nullable: nullable}
+		for _, side := range []*shape{a, b} {
+			s.elem = mergeShapes(s.elem, side.elem)
+			for _, f := range side.fields {
+				s.elem = mergeShapes(s.elem, f)
+			}
+		}
+		return s
+	}
+	return &shape{kind: shapeString, nullable: nullable}
+}
+
+func isNumber(k shapeKind) bool { return k == shapeInt || k == shapeLong || k == shapeDouble }
+
+// isIdentifier reports whether key can be a C# property name once capitalized
+func isIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// propertyName returns the C# property bound to key. The configuration binder
+// matches names case-insensitively, so the first letter is capitalized.
+func propertyName(key string) string {
+	r := []rune(key)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// csharpGen writes C# options classes
+type csharpGen struct {
+	b       strings.Builder
+	records bool
+}
+
+// generateCSharp writes an options class for every top-level section of tree in
+// namespace, with a SectionName constant and an extension method binding them all.
+// Sections that are dictionaries or single values have no class.
+func generateCSharp(tree map[string]any, namespace string, records bool) []byte {
+	g := &csharpGen{records: records}
+	g.b.WriteString("// <auto-generated/>\n#nullable enable\n\nusing System.Collections.Generic;\nusing Microsoft.Extensions.Configuration;\nusing Microsoft.Extensions.DependencyInjection;\n\n")
+	fmt.Fprintf(&g.b, "namespace %s;\n", namespace)
+
+	var classes []string
+	for _, k := range sortedFold(tree) {
+		s := inferShape(tree[k])
+		if s.kind != shapeObject {
+			continue
+		}
+		name := propertyName(k) + "Options"
+		g.b.WriteString("\n")
+		g.writeClass(name, k, s, "")
+		classes = append(classes, name)
+	}
+
+	g.b.WriteString("\npublic static class AppSettingsServiceCollectionExtensions\n{\n")
+	g.b.WriteString("    /// <summary>Binds every options class to its configuration section.</summary>\n")
+	g.b.WriteString("    public static IServiceCollection AddAppSettingsOptions(this IServiceCollection services, IConfiguration configuration)\n    {\n")
+	for _, name := range classes {
+		fmt.Fprintf(&g.b, "        services.Configure<%s>(configuration.GetSection(%s.SectionName));\n", name, name)
+	}
+	g.b.WriteString("        return services;\n    }\n}\n")
+	return []byte(g.b.String())
+}
+
+// writeClass writes the class of the object s, indented by indent. section is the
+// configuration path of top-level classes, which nested classes have not.
+func (g *csharpGen) writeClass(name, section string, s *shape, indent string) {
+	kind := "class"
+	if g.records {
+		kind = "record"
+	}
+	if section != "" {
+		fmt.Fprintf(&g.b, "%s/// <summary>Options bound to the %q configuration section.</summary>\n", indent, section)
+	}
+	fmt.Fprintf(&g.b, "%spublic sealed %s %s\n%s{\n", indent, kind, name, indent)
+	if section != "" {
+		fmt.Fprintf(&g.b, "%s    public const string SectionName = %q;\n\n", indent, section)
+	}
+
+	type nestedClass struct {
+		name  string
+		shape *shape
+	}
+	var nested []nestedClass
+	for _, k := range sortedFold(s.fields) {
+		prop := propertyName(k)
+		typ, init, class, className := g.typeOf(s.fields[k], prop, name)
+		if class != nil {
+			nested = append(nested, nestedClass{className, class})
+		}
+		accessor := "set"
+		if g.records {
+			accessor = "init"
+		}
+		fmt.Fprintf(&g.b, "%s    public %s %s { get; %s; }%s\n", indent, typ, prop, accessor, init)
+	}
+	for _, n := range nested {
+		g.b.WriteString("\n")
+		g.writeClass(n.name, "", n.shape, indent+"    ")
+	}
+	fmt.Fprintf(&g.b, "%s}\n", indent)
+}
+
+// typeOf returns the C# type of a property and its initializer, with the object
+// shape that needs a nested class and the name of that class, if any
+func (g *csharpGen) typeOf(s *shape, prop, enclosing string) (typ, init string, class *shape, className string) {
+	switch s.kind {
+	case shapeObject:
+		className = nestedClassName(prop+"Options", enclosing)
+		return className, " = new();", s, className
+	case shapeArray, shapeDict:
+		elem := s.elem
+		if elem == nil {
+			elem = &shape{kind: shapeString}
+		}
+		elemType := ""
+		if elem.kind == shapeObject {
+			className = nestedClassName(prop+"Item", enclosing)
+			elemType, class = className, elem
+		} else {
+			elemType, _, class, className = g.typeOf(elem, prop+"Item", enclosing)
+		}
+		if s.kind == shapeArray {
+			return "List<" + elemType + ">", " = new();", class, className
+		}
+		return "Dictionary<string, " + elemType + ">", " = new();", class, className
+	case shapeNull:
+		return "string?", "", nil, ""
+	}
+
+	typ = map[shapeKind]string{shapeBool: "bool", shapeInt: "int", shapeLong: "long", shapeDouble: "double", shapeString: "string"}[s.kind]
+	switch {
+	case s.nullable:
+		return typ + "?", "", nil, ""
+	case s.kind == shapeString:
+		return typ, " = \"\";", nil, ""
+	}
+	return typ, "", nil, ""
+}
+
+// nestedClassName returns name, unless it is the name of the enclosing class, which
+// C# does not allow
+func nestedClassName(name, enclosing string) string {
+	if name == enclosing {
+		return name + "Section"
+	}
+	return name
+}
+
+// sortedFold returns the keys of m sorted case-insensitively
+func sortedFold[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	return keys
+}
+
+// runGenCSharp implements gen csharp
+func runGenCSharp(args []string) int {
+	namespace := flag.String("namespace", "AppSettings", "Namespace of the generated classes")
+	records := flag.Bool("records", false, "Generate records with init-only properties instead of classes")
+
+	tree, sources, code := loadTree("gen csharp", args)
+	if tree == nil {
+		return code
+	}
+	if !isIdentifier(strings.ReplaceAll(*namespace, ".", "_")) {
+		errorf("invalid namespace: %q", *namespace)
+		return exitUsage
+	}
+
+	out := generateCSharp(tree, *namespace, *records)
+	if !*noHeader {
+		out = withHeader(out, "//", sources, true)
+	}
+	if *newline == "crlf" {
+		out = crlf(out)
+	}
+	return writeGenerated(out)
+}
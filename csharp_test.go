@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMergeShapes(t *testing.T) {
+	cases := []struct {
+		a, b any
+		want shapeKind
+	}{
+		{json.Number("1"), json.Number("3000000000"), shapeLong},
+		{json.Number("1"), json.Number("1.5"), shapeDouble},
+		{json.Number("1"), "x", shapeString},
+		{nil, true, shapeBool},
+		{map[string]any{"A": "x"}, map[string]any{"b.c": "y"}, shapeDict},
+	}
+	for _, c := range cases {
+		if got := mergeShapes(inferShape(c.a), inferShape(c.b)); got.kind != c.want {
+			t.Fatalf("%v and %v: got kind %d, want %d", c.a, c.b, got.kind, c.want)
+		}
+	}
+	if got := mergeShapes(inferShape(nil), inferShape(json.Number("1"))); !got.nullable {
+		t.Fatalf("null should make the type nullable")
+	}
+}
+
+func TestGenerateCSharp(t *testing.T) {
+	tree := map[string]any{
+		"AllowedHosts": "*",
+		"Logging":      map[string]any{"LogLevel": map[string]any{"Default": "Information", "Microsoft.AspNetCore": "Warning"}},
+		"Api": map[string]any{
+			"Timeout":   json.Number("30"),
+			"Enabled":   true,
+			"Proxy":     nil,
+			"Endpoints": []any{map[string]any{"Name": "a"}, map[string]any{"Name": "b", "Port": json.Number("1")}},
+			"Api":       map[string]any{"Url": "https://api"},
+		},
+	}
+	got := string(generateCSharp(tree, "MyApp.Configuration", true))
+	for _, want := range []string{
+		"namespace MyApp.Configuration;\n",
+		"public sealed record ApiOptions\n{\n    public const string SectionName = \"Api\";\n\n" +
+			"    public ApiOptionsSection Api { get; init; } = new();\n" +
+			"    public bool Enabled { get; init; }\n" +
+			"    public List<EndpointsItem> Endpoints { get; init; } = new();\n" +
+			"    public string? Proxy { get; init; }\n" +
+			"    public int Timeout { get; init; }\n",
+		"    public sealed record EndpointsItem\n    {\n        public string Name { get; init; } = \"\";\n        public int Port { get; init; }\n    }\n",
+		"    public Dictionary<string, string> LogLevel { get; init; } = new();\n",
+		"        services.Configure<LoggingOptions>(configuration.GetSection(LoggingOptions.SectionName));\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing:\n%s\nin:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "AllowedHosts") {
+		t.Fatalf("top-level values should get no class:\n%s", got)
+	}
+}
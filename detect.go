@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// detectModes lists the accepted -detect-secrets values
+var detectModes = map[string]bool{"": true, "warn": true, "classify": true}
+
+// credentialPatterns match well-known credential formats in values
+var credentialPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"a private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"a JWT", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`)},
+	{"an AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"a GitHub token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"a Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"a connection string with a password", regexp.MustCompile(`(?i)(^|;)\s*(password|pwd|accountkey|sharedaccesskey)\s*=\s*[^;\s]`)},
+	{"a URL with a password", regexp.MustCompile(`://[^/\s:@]+:[^/\s@]+@`)},
+}
+
+// Random-looking values are suspected from their length, their Shannon entropy in bits
+// per character, and how often consecutive characters change between lower-case,
+// upper-case and digits, which is rare in identifiers and words
+const (
+	minSecretLength      = 20
+	minSecretEntropy     = 3.5
+	minSecretTransitions = 0.4
+)
+
+// secretValueKind returns what value looks like when it holds a credential, or ""
+func secretValueKind(value string) string {
+	if isKeyVaultRef(value) {
+		return ""
+	}
+	for _, p := range credentialPatterns {
+		if p.re.MatchString(value) {
+			return p.kind
+		}
+	}
+	if randomLooking(value) {
+		return "a random key or password"
+	}
+	return ""
+}
+
+// randomLooking reports whether value is a single long word mixing lower-case and
+// upper-case letters and digits at random, like generated keys. URLs and paths, whose
+// segments mix cases too, are left out.
+func randomLooking(value string) bool {
+	if len(value) < minSecretLength || strings.Contains(value, "://") || strings.Count(value, "/") > 2 ||
+		strings.ContainsFunc(value, unicode.IsSpace) {
+		return false
+	}
+	var lower, upper, digit bool
+	transitions, n := 0, 0
+	previous := ' '
+	for _, r := range value {
+		lower = lower || unicode.IsLower(r)
+		upper = upper || unicode.IsUpper(r)
+		digit = digit || unicode.IsDigit(r)
+		if n > 0 && characterClass(r) != characterClass(previous) {
+			transitions++
+		}
+		previous = r
+		n++
+	}
+	return lower && upper && digit && entropy(value) >= minSecretEntropy &&
+		float64(transitions) >= minSecretTransitions*float64(n-1)
+}
+
+// Character classes told apart by randomLooking
+const (
+	classOther = iota
+	classLower
+	classUpper
+	classDigit
+)
+
+// characterClass returns the class of r counted by randomLooking
+func characterClass(r rune) int {
+	switch {
+	case unicode.IsLower(r):
+		return classLower
+	case unicode.IsUpper(r):
+		return classUpper
+	case unicode.IsDigit(r):
+		return classDigit
+	}
+	return classOther
+}
+
+// entropy returns the Shannon entropy of the characters of s, in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var e float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+// checkDetectMode validates -detect-secrets
+func checkDetectMode() error {
+	*detectSecrets = strings.ToLower(strings.TrimSpace(*detectSecrets))
+	if !detectModes[*detectSecrets] {
+		return fmt.Errorf("invalid secret detection mode: %q", *detectSecrets)
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// diffResult holds the flattened keys that differ between two configurations
+type diffResult struct {
+	Added   map[string]string      `json:"added"`
+	Removed map[string]string      `json:"removed"`
+	Changed map[string]valueChange `json:"changed"`
+
+	// equal holds the keys of a with the same value in b, the context of unified diffs
+	equal map[string]string
+}
+
+type valueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// runDiff implements the diff subcommand and returns the process exit code:
+// 0 when both configurations are equal, 1 when they differ, and otherwise the code of
+// the error (see exitcode.go), 2 for unclassified ones.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	base := fs.String("file", "./appsettings.json", "Base file used when an input is an environment name")
+	sep := fs.String("separator", "__", "Separator character(s)")
+	outFormat := fs.String("format", "text", "Output format: text|unified|json")
+	fs.BoolVar(noColor, "no-color", false, "Disable colors, also disabled by NO_COLOR or when the output is not a terminal")
+	fs.StringVar(errorFormat, "error-format", "text", "Errors and warnings format: text, or github for GitHub Actions annotations")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s diff [flags] <a> <b>:\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Inputs are files or globs. An input that matches no file and does not look like a")
+		fmt.Fprintln(fs.Output(), "path is treated as an environment name layered over -file (e.g. Staging).")
+		fmt.Fprintln(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	outFmt := strings.ToLower(strings.TrimSpace(*outFormat))
+	if outFmt != "text" && outFmt != "unified" && outFmt != "json" {
+		errorf("invalid diff format: %q", *outFormat)
+		return exitUsage
+	}
+	if err := checkErrorFormat(); err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	if len(*sep) < 1 {
+		errorf("separator cannot be an empty string")
+		return exitUsage
+	}
+
+	a, err := loadInput(fs.Arg(0), *base, *sep)
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitUsage)
+	}
+
+	b, err := loadInput(fs.Arg(1), *base, *sep)
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitUsage)
+	}
+
+	d := diffVariables(a, b)
+	if err := writeStdout(func(w io.Writer) error {
+		return writeDiff(w, d, outFmt, fs.Arg(0), fs.Arg(1), useColor(os.Stdout))
+	}); err != nil {
+		logError(err)
+		return exitIO
+	}
+
+	if d.empty() {
+		return exitOK
+	}
+	return exitFailure
+}
+
+// loadInput loads a diff input, which is either a file/glob or an environment name
+func loadInput(input, base, sep string) (map[string]string, error) {
+	if files, err := filepath.Glob(input); err == nil && len(files) > 0 {
+		return loadFiles(input, "", sep)
+	}
+
+	if strings.ContainsAny(input, `/\*?[`) || strings.EqualFold(filepath.Ext(input), ".json") {
+		return loadFiles(input, "", sep)
+	}
+
+	// An environment without its file would silently compare the base file alone
+	files, _ := filepath.Glob(base)
+	found := false
+	for _, f := range files {
+		if fileExists(environmentFile(f, input)) {
+			found = true
+			break
+		}
+	}
+	if len(files) > 0 && !found {
+		return nil, ioError(fmt.Errorf("no %s file for environment %s", environmentFile(base, input), input))
+	}
+
+	return loadFiles(base, input, sep)
+}
+
+// diffVariables compares two flattened configurations, matching keys case-insensitively
+func diffVariables(a, b map[string]string) diffResult {
+	d := diffResult{
+		Added:   make(map[string]string),
+		Removed: make(map[string]string),
+		Changed: make(map[string]valueChange),
+		equal:   make(map[string]string),
+	}
+
+	index := make(map[string]string, len(b))
+	for k := range b {
+		index[strings.ToLower(k)] = k
+	}
+
+	for k, v := range a {
+		other, ok := index[strings.ToLower(k)]
+		if !ok {
+			d.Removed[k] = v
+			continue
+		}
+		delete(index, strings.ToLower(k))
+		if b[other] != v {
+			d.Changed[k] = valueChange{From: v, To: b[other]}
+		} else {
+			d.equal[k] = v
+		}
+	}
+
+	for _, k := range index {
+		d.Added[k] = b[k]
+	}
+
+	return d
+}
+
+// overriddenVariables returns the variables of effective that are new or carry a
+// different value than in base
+func overriddenVariables(base, effective map[string]string) map[string]string {
+	d := diffVariables(base, effective)
+	out := make(map[string]string, len(d.Added)+len(d.Changed))
+	for k, v := range d.Added {
+		out[k] = v
+	}
+	for k, c := range d.Changed {
+		out[k] = c.To
+	}
+	return out
+}
+
+func (d diffResult) empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// writeDiff renders d in the requested format, coloring the text formats when color is set
+func writeDiff(w io.Writer, d diffResult, format, nameA, nameB string, color bool) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+
+	if format == "unified" {
+		return writeUnified(w, d, nameA, nameB, color)
+	}
+
+	// Walk the union of keys in output order
+	all := make(map[string]string, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, m := range []map[string]string{d.Added, d.Removed} {
+		for k, v := range m {
+			all[k] = v
+		}
+	}
+	for k := range d.Changed {
+		all[k] = ""
+	}
+
+	for _, k := range appsettingsenv.SortedKeys(all) {
+		var err error
+		if v, ok := d.Removed[k]; ok {
+			_, err = fmt.Fprintf(w, "%s %s: %q\n", colorize(color, colorRed, "removed"), k, v)
+		} else if v, ok := d.Added[k]; ok {
+			_, err = fmt.Fprintf(w, "%s   %s: %q\n", colorize(color, colorGreen, "added"), k, v)
+		} else {
+			c := d.Changed[k]
+			_, err = fmt.Fprintf(w, "%s %s: %q -> %q\n", colorize(color, colorYellow, "changed"), k, c.From, c.To)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unifiedContext is the number of unchanged lines around each hunk, as with diff -u
+const unifiedContext = 3
+
+// diffLine is a KEY="value" line of a unified diff, op being ' ', '-' or '+'
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// writeUnified renders d as a unified diff of both configurations listed as sorted
+// KEY="value" lines
+func writeUnified(w io.Writer, d diffResult, nameA, nameB string, color bool) error {
+	all := make(map[string]string, len(d.equal)+len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, m := range []map[string]string{d.equal, d.Added, d.Removed} {
+		for k, v := range m {
+			all[k] = v
+		}
+	}
+	for k := range d.Changed {
+		all[k] = ""
+	}
+
+	var lines []diffLine
+	for _, k := range appsettingsenv.SortedKeys(all) {
+		if v, ok := d.equal[k]; ok {
+			lines = append(lines, diffLine{' ', fmt.Sprintf("%s=%q", k, v)})
+		} else if v, ok := d.Removed[k]; ok {
+			lines = append(lines, diffLine{'-', fmt.Sprintf("%s=%q", k, v)})
+		} else if v, ok := d.Added[k]; ok {
+			lines = append(lines, diffLine{'+', fmt.Sprintf("%s=%q", k, v)})
+		} else {
+			c := d.Changed[k]
+			lines = append(lines, diffLine{'-', fmt.Sprintf("%s=%q", k, c.From)}, diffLine{'+', fmt.Sprintf("%s=%q", k, c.To)})
+		}
+	}
+
+	// before[i] counts the lines of a and b above lines[i]
+	before := make([][2]int, len(lines)+1)
+	for i, l := range lines {
+		before[i+1] = before[i]
+		if l.op != '+' {
+			before[i+1][0]++
+		}
+		if l.op != '-' {
+			before[i+1][1]++
+		}
+	}
+
+	nextChange := func(i int) int {
+		for ; i < len(lines); i++ {
+			if lines[i].op != ' ' {
+				return i
+			}
+		}
+		return -1
+	}
+
+	if nextChange(0) < 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", nameA, nameB); err != nil {
+		return err
+	}
+
+	// Changes closer than twice the context share a hunk
+	for i := nextChange(0); i >= 0; {
+		lo, hi := max(i-unifiedContext, 0), i
+		for {
+			next := nextChange(hi + 1)
+			if next < 0 || next-hi-1 > 2*unifiedContext {
+				break
+			}
+			hi = next
+		}
+		end := min(hi+1+unifiedContext, len(lines))
+
+		a, b := before[end][0]-before[lo][0], before[end][1]-before[lo][1]
+		if _, err := fmt.Fprintf(w, "@@ -%s +%s @@\n", hunkRange(before[lo][0], a), hunkRange(before[lo][1], b)); err != nil {
+			return err
+		}
+		for _, l := range lines[lo:end] {
+			text := string(l.op) + l.text
+			switch l.op {
+			case '-':
+				text = colorize(color, colorRed, text)
+			case '+':
+				text = colorize(color, colorGreen, text)
+			}
+			if _, err := fmt.Fprintln(w, text); err != nil {
+				return err
+			}
+		}
+		i = nextChange(end)
+	}
+	return nil
+}
+
+// hunkRange formats the range of a hunk header from the lines above it and its length.
+// As with diff -u, a single line omits the length and an empty range names the line
+// above it.
+func hunkRange(above, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", above)
+	case 1:
+		return fmt.Sprint(above + 1)
+	}
+	return fmt.Sprintf("%d,%d", above+1, n)
+}
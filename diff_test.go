@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffVariables(t *testing.T) {
+	a := map[string]string{"Api__Url": "http://staging", "Logging__Level": "Debug", "Old": "x"}
+	b := map[string]string{"api__url": "http://prod", "Logging__Level": "Debug", "New": "y"}
+
+	d := diffVariables(a, b)
+
+	if len(d.Changed) != 1 || d.Changed["Api__Url"] != (valueChange{From: "http://staging", To: "http://prod"}) {
+		t.Fatalf("unexpected changed set: %v", d.Changed)
+	}
+	if len(d.Removed) != 1 || d.Removed["Old"] != "x" {
+		t.Fatalf("unexpected removed set: %v", d.Removed)
+	}
+	if len(d.Added) != 1 || d.Added["New"] != "y" {
+		t.Fatalf("unexpected added set: %v", d.Added)
+	}
+	if diffVariables(a, a).empty() != true {
+		t.Fatalf("identical configurations should produce an empty diff")
+	}
+}
+
+func TestWriteDiff(t *testing.T) {
+	d := diffVariables(
+		map[string]string{"A": "1", "B": "2"},
+		map[string]string{"A": "1", "B": "3", "C": "4"},
+	)
+
+	var buf bytes.Buffer
+	if err := writeDiff(&buf, d, "unified", "a.json", "b.json", false); err != nil {
+		t.Fatalf("writeDiff failed: %v", err)
+	}
+	want := "--- a.json\n+++ b.json\n@@ -1,2 +1,3 @@\n A=\"1\"\n-B=\"2\"\n+B=\"3\"\n+C=\"4\"\n"
+	if buf.String() != want {
+		t.Fatalf("unified diff mismatch:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeDiff(&buf, d, "json", "a.json", "b.json", false); err != nil {
+		t.Fatalf("writeDiff failed: %v", err)
+	}
+	var decoded diffResult
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("json diff does not decode: %v", err)
+	}
+	if decoded.Added["C"] != "4" || decoded.Changed["B"].To != "3" {
+		t.Fatalf("unexpected json diff: %s", buf.String())
+	}
+}
+
+func TestWriteDiff_Hunks(t *testing.T) {
+	a, b := make(map[string]string), make(map[string]string)
+	for i := 1; i <= 20; i++ {
+		a[fmt.Sprintf("K%d", i)] = "x"
+		b[fmt.Sprintf("K%d", i)] = "x"
+	}
+	a["K2"], b["K2"] = "1", "2"
+	delete(a, "K18")
+
+	var buf bytes.Buffer
+	if err := writeDiff(&buf, diffVariables(a, b), "unified", "a", "b", false); err != nil {
+		t.Fatalf("writeDiff failed: %v", err)
+	}
+	want := `--- a
++++ b
+@@ -1,5 +1,5 @@
+ K1="x"
+-K2="1"
++K2="2"
+ K3="x"
+ K4="x"
+ K5="x"
+@@ -15,5 +15,6 @@
+ K15="x"
+ K16="x"
+ K17="x"
++K18="x"
+ K19="x"
+ K20="x"
+`
+	if buf.String() != want {
+		t.Fatalf("unified diff mismatch:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := writeDiff(&buf, diffVariables(a, a), "unified", "a", "b", false); err != nil || buf.Len() != 0 {
+		t.Fatalf("equal configurations should print nothing, got %q (%v)", buf.String(), err)
+	}
+}
+
+func TestLoadInput_Environment(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "appsettings.json")
+	if err := os.WriteFile(base, []byte(`{"Api": "base"}`), 0o644); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "appsettings.Staging.json"), []byte(`{"Api": "staging"}`), 0o644); err != nil {
+		t.Fatalf("write staging: %v", err)
+	}
+
+	vars, err := loadInput("Staging", base, "__")
+	if err != nil {
+		t.Fatalf("loadInput failed: %v", err)
+	}
+	if vars["Api"] != "staging" {
+		t.Fatalf("environment input not layered: %v", vars)
+	}
+
+	if _, err := loadInput(filepath.Join(dir, "missing.json"), base, "__"); err == nil {
+		t.Fatalf("expected error for missing file input")
+	}
+
+	_, err = loadInput("Production", base, "__")
+	if err == nil || !strings.Contains(err.Error(), "appsettings.Production.json") {
+		t.Fatalf("expected error for missing environment file, got %v", err)
+	}
+	if code := exitCode(err, exitUsage); code != exitIO {
+		t.Fatalf("missing environment file exit code = %d, want %d", code, exitIO)
+	}
+}
+
+func TestOverriddenVariables(t *testing.T) {
+	base := map[string]string{"Api": "base", "Logging__Level": "Information", "Same": "1"}
+	effective := map[string]string{"Api": "prod", "Logging__Level": "Information", "Same": "1", "Extra": "x"}
+
+	got := overriddenVariables(base, effective)
+
+	if len(got) != 2 || got["Api"] != "prod" || got["Extra"] != "x" {
+		t.Fatalf("unexpected overrides: %v", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// dopplerAPI is the Doppler REST API URL
+const dopplerAPI = "https://api.doppler.com"
+
+// dopplerStore is the secretStore of a Doppler project config
+type dopplerStore struct {
+	restClient
+	token   string
+	project string
+	config  string
+}
+
+// newDopplerStore returns the store of a project config, or of the config of a
+// service token when project is empty
+func newDopplerStore(api, token, project, config string) *dopplerStore {
+	s := &dopplerStore{token: token, project: project, config: config}
+	s.restClient = newRESTClient("Doppler API", api+"/v3", func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		return nil
+	})
+	return s
+}
+
+func (s *dopplerStore) String() string {
+	if s.project == "" {
+		return "the Doppler config of the service token"
+	}
+	return "Doppler config " + s.project + "/" + s.config
+}
+
+// entry upper-cases name, as Doppler only accepts upper-case secret names. Every
+// Doppler value is a secret.
+func (s *dopplerStore) entry(name, value string, _ bool) (*secretEntry, error) {
+	upper := strings.ToUpper(name)
+	if !validName(upper, identifierRule) || strings.HasPrefix(upper, "DOPPLER_") {
+		return nil, fmt.Errorf("%s: expected %s, not starting with DOPPLER_", name, identifierRule.description)
+	}
+	return &secretEntry{name: upper, value: value, secret: true}, nil
+}
+
+// entries returns the raw values of the config, references to other secrets unexpanded
+func (s *dopplerStore) entries(ctx context.Context, _ []string) (map[string]secretEntry, error) {
+	query := url.Values{"include_dynamic_secrets": {"false"}}
+	if s.project != "" {
+		query.Set("project", s.project)
+		query.Set("config", s.config)
+	}
+
+	var resp struct {
+		Secrets map[string]struct {
+			Raw string `json:"raw"`
+		} `json:"secrets"`
+	}
+	if err := s.do(ctx, http.MethodGet, "configs/config/secrets?"+query.Encode(), nil, &resp); err != nil {
+		return nil, fmt.Errorf("list secrets: %w", err)
+	}
+
+	entries := make(map[string]secretEntry, len(resp.Secrets))
+	for name, secret := range resp.Secrets {
+		entries[name] = secretEntry{name: name, value: secret.Raw, secret: true}
+	}
+	return entries, nil
+}
+
+// write creates or updates the changed secrets in a single request, which Doppler
+// records as one change of the config
+func (s *dopplerStore) write(ctx context.Context, changed []secretEntry, _ map[string]secretEntry) error {
+	if len(changed) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(changed))
+	for _, e := range changed {
+		values[e.name] = e.value
+	}
+	body := map[string]any{"secrets": values}
+	if s.project != "" {
+		body["project"] = s.project
+		body["config"] = s.config
+	}
+	if err := s.do(ctx, http.MethodPost, "configs/config/secrets", body, nil); err != nil {
+		return fmt.Errorf("update secrets: %w", err)
+	}
+	return nil
+}
+
+// runPushDoppler implements push doppler, which syncs the variables to a Doppler
+// project config
+func runPushDoppler(args []string) int {
+	project := flag.String("project", "", "Doppler project (default: $DOPPLER_PROJECT; implied by a service token)")
+	// -config names the tool configuration file
+	config := flag.String("doppler-config", "", "Config of the project, e.g. prd (default: $DOPPLER_CONFIG; implied by a service token)")
+	apiURL := flag.String("api-url", "", "REST API URL (default: $DOPPLER_API_HOST or "+dopplerAPI+")")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push doppler [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "The token is read from $DOPPLER_TOKEN. Names are upper-cased, as Doppler requires.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// The k8s type emits __ names and keeps multi-line values
+	r, code := renderOutput("push doppler", "k8s", args)
+	if r == nil {
+		return code
+	}
+
+	if *project == "" {
+		*project = os.Getenv("DOPPLER_PROJECT")
+	}
+	if *config == "" {
+		*config = os.Getenv("DOPPLER_CONFIG")
+	}
+	if (*project == "") != (*config == "") {
+		errorf("-project and -doppler-config must be given together")
+		return exitUsage
+	}
+	token := os.Getenv("DOPPLER_TOKEN")
+	if token == "" {
+		errorf("DOPPLER_TOKEN is not set")
+		return exitUsage
+	}
+
+	if *apiURL == "" {
+		*apiURL = os.Getenv("DOPPLER_API_HOST")
+	}
+	if *apiURL == "" {
+		*apiURL = dopplerAPI
+	}
+
+	store := newDopplerStore(*apiURL, token, *project, *config)
+	if err := pushSecretStore(context.Background(), os.Stdout, store, r, *dryRun); err != nil {
+		logError(err)
+		return exitCode(err, exitIO)
+	}
+	return exitOK
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newDopplerServer serves the secrets of the config prd of the project api, recording
+// the updates
+func newDopplerServer(t *testing.T, current map[string]string, updates *[]map[string]string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer dp.st.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"messages": []string{"Invalid token"}, "success": false})
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v3/configs/config/secrets":
+			if r.URL.Query().Get("project") != "api" || r.URL.Query().Get("config") != "prd" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			secrets := make(map[string]any)
+			for name, value := range current {
+				secrets[name] = map[string]string{"raw": value, "computed": value}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"secrets": secrets})
+		case r.Method == http.MethodPost && r.URL.Path == "/v3/configs/config/secrets":
+			var body struct {
+				Project string            `json:"project"`
+				Config  string            `json:"config"`
+				Secrets map[string]string `json:"secrets"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			for name, value := range body.Secrets {
+				current[name] = value
+			}
+			*updates = append(*updates, body.Secrets)
+			w.Write([]byte(`{"success": true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPushDoppler(t *testing.T) {
+	current := map[string]string{"LOGGING__LEVEL": "Information", "UNCHANGED": "same", "OTHER": "kept"}
+	var updates []map[string]string
+	srv := newDopplerServer(t, current, &updates)
+
+	store := newDopplerStore(srv.URL, "dp.st.token", "api", "prd")
+	r := &rendered{named: map[string]string{"Logging__Level": "Warning", "Unchanged": "same", "Db__Password": "s3cret"}}
+
+	var out bytes.Buffer
+	if err := pushSecretStore(context.Background(), &out, store, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "added   DB__PASSWORD: \"(secret)\"\nchanged LOGGING__LEVEL: \"(secret)\" -> \"(secret)\"\n"
+	if out.String() != want || len(updates) != 0 {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if len(updates) != 1 || len(updates[0]) != 2 || updates[0]["DB__PASSWORD"] != "s3cret" {
+		t.Fatalf("changed secrets should be written in one request: %v", updates)
+	}
+	if !strings.HasSuffix(out.String(), "2 secret(s) written to Doppler config api/prd\n") || current["OTHER"] != "kept" {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+
+	store.token = "wrong"
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err == nil || !strings.Contains(err.Error(), "Invalid token") {
+		t.Fatalf("expected the API message, got %v", err)
+	}
+
+	r = &rendered{named: map[string]string{"Doppler_Env": "x"}}
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err == nil {
+		t.Fatalf("reserved DOPPLER_ prefix should fail")
+	}
+}
+
+func TestRunPushDoppler(t *testing.T) {
+	defer func(f string) { *file = f }(*file)
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = io.Discard
+
+	current := map[string]string{"LOGGING__LEVEL": "Information"}
+	var updates []map[string]string
+	srv := newDopplerServer(t, current, &updates)
+
+	path := filepath.Join(t.TempDir(), "appsettings.json")
+	if err := os.WriteFile(path, []byte(`{"Logging": {"Level": "Warning"}, "Db": {"Password": "s3cret"}}`), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+	t.Setenv("DOPPLER_TOKEN", "dp.st.token")
+
+	// -config is the tool configuration file, which the Doppler config must not clash with
+	args := []string{"-file", path, "-project", "api", "-doppler-config", "prd", "-api-url", srv.URL}
+	if code := runPushDoppler(args); code != exitOK {
+		t.Fatalf("want exit code %d got %d", exitOK, code)
+	}
+	if current["LOGGING__LEVEL"] != "Warning" || current["DB__PASSWORD"] != "s3cret" || len(updates) != 1 {
+		t.Fatalf("unexpected secrets: %v", current)
+	}
+}
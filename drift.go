@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// kubeObject is the subset of Kubernetes workloads, ConfigMaps and Secrets needed to
+// resolve a container's environment. Field tags match both YAML manifests and the
+// JSON returned by the API server.
+type kubeObject struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+	Metadata   struct {
+		Name      string `json:"name" yaml:"name"`
+		Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+		// Annotations carry the -checksum of generated manifests
+		Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	} `json:"metadata" yaml:"metadata"`
+	Data       map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+	StringData map[string]string `json:"stringData,omitempty" yaml:"stringData,omitempty"`
+	Spec       struct {
+		Template struct {
+			Spec struct {
+				Containers []kubeContainer `json:"containers" yaml:"containers"`
+			} `json:"spec" yaml:"spec"`
+		} `json:"template" yaml:"template"`
+	} `json:"spec" yaml:"spec"`
+}
+
+type kubeContainer struct {
+	Name    string        `json:"name" yaml:"name"`
+	Env     []kubeEnvVar  `json:"env,omitempty" yaml:"env,omitempty"`
+	EnvFrom []kubeEnvFrom `json:"envFrom,omitempty" yaml:"envFrom,omitempty"`
+}
+
+type kubeEnvVar struct {
+	Name      string `json:"name" yaml:"name"`
+	Value     string `json:"value,omitempty" yaml:"value,omitempty"`
+	ValueFrom *struct {
+		ConfigMapKeyRef *kubeKeyRef `json:"configMapKeyRef,omitempty" yaml:"configMapKeyRef,omitempty"`
+		SecretKeyRef    *kubeKeyRef `json:"secretKeyRef,omitempty" yaml:"secretKeyRef,omitempty"`
+	} `json:"valueFrom,omitempty" yaml:"valueFrom,omitempty"`
+}
+
+type kubeKeyRef struct {
+	Name string `json:"name" yaml:"name"`
+	Key  string `json:"key" yaml:"key"`
+}
+
+type kubeEnvFrom struct {
+	Prefix       string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	ConfigMapRef *struct {
+		Name string `json:"name" yaml:"name"`
+	} `json:"configMapRef,omitempty" yaml:"configMapRef,omitempty"`
+	SecretRef *struct {
+		Name string `json:"name" yaml:"name"`
+	} `json:"secretRef,omitempty" yaml:"secretRef,omitempty"`
+}
+
+// workloadKinds maps the accepted -workload kinds to their Kind
+var workloadKinds = map[string]string{
+	"deployment":  "Deployment",
+	"statefulset": "StatefulSet",
+	"daemonset":   "DaemonSet",
+}
+
+// objectSource resolves ConfigMaps and Secrets referenced by a container
+type objectSource func(kind, name string) (*kubeObject, error)
+
+// runDrift implements the drift subcommand. It accepts the conversion flags and
+// compares the converted variables against the environment of a workload container,
+// returning 0 when in sync, 1 on drift, and otherwise the code of the error as for diff.
+func runDrift(args []string) int {
+	manifest := flag.String("manifest", "", "Read the workload (and its ConfigMaps/Secrets) from this YAML/JSON manifest instead of the cluster")
+	workload := flag.String("workload", "", "Workload as kind/name, e.g. deployment/api (required without -manifest)")
+	containerName := flag.String("container", "", "Container name (default: the first container)")
+	namespace := flag.String("namespace", "", "Namespace (default: from the kubeconfig context)")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flag.String("context", "", "Kubeconfig context (default: current context)")
+	outFormat := flag.String("format", "text", "Output format: text|unified|json")
+	ignoreExtra := flag.Bool("ignore-extra", false, "Ignore variables defined by the workload but not by the configuration")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s drift [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := applyToolConfig(flag.CommandLine); err != nil {
+		logError(err)
+		return exitUsage
+	}
+	if err := setupLogging(); err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	outFmt := strings.ToLower(strings.TrimSpace(*outFormat))
+	if outFmt != "text" && outFmt != "unified" && outFmt != "json" {
+		errorf("invalid drift format: %q", *outFormat)
+		return exitUsage
+	}
+
+	if *manifest == "" && *workload == "" {
+		errorf("either -manifest or -workload is required")
+		return exitUsage
+	}
+
+	sep, err := keySeparator("k8s")
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	*keyCase = strings.ToLower(strings.TrimSpace(*keyCase))
+	if !caseModes[*keyCase] {
+		errorf("invalid case: %q", *keyCase)
+		return exitUsage
+	}
+
+	setVars, err := parseOverrides(overrides, keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	filter, err := outputFilter(sep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	layers, err := configurationLayers(setVars)
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitUsage)
+	}
+	merged, err := expandValues(mergeLayers(layers))
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitUsage)
+	}
+	variables := outputNames(filter.apply(withSeparator(merged, sep)), sep)
+
+	var (
+		obj    *kubeObject
+		source objectSource
+		name   string
+	)
+	if *manifest != "" {
+		obj, source, err = manifestWorkload(*manifest, *workload)
+		name = *manifest
+	} else {
+		obj, source, err = clusterWorkload(*kubeconfig, *kubeContext, *namespace, *workload)
+		name = *workload
+	}
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitUsage)
+	}
+
+	env, err := containerEnv(obj, *containerName, source)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	// Only compare workload variables the configuration could have produced
+	workloadVars := make(map[string]string, len(env))
+	for k, v := range env {
+		if name, ok := strings.CutPrefix(k, *prefix); ok && filter.match(name) {
+			workloadVars[k] = v
+		}
+	}
+
+	d := diffVariables(variables, workloadVars)
+	if *ignoreExtra {
+		clear(d.Added)
+	}
+
+	if err := writeStdout(func(w io.Writer) error {
+		return writeDiff(w, d, outFmt, "appsettings", name, useColor(os.Stdout))
+	}); err != nil {
+		logError(err)
+		return exitIO
+	}
+
+	if d.empty() {
+		return exitOK
+	}
+	return exitFailure
+}
+
+// parseWorkloadRef splits kind/name, validating the kind
+func parseWorkloadRef(ref string) (kind, name string, err error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	kind = strings.ToLower(kind)
+	if !ok || name == "" || workloadKinds[kind] == "" {
+		return "", "", fmt.Errorf("invalid workload %q: expected deployment|statefulset|daemonset/name", ref)
+	}
+	return kind, name, nil
+}
+
+// manifestWorkload reads the workload matching ref (or the first workload when ref is
+// empty) from a multi-document manifest. ConfigMaps and Secrets resolve from the same file.
+func manifestWorkload(path, ref string) (*kubeObject, objectSource, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, ioError(fmt.Errorf("read manifest: %w", err))
+	}
+
+	objects, err := decodeManifest(content)
+	if err != nil {
+		return nil, nil, parseError(fmt.Errorf("parse manifest %s: %w", path, err))
+	}
+
+	var kind, name string
+	if ref != "" {
+		if kind, name, err = parseWorkloadRef(ref); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	source := func(kind, name string) (*kubeObject, error) {
+		for _, o := range objects {
+			if strings.EqualFold(o.Kind, kind) && o.Metadata.Name == name {
+				return o, nil
+			}
+		}
+		return nil, fmt.Errorf("%s %q not found in %s", kind, name, path)
+	}
+
+	for _, o := range objects {
+		k := strings.ToLower(o.Kind)
+		if workloadKinds[k] == "" {
+			continue
+		}
+		if ref == "" || (k == kind && o.Metadata.Name == name) {
+			return o, source, nil
+		}
+	}
+
+	if ref == "" {
+		return nil, nil, fmt.Errorf("no Deployment, StatefulSet or DaemonSet found in %s", path)
+	}
+	return nil, nil, fmt.Errorf("%s not found in %s", ref, path)
+}
+
+// decodeManifest decodes every document of a YAML (or JSON) manifest, flattening List kinds
+func decodeManifest(content []byte) ([]*kubeObject, error) {
+	var objects []*kubeObject
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc struct {
+			kubeObject `yaml:",inline"`
+			Items      []*kubeObject `yaml:"items"`
+		}
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.HasSuffix(doc.Kind, "List") {
+			objects = append(objects, doc.Items...)
+			continue
+		}
+		if doc.Kind != "" {
+			obj := doc.kubeObject
+			objects = append(objects, &obj)
+		}
+	}
+	return objects, nil
+}
+
+// clusterWorkload reads a workload from the API server, resolving references there too
+func clusterWorkload(kubeconfig, kubeContext, namespace, ref string) (*kubeObject, objectSource, error) {
+	kind, name, err := parseWorkloadRef(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := newKubeClient(kubeconfig, kubeContext, namespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	obj, err := client.get(ctx, kind, name)
+	if err != nil {
+		return nil, nil, ioError(fmt.Errorf("get %s: %w", ref, err))
+	}
+
+	source := func(kind, name string) (*kubeObject, error) {
+		o, err := client.get(ctx, kind, name)
+		if err != nil {
+			return nil, fmt.Errorf("get %s %s: %w", kind, name, err)
+		}
+		return o, nil
+	}
+
+	return obj, source, nil
+}
+
+// containerEnv resolves the effective environment of a container the way the kubelet
+// does: envFrom sources first, then env entries overriding them.
+func containerEnv(obj *kubeObject, name string, source objectSource) (map[string]string, error) {
+	containers := obj.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return nil, fmt.Errorf("%s %s has no containers", obj.Kind, obj.Metadata.Name)
+	}
+
+	c := &containers[0]
+	if name != "" {
+		c = nil
+		for i := range containers {
+			if containers[i].Name == name {
+				c = &containers[i]
+				break
+			}
+		}
+		if c == nil {
+			return nil, fmt.Errorf("container %q not found in %s %s", name, obj.Kind, obj.Metadata.Name)
+		}
+	}
+
+	env := make(map[string]string)
+	for _, from := range c.EnvFrom {
+		var data map[string]string
+		var err error
+		switch {
+		case from.ConfigMapRef != nil:
+			data, err = objectData(source, "ConfigMap", from.ConfigMapRef.Name)
+		case from.SecretRef != nil:
+			data, err = objectData(source, "Secret", from.SecretRef.Name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range data {
+			env[from.Prefix+k] = v
+		}
+	}
+
+	for _, e := range c.Env {
+		switch {
+		case e.ValueFrom == nil:
+			env[e.Name] = e.Value
+		case e.ValueFrom.ConfigMapKeyRef != nil:
+			data, err := objectData(source, "ConfigMap", e.ValueFrom.ConfigMapKeyRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			env[e.Name] = data[e.ValueFrom.ConfigMapKeyRef.Key]
+		case e.ValueFrom.SecretKeyRef != nil:
+			data, err := objectData(source, "Secret", e.ValueFrom.SecretKeyRef.Name)
+			if err != nil {
+				return nil, err
+			}
+			env[e.Name] = data[e.ValueFrom.SecretKeyRef.Key]
+		}
+	}
+
+	return env, nil
+}
+
+// objectData returns the decoded data of a ConfigMap or Secret
+func objectData(source objectSource, kind, name string) (map[string]string, error) {
+	obj, err := source(kind, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind != "Secret" {
+		return obj.Data, nil
+	}
+
+	data := make(map[string]string, len(obj.Data)+len(obj.StringData))
+	for k, v := range obj.Data {
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s key %s: %w", name, k, err)
+		}
+		data[k] = string(decoded)
+	}
+	for k, v := range obj.StringData {
+		data[k] = v
+	}
+	return data, nil
+}
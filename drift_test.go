@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const driftManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-config
+data:
+  Logging__Level: Warning
+  Port: 8080
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api-secrets
+data:
+  Db__Password: c2VjcmV0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: sidecar
+        - name: api
+          envFrom:
+            - configMapRef:
+                name: api-config
+          env:
+            - name: Logging__Level
+              value: Debug
+            - name: Db__Password
+              valueFrom:
+                secretKeyRef:
+                  name: api-secrets
+                  key: Db__Password
+`
+
+func TestManifestWorkloadEnv(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "deploy.yaml")
+	if err := os.WriteFile(fn, []byte(driftManifest), 0o644); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+
+	obj, source, err := manifestWorkload(fn, "deployment/api")
+	if err != nil {
+		t.Fatalf("manifestWorkload failed: %v", err)
+	}
+
+	env, err := containerEnv(obj, "api", source)
+	if err != nil {
+		t.Fatalf("containerEnv failed: %v", err)
+	}
+
+	want := map[string]string{"Logging__Level": "Debug", "Port": "8080", "Db__Password": "secret"}
+	if len(env) != len(want) {
+		t.Fatalf("unexpected env: %v", env)
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Fatalf("env %s: want %q got %q", k, v, env[k])
+		}
+	}
+
+	if _, err := containerEnv(obj, "missing", source); err == nil {
+		t.Fatalf("expected error for unknown container")
+	}
+}
+
+func TestClusterWorkload(t *testing.T) {
+	// client-go only sends the credentials of a kubeconfig over TLS
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/apis/apps/v1/namespaces/prod/deployments/api":
+			w.Write([]byte(`{"kind":"Deployment","metadata":{"name":"api"},"spec":{"template":{"spec":{"containers":[{"name":"api","envFrom":[{"configMapRef":{"name":"cfg"}}]}]}}}}`))
+		case "/api/v1/namespaces/prod/configmaps/cfg":
+			w.Write([]byte(`{"kind":"ConfigMap","data":{"Api__Url":"https://api"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","message":"not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	content := `apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+  - name: test
+    cluster:
+      server: ` + srv.URL + `
+      certificate-authority-data: ` + base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})) + `
+contexts:
+  - name: test
+    context:
+      cluster: test
+      user: test
+      namespace: prod
+users:
+  - name: test
+    user:
+      token: test-token
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0o600); err != nil {
+		t.Fatalf("write kubeconfig: %v", err)
+	}
+
+	obj, source, err := clusterWorkload(kubeconfig, "", "", "deployment/api")
+	if err != nil {
+		t.Fatalf("clusterWorkload failed: %v", err)
+	}
+
+	env, err := containerEnv(obj, "", source)
+	if err != nil {
+		t.Fatalf("containerEnv failed: %v", err)
+	}
+	if env["Api__Url"] != "https://api" {
+		t.Fatalf("unexpected env: %v", env)
+	}
+
+	client, err := newKubeClient(kubeconfig, "", "")
+	if err != nil {
+		t.Fatalf("newKubeClient failed: %v", err)
+	}
+	if _, err := client.get(context.Background(), "ConfigMap", "missing"); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
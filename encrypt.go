@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// ageValuePrefix marks the values encrypted for -age-recipient. The rest of the value
+// is the base64-encoded binary age file, which `base64 -d | age -d` unwraps.
+const ageValuePrefix = "age:"
+
+// parseAgeRecipients parses the -age-recipient flags: age1... public keys, or @file
+// entries listing them one per line, with # comments
+func parseAgeRecipients(specs []string) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+	for _, s := range specs {
+		content := []byte(s)
+		if name, ok := strings.CutPrefix(s, "@"); ok {
+			var err error
+			if content, err = os.ReadFile(name); err != nil {
+				return nil, fmt.Errorf("read age recipients: %w", err)
+			}
+		}
+
+		parsed, err := age.ParseRecipients(bytes.NewReader(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", s, err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return recipients, nil
+}
+
+// encryptValue encrypts v for every recipient, returning the ageValuePrefix value
+func encryptValue(v string, recipients []age.Recipient) (string, error) {
+	var b bytes.Buffer
+	w, err := age.Encrypt(&b, recipients...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write([]byte(v)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return ageValuePrefix + base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func decryptValue(t *testing.T, v string, id age.Identity) string {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, ageValuePrefix))
+	if !strings.HasPrefix(v, ageValuePrefix) || err != nil {
+		t.Fatalf("%q is not an encrypted value: %v", v, err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(data), id)
+	if err != nil {
+		t.Fatalf("decrypt failed: %v", err)
+	}
+	plain, _ := io.ReadAll(r)
+	return string(plain)
+}
+
+func TestParseAgeRecipients(t *testing.T) {
+	a, _ := age.GenerateX25519Identity()
+	b, _ := age.GenerateX25519Identity()
+	file := filepath.Join(t.TempDir(), "recipients.txt")
+	os.WriteFile(file, []byte("# ops\n"+b.Recipient().String()+"\n"), 0o644)
+
+	recipients, err := parseAgeRecipients([]string{a.Recipient().String(), "@" + file})
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %d (%v)", len(recipients), err)
+	}
+	if _, err := parseAgeRecipients([]string{"age1bogus"}); err == nil {
+		t.Fatal("expected an error for an invalid recipient")
+	}
+}
+
+func TestConverterRender_AgeRecipient(t *testing.T) {
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+	id, _ := age.GenerateX25519Identity()
+	defer func(r stringList) { ageRecipients = r }(ageRecipients)
+	ageRecipients = stringList{id.Recipient().String()}
+
+	c, err := newConverter("docker", nil)
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	c.recipients = []age.Recipient{id.Recipient()}
+	vars := map[string]string{"Api:Url": "http://api", "Api:Password": "hunter2", "Vault:ApiKey": "@Microsoft.KeyVault(SecretUri=https://kv.vault.azure.net/secrets/k)"}
+	r, err := c.render(vars)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if got := decryptValue(t, r.named["Api__Password"], id); got != "hunter2" {
+		t.Fatalf("decrypted %q, want hunter2", got)
+	}
+	if r.named["Api__Url"] != "http://api" || r.encrypted["Api__Url"] || !r.encrypted["Api__Password"] {
+		t.Fatalf("only the secret key should be encrypted: %v", r.named)
+	}
+	if !strings.HasPrefix(r.named["Vault__ApiKey"], "@Microsoft.KeyVault") {
+		t.Fatalf("Key Vault references should not be encrypted: %q", r.named["Vault__ApiKey"])
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import "errors"
+
+// Process exit codes. diff and drift use exitFailure to report differences.
+const (
+	exitOK         = 0
+	exitFailure    = 1
+	exitUsage      = 2
+	exitParse      = 3
+	exitValidation = 4
+	exitIO         = 5
+)
+
+// exitError attaches an exit code to an error. Wrapping it further with %w keeps the code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// parseError marks err as an unreadable input: invalid JSON, YAML or manifest
+func parseError(err error) error { return &exitError{code: exitParse, err: err} }
+
+// validationError marks err as a configuration the targets cannot represent,
+// including warnings promoted by -strict
+func validationError(err error) error { return &exitError{code: exitValidation, err: err} }
+
+// ioError marks err as a failure to read inputs or write outputs
+func ioError(err error) error { return &exitError{code: exitIO, err: err} }
+
+// exitCode returns the exit code carried by err, or fallback for unclassified errors
+func exitCode(err error, fallback int) int {
+	var e *exitError
+	if errors.As(err, &e) {
+		return e.code
+	}
+	return fallback
+}
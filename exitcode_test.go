@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"a": }`), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	_, err := processFile(bad, "__")
+	if code := exitCode(fmt.Errorf("error processing %s: %w", bad, err), exitFailure); code != exitParse {
+		t.Fatalf("syntax error: want %d got %d (%v)", exitParse, code, err)
+	}
+
+	_, err = processFile(filepath.Join(dir, "missing.json"), "__")
+	if code := exitCode(err, exitFailure); code != exitIO {
+		t.Fatalf("missing file: want %d got %d (%v)", exitIO, code, err)
+	}
+
+	_, err = resolvePlaceholders(map[string]string{"A": "${B}", "B": "${A}"})
+	if code := exitCode(err, exitFailure); code != exitValidation {
+		t.Fatalf("placeholder cycle: want %d got %d (%v)", exitValidation, code, err)
+	}
+
+	if code := exitCode(fmt.Errorf("plain"), exitUsage); code != exitUsage {
+		t.Fatalf("unclassified error: want fallback %d got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// keyOrigin is the value a single layer assigns to a key
+type keyOrigin struct {
+	source string
+	key    string
+	value  string
+	found  bool
+}
+
+// runExplain implements the explain subcommand. It accepts the same flags as the
+// conversion and prints, for one key, the value of every layer and which one wins.
+func runExplain(args []string) int {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s explain [flags] <key>:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := applyToolConfig(flag.CommandLine); err != nil {
+		logError(err)
+		return exitUsage
+	}
+	if err := setupLogging(); err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return exitUsage
+	}
+
+	sep, err := keySeparator(outputTypes.first())
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	setVars, err := parseOverrides(overrides, keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	layers, err := configurationLayers(setVars)
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+
+	key := normalizeKey(flag.Arg(0), keySep)
+	origins := explainKey(layers, key)
+	if winner(origins) < 0 {
+		errorf("key not found in any layer: %s", flag.Arg(0))
+		return exitFailure
+	}
+
+	if err := writeExplain(os.Stdout, key, sep, origins); err != nil {
+		logError(err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// explainKey looks key up case-insensitively in every layer
+func explainKey(layers []layer, key string) []keyOrigin {
+	origins := make([]keyOrigin, 0, len(layers))
+	for _, l := range layers {
+		o := keyOrigin{source: l.source}
+		for k, v := range l.vars {
+			if strings.EqualFold(k, key) {
+				o.key, o.value, o.found = k, v, true
+				break
+			}
+		}
+		origins = append(origins, o)
+	}
+	return origins
+}
+
+// winner returns the index of the last layer defining the key, or -1
+func winner(origins []keyOrigin) int {
+	for i := len(origins) - 1; i >= 0; i-- {
+		if origins[i].found {
+			return i
+		}
+	}
+	return -1
+}
+
+// writeExplain prints the origins of key, displayed with separator sep
+func writeExplain(w io.Writer, key, sep string, origins []keyOrigin) error {
+	// Merging keeps the casing of the first layer defining the key
+	for _, o := range origins {
+		if o.found {
+			key = o.key
+			break
+		}
+	}
+
+	win := winner(origins)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "key: %s\n", strings.ReplaceAll(key, keySep, sep))
+	for i, o := range origins {
+		marker := " "
+		if i == win {
+			marker = "*"
+		}
+
+		value := "(not set)"
+		if o.found {
+			value = fmt.Sprintf("%q", o.value)
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\n", marker, o.source, value)
+	}
+	return tw.Flush()
+}
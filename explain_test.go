@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestExplainKey(t *testing.T) {
+	layers := []layer{
+		{source: "appsettings.json", vars: map[string]string{"Logging__LogLevel__Default": "Information"}},
+		{source: "appsettings.Production.json", vars: map[string]string{"Other": "x"}},
+		{source: "-set", vars: map[string]string{"logging__loglevel__default": "Debug"}},
+	}
+
+	origins := explainKey(layers, "Logging__LogLevel__Default")
+	if winner(origins) != 2 {
+		t.Fatalf("expected -set to win, got %d", winner(origins))
+	}
+
+	var buf bytes.Buffer
+	if err := writeExplain(&buf, "Logging__LogLevel__Default", "__", origins); err != nil {
+		t.Fatalf("writeExplain failed: %v", err)
+	}
+
+	want := "key: Logging__LogLevel__Default\n" +
+		"  appsettings.json             \"Information\"\n" +
+		"  appsettings.Production.json  (not set)\n" +
+		"* -set                         \"Debug\"\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected explain output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	if winner(explainKey(layers, "Missing")) != -1 {
+		t.Fatalf("missing key should have no winner")
+	}
+}
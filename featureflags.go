@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// featureManagementSection is the section Microsoft.FeatureManagement reads
+const featureManagementSection = "FeatureManagement"
+
+// featureFlag is the value of an App Configuration feature flag
+type featureFlag struct {
+	ID         string         `json:"id"`
+	Enabled    bool           `json:"enabled"`
+	Conditions flagConditions `json:"conditions"`
+}
+
+// flagConditions are the filters enabling a feature flag
+type flagConditions struct {
+	ClientFilters   []clientFilter `json:"client_filters"`
+	RequirementType string         `json:"requirement_type,omitempty"`
+}
+
+// clientFilter is a feature filter and its parameters
+type clientFilter struct {
+	Name       string `json:"name"`
+	Parameters any    `json:"parameters,omitempty"`
+}
+
+// featureFlags converts the features of the FeatureManagement section of variables,
+// keyed by sep, to App Configuration feature flags keyed by their key-value names.
+// rest holds the other variables, and the features that cannot be converted, which
+// are reported and kept as key-values.
+func featureFlags(variables map[string]string, sep string) (flags, rest map[string]string) {
+	features := make(map[string]map[string]string)
+	rest = make(map[string]string, len(variables))
+	for k, v := range variables {
+		section, key, ok := strings.Cut(k, sep)
+		if !ok || !strings.EqualFold(section, featureManagementSection) {
+			rest[k] = v
+			continue
+		}
+		name, _, _ := strings.Cut(key, sep)
+		folded := strings.ToLower(name)
+		if features[folded] == nil {
+			features[folded] = make(map[string]string)
+		}
+		features[folded][k] = v
+	}
+
+	literal := func(k, v string) bool { return isLiteral(strings.ReplaceAll(k, sep, keySep), v) }
+	flags = make(map[string]string, len(features))
+	for _, folded := range sortedFold(features) {
+		// The tree holds the FeatureManagement section with this feature alone
+		tree, _ := buildTree(features[folded], sep, literal)
+		var name string
+		var value any
+		for _, section := range tree {
+			for n, v := range section.(map[string]any) {
+				name, value = n, v
+			}
+		}
+
+		flag, err := newFeatureFlag(name, value)
+		if err != nil {
+			warnf("%s%s%s: %v, kept as key-values", featureManagementSection, sep, name, err)
+			for k, v := range features[folded] {
+				rest[k] = v
+			}
+			continue
+		}
+		data, _ := json.Marshal(flag)
+		flags[appsettingsenv.FeatureFlagPrefix+name] = string(data)
+	}
+	return flags, rest
+}
+
+// newFeatureFlag returns the feature flag of a feature declared as a boolean, or as
+// an object with EnabledFor filters and a RequirementType
+func newFeatureFlag(name string, value any) (featureFlag, error) {
+	flag := featureFlag{ID: name, Conditions: flagConditions{ClientFilters: []clientFilter{}}}
+	switch v := value.(type) {
+	case bool:
+		flag.Enabled = v
+		return flag, nil
+	case string:
+		if !strings.EqualFold(v, "true") && !strings.EqualFold(v, "false") {
+			return flag, fmt.Errorf("%q is not a boolean", v)
+		}
+		flag.Enabled = strings.EqualFold(v, "true")
+		return flag, nil
+	case map[string]any:
+		for _, k := range sortedFold(v) {
+			c := v[k]
+			switch strings.ToLower(k) {
+			case "enabledfor":
+				filters, ok := c.([]any)
+				if !ok {
+					return flag, fmt.Errorf("%s is not an array", k)
+				}
+				for _, f := range filters {
+					filter, err := newClientFilter(f)
+					if err != nil {
+						return flag, err
+					}
+					flag.Enabled = true
+					// AlwaysOn is what an enabled flag without filters means
+					if !strings.EqualFold(filter.Name, "AlwaysOn") && !strings.EqualFold(filter.Name, "Microsoft.AlwaysOn") {
+						flag.Conditions.ClientFilters = append(flag.Conditions.ClientFilters, filter)
+					}
+				}
+			case "requirementtype":
+				s, ok := c.(string)
+				if !ok || !strings.EqualFold(s, "Any") && !strings.EqualFold(s, "All") {
+					return flag, fmt.Errorf("%s must be Any or All", k)
+				}
+				flag.Conditions.RequirementType = s
+			default:
+				return flag, fmt.Errorf("%s has no feature flag equivalent", k)
+			}
+		}
+		return flag, nil
+	}
+	return flag, fmt.Errorf("unsupported declaration")
+}
+
+// newClientFilter returns the filter of an EnabledFor element
+func newClientFilter(value any) (clientFilter, error) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return clientFilter{}, fmt.Errorf("EnabledFor elements must be objects")
+	}
+	var filter clientFilter
+	for _, k := range sortedFold(m) {
+		c := m[k]
+		switch strings.ToLower(k) {
+		case "name":
+			filter.Name, _ = c.(string)
+		case "parameters":
+			filter.Parameters = c
+		default:
+			return filter, fmt.Errorf("EnabledFor element key %s is not supported", k)
+		}
+	}
+	if filter.Name == "" {
+		return filter, fmt.Errorf("EnabledFor element has no Name")
+	}
+	return filter, nil
+}
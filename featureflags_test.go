@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+	clear(literals)
+	defer clear(literals)
+	recordLiteral("FeatureManagement:Delta:EnabledFor:0:Parameters:Value", "50")
+
+	vars := map[string]string{
+		"Api:Url":                                                 "https://api",
+		"FeatureManagement:Beta":                                  "true",
+		"featuremanagement:Gamma":                                 "False",
+		"FeatureManagement:Bad":                                   "maybe",
+		"FeatureManagement:On:EnabledFor:0:Name":                  "AlwaysOn",
+		"FeatureManagement:Delta:EnabledFor:0:Name":               "Microsoft.Percentage",
+		"FeatureManagement:Delta:EnabledFor:0:Parameters:Value":   "50",
+		"FeatureManagement:Delta:EnabledFor:1:Name":               "TimeWindow",
+		"FeatureManagement:Delta:EnabledFor:1:Parameters:Start":   "Mon, 01 May 2023 13:59:59 GMT",
+		"FeatureManagement:Delta:RequirementType":                 "All",
+		"FeatureManagement:Variant:Allocation:DefaultWhenEnabled": "Big",
+	}
+	flags, rest := featureFlags(vars, ":")
+
+	want := map[string]string{
+		"Beta":  `{"id":"Beta","enabled":true,"conditions":{"client_filters":[]}}`,
+		"Gamma": `{"id":"Gamma","enabled":false,"conditions":{"client_filters":[]}}`,
+		"On":    `{"id":"On","enabled":true,"conditions":{"client_filters":[]}}`,
+		"Delta": `{"id":"Delta","enabled":true,"conditions":{"client_filters":[{"name":"Microsoft.Percentage","parameters":{"Value":50}},` +
+			`{"name":"TimeWindow","parameters":{"Start":"Mon, 01 May 2023 13:59:59 GMT"}}],"requirement_type":"All"}}`,
+	}
+	if len(flags) != len(want) {
+		t.Fatalf("unexpected flags: %v", flags)
+	}
+	for name, value := range want {
+		if got := flags[appsettingsenv.FeatureFlagPrefix+name]; got != value {
+			t.Fatalf("unexpected %s flag:\n%s\nwant:\n%s", name, got, value)
+		}
+		var f featureFlag
+		if err := json.Unmarshal([]byte(flags[appsettingsenv.FeatureFlagPrefix+name]), &f); err != nil || f.ID != name {
+			t.Fatalf("invalid %s flag: %v", name, err)
+		}
+	}
+
+	// Features without a feature flag equivalent stay key-values
+	if len(rest) != 3 || rest["Api:Url"] == "" || rest["FeatureManagement:Bad"] != "maybe" || rest["FeatureManagement:Variant:Allocation:DefaultWhenEnabled"] != "Big" {
+		t.Fatalf("unexpected remaining variables: %v", rest)
+	}
+}
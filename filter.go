@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// keyFilter selects flattened keys by include and exclude patterns
+type keyFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+	// patterns holds the include then exclude patterns as given, for reasons
+	patterns []string
+}
+
+// newKeyFilter compiles include/exclude patterns. Patterns are case-insensitive globs
+// (* and ?) in __ or : notation, or regular expressions when prefixed with "re:".
+func newKeyFilter(include, exclude []string, sep string) (*keyFilter, error) {
+	f := &keyFilter{}
+	for _, p := range include {
+		re, err := compilePattern(p, sep)
+		if err != nil {
+			return nil, err
+		}
+		f.include = append(f.include, re)
+	}
+	f.patterns = append(f.patterns, include...)
+	for _, p := range exclude {
+		re, err := compilePattern(p, sep)
+		if err != nil {
+			return nil, err
+		}
+		f.exclude = append(f.exclude, re)
+	}
+	f.patterns = append(f.patterns, exclude...)
+	return f, nil
+}
+
+// compilePattern turns a glob or "re:" pattern into an anchored regular expression
+func compilePattern(pattern, sep string) (*regexp.Regexp, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return re, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range normalizeKey(pattern, sep) {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// match reports whether key passes the filter
+func (f *keyFilter) match(key string) bool {
+	if len(f.include) > 0 && !anyMatch(f.include, key) {
+		return false
+	}
+	return !anyMatch(f.exclude, key)
+}
+
+// apply returns the variables whose keys pass the filter
+func (f *keyFilter) apply(vars map[string]string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if f.match(k) {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// reason explains why match selects key or not: by the first include pattern
+// matching it, the first exclude pattern matching it, or the lack of an include one
+func (f *keyFilter) reason(key string) string {
+	for i, re := range f.exclude {
+		if re.MatchString(key) {
+			return fmt.Sprintf("matches the exclude pattern %q", f.patterns[len(f.include)+i])
+		}
+	}
+	for i, re := range f.include {
+		if re.MatchString(key) {
+			return fmt.Sprintf("matches %q", f.patterns[i])
+		}
+	}
+	if len(f.include) > 0 {
+		return "matches no include pattern"
+	}
+	return ""
+}
+
+func anyMatch(patterns []*regexp.Regexp, key string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// presets are the built-in -preset section lists
+var presets = map[string][]string{
+	// aspnet drops sections only meaningful on a developer machine
+	"aspnet": {"Logging", "AllowedHosts", "DetailedErrors", "Kestrel:Certificates:Development"},
+}
+
+// presetPatterns returns the exclude patterns of the named presets. Custom presets of
+// the configuration file take precedence over the built-in ones. Every entry excludes
+// a section and everything beneath it, or is used as is when it is a "re:" pattern.
+func presetPatterns(names []string, cfg *toolConfig) ([]string, error) {
+	var patterns []string
+	for _, name := range names {
+		sections, ok := cfg.Presets[name]
+		if !ok {
+			sections, ok = presets[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown preset: %q", name)
+		}
+
+		for _, s := range sections {
+			if strings.HasPrefix(s, "re:") {
+				patterns = append(patterns, s)
+				continue
+			}
+			patterns = append(patterns, s, s+keySep+"*")
+		}
+	}
+	return patterns, nil
+}
+
+// outputFilter builds the key filter of the -include, -exclude and -preset flags
+func outputFilter(sep string) (*keyFilter, error) {
+	exclude := excludes
+	if len(presetNames) > 0 {
+		patterns, err := presetPatterns(presetNames, projectConfig)
+		if err != nil {
+			return nil, err
+		}
+		exclude = append(exclude[:len(exclude):len(exclude)], patterns...)
+	}
+	return newKeyFilter(includes, exclude, sep)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyFilter(t *testing.T) {
+	vars := map[string]string{
+		"Logging__LogLevel__Default": "Information",
+		"Logging__Console__Enabled":  "true",
+		"Api__Url":                   "http://localhost",
+		"Api__Key":                   "secret",
+		"Serilog__MinimumLevel":      "Debug",
+	}
+
+	f, err := newKeyFilter([]string{"logging:*", "Api__*"}, []string{"re:__Key$", "Logging__Console__*"}, "__")
+	if err != nil {
+		t.Fatalf("newKeyFilter failed: %v", err)
+	}
+
+	got := f.apply(vars)
+	want := []string{"Logging__LogLevel__Default", "Api__Url"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected filtered keys: %v", got)
+	}
+	for _, k := range want {
+		if _, ok := got[k]; !ok {
+			t.Fatalf("missing key %q in %v", k, got)
+		}
+	}
+
+	if _, err := newKeyFilter(nil, []string{"re:("}, "__"); err == nil {
+		t.Fatalf("expected error for invalid regular expression")
+	}
+}
+
+func TestPresetPatterns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFileName)
+	if err := os.WriteFile(path, []byte("presets:\n  internal:\n    - Telemetry\n    - re:^Features__Experimental\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadToolConfig(path)
+	if err != nil {
+		t.Fatalf("loadToolConfig failed: %v", err)
+	}
+
+	patterns, err := presetPatterns([]string{"aspnet", "internal"}, cfg)
+	if err != nil {
+		t.Fatalf("presetPatterns failed: %v", err)
+	}
+	f, err := newKeyFilter(nil, patterns, "__")
+	if err != nil {
+		t.Fatalf("newKeyFilter failed: %v", err)
+	}
+
+	for key, want := range map[string]bool{
+		"Logging__LogLevel__Default":                   false,
+		"AllowedHosts":                                 false,
+		"Kestrel__Certificates__Development__Password": false,
+		"Kestrel__Endpoints__Http__Url":                true,
+		"Telemetry__Endpoint":                          false,
+		"Features__ExperimentalSearch":                 false,
+		"LoggingEnabled":                               true,
+	} {
+		if got := f.match(key); got != want {
+			t.Fatalf("match(%q): want %v got %v", key, want, got)
+		}
+	}
+
+	if _, err := presetPatterns([]string{"unknown"}, cfg); err == nil {
+		t.Fatalf("expected unknown preset error")
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// genTargets lists the artifacts gen derives from the configuration
+var genTargets []target
+
+func init() {
+	genTargets = []target{
+		{"csharp", "C# options classes for the sections, with their IOptions binding", runGenCSharp},
+		{"schema", "JSON Schema inferred from the files of every environment", runGenSchema},
+		{"template", "appsettings.json template with ${NAME} placeholders or empty values", runGenTemplate},
+	}
+}
+
+// runGen implements the gen subcommand, which writes code and documents derived from
+// the structure of the configuration
+func runGen(args []string) int {
+	return runTarget("gen", genTargets, args)
+}
+
+// parseGenFlags parses the command line of a gen target, returning exitOK or the
+// exit code of a usage error
+func parseGenFlags(command string, args []string) int {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s %s [flags]:\n", os.Args[0], command)
+		flag.PrintDefaults()
+	}
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return exitUsage
+	}
+	if err := applyToolConfig(flag.CommandLine); err != nil {
+		logError(err)
+		return exitUsage
+	}
+	if err := setupLogging(); err != nil {
+		logError(err)
+		return exitUsage
+	}
+	if flag.NArg() > 0 {
+		errorf("unexpected argument: %q", flag.Arg(0))
+		return exitUsage
+	}
+	return exitOK
+}
+
+// loadTree parses the command line of a gen target and returns the merged
+// configuration as a nested tree, with the names of its sources
+func loadTree(command string, args []string) (map[string]any, []string, int) {
+	if code := parseGenFlags(command, args); code != exitOK {
+		return nil, nil, code
+	}
+
+	setVars, err := parseOverrides(overrides, keySep)
+	if err != nil {
+		logError(err)
+		return nil, nil, exitUsage
+	}
+	layers, err := configurationLayers(setVars)
+	if err != nil {
+		logError(err)
+		return nil, nil, exitCode(err, exitFailure)
+	}
+
+	sources := make([]string, 0, len(layers))
+	for _, l := range layers {
+		sources = append(sources, l.source)
+	}
+	tree, conflicts := buildTree(mergeLayers(layers), keySep, isLiteral)
+	for _, c := range conflicts {
+		warnf("%s is both a value and a section, its value is ignored", c)
+	}
+	return tree, sources, exitOK
+}
+
+// writeGenerated writes the output of a gen target to -out, or to stdout
+func writeGenerated(data []byte) int {
+	if *outPath == "" {
+		if _, err := os.Stdout.Write(data); err != nil {
+			logError(ioError(err))
+			return exitIO
+		}
+		return exitOK
+	}
+	if err := writeFileAtomic(*outPath, data, 0o644); err != nil {
+		logError(ioError(err))
+		return exitIO
+	}
+	infof("wrote %s", *outPath)
+	return exitOK
+}
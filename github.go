@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// githubClient is a GitHub REST API client for Actions secrets and variables
+type githubClient struct {
+	restClient
+}
+
+// newGitHubClient returns a client of the REST API at api
+func newGitHubClient(api, token string) *githubClient {
+	return &githubClient{newRESTClient("GitHub API", api, func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})}
+}
+
+// githubPageSize is the page size used to list secrets and variables, the API maximum
+const githubPageSize = 100
+
+// githubScope returns the API path holding the secrets and variables of a
+// repository, or of one of its environments
+func githubScope(repo, environment string) string {
+	if environment == "" {
+		return "repos/" + repo + "/actions"
+	}
+	return "repos/" + repo + "/environments/" + url.PathEscape(environment)
+}
+
+// variables returns the values of the variables of scope
+func (c *githubClient) variables(ctx context.Context, scope string) (map[string]string, error) {
+	values := make(map[string]string)
+	for page := 1; ; page++ {
+		var resp struct {
+			Variables []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"variables"`
+		}
+		path := fmt.Sprintf("%s/variables?per_page=%d&page=%d", scope, githubPageSize, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("list variables: %w", err)
+		}
+		for _, v := range resp.Variables {
+			values[v.Name] = v.Value
+		}
+		if len(resp.Variables) < githubPageSize {
+			return values, nil
+		}
+	}
+}
+
+// secrets returns the names of the secrets of scope. GitHub never returns their values.
+func (c *githubClient) secrets(ctx context.Context, scope string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for page := 1; ; page++ {
+		var resp struct {
+			Secrets []struct {
+				Name string `json:"name"`
+			} `json:"secrets"`
+		}
+		path := fmt.Sprintf("%s/secrets?per_page=%d&page=%d", scope, githubPageSize, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+			return nil, fmt.Errorf("list secrets: %w", err)
+		}
+		for _, s := range resp.Secrets {
+			names[s.Name] = true
+		}
+		if len(resp.Secrets) < githubPageSize {
+			return names, nil
+		}
+	}
+}
+
+// githubPublicKey is the key secrets of a scope are encrypted with
+type githubPublicKey struct {
+	id  string
+	key [32]byte
+}
+
+func (c *githubClient) publicKey(ctx context.Context, scope string) (*githubPublicKey, error) {
+	var resp struct {
+		KeyID string `json:"key_id"`
+		Key   string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodGet, scope+"/secrets/public-key", nil, &resp); err != nil {
+		return nil, fmt.Errorf("get public key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(resp.Key)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("get public key: not a base64 Curve25519 key")
+	}
+	pk := &githubPublicKey{id: resp.KeyID}
+	copy(pk.key[:], key)
+	return pk, nil
+}
+
+// setSecret encrypts value in a libsodium sealed box for the public key of the
+// scope, and creates or updates the secret name with it
+func (c *githubClient) setSecret(ctx context.Context, scope string, pk *githubPublicKey, name, value string) error {
+	sealed, err := box.SealAnonymous(nil, []byte(value), &pk.key, rand.Reader)
+	if err != nil {
+		return fmt.Errorf("encrypt secret %s: %w", name, err)
+	}
+	body := map[string]string{
+		"encrypted_value": base64.StdEncoding.EncodeToString(sealed),
+		"key_id":          pk.id,
+	}
+	if err := c.do(ctx, http.MethodPut, scope+"/secrets/"+name, body, nil); err != nil {
+		return fmt.Errorf("set secret %s: %w", name, err)
+	}
+	return nil
+}
+
+// setVariable updates the variable name, or creates it when it does not exist
+func (c *githubClient) setVariable(ctx context.Context, scope, name, value string, exists bool) error {
+	body := map[string]string{"name": name, "value": value}
+	var err error
+	if exists {
+		err = c.do(ctx, http.MethodPatch, scope+"/variables/"+name, body, nil)
+	} else {
+		err = c.do(ctx, http.MethodPost, scope+"/variables", body, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("set variable %s: %w", name, err)
+	}
+	return nil
+}
+
+// runPushGitHub implements push github. It writes the variables matched by
+// -secret-keys as Actions secrets and the others as Actions variables, of a
+// repository or of one of its environments.
+func runPushGitHub(args []string) int {
+	repo := flag.String("repo", "", "Repository as owner/name (default: $GITHUB_REPOSITORY)")
+	environment := flag.String("environment", "", "Deployment environment of the repository (default: repository-level)")
+	apiURL := flag.String("api-url", "", "REST API URL, for GitHub Enterprise Server (default: $GITHUB_API_URL or https://api.github.com)")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push github [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "The token is read from $GITHUB_TOKEN or $GH_TOKEN. Names are upper-cased, as")
+		fmt.Fprintln(flag.CommandLine.Output(), "GitHub stores them.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// The k8s type emits __ names and keeps multi-line values, which secrets can hold
+	r, code := renderOutput("push github", "k8s", args)
+	if r == nil {
+		return code
+	}
+
+	if *repo == "" {
+		*repo = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if owner, name, ok := strings.Cut(*repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		errorf("-repo is required as owner/name when GITHUB_REPOSITORY is not set")
+		return exitUsage
+	}
+	if *apiURL == "" {
+		*apiURL = os.Getenv("GITHUB_API_URL")
+	}
+	if *apiURL == "" {
+		*apiURL = "https://api.github.com"
+	}
+
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		errorf("GITHUB_TOKEN is not set")
+		return exitUsage
+	}
+
+	store := &githubStore{client: newGitHubClient(*apiURL, token), scope: githubScope(*repo, *environment)}
+	if err := pushSecretStore(context.Background(), os.Stdout, store, r, *dryRun); err != nil {
+		logError(err)
+		return exitCode(err, exitIO)
+	}
+	return exitOK
+}
+
+// githubStore is the secretStore of the Actions secrets and variables of a scope.
+// The -secret-keys become secrets, which GitHub never returns, so they are rewritten
+// on every push; the other variables become variables.
+type githubStore struct {
+	client *githubClient
+	scope  string
+}
+
+func (s *githubStore) String() string {
+	return "GitHub " + s.scope
+}
+
+// entry upper-cases name, as GitHub stores it
+func (s *githubStore) entry(name, value string, secret bool) (*secretEntry, error) {
+	// GitHub rejects empty secrets and variables
+	if value == "" {
+		warnf("%s: empty value, skipped", name)
+		return nil, nil
+	}
+	upper := strings.ToUpper(name)
+	if !validName(upper, identifierRule) || strings.HasPrefix(upper, "GITHUB_") {
+		return nil, fmt.Errorf("%s: expected %s, not starting with GITHUB_", name, identifierRule.description)
+	}
+	return &secretEntry{name: upper, value: value, secret: secret}, nil
+}
+
+// entries returns the variables of the scope, and its secrets without their values
+func (s *githubStore) entries(ctx context.Context, _ []string) (map[string]secretEntry, error) {
+	variables, err := s.client.variables(ctx, s.scope)
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := s.client.secrets(ctx, s.scope)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]secretEntry, len(variables)+len(secrets))
+	for name := range secrets {
+		entries[name] = secretEntry{name: name, secret: true, writeOnly: true}
+	}
+	for name, value := range variables {
+		entries[name] = secretEntry{name: name, value: value}
+	}
+	return entries, nil
+}
+
+// write writes the changed secrets, encrypted for the public key of the scope, then
+// the changed variables
+func (s *githubStore) write(ctx context.Context, changed []secretEntry, current map[string]secretEntry) error {
+	var pk *githubPublicKey
+	for _, e := range changed {
+		if !e.secret {
+			continue
+		}
+		if pk == nil {
+			var err error
+			if pk, err = s.client.publicKey(ctx, s.scope); err != nil {
+				return err
+			}
+		}
+		if err := s.client.setSecret(ctx, s.scope, pk, e.name, e.value); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range changed {
+		if e.secret {
+			continue
+		}
+		old, exists := current[e.name]
+		if err := s.client.setVariable(ctx, s.scope, e.name, e.value, exists && !old.writeOnly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
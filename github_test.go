@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+func TestGitHubPush(t *testing.T) {
+	pub, priv, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scope := "/repos/octo/api/environments/prod%20eu"
+	variables := map[string]string{"LOGGING__LEVEL": "Information", "UNCHANGED": "same", "OTHER": "kept"}
+	secrets := map[string]string{"API_KEY": ""}
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		switch path := r.URL.EscapedPath(); {
+		case r.Method == http.MethodGet && path == scope+"/variables":
+			var list []map[string]string
+			for name, value := range variables {
+				list = append(list, map[string]string{"name": name, "value": value})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"variables": list})
+		case r.Method == http.MethodGet && path == scope+"/secrets":
+			var list []map[string]string
+			for name := range secrets {
+				list = append(list, map[string]string{"name": name})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"secrets": list})
+		case r.Method == http.MethodGet && path == scope+"/secrets/public-key":
+			json.NewEncoder(w).Encode(map[string]string{"key_id": "42", "key": base64.StdEncoding.EncodeToString(pub[:])})
+		case r.Method == http.MethodPut && strings.HasPrefix(path, scope+"/secrets/"):
+			sealed, _ := base64.StdEncoding.DecodeString(body["encrypted_value"])
+			value, ok := box.OpenAnonymous(nil, sealed, pub, priv)
+			if !ok || body["key_id"] != "42" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			name := strings.TrimPrefix(path, scope+"/secrets/")
+			secrets[name] = string(value)
+			writes = append(writes, "secret "+name)
+		case r.Method == http.MethodPatch && path == scope+"/variables/"+body["name"],
+			r.Method == http.MethodPost && path == scope+"/variables":
+			variables[body["name"]] = body["value"]
+			writes = append(writes, "variable "+body["name"])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	client := newGitHubClient(srv.URL, "token")
+	store := &githubStore{client: client, scope: githubScope("octo/api", "prod eu")}
+	r := &rendered{
+		named:  map[string]string{"Logging__Level": "Warning", "Unchanged": "same", "New": "x", "Api_Key": "k3y", "Db__Password": "s3cret"},
+		secret: map[string]bool{"Api_Key": true, "Db__Password": true},
+	}
+
+	var out bytes.Buffer
+	if err := pushSecretStore(context.Background(), &out, store, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "changed API_KEY: \"(secret)\" -> \"(secret)\"\n" +
+		"added   DB__PASSWORD: \"(secret)\"\n" +
+		"changed LOGGING__LEVEL: \"Information\" -> \"Warning\"\n" +
+		"added   NEW: \"x\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if len(writes) != 0 {
+		t.Fatalf("dry run should not write: %v", writes)
+	}
+
+	out.Reset()
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2 secret(s) and 2 variable(s) written to GitHub repos/octo/api/environments/prod%20eu\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	if strings.Join(writes, ",") != "secret API_KEY,secret DB__PASSWORD,variable LOGGING__LEVEL,variable NEW" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+	if secrets["DB__PASSWORD"] != "s3cret" || variables["OTHER"] != "kept" || variables["NEW"] != "x" {
+		t.Fatalf("unexpected state: %v %v", secrets, variables)
+	}
+
+	r = &rendered{named: map[string]string{"GitHub_Token": "x"}}
+	store = &githubStore{client: client, scope: githubScope("octo/api", "")}
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err == nil {
+		t.Fatalf("reserved GITHUB_ prefix should fail")
+	}
+}
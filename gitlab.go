@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gitlabClient is a GitLab REST API client for CI/CD variables
+type gitlabClient struct {
+	restClient
+}
+
+// newGitLabClient returns a client of the v4 API at api
+func newGitLabClient(api, token string) *gitlabClient {
+	return &gitlabClient{newRESTClient("GitLab API", api, func(_ context.Context, req *http.Request) error {
+		req.Header.Set("PRIVATE-TOKEN", token)
+		return nil
+	})}
+}
+
+// gitlabVariable is a CI/CD variable
+type gitlabVariable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	Protected        bool   `json:"protected"`
+	Masked           bool   `json:"masked"`
+	Raw              bool   `json:"raw"`
+	EnvironmentScope string `json:"environment_scope,omitempty"`
+}
+
+// gitlabPageSize is the page size used to list variables, the API maximum
+const gitlabPageSize = 100
+
+// minMaskedLength is the shortest value GitLab accepts for a masked variable
+const minMaskedLength = 8
+
+// gitlabScope returns the API path of a project or group, given by ID or full path
+func gitlabScope(project, group string) string {
+	if group != "" {
+		return "groups/" + url.PathEscape(group)
+	}
+	return "projects/" + url.PathEscape(project)
+}
+
+// variables returns the variables of scope in the environment scope envScope
+func (c *gitlabClient) variables(ctx context.Context, scope, envScope string) (map[string]gitlabVariable, error) {
+	vars := make(map[string]gitlabVariable)
+	for page := 1; ; page++ {
+		var list []gitlabVariable
+		path := fmt.Sprintf("%s/variables?per_page=%d&page=%d", scope, gitlabPageSize, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &list); err != nil {
+			return nil, fmt.Errorf("list variables: %w", err)
+		}
+		for _, v := range list {
+			// Group variables only have a scope on Premium; the others apply everywhere
+			if v.EnvironmentScope == "" || v.EnvironmentScope == envScope {
+				vars[v.Key] = v
+			}
+		}
+		if len(list) < gitlabPageSize {
+			return vars, nil
+		}
+	}
+}
+
+// setVariable updates the variable v in its environment scope, or creates it when
+// it does not exist
+func (c *gitlabClient) setVariable(ctx context.Context, scope string, v gitlabVariable, exists bool) error {
+	var err error
+	if exists {
+		query := url.Values{"filter[environment_scope]": {v.EnvironmentScope}}
+		err = c.do(ctx, http.MethodPut, scope+"/variables/"+v.Key+"?"+query.Encode(), v, nil)
+	} else {
+		err = c.do(ctx, http.MethodPost, scope+"/variables", v, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("set variable %s: %w", v.Key, err)
+	}
+	return nil
+}
+
+// runPushGitLab implements push gitlab. It writes the variables as CI/CD variables
+// of a project or group, the -secret-keys masked and protected.
+func runPushGitLab(args []string) int {
+	project := flag.String("project", "", "Project ID or full path, e.g. group/api (default: $CI_PROJECT_ID)")
+	group := flag.String("group", "", "Group ID or full path, to write group variables instead of project ones")
+	envScope := flag.String("environment-scope", "*", "Environment scope of the variables")
+	protect := flag.Bool("protect", true, "Protect the secret variables, exposing them to protected branches and tags only")
+	apiURL := flag.String("api-url", "", "v4 API URL, for self-managed instances (default: $CI_API_V4_URL or https://gitlab.com/api/v4)")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push gitlab [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "The token is read from $GITLAB_TOKEN and needs the api scope.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// The k8s type emits __ names and keeps multi-line values
+	r, code := renderOutput("push gitlab", "k8s", args)
+	if r == nil {
+		return code
+	}
+
+	if *project == "" && *group == "" {
+		*project = os.Getenv("CI_PROJECT_ID")
+	}
+	if *project == "" && *group == "" {
+		errorf("-project or -group is required when CI_PROJECT_ID is not set")
+		return exitUsage
+	}
+	if *project != "" && *group != "" {
+		errorf("-project and -group cannot be used together")
+		return exitUsage
+	}
+	if *apiURL == "" {
+		*apiURL = os.Getenv("CI_API_V4_URL")
+	}
+	if *apiURL == "" {
+		*apiURL = "https://gitlab.com/api/v4"
+	}
+
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		errorf("GITLAB_TOKEN is not set")
+		return exitUsage
+	}
+
+	store := &gitlabStore{client: newGitLabClient(*apiURL, token), scope: gitlabScope(*project, *group), envScope: *envScope, protect: *protect}
+	if err := pushSecretStore(context.Background(), os.Stdout, store, r, *dryRun); err != nil {
+		logError(err)
+		return exitCode(err, exitIO)
+	}
+	return exitOK
+}
+
+// maskable reports whether GitLab can mask value in job logs
+func maskable(value string) bool {
+	return len(value) >= minMaskedLength && !strings.ContainsAny(value, "\r\n")
+}
+
+// gitlabStore is the secretStore of the CI/CD variables of a project or group in an
+// environment scope. Variables are created raw, so that GitLab does not expand $
+// references in the values, and the -secret-keys masked and, with protect, protected.
+type gitlabStore struct {
+	client   *gitlabClient
+	scope    string
+	envScope string
+	protect  bool
+}
+
+func (s *gitlabStore) String() string {
+	return "GitLab " + s.scope
+}
+
+// gitlabAttrs returns the flags of v, as compared between pushes
+func gitlabAttrs(v gitlabVariable) string {
+	return fmt.Sprintf("protected=%t masked=%t raw=%t", v.Protected, v.Masked, v.Raw)
+}
+
+func (s *gitlabStore) variable(e secretEntry) gitlabVariable {
+	return gitlabVariable{
+		Key:              e.name,
+		Value:            e.value,
+		Protected:        e.secret && s.protect,
+		Masked:           e.secret,
+		Raw:              true,
+		EnvironmentScope: s.envScope,
+	}
+}
+
+func (s *gitlabStore) entry(name, value string, secret bool) (*secretEntry, error) {
+	if !validName(name, identifierRule) {
+		return nil, fmt.Errorf("%s: expected %s", name, identifierRule.description)
+	}
+	if secret && !maskable(value) {
+		return nil, fmt.Errorf("%s: GitLab only masks values of at least %d characters on a single line", name, minMaskedLength)
+	}
+	e := &secretEntry{name: name, value: value, secret: secret}
+	e.attrs = gitlabAttrs(s.variable(*e))
+	return e, nil
+}
+
+// entries returns the variables of the environment scope, masked ones as secrets
+func (s *gitlabStore) entries(ctx context.Context, _ []string) (map[string]secretEntry, error) {
+	vars, err := s.client.variables(ctx, s.scope, s.envScope)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]secretEntry, len(vars))
+	for k, v := range vars {
+		entries[k] = secretEntry{name: k, value: v.Value, secret: v.Masked, attrs: gitlabAttrs(v)}
+	}
+	return entries, nil
+}
+
+func (s *gitlabStore) write(ctx context.Context, changed []secretEntry, current map[string]secretEntry) error {
+	for _, e := range changed {
+		_, exists := current[e.name]
+		if err := s.client.setVariable(ctx, s.scope, s.variable(e), exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitLabPush(t *testing.T) {
+	scope := "/projects/group%2Fapi"
+	variables := map[string]gitlabVariable{
+		"Logging__Level": {Key: "Logging__Level", Value: "Information", Raw: true, EnvironmentScope: "production"},
+		"Unchanged":      {Key: "Unchanged", Value: "same", Raw: true, EnvironmentScope: "production"},
+		"Api__Key":       {Key: "Api__Key", Value: "0123456789", Raw: true, EnvironmentScope: "production"},
+		"Other":          {Key: "Other", Value: "staging", Raw: true, EnvironmentScope: "staging"},
+	}
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var v gitlabVariable
+		json.NewDecoder(r.Body).Decode(&v)
+
+		switch path := r.URL.EscapedPath(); {
+		case r.Method == http.MethodGet && path == scope+"/variables":
+			list := []gitlabVariable{}
+			for _, v := range variables {
+				list = append(list, v)
+			}
+			json.NewEncoder(w).Encode(list)
+		case r.Method == http.MethodPut && path == scope+"/variables/"+v.Key && r.URL.Query().Get("filter[environment_scope]") == "production",
+			r.Method == http.MethodPost && path == scope+"/variables":
+			variables[v.Key] = v
+			writes = append(writes, r.Method+" "+v.Key)
+			json.NewEncoder(w).Encode(v)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	store := &gitlabStore{client: newGitLabClient(srv.URL, "token"), scope: gitlabScope("group/api", ""), envScope: "production", protect: true}
+	r := &rendered{
+		named:  map[string]string{"Logging__Level": "Warning", "Unchanged": "same", "Api__Key": "0123456789", "Db__Password": "$ecret-value"},
+		secret: map[string]bool{"Api__Key": true, "Db__Password": true},
+	}
+
+	var out bytes.Buffer
+	if err := pushSecretStore(context.Background(), &out, store, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "changed Api__Key: \"(secret)\" -> \"(secret)\"\n" +
+		"added   Db__Password: \"(secret)\"\n" +
+		"changed Logging__Level: \"Information\" -> \"Warning\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2 secret(s) and 1 variable(s) written to GitLab projects/group%2Fapi\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	if strings.Join(writes, ",") != "PUT Api__Key,POST Db__Password,PUT Logging__Level" {
+		t.Fatalf("unexpected writes: %v", writes)
+	}
+	if v := variables["Db__Password"]; !v.Masked || !v.Protected || !v.Raw || v.EnvironmentScope != "production" {
+		t.Fatalf("secret should be masked, protected and raw: %+v", v)
+	}
+	if v := variables["Logging__Level"]; v.Masked || v.Protected {
+		t.Fatalf("plain variable should not be masked: %+v", v)
+	}
+	if variables["Other"].Value != "staging" {
+		t.Fatalf("variables of other scopes should be kept")
+	}
+
+	r = &rendered{named: map[string]string{"Short": "abc"}, secret: map[string]bool{"Short": true}}
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err == nil {
+		t.Fatalf("unmaskable secret should fail")
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import "strings"
+
+// hostVariables returns the variables the ASP.NET Core host reads from the process
+// environment, emitted by -host-vars: the environment name of env for the web and
+// the generic host, and ASPNETCORE_URLS listing the Kestrel endpoints of variables,
+// keyed by canonical ":" keys.
+func hostVariables(variables map[string]string, env string) map[string]string {
+	host := make(map[string]string)
+	if env != "" {
+		host["ASPNETCORE_ENVIRONMENT"] = env
+		host["DOTNET_ENVIRONMENT"] = env
+	}
+	if urls := endpointURLs(variables); len(urls) > 0 {
+		host["ASPNETCORE_URLS"] = strings.Join(urls, ";")
+	}
+	return host
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestHostVariables(t *testing.T) {
+	variables := map[string]string{
+		"Kestrel:Endpoints:Https:Url":              "https://+:8443",
+		"Kestrel:Endpoints:Http:Url":               "http://+:8080",
+		"Kestrel:Endpoints:Http:Protocols":         "Http1",
+		"kestrel:endpoints:Grpc:url":               "http://+:5000",
+		"Kestrel:Endpoints:Https:Certificate:Path": "/certs/tls.pfx",
+	}
+	got := hostVariables(variables, "Production")
+	want := map[string]string{
+		"ASPNETCORE_ENVIRONMENT": "Production",
+		"DOTNET_ENVIRONMENT":     "Production",
+		"ASPNETCORE_URLS":        "http://+:5000;http://+:8080;https://+:8443",
+	}
+	if !maps.Equal(got, want) {
+		t.Fatalf("unexpected host variables: %v", got)
+	}
+
+	if got := hostVariables(map[string]string{"Logging:LogLevel:Default": "Debug"}, ""); len(got) != 0 {
+		t.Fatalf("no environment nor endpoint should give no variable: %v", got)
+	}
+}
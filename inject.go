@@ -0,0 +1,843 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// injectTargets lists the file kinds inject updates
+var injectTargets []target
+
+func init() {
+	injectTargets = []target{
+		{"compose", "Update the environment of a service in a docker-compose.yml", runInjectCompose},
+		{"k8s", "Update the env of a workload container, or its ConfigMap, in a manifest", runInjectK8s},
+		{"bicep", "Replace the entries of a marked or named env array in a Bicep file", runInjectBicep},
+	}
+}
+
+// runInject implements the inject subcommand, which writes the converted variables
+// into an existing file, leaving the rest of the file as it is
+func runInject(args []string) int {
+	return runTarget("inject", injectTargets, args)
+}
+
+// lineEdit replaces the lines [start, end) of a file, 0-based, with lines
+type lineEdit struct {
+	start, end int
+	lines      []string
+}
+
+// applyEdits applies edits, which must not overlap, to lines
+func applyEdits(lines []string, edits []lineEdit) []string {
+	// From the bottom up, so that the positions of the remaining edits hold
+	slices.SortStableFunc(edits, func(a, b lineEdit) int { return b.start - a.start })
+	for _, e := range edits {
+		lines = slices.Replace(lines, e.start, e.end, e.lines...)
+	}
+	return lines
+}
+
+// indentOf returns the number of leading spaces of line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isBlankOrComment reports whether a YAML line holds no content
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || strings.HasPrefix(trimmed, "#")
+}
+
+// blockEnd returns the index of the line after the YAML block starting at line
+// start: the following lines indented deeper than start. Blank and comment lines
+// trailing the block are left out of it.
+func blockEnd(lines []string, start int) int {
+	indent := indentOf(lines[start])
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		if isBlankOrComment(lines[i]) {
+			continue
+		}
+		if indentOf(lines[i]) <= indent {
+			break
+		}
+		end = i + 1
+	}
+	return end
+}
+
+// mappingEntry returns the key and value nodes of key in the mapping node n
+func mappingEntry(n *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i], n.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// fileLines splits data into lines, reporting whether they end with CRLF
+func fileLines(data []byte) ([]string, bool) {
+	crlf := bytes.Contains(data, []byte("\r\n"))
+	text := strings.TrimSuffix(string(data), "\n")
+	if crlf {
+		text = strings.ReplaceAll(strings.TrimSuffix(text, "\r"), "\r\n", "\n")
+	}
+	return strings.Split(text, "\n"), crlf
+}
+
+// joinLines is the inverse of fileLines
+func joinLines(lines []string, crlf bool) []byte {
+	nl := "\n"
+	if crlf {
+		nl = "\r\n"
+	}
+	return []byte(strings.Join(lines, nl) + nl)
+}
+
+// writeInjected writes data back to path, or to the standard output with dryRun
+func writeInjected(path string, data []byte, dryRun bool) error {
+	if dryRun {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return ioError(err)
+	}
+	if err := writeFileAtomic(path, data, info.Mode().Perm()); err != nil {
+		return ioError(err)
+	}
+	return nil
+}
+
+// runInjectCompose implements inject compose
+func runInjectCompose(args []string) int {
+	composeFile := flag.String("compose-file", "", "Compose file to update in place (default: compose.yaml, compose.yml, docker-compose.yaml or docker-compose.yml)")
+	service := flag.String("service", "", "Service whose environment is updated (required)")
+	replace := flag.Bool("replace", false, "Replace the whole environment instead of merging into it")
+	dryRun := flag.Bool("dry-run", false, "Print the updated file instead of writing it")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s inject compose [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Only the environment of the service is rewritten: comments and formatting of the")
+		fmt.Fprintln(flag.CommandLine.Output(), "rest of the file are kept.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	r, code := renderOutput("inject compose", "compose", args)
+	if r == nil {
+		return code
+	}
+	if *service == "" {
+		errorf("-service is required")
+		return exitUsage
+	}
+
+	if *composeFile == "" {
+		*composeFile = defaultComposeFile()
+	}
+	data, err := os.ReadFile(*composeFile)
+	if err != nil {
+		logError(ioError(err))
+		return exitIO
+	}
+	updated, err := injectCompose(data, *service, r.named, *replace)
+	if err == nil {
+		err = writeInjected(*composeFile, updated, *dryRun)
+	}
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+	if !*dryRun {
+		infof("%s: environment of %s updated", *composeFile, *service)
+	}
+	return exitOK
+}
+
+// defaultComposeFile returns the first compose file of the working directory, in
+// the order docker compose looks for them
+func defaultComposeFile() string {
+	names := []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+	for _, name := range names {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return names[len(names)-1]
+}
+
+// yamlEntry is an entry of a YAML block collection rewritten by inject, spanning
+// the lines [start, end)
+type yamlEntry struct {
+	name string
+	// current is set when the entry already holds the wanted value
+	current    bool
+	start, end int
+}
+
+// mergeEntries returns the edits rewriting the entries of a collection whose variable
+// changed, and with replace removing those vars does not define. The lines of the
+// variables without an entry are appended after the last entry, or returned when
+// the collection has none.
+func mergeEntries(entries []yamlEntry, vars map[string]string, replace bool, format func(name string) ([]string, error)) ([]lineEdit, []string, error) {
+	var edits []lineEdit
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		_, ok := vars[e.name]
+		switch {
+		case ok && !e.current:
+			lines, err := format(e.name)
+			if err != nil {
+				return nil, nil, err
+			}
+			edits = append(edits, lineEdit{start: e.start, end: e.end, lines: lines})
+		case !ok && replace:
+			edits = append(edits, lineEdit{start: e.start, end: e.end})
+		}
+		seen[e.name] = true
+	}
+
+	var added []string
+	for _, name := range appsettingsenv.SortedKeys(vars) {
+		if seen[name] {
+			continue
+		}
+		lines, err := format(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		added = append(added, lines...)
+	}
+
+	if len(entries) == 0 {
+		return edits, added, nil
+	}
+	last := entries[len(entries)-1].end
+	return append(edits, lineEdit{start: last, end: last, lines: added}), nil, nil
+}
+
+// placeEntries returns the edit writing the lines of a collection that has no
+// entries under the key line key: in place of the line keyLine of an empty
+// collection, which may hold a null or [], or inserted at line insertAt when
+// keyLine is -1
+func placeEntries(key string, keyLine, insertAt int, added []string) []lineEdit {
+	if len(added) == 0 {
+		return nil
+	}
+	lines := append([]string{key}, added...)
+	if keyLine < 0 {
+		return []lineEdit{{start: insertAt, end: insertAt, lines: lines}}
+	}
+	return []lineEdit{{start: keyLine, end: keyLine + 1, lines: lines}}
+}
+
+// injectCompose merges vars into the environment of service in the compose file
+// data, or with replace makes them its only variables. The environment keeps its
+// mapping or list form, and lines outside of it are kept verbatim.
+func injectCompose(data []byte, service string, vars map[string]string, replace bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, parseError(fmt.Errorf("compose file: %w", err))
+	}
+	if len(doc.Content) == 0 {
+		return nil, parseError(errors.New("compose file: empty document"))
+	}
+	_, services := mappingEntry(doc.Content[0], "services")
+	serviceKey, serviceNode := mappingEntry(services, service)
+	if serviceNode == nil {
+		return nil, validationError(fmt.Errorf("compose file: service %q not found", service))
+	}
+	if serviceNode.Kind != yaml.MappingNode || serviceNode.Style&yaml.FlowStyle != 0 {
+		return nil, validationError(fmt.Errorf("compose file: service %q is not a block mapping", service))
+	}
+
+	lines, crlf := fileLines(data)
+	envKey, env := mappingEntry(serviceNode, "environment")
+	list := env != nil && env.Kind == yaml.SequenceNode
+
+	// Indentation of the service keys and of their children
+	keyIndent := indentOf(lines[serviceKey.Line-1]) + 2
+	if len(serviceNode.Content) > 0 {
+		keyIndent = serviceNode.Content[0].Column - 1
+	}
+	entryIndent := keyIndent + keyIndent - indentOf(lines[serviceKey.Line-1])
+
+	var entries []yamlEntry
+	switch {
+	case env == nil || isEmptyCollection(env):
+	case env.Style&yaml.FlowStyle != 0:
+		return nil, validationError(fmt.Errorf("compose file: the environment of %q is in flow style, which cannot be updated in place", service))
+	case env.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(env.Content); i += 2 {
+			k, v := env.Content[i], env.Content[i+1]
+			value, ok := vars[k.Value]
+			entries = append(entries, yamlEntry{name: k.Value, current: ok && v.Tag != "!!null" && v.Value == value, start: k.Line - 1})
+		}
+	case env.Kind == yaml.SequenceNode:
+		for _, item := range env.Content {
+			name, value, set := strings.Cut(item.Value, "=")
+			entries = append(entries, yamlEntry{name: name, current: set && vars[name] == value, start: item.Line - 1})
+		}
+	default:
+		return nil, validationError(fmt.Errorf("compose file: unexpected environment of %q", service))
+	}
+	for i := range entries {
+		entries[i].end = blockEnd(lines, entries[i].start)
+	}
+	if len(entries) > 0 {
+		entryIndent = indentOf(lines[entries[0].start])
+	}
+
+	format := func(name string) ([]string, error) {
+		if list {
+			item, err := yaml.Marshal(name + "=" + vars[name])
+			return []string{strings.Repeat(" ", entryIndent) + "- " + strings.TrimSuffix(string(item), "\n")}, err
+		}
+		return formatLines(entryIndent, "compose", appsettingsenv.KV{Name: name, Value: vars[name]})
+	}
+	edits, added, err := mergeEntries(entries, vars, replace, format)
+	if err != nil {
+		return nil, err
+	}
+
+	key, keyLine := strings.Repeat(" ", keyIndent)+"environment:", -1
+	if envKey != nil {
+		keyLine = envKey.Line - 1
+	}
+	edits = append(edits, placeEntries(key, keyLine, blockEnd(lines, serviceKey.Line-1), added)...)
+	return joinLines(applyEdits(lines, edits), crlf), nil
+}
+
+// isEmptyCollection reports whether n is a null, {} or []
+func isEmptyCollection(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == "!!null" || (n.Kind == yaml.MappingNode || n.Kind == yaml.SequenceNode) && len(n.Content) == 0
+}
+
+// formatLines formats kv in the output type, every line indented by indent spaces
+func formatLines(indent int, outType string, kv appsettingsenv.KV) ([]string, error) {
+	var b bytes.Buffer
+	if err := appsettingsenv.Format(&b, []appsettingsenv.KV{kv}, formatOptions(outType)); err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.Repeat(" ", indent) + lines[i]
+	}
+	return lines, nil
+}
+
+// runInjectK8s implements inject k8s
+func runInjectK8s(args []string) int {
+	manifest := flag.String("manifest", "", "Manifest file holding the workload, updated in place (required)")
+	workload := flag.String("workload", "", "Workload as deployment|statefulset|daemonset/name (default: the first workload of the manifest)")
+	containerName := flag.String("container", "", "Container name (default: the first container)")
+	configMap := flag.Bool("configmap", false, "Rewrite the data of the ConfigMap the container references with envFrom, in the same manifest, instead of its env")
+	replace := flag.Bool("replace", false, "Replace the whole env or ConfigMap data instead of merging into it")
+	dryRun := flag.Bool("dry-run", false, "Print the updated manifest instead of writing it")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s inject k8s [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Only the env of the container, or the data of its ConfigMap, is rewritten: the")
+		fmt.Fprintln(flag.CommandLine.Output(), "other documents and fields of the manifest are kept.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	r, code := renderOutput("inject k8s", "k8s", args)
+	if r == nil {
+		return code
+	}
+	if *manifest == "" {
+		errorf("-manifest is required")
+		return exitUsage
+	}
+
+	data, err := os.ReadFile(*manifest)
+	if err != nil {
+		logError(ioError(err))
+		return exitIO
+	}
+	updated, err := injectK8s(data, *workload, *containerName, r, *configMap, *replace)
+	if err == nil {
+		err = writeInjected(*manifest, updated, *dryRun)
+	}
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+	if !*dryRun {
+		infof("%s updated", *manifest)
+	}
+	return exitOK
+}
+
+// manifestNodes decodes the documents of a multi-document manifest
+func manifestNodes(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, parseError(fmt.Errorf("parse manifest: %w", err))
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+// nodePath returns the node at the path of mapping keys from n, or nil
+func nodePath(n *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if _, n = mappingEntry(n, key); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// scalar returns the value of the scalar at the path of keys from n
+func scalar(n *yaml.Node, keys ...string) string {
+	if n = nodePath(n, keys...); n == nil || n.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return n.Value
+}
+
+// injectK8s writes the variables of r into the env of a workload container of the
+// manifest data, or with configMap into the data of the ConfigMap the container
+// references. Secret variables reference the Secret as the k8s output does. With
+// -checksum, the pod template is annotated with the templateChecksum of the variables.
+func injectK8s(data []byte, workload, container string, r *rendered, configMap, replace bool) ([]byte, error) {
+	docs, err := manifestNodes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var kind, name string
+	if workload != "" {
+		if kind, name, err = parseWorkloadRef(workload); err != nil {
+			return nil, err
+		}
+	}
+	var doc *yaml.Node
+	for _, d := range docs {
+		k := strings.ToLower(scalar(d, "kind"))
+		if workloadKinds[k] != "" && (workload == "" || k == kind && scalar(d, "metadata", "name") == name) {
+			doc = d
+			break
+		}
+	}
+	if doc == nil {
+		if workload == "" {
+			return nil, validationError(errors.New("no Deployment, StatefulSet or DaemonSet found in the manifest"))
+		}
+		return nil, validationError(fmt.Errorf("%s not found in the manifest", workload))
+	}
+
+	containersKey, containers := mappingEntry(nodePath(doc, "spec", "template", "spec"), "containers")
+	var c *yaml.Node
+	if containers != nil && containers.Kind == yaml.SequenceNode {
+		for _, item := range containers.Content {
+			if container == "" || scalar(item, "name") == container {
+				c = item
+				break
+			}
+		}
+	}
+	if c == nil || c.Kind != yaml.MappingNode || c.Style&yaml.FlowStyle != 0 || len(c.Content) == 0 {
+		if container == "" {
+			return nil, validationError(errors.New("the workload has no block-style container"))
+		}
+		return nil, validationError(fmt.Errorf("container %q not found in the workload", container))
+	}
+
+	lines, crlf := fileLines(data)
+	var edits []lineEdit
+	if *checksum {
+		if edits, err = annotateTemplate(lines, doc, templateChecksum(r.named, r.secret)); err != nil {
+			return nil, err
+		}
+	}
+	if configMap {
+		dataEdits, err := injectConfigMapData(lines, docs, c, r, replace)
+		if err != nil {
+			return nil, err
+		}
+		return joinLines(applyEdits(lines, append(edits, dataEdits...)), crlf), nil
+	}
+
+	secretName := *secretResource
+	if secretName == "" {
+		secretName = *resourceName + "-secrets"
+	}
+
+	keyIndent := c.Content[0].Column - 1
+	// Sequences indented under their key, or not, as the containers list is
+	entryIndent := keyIndent + indentOf(lines[c.Line-1]) - indentOf(lines[containersKey.Line-1])
+
+	envKey, env := mappingEntry(c, "env")
+	var entries []yamlEntry
+	switch {
+	case env == nil || isEmptyCollection(env):
+	case env.Kind != yaml.SequenceNode || env.Style&yaml.FlowStyle != 0:
+		return nil, validationError(errors.New("the env of the container is not a block sequence, which cannot be updated in place"))
+	default:
+		for _, item := range env.Content {
+			n := scalar(item, "name")
+			value, ok := r.named[n]
+			current := ok && nodePath(item, "valueFrom") == nil && nodePath(item, "value") != nil && scalar(item, "value") == value
+			if r.secret[n] {
+				ref := nodePath(item, "valueFrom", "secretKeyRef")
+				current = nodePath(item, "value") == nil && scalar(ref, "name") == secretName && scalar(ref, "key") == n
+			}
+			entries = append(entries, yamlEntry{name: n, current: current, start: item.Line - 1})
+		}
+	}
+	for i := range entries {
+		entries[i].end = blockEnd(lines, entries[i].start)
+	}
+	if len(entries) > 0 {
+		entryIndent = indentOf(lines[entries[0].start])
+	}
+
+	format := func(name string) ([]string, error) {
+		return formatLines(entryIndent, "k8s", appsettingsenv.KV{Name: name, Value: r.named[name], Secret: r.secret[name]})
+	}
+	envEdits, added, err := mergeEntries(entries, r.named, replace, format)
+	if err != nil {
+		return nil, err
+	}
+	edits = append(edits, envEdits...)
+
+	key, keyLine := strings.Repeat(" ", keyIndent)+"env:", -1
+	if envKey != nil {
+		keyLine = envKey.Line - 1
+	}
+	edits = append(edits, placeEntries(key, keyLine, blockEnd(lines, c.Line-1), added)...)
+	return joinLines(applyEdits(lines, edits), crlf), nil
+}
+
+// annotateTemplate returns the edits setting the checksum annotation of the pod
+// template of the workload doc to sum, adding the metadata and annotations
+// mappings the template lacks
+func annotateTemplate(lines []string, doc *yaml.Node, sum string) ([]lineEdit, error) {
+	template := nodePath(doc, "spec", "template")
+	if template == nil || template.Kind != yaml.MappingNode || template.Style&yaml.FlowStyle != 0 || len(template.Content) == 0 {
+		return nil, validationError(errors.New("the pod template of the workload is not a block mapping, which cannot be updated in place"))
+	}
+	pad := func(indent int) string { return strings.Repeat(" ", indent) }
+	entry := func(indent int) string {
+		return fmt.Sprintf("%s%s: %q", pad(indent), appsettingsenv.ChecksumAnnotation, sum)
+	}
+	insert := func(n *yaml.Node, lines ...string) []lineEdit {
+		return []lineEdit{{start: n.Line - 1, end: n.Line - 1, lines: lines}}
+	}
+
+	// Each missing level is nested two spaces deeper than its parent
+	indent := template.Content[0].Column - 1
+	metaKey, meta := mappingEntry(template, "metadata")
+	switch {
+	case meta == nil:
+		return insert(template.Content[0], pad(indent)+"metadata:", pad(indent+2)+"annotations:", entry(indent+4)), nil
+	case isEmptyCollection(meta):
+		return placeEntries(pad(indent)+"metadata:", metaKey.Line-1, 0, []string{pad(indent+2) + "annotations:", entry(indent + 4)}), nil
+	case meta.Kind != yaml.MappingNode || meta.Style&yaml.FlowStyle != 0:
+		return nil, validationError(errors.New("the pod template metadata is not a block mapping, which cannot be updated in place"))
+	}
+
+	indent = meta.Content[0].Column - 1
+	annotationsKey, annotations := mappingEntry(meta, "annotations")
+	switch {
+	case annotations == nil:
+		return insert(meta.Content[0], pad(indent)+"annotations:", entry(indent+2)), nil
+	case isEmptyCollection(annotations):
+		return placeEntries(pad(indent)+"annotations:", annotationsKey.Line-1, 0, []string{entry(indent + 2)}), nil
+	case annotations.Kind != yaml.MappingNode || annotations.Style&yaml.FlowStyle != 0:
+		return nil, validationError(errors.New("the pod template annotations are not a block mapping, which cannot be updated in place"))
+	}
+
+	first := annotations.Content[0]
+	if k, _ := mappingEntry(annotations, appsettingsenv.ChecksumAnnotation); k != nil {
+		return []lineEdit{{start: k.Line - 1, end: blockEnd(lines, k.Line-1), lines: []string{entry(first.Column - 1)}}}, nil
+	}
+	return insert(first, entry(first.Column-1)), nil
+}
+
+// injectConfigMapData returns the edits writing the variables of r into the data of
+// the ConfigMap of docs that the container c references with envFrom. Secret
+// variables do not belong in a ConfigMap and are skipped.
+func injectConfigMapData(lines []string, docs []*yaml.Node, c *yaml.Node, r *rendered, replace bool) ([]lineEdit, error) {
+	var cm *yaml.Node
+	var refs []string
+	var envFrom []*yaml.Node
+	if n := nodePath(c, "envFrom"); n != nil {
+		envFrom = n.Content
+	}
+	for _, from := range envFrom {
+		name := scalar(from, "configMapRef", "name")
+		if name == "" {
+			continue
+		}
+		refs = append(refs, name)
+		for _, d := range docs {
+			if cm == nil && scalar(d, "kind") == "ConfigMap" && scalar(d, "metadata", "name") == name {
+				cm = d
+			}
+		}
+	}
+	if cm == nil {
+		if len(refs) == 0 {
+			return nil, validationError(errors.New("the container references no ConfigMap with envFrom"))
+		}
+		return nil, validationError(fmt.Errorf("ConfigMap %s not found in the manifest", strings.Join(refs, ", ")))
+	}
+
+	vars := make(map[string]string, len(r.named))
+	for name, value := range r.named {
+		if r.secret[name] {
+			warnf("%s: secret variable not written to the ConfigMap", name)
+			continue
+		}
+		vars[name] = value
+	}
+
+	dataKey, data := mappingEntry(cm, "data")
+	entryIndent := cm.Content[0].Column - 1 + 2
+	var entries []yamlEntry
+	switch {
+	case data == nil || isEmptyCollection(data):
+	case data.Kind != yaml.MappingNode || data.Style&yaml.FlowStyle != 0:
+		return nil, validationError(errors.New("the data of the ConfigMap is not a block mapping, which cannot be updated in place"))
+	default:
+		for i := 0; i+1 < len(data.Content); i += 2 {
+			k, v := data.Content[i], data.Content[i+1]
+			value, ok := vars[k.Value]
+			entries = append(entries, yamlEntry{name: k.Value, current: ok && v.Tag != "!!null" && v.Value == value, start: k.Line - 1})
+		}
+	}
+	for i := range entries {
+		entries[i].end = blockEnd(lines, entries[i].start)
+	}
+	if len(entries) > 0 {
+		entryIndent = indentOf(lines[entries[0].start])
+	}
+
+	format := func(name string) ([]string, error) {
+		return formatLines(entryIndent, "compose", appsettingsenv.KV{Name: name, Value: vars[name]})
+	}
+	edits, added, err := mergeEntries(entries, vars, replace, format)
+	if err != nil {
+		return nil, err
+	}
+
+	key, keyLine := strings.Repeat(" ", cm.Content[0].Column-1)+"data:", -1
+	if dataKey != nil {
+		keyLine = dataKey.Line - 1
+	}
+	last := cm.Content[len(cm.Content)-2]
+	return append(edits, placeEntries(key, keyLine, blockEnd(lines, last.Line-1), added)...), nil
+}
+
+// runInjectBicep implements inject bicep
+func runInjectBicep(args []string) int {
+	bicepFile := flag.String("bicep-file", "", "Bicep file to update in place (required)")
+	variable := flag.String("variable", "", "Name of the var holding the env array (default: the array between the marker comments)")
+	marker := flag.String("marker", "", "Name after the begin marker, to pick one of several marked arrays")
+	dryRun := flag.Bool("dry-run", false, "Print the updated file instead of writing it")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s inject bicep [flags]:\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "The entries of the array are replaced: the var -variable, or the lines between\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "%q and %q comments.\n\n", bicepBeginMarker, bicepEndMarker)
+		flag.PrintDefaults()
+	}
+
+	// Entries nest inside the template, unlike the bare bicep output
+	*indent = 2
+	r, code := renderOutput("inject bicep", "bicep", args)
+	if r == nil {
+		return code
+	}
+	if *bicepFile == "" {
+		errorf("-bicep-file is required")
+		return exitUsage
+	}
+	if *variable != "" && *marker != "" {
+		errorf("-variable and -marker cannot be used together")
+		return exitUsage
+	}
+
+	data, err := os.ReadFile(*bicepFile)
+	if err != nil {
+		logError(ioError(err))
+		return exitIO
+	}
+	entries := append(r.output, r.secrets...)
+	var updated []byte
+	if *variable != "" {
+		updated, err = injectBicepVariable(data, *variable, entries)
+	} else {
+		updated, err = injectBicepMarkers(data, *marker, entries)
+	}
+	if err == nil {
+		err = writeInjected(*bicepFile, updated, *dryRun)
+	}
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+	if !*dryRun {
+		infof("%s updated", *bicepFile)
+	}
+	return exitOK
+}
+
+// Comments delimiting the lines inject bicep replaces
+var (
+	bicepBeginMarker = "// " + app + ":begin"
+	bicepEndMarker   = "// " + app + ":end"
+)
+
+// indentEntries returns the lines of entries, each indented by indent spaces
+func indentEntries(entries []byte, indent int) []string {
+	text := strings.TrimSuffix(string(entries), "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		lines[i] = strings.Repeat(" ", indent) + lines[i]
+	}
+	return lines
+}
+
+// injectBicepMarkers replaces the lines between the begin marker named marker and
+// the following end marker with entries, indented as the begin marker
+func injectBicepMarkers(data []byte, marker string, entries []byte) ([]byte, error) {
+	begin := strings.TrimSpace(bicepBeginMarker + " " + marker)
+	lines, crlf := fileLines(data)
+	start := slices.IndexFunc(lines, func(line string) bool { return strings.TrimSpace(line) == begin })
+	if start < 0 {
+		return nil, validationError(fmt.Errorf("bicep file: %q not found", begin))
+	}
+	end := slices.IndexFunc(lines[start+1:], func(line string) bool { return strings.TrimSpace(line) == bicepEndMarker })
+	if end < 0 {
+		return nil, validationError(fmt.Errorf("bicep file: %q not found after %q", bicepEndMarker, begin))
+	}
+
+	edit := lineEdit{start: start + 1, end: start + 1 + end, lines: indentEntries(entries, indentOf(lines[start]))}
+	return joinLines(applyEdits(lines, []lineEdit{edit}), crlf), nil
+}
+
+// injectBicepVariable replaces the items of the array assigned to the var name with
+// entries
+func injectBicepVariable(data []byte, name string, entries []byte) ([]byte, error) {
+	text := string(data)
+	m := regexp.MustCompile(`(?m)^([ \t]*)var\s+` + regexp.QuoteMeta(name) + `\s*=\s*\[`).FindStringSubmatchIndex(text)
+	if m == nil {
+		return nil, validationError(fmt.Errorf("bicep file: no var %s = [...] found", name))
+	}
+	open := m[1] - 1
+	end, err := bicepClose(text, open)
+	if err != nil {
+		return nil, parseError(fmt.Errorf("bicep file: var %s: %w", name, err))
+	}
+
+	nl := "\n"
+	if strings.Contains(text, "\r\n") {
+		nl = "\r\n"
+	}
+	varIndent := text[m[2]:m[3]]
+	var b strings.Builder
+	b.WriteString(text[:open+1] + nl)
+	for _, line := range indentEntries(entries, len(varIndent)+2) {
+		b.WriteString(line + nl)
+	}
+	b.WriteString(varIndent + text[end:])
+	return []byte(b.String()), nil
+}
+
+// bicepClose returns the index of the bracket closing the one at open, skipping
+// strings, interpolations and comments
+func bicepClose(text string, open int) (int, error) {
+	closers := map[byte]byte{'[': ']', '{': '}', '(': ')'}
+	var stack []byte
+	for i := open; i < len(text); i++ {
+		switch c := text[i]; {
+		case strings.HasPrefix(text[i:], "//"):
+			i += strings.IndexByte(text[i:]+"\n", '\n')
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return 0, errors.New("unterminated comment")
+			}
+			i += end + 3
+		case strings.HasPrefix(text[i:], "'''"):
+			end := strings.Index(text[i+3:], "'''")
+			if end < 0 {
+				return 0, errors.New("unterminated multi-line string")
+			}
+			i += end + 5
+		case c == '\'':
+			end, err := bicepStringEnd(text, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case closers[c] != 0:
+			stack = append(stack, closers[c])
+		case c == ']' || c == '}' || c == ')':
+			if len(stack) == 0 || stack[len(stack)-1] != c {
+				return 0, fmt.Errorf("unexpected %c", c)
+			}
+			if stack = stack[:len(stack)-1]; len(stack) == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, errors.New("unterminated array")
+}
+
+// bicepStringEnd returns the index of the quote ending the string starting at start
+func bicepStringEnd(text string, start int) (int, error) {
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case text[i] == '\'':
+			return i, nil
+		case strings.HasPrefix(text[i:], "${"):
+			end, err := bicepClose(text, i+1)
+			if err != nil {
+				return 0, err
+			}
+			i = end
+		case text[i] == '\n':
+			return 0, errors.New("unterminated string")
+		}
+	}
+	return 0, errors.New("unterminated string")
+}
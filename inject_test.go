@@ -0,0 +1,276 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+const testCompose = `# Local stack
+services:
+  db:
+    image: postgres:16
+
+  api:
+    image: api:dev   # built by CI
+    environment:
+      # Logging
+      Logging__Level: Information
+      KEEP: "x"
+      PASSTHROUGH:
+
+    ports:
+      - "8080:80"
+`
+
+func TestInjectComposeMapping(t *testing.T) {
+	vars := map[string]string{"Logging__Level": "Warning", "KEEP": "x", "PASSTHROUGH": "set", "Api__Url": "https://api"}
+	got, err := injectCompose([]byte(testCompose), "api", vars, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := strings.Replace(testCompose, `      Logging__Level: Information
+      KEEP: "x"
+      PASSTHROUGH:
+`, `      Logging__Level: "Warning"
+      KEEP: "x"
+      PASSTHROUGH: "set"
+      Api__Url: "https://api"
+`, 1)
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = injectCompose([]byte(testCompose), "api", map[string]string{"KEEP": "x"}, true)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if strings.Contains(string(got), "Logging__Level") || strings.Contains(string(got), "PASSTHROUGH") || !strings.Contains(string(got), "# Logging\n      KEEP: \"x\"\n") {
+		t.Fatalf("replace should only keep KEEP:\n%s", got)
+	}
+}
+
+func TestInjectComposeList(t *testing.T) {
+	in := "services:\n  api:\n    environment:\n    - A=1\n    - B\n    command: run\n"
+	got, err := injectCompose([]byte(in), "api", map[string]string{"A": "2", "B": "b", "C": "a: b"}, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := "services:\n  api:\n    environment:\n    - A=2\n    - B=b\n    - 'C=a: b'\n    command: run\n"
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestInjectComposeNewEnvironment(t *testing.T) {
+	in := "services:\r\n  api:\r\n    image: api\r\n  web:\r\n    image: web\r\n"
+	got, err := injectCompose([]byte(in), "api", map[string]string{"A": "1"}, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := "services:\r\n  api:\r\n    image: api\r\n    environment:\r\n      A: \"1\"\r\n  web:\r\n    image: web\r\n"
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%q\nwant:\n%q", got, want)
+	}
+
+	for _, in := range []string{"services:\n  api: {image: api}\n", "services:\n  api:\n    environment: {A: 1}\n"} {
+		if _, err := injectCompose([]byte(in), "api", map[string]string{"A": "1"}, false); err == nil {
+			t.Fatalf("flow style should fail: %s", in)
+		}
+	}
+	if _, err := injectCompose([]byte(in), "worker", nil, false); err == nil {
+		t.Fatalf("missing service should fail")
+	}
+}
+
+const testManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: api-config
+data:
+  Logging__Level: Information # noisy
+  KEEP: x
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: proxy
+      - name: api
+        image: api:1
+        envFrom:
+        - configMapRef:
+            name: api-config
+        env:
+        - name: Logging__Level
+          value: "Information"
+        - name: Db__Password
+          valueFrom:
+            secretKeyRef:
+              name: old-secret
+              key: Db__Password
+        - name: KEEP
+          value: x
+        ports:
+        - containerPort: 8080
+`
+
+func TestInjectK8sEnv(t *testing.T) {
+	r := &rendered{
+		named:  map[string]string{"Logging__Level": "Warning", "Db__Password": "s3cret", "KEEP": "x", "Api__Url": "https://api"},
+		secret: map[string]bool{"Db__Password": true},
+	}
+	got, err := injectK8s([]byte(testManifest), "deployment/api", "api", r, false, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := strings.Replace(testManifest, `        - name: Logging__Level
+          value: "Information"
+        - name: Db__Password
+          valueFrom:
+            secretKeyRef:
+              name: old-secret
+              key: Db__Password
+        - name: KEEP
+          value: x
+`, `        - name: "Logging__Level"
+          value: "Warning"
+        - name: "Db__Password"
+          valueFrom:
+            secretKeyRef:
+              name: "appsettings-secrets"
+              key: "Db__Password"
+        - name: KEEP
+          value: x
+        - name: "Api__Url"
+          value: "https://api"
+`, 1)
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The first container has no env yet
+	got, err = injectK8s([]byte(testManifest), "", "", &rendered{named: map[string]string{"A": "1"}}, false, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	if !strings.Contains(string(got), "        image: proxy\n        env:\n        - name: \"A\"\n          value: \"1\"\n      - name: api\n") {
+		t.Fatalf("unexpected manifest:\n%s", got)
+	}
+
+	if _, err := injectK8s([]byte(testManifest), "deployment/web", "", r, false, false); err == nil {
+		t.Fatalf("missing workload should fail")
+	}
+	if _, err := injectK8s([]byte(testManifest), "", "worker", r, false, false); err == nil {
+		t.Fatalf("missing container should fail")
+	}
+}
+
+func TestInjectK8sConfigMap(t *testing.T) {
+	r := &rendered{
+		named:  map[string]string{"Logging__Level": "Warning", "Db__Password": "s3cret", "Api__Url": "https://api"},
+		secret: map[string]bool{"Db__Password": true},
+	}
+	got, err := injectK8s([]byte(testManifest), "", "api", r, true, true)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := strings.Replace(testManifest, "data:\n  Logging__Level: Information # noisy\n  KEEP: x\n",
+		"data:\n  Logging__Level: \"Warning\"\n  Api__Url: \"https://api\"\n", 1)
+	if string(got) != want {
+		t.Fatalf("unexpected manifest:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := injectK8s([]byte(testManifest), "", "sidecar", r, true, false); err == nil {
+		t.Fatalf("container without envFrom should fail")
+	}
+}
+
+func TestInjectK8sChecksum(t *testing.T) {
+	defer func(c bool) { *checksum = c }(*checksum)
+	*checksum = true
+	r := &rendered{named: map[string]string{"A": "1"}}
+	sum := appsettingsenv.Checksum([]appsettingsenv.KV{{Name: "A", Value: "1"}})
+	annotation := "checksum/config: \"" + sum + "\"\n"
+
+	got, err := injectK8s([]byte(testManifest), "", "api", r, true, false)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	if !strings.Contains(string(got), "  template:\n    metadata:\n      annotations:\n        "+annotation+"    spec:\n") {
+		t.Fatalf("unexpected manifest:\n%s", got)
+	}
+
+	// An existing annotation is replaced, the others are kept
+	in := strings.Replace(testManifest, "  template:\n", "  template:\n    metadata:\n      labels: {app: api}\n      annotations:\n        team: web\n        checksum/config: old\n", 1)
+	if got, err = injectK8s([]byte(in), "", "api", r, true, false); err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	if !strings.Contains(string(got), "      annotations:\n        team: web\n        "+annotation) {
+		t.Fatalf("unexpected manifest:\n%s", got)
+	}
+
+	in = strings.Replace(testManifest, "  template:\n", "  template:\n    metadata: {labels: {app: api}}\n", 1)
+	if _, err := injectK8s([]byte(in), "", "api", r, true, false); err == nil {
+		t.Fatalf("flow style metadata should fail")
+	}
+}
+
+func TestInjectBicepMarkers(t *testing.T) {
+	in := "resource app 'Microsoft.App/containerApps@2024-03-01' = {\n" +
+		"  properties: {\n" +
+		"    env: [\n" +
+		"      // dotnet-appsettings-env:begin\n" +
+		"      { name: 'Old', value: 'x' }\n" +
+		"      // dotnet-appsettings-env:end\n" +
+		"      { name: 'Extra', value: 'kept' }\n" +
+		"    ]\n" +
+		"  }\n" +
+		"}\n"
+	entries := []byte("{\n  name: 'A'\n  value: '1'\n}\n")
+	got, err := injectBicepMarkers([]byte(in), "", entries)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := strings.Replace(in, "      { name: 'Old', value: 'x' }\n", "      {\n        name: 'A'\n        value: '1'\n      }\n", 1)
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := injectBicepMarkers([]byte(in), "api", entries); err == nil {
+		t.Fatalf("missing named marker should fail")
+	}
+}
+
+func TestInjectBicepVariable(t *testing.T) {
+	in := "param name string\n" +
+		"var appEnv = [\n" +
+		"  { name: 'Url', value: 'https://${name}/]' } // ] in a comment\n" +
+		"  /* ] */ { name: 'Json', value: '''\n[\n''' }\n" +
+		"]\n" +
+		"var other = []\n"
+	entries := []byte("{\n  name: 'A'\n  value: '1'\n}\n")
+	got, err := injectBicepVariable([]byte(in), "appEnv", entries)
+	if err != nil {
+		t.Fatalf("inject: %v", err)
+	}
+	want := "param name string\nvar appEnv = [\n  {\n    name: 'A'\n    value: '1'\n  }\n]\nvar other = []\n"
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%s\nwant:\n%s", got, want)
+	}
+
+	got, err = injectBicepVariable([]byte(in), "other", entries)
+	if err != nil || !strings.HasSuffix(string(got), "var other = [\n  {\n    name: 'A'\n    value: '1'\n  }\n]\n") {
+		t.Fatalf("unexpected file: %v\n%s", err, got)
+	}
+
+	if _, err := injectBicepVariable([]byte("var appEnv = [\n  'x\n"), "appEnv", entries); err == nil {
+		t.Fatalf("unterminated string should fail")
+	}
+}
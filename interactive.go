@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// pickerItem is a flattened key of the interactive picker
+type pickerItem struct {
+	key, value       string
+	selected, secret bool
+}
+
+// picker is the state of the -interactive key list
+type picker struct {
+	items  []pickerItem
+	cursor int
+	// offset is the first item shown, height the number of items that fit
+	offset, height int
+}
+
+// pickerHelp is the key binding line shown above the list
+const pickerHelp = "↑/↓ move  space select  s secret  a all  w save  q cancel"
+
+// newPicker lists vars, selected when they pass filter and marked when they match secret
+func newPicker(vars map[string]string, filter, secret *keyFilter) *picker {
+	p := &picker{height: 20}
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		p.items = append(p.items, pickerItem{
+			key:      k,
+			value:    vars[k],
+			selected: filter.match(k),
+			secret:   len(secret.include) > 0 && secret.match(k),
+		})
+	}
+	return p
+}
+
+// handle applies a key press and reports whether the picker is done, and if so
+// whether the selection is to be saved
+func (p *picker) handle(key string) (done, save bool) {
+	switch key {
+	case "up", "k":
+		p.cursor = max(p.cursor-1, 0)
+	case "down", "j":
+		p.cursor = min(p.cursor+1, len(p.items)-1)
+	case "pgup":
+		p.cursor = max(p.cursor-p.height, 0)
+	case "pgdown":
+		p.cursor = min(p.cursor+p.height, len(p.items)-1)
+	case "home", "g":
+		p.cursor = 0
+	case "end", "G":
+		p.cursor = len(p.items) - 1
+	case " ":
+		p.items[p.cursor].selected = !p.items[p.cursor].selected
+	case "s":
+		p.items[p.cursor].secret = !p.items[p.cursor].secret
+	case "a":
+		all := true
+		for _, it := range p.items {
+			all = all && it.selected
+		}
+		for i := range p.items {
+			p.items[i].selected = !all
+		}
+	case "w", "enter":
+		return true, true
+	case "q", "esc", "ctrl-c":
+		return true, false
+	}
+
+	// Keep the cursor in view
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+p.height {
+		p.offset = p.cursor - p.height + 1
+	}
+	return false, false
+}
+
+// render draws the picker on a cleared screen of the given width
+func (p *picker) render(w io.Writer, width int) {
+	var b bytes.Buffer
+	selected := 0
+	for _, it := range p.items {
+		if it.selected {
+			selected++
+		}
+	}
+
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "%d/%d keys selected\r\n%s\r\n\r\n", selected, len(p.items), pickerHelp)
+	for i := p.offset; i < len(p.items) && i < p.offset+p.height; i++ {
+		it := p.items[i]
+		cursor, check, secret := " ", " ", " "
+		if i == p.cursor {
+			cursor = ">"
+		}
+		if it.selected {
+			check = "x"
+		}
+		value := it.value
+		if it.secret {
+			secret, value = "S", "********"
+		}
+		line := fmt.Sprintf("%s [%s] %s %s = %q", cursor, check, secret, it.key, value)
+		if r := []rune(line); width > 1 && len(r) > width {
+			line = string(r[:width-1]) + "…"
+		}
+		b.WriteString(line + "\r\n")
+	}
+	w.Write(b.Bytes())
+}
+
+// readKey reads a key press from a terminal in raw mode
+func readKey(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	switch c {
+	case '\r', '\n':
+		return "enter", nil
+	case 3:
+		return "ctrl-c", nil
+	case 0x1b:
+		// A lone escape has nothing buffered after it
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := make([]byte, 0, 4)
+		for r.Buffered() > 0 && len(seq) < cap(seq) {
+			b, _ := r.ReadByte()
+			seq = append(seq, b)
+			if len(seq) > 1 && (b >= 'A' && b <= 'Z' || b == '~') {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return "up", nil
+		case "[B", "OB":
+			return "down", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdown", nil
+		case "[H", "OH", "[1~":
+			return "home", nil
+		case "[F", "OF", "[4~":
+			return "end", nil
+		}
+		return "", nil
+	}
+	return string(c), nil
+}
+
+// runInteractive lets the user pick the keys to convert and the secret ones, then
+// writes the selection to the configuration file as exclude and secret-keys patterns
+func runInteractive(cv *conversion) int {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		errorf("-interactive requires a terminal")
+		return exitUsage
+	}
+
+	layers, err := configurationLayers(cv.setVars)
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+	vars, err := expandValues(mergeLayers(layers))
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+
+	filter, err := outputFilter(keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	secretPatterns, err := expandPatternFiles(secretKeys)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	secret, err := newKeyFilter(secretPatterns, nil, keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	// Keys a preset already excludes are left to the preset
+	presetExcludes, err := presetPatterns(presetNames, projectConfig)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	preset, err := newKeyFilter(nil, presetExcludes, keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	for k := range vars {
+		if !preset.match(k) {
+			delete(vars, k)
+		}
+	}
+	if len(vars) == 0 {
+		errorf("no keys to pick from")
+		return exitFailure
+	}
+
+	p := newPicker(vars, filter, secret)
+	save, err := pick(p, in)
+	if err != nil {
+		logError(err)
+		return exitIO
+	}
+	if !save {
+		infof("selection discarded")
+		return exitOK
+	}
+
+	path := projectConfigPath
+	if path == "" {
+		path = configFileName
+	}
+	if err := saveSelection(path, p.items); err != nil {
+		logError(err)
+		return exitCode(err, exitIO)
+	}
+	infof("selection written to %s", path)
+	if *secretOut == "" && hasSecrets(p.items) {
+		infof("set secret-out in %s to write the secret keys to a separate file", path)
+	}
+	return exitOK
+}
+
+// pick runs the picker on the terminal until it is saved or cancelled
+func pick(p *picker, fd int) (bool, error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return false, err
+	}
+	defer term.Restore(fd, state)
+
+	// Alternate screen, hidden cursor
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+
+	r := bufio.NewReader(os.Stdin)
+	for {
+		width, height, err := term.GetSize(fd)
+		if err == nil {
+			p.height = max(height-4, 1)
+		}
+		p.render(os.Stdout, width)
+
+		key, err := readKey(r)
+		if err != nil {
+			return false, err
+		}
+		if done, save := p.handle(key); done {
+			return save, nil
+		}
+	}
+}
+
+func hasSecrets(items []pickerItem) bool {
+	for _, it := range items {
+		if it.secret {
+			return true
+		}
+	}
+	return false
+}
+
+// selectionPatterns returns the patterns of the items for which in reports true.
+// A section of several keys that all match is collapsed into a single Section:* pattern.
+func selectionPatterns(items []pickerItem, in func(pickerItem) bool) []string {
+	var patterns []string
+	covered := func(key string) bool {
+		for _, p := range patterns {
+			if section, ok := strings.CutSuffix(p, keySep+"*"); ok && hasSectionPrefix(key, section) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, it := range items {
+		if !in(it) || covered(it.key) {
+			continue
+		}
+
+		pattern := it.key
+		parts := strings.Split(it.key, keySep)
+		for i := 1; i < len(parts); i++ {
+			section := strings.Join(parts[:i], keySep)
+			all, count := true, 0
+			for _, other := range items {
+				if hasSectionPrefix(other.key, section) {
+					all = all && in(other)
+					count++
+				}
+			}
+			if all && count > 1 {
+				pattern = section + keySep + "*"
+				break
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// hasSectionPrefix reports whether key lies below section, case-insensitively
+func hasSectionPrefix(key, section string) bool {
+	prefix := section + keySep
+	return len(key) > len(prefix) && strings.EqualFold(key[:len(prefix)], prefix)
+}
+
+// saveSelection updates the exclude and secret-keys entries of the configuration
+// file at path, creating it when missing. Other settings and comments are kept;
+// include is removed, since the excludes describe the whole selection.
+func saveSelection(path string, items []pickerItem) error {
+	var doc yaml.Node
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return ioError(fmt.Errorf("read config: %w", err))
+	}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return parseError(fmt.Errorf("parse config %s: %w", path, err))
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return parseError(fmt.Errorf("parse config %s: not a mapping", path))
+	}
+
+	setConfigList(root, "include", nil)
+	setConfigList(root, "exclude", selectionPatterns(items, func(it pickerItem) bool { return !it.selected }))
+	setConfigList(root, "secret-keys", selectionPatterns(items, func(it pickerItem) bool { return it.secret }))
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Clean(path), buf.Bytes(), 0o644); err != nil {
+		return ioError(err)
+	}
+	return nil
+}
+
+// setConfigList replaces the list stored under key in a YAML mapping, removing the
+// key when values is empty
+func setConfigList(mapping *yaml.Node, key string, values []string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != key {
+			continue
+		}
+		if len(values) == 0 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+		mapping.Content[i+1] = listNode(values)
+		return
+	}
+
+	if len(values) > 0 {
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, listNode(values))
+	}
+}
+
+func listNode(values []string) *yaml.Node {
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, v := range values {
+		list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: v})
+	}
+	return list
+}
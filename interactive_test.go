@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPicker(t *testing.T) {
+	vars := map[string]string{"Logging:Level": "Debug", "Db:Password": "secret", "Name": "api"}
+	filter, _ := newKeyFilter(nil, []string{"Logging:*"}, keySep)
+	secret, _ := newKeyFilter([]string{"*Password"}, nil, keySep)
+
+	p := newPicker(vars, filter, secret)
+	if len(p.items) != 3 || !p.items[0].selected || !p.items[0].secret || p.items[1].selected {
+		t.Fatalf("unexpected initial state: %+v", p.items)
+	}
+
+	for _, key := range []string{"down", "down", " ", "s", "up", " "} {
+		if done, _ := p.handle(key); done {
+			t.Fatalf("%q should not end the picker", key)
+		}
+	}
+	if !p.items[1].selected || p.items[2].selected || !p.items[2].secret {
+		t.Fatalf("unexpected state after key presses: %+v", p.items)
+	}
+
+	if done, save := p.handle("w"); !done || !save {
+		t.Fatalf("w should save")
+	}
+	if done, save := p.handle("esc"); !done || save {
+		t.Fatalf("esc should cancel")
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("\x1b[Aj \r"))
+	var keys []string
+	for range 4 {
+		key, err := readKey(r)
+		if err != nil {
+			t.Fatalf("readKey failed: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if got := strings.Join(keys, ","); got != "up,j, ,enter" {
+		t.Fatalf("unexpected keys: %q", got)
+	}
+}
+
+func TestSelectionPatterns(t *testing.T) {
+	items := []pickerItem{
+		{key: "Db:Host"},
+		{key: "Db:Password", secret: true},
+		{key: "Logging:Console:Level"},
+		{key: "Logging:Level"},
+		{key: "Name", selected: true},
+	}
+	excluded := selectionPatterns(items, func(it pickerItem) bool { return !it.selected })
+	if got := strings.Join(excluded, " "); got != "Db:* Logging:*" {
+		t.Fatalf("unexpected exclude patterns: %q", got)
+	}
+	secrets := selectionPatterns(items, func(it pickerItem) bool { return it.secret })
+	if got := strings.Join(secrets, " "); got != "Db:Password" {
+		t.Fatalf("unexpected secret patterns: %q", got)
+	}
+}
+
+func TestSaveSelection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), configFileName)
+	src := "# project settings\ntype: k8s\ninclude: Api:*\nexclude: [Old]\n"
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	items := []pickerItem{{key: "Db:Password", selected: true, secret: true}, {key: "Logging:Level"}}
+	if err := saveSelection(path, items); err != nil {
+		t.Fatalf("saveSelection failed: %v", err)
+	}
+
+	cfg, err := loadToolConfig(path)
+	if err != nil {
+		t.Fatalf("load saved config: %v", err)
+	}
+	if len(cfg.Include) != 0 || strings.Join(cfg.Exclude, " ") != "Logging:Level" ||
+		strings.Join(cfg.SecretKeys, " ") != "Db:Password" || strings.Join(cfg.Type, " ") != "k8s" {
+		t.Fatalf("unexpected saved config: %+v", cfg)
+	}
+
+	content, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(content), "# project settings\n") {
+		t.Fatalf("comments should be kept:\n%s", content)
+	}
+}
@@ -0,0 +1,91 @@
+// Package synth generates appsettings.json documents of a given shape, for the
+// benchmarks of the conversion and the bench subcommand.
+package synth
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Options shape the documents Generate generates
+type Options struct {
+	// Width is the number of members of every object
+	Width int
+	// Depth is the number of levels of objects, the root included
+	Depth int
+	// ArraySize is the number of elements of every array
+	ArraySize int
+}
+
+// Generate generates an appsettings.json document of the shape of o. The members of an object are in turn a section, while Depth allows,
+// an array of ArraySize elements, a string, a number and a boolean. The document is
+// indented and the same for the same options.
+func Generate(o Options) []byte {
+	s := synthesizer{o: o}
+	s.object(1, "")
+	s.b.WriteByte('\n')
+	return s.b.Bytes()
+}
+
+type synthesizer struct {
+	o Options
+	b bytes.Buffer
+	// n numbers the values
+	n int
+}
+
+func (s *synthesizer) object(level int, in string) {
+	if s.o.Width <= 0 {
+		s.b.WriteString("{}")
+		return
+	}
+	s.b.WriteString("{\n")
+	inner := in + "  "
+	for i := range s.o.Width {
+		if i > 0 {
+			s.b.WriteString(",\n")
+		}
+		s.b.WriteString(inner)
+		switch {
+		case i%5 == 0 && level < s.o.Depth:
+			fmt.Fprintf(&s.b, `"Section%d": `, i)
+			s.object(level+1, inner)
+		case i%5 == 1 && s.o.ArraySize > 0:
+			fmt.Fprintf(&s.b, `"Items%d": `, i)
+			s.array(level, inner)
+		default:
+			fmt.Fprintf(&s.b, `"Setting%d": `, i)
+			s.scalar(i)
+		}
+	}
+	s.b.WriteString("\n" + in + "}")
+}
+
+// array writes ArraySize elements, objects of a few settings while the depth allows
+// them, as lists of hosts or endpoints are, and strings otherwise
+func (s *synthesizer) array(level int, in string) {
+	elements := make([]string, s.o.ArraySize)
+	for i := range elements {
+		s.n++
+		if level < s.o.Depth {
+			elements[i] = fmt.Sprintf(`{"Name": "item-%d", "Port": %d, "Enabled": %t}`, s.n, 8000+i, i%2 == 0)
+		} else {
+			elements[i] = fmt.Sprintf(`"item-%d"`, s.n)
+		}
+	}
+	s.b.WriteString("[\n" + in + "  " + strings.Join(elements, ",\n"+in+"  ") + "\n" + in + "]")
+}
+
+// scalar writes the value of the i-th member of an object
+func (s *synthesizer) scalar(i int) {
+	s.n++
+	switch i % 5 {
+	case 3:
+		fmt.Fprintf(&s.b, "%d", s.n)
+	case 4:
+		fmt.Fprintf(&s.b, "%t", s.n%2 == 0)
+	default:
+		fmt.Fprintf(&s.b, `"https://service-%d.example.com/api?timeout=30"`, s.n)
+	}
+}
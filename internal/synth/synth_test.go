@@ -0,0 +1,32 @@
+package synth
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestGenerate(t *testing.T) {
+	o := Options{Width: 5, Depth: 2, ArraySize: 2}
+	data := Generate(o)
+	if !bytes.Equal(data, Generate(o)) {
+		t.Fatalf("the same options should give the same document")
+	}
+	doc, err := appsettingsenv.ParseStrict(data)
+	if err != nil {
+		t.Fatalf("invalid document: %v\n%s", err, data)
+	}
+
+	vars := appsettingsenv.Flatten(doc, appsettingsenv.FlattenOptions{Separator: "__"})
+	want := map[string]string{"Section0__Setting0": "https://service-1.example.com/api?timeout=30", "Section0__Items1__1": "item-3",
+		"Items1__0__Port": "8000", "Setting3": "10", "Setting4": "false"}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Fatalf("%s: want %q got %q in %v", k, v, vars[k], vars)
+		}
+	}
+	if len(vars) != 15 {
+		t.Fatalf("unexpected variables: %v", vars)
+	}
+}
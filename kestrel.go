@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// kestrelEndpoint is an endpoint of the Kestrel:Endpoints section
+type kestrelEndpoint struct {
+	name string
+	// settings holds the keys of the endpoint, relative to it and lower-cased, such
+	// as url or certificate:path, with their full key
+	settings map[string]string
+}
+
+// kestrelEndpoints returns the endpoints configured in variables, keyed by canonical
+// ":" keys, in name order. Names are case-insensitive, as in .NET configuration.
+func kestrelEndpoints(variables map[string]string) []kestrelEndpoint {
+	byName := make(map[string]*kestrelEndpoint)
+	var endpoints []*kestrelEndpoint
+	for _, k := range appsettingsenv.SortedKeys(variables) {
+		parts := strings.SplitN(k, keySep, 4)
+		if len(parts) < 4 || !strings.EqualFold(parts[0], "Kestrel") || !strings.EqualFold(parts[1], "Endpoints") {
+			continue
+		}
+		e, ok := byName[strings.ToLower(parts[2])]
+		if !ok {
+			e = &kestrelEndpoint{name: parts[2], settings: make(map[string]string)}
+			byName[strings.ToLower(parts[2])] = e
+			endpoints = append(endpoints, e)
+		}
+		e.settings[strings.ToLower(parts[3])] = k
+	}
+
+	out := make([]kestrelEndpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		out = append(out, *e)
+	}
+	slices.SortFunc(out, func(a, b kestrelEndpoint) int {
+		return strings.Compare(strings.ToLower(a.name), strings.ToLower(b.name))
+	})
+	return out
+}
+
+// endpointURLs returns the Url of every Kestrel endpoint of variables, in endpoint
+// name order
+func endpointURLs(variables map[string]string) []string {
+	var urls []string
+	for _, e := range kestrelEndpoints(variables) {
+		if u := variables[e.settings["url"]]; u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// translateEndpoints removes the Kestrel endpoints of variables for -kestrel-urls and
+// returns their URLs as an ASPNETCORE_URLS value. Kestrel ignores ASPNETCORE_URLS as
+// soon as one endpoint is configured, so endpoints are translated only when none of
+// them holds more than a Url; otherwise variables are returned unchanged and ok is
+// false.
+func translateEndpoints(variables map[string]string) (out map[string]string, urls string, ok bool) {
+	endpoints := kestrelEndpoints(variables)
+	if len(endpoints) == 0 {
+		return variables, "", false
+	}
+	for _, e := range endpoints {
+		if len(e.settings) > 1 || variables[e.settings["url"]] == "" {
+			warnf("Kestrel endpoint %s has settings other than a Url, the endpoints are not translated to ASPNETCORE_URLS", e.name)
+			return variables, "", false
+		}
+	}
+
+	out = maps.Clone(variables)
+	for _, e := range endpoints {
+		delete(out, e.settings["url"])
+	}
+	return out, strings.Join(endpointURLs(variables), ";"), true
+}
+
+// containerTargets run the application in a Linux container
+var containerTargets = map[string]bool{
+	"k8s": true, "configmap": true, "docker": true, "compose": true, "bicep": true,
+}
+
+// ingressTargets run behind an ingress that terminates TLS
+var ingressTargets = map[string]bool{"k8s": true, "configmap": true, "bicep": true}
+
+// certificatePathKey matches the certificate files of Kestrel endpoints and of the
+// Kestrel:Certificates section
+var certificatePathKey = regexp.MustCompile(`(?i)^Kestrel:(Endpoints:[^:]+:Certificate|Certificates:[^:]+):(Path|KeyPath)$`)
+
+// windowsPath matches drive-letter and UNC paths
+var windowsPath = regexp.MustCompile(`^([A-Za-z]:|\\\\)`)
+
+// kestrelProblems lists the Kestrel settings of variables that will not work where
+// the output type runs the application: certificate files that cannot exist there,
+// and endpoints at odds with containers and ingresses. Certificate paths of local
+// outputs are resolved against dir, the content root.
+func kestrelProblems(outType string, variables map[string]string, dir string) []string {
+	var problems []string
+	for _, k := range appsettingsenv.SortedKeys(variables) {
+		path := variables[k]
+		if !certificatePathKey.MatchString(k) || path == "" {
+			continue
+		}
+		switch {
+		case containerTargets[outType]:
+			if windowsPath.MatchString(path) || strings.Contains(path, `\`) {
+				problems = append(problems, fmt.Sprintf("%s: certificate path %q is a Windows path, which does not exist in a Linux container", k, path))
+			}
+		case dir != "":
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if !fileExists(path) {
+				problems = append(problems, fmt.Sprintf("%s: certificate file %s not found", k, path))
+			}
+		}
+	}
+
+	if !containerTargets[outType] {
+		return problems
+	}
+	for _, e := range kestrelEndpoints(variables) {
+		key := e.settings["url"]
+		scheme, host, port, ok := splitEndpointURL(variables[key])
+		if !ok {
+			continue
+		}
+		switch host {
+		case "localhost", "127.0.0.1", "[::1]":
+			problems = append(problems, fmt.Sprintf("%s: endpoint listens on %s only, which is unreachable from outside the container (use + or 0.0.0.0)", key, host))
+		}
+		if port > 0 && port < 1024 {
+			problems = append(problems, fmt.Sprintf("%s: port %d needs root, which .NET 8 and later container images do not run as (use 8080)", key, port))
+		}
+		if scheme == "https" && ingressTargets[outType] {
+			problems = append(problems, fmt.Sprintf("%s: HTTPS endpoint behind an ingress, which usually terminates TLS and forwards plain HTTP", key))
+		}
+	}
+	return problems
+}
+
+// validateKestrel warns about the Kestrel problems of variables for the output type
+// and returns their number
+func validateKestrel(outType string, variables map[string]string) int {
+	dir := ""
+	if *file != "-" {
+		dir = filepath.Dir(*file)
+	}
+	problems := kestrelProblems(outType, variables, dir)
+	for _, p := range problems {
+		warnf("%s", p)
+	}
+	return len(problems)
+}
+
+// splitEndpointURL splits a Kestrel endpoint URL such as http://+:8080 into its
+// lower-cased scheme and host and its port, the default port of the scheme when
+// omitted. ok is false for URLs without a scheme and for Unix sockets.
+func splitEndpointURL(u string) (scheme, host string, port int, ok bool) {
+	scheme, rest, found := strings.Cut(strings.ToLower(strings.TrimSpace(u)), "://")
+	if !found || strings.HasPrefix(rest, "unix:") {
+		return "", "", 0, false
+	}
+	host, _, _ = strings.Cut(rest, "/")
+	switch scheme {
+	case "http":
+		port = 80
+	case "https":
+		port = 443
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		p, err := strconv.Atoi(host[i+1:])
+		if err != nil {
+			return "", "", 0, false
+		}
+		host, port = host[:i], p
+	}
+	return scheme, host, port, true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKestrelProblems(t *testing.T) {
+	variables := map[string]string{
+		"Kestrel:Endpoints:Http:Url":               "http://localhost:80",
+		"Kestrel:Endpoints:Https:Url":              "https://+:8443",
+		"Kestrel:Endpoints:Https:Certificate:Path": `C:\certs\api.pfx`,
+		"Kestrel:Endpoints:Grpc:Url":               "http://0.0.0.0:5000",
+		"Kestrel:Certificates:Default:Path":        "certs/default.pem",
+		"Kestrel:Endpoints:Socket:Url":             "http://unix:/tmp/api.sock",
+	}
+
+	got := strings.Join(kestrelProblems("k8s", variables, ""), "\n")
+	for _, want := range []string{
+		`Kestrel:Endpoints:Https:Certificate:Path: certificate path "C:\\certs\\api.pfx" is a Windows path`,
+		"Kestrel:Endpoints:Http:Url: endpoint listens on localhost only",
+		"Kestrel:Endpoints:Http:Url: port 80 needs root",
+		"Kestrel:Endpoints:Https:Url: HTTPS endpoint behind an ingress",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing problem %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Grpc") || strings.Contains(got, "Socket") || strings.Contains(got, "Default") {
+		t.Fatalf("unexpected problems:\n%s", got)
+	}
+	if got := kestrelProblems("docker", variables, ""); strings.Contains(strings.Join(got, "\n"), "ingress") {
+		t.Fatalf("docker has no ingress: %v", got)
+	}
+
+	// Local outputs look for the certificate files in the content root
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "certs"), 0o755)
+	os.WriteFile(filepath.Join(dir, "certs", "default.pem"), nil, 0o644)
+	got = strings.Join(kestrelProblems("launchsettings", variables, dir), "\n")
+	if !strings.Contains(got, "Kestrel:Endpoints:Https:Certificate:Path: certificate file") || strings.Contains(got, "default.pem") ||
+		strings.Contains(got, "localhost") {
+		t.Fatalf("unexpected problems:\n%s", got)
+	}
+}
+
+func TestTranslateEndpoints(t *testing.T) {
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+
+	variables := map[string]string{
+		"Kestrel:Endpoints:Http:Url":  "http://+:8080",
+		"Kestrel:Endpoints:Admin:Url": "http://+:9090",
+		"Kestrel:Limits:MaxBodySize":  "1024",
+	}
+	out, urls, ok := translateEndpoints(variables)
+	if !ok || urls != "http://+:9090;http://+:8080" || len(out) != 1 || out["Kestrel:Limits:MaxBodySize"] != "1024" {
+		t.Fatalf("unexpected translation: %v %q %v", ok, urls, out)
+	}
+	if len(variables) != 3 {
+		t.Fatalf("variables should be left unchanged: %v", variables)
+	}
+
+	variables["Kestrel:Endpoints:Http:Protocols"] = "Http2"
+	if out, _, ok := translateEndpoints(variables); ok || len(out) != 4 {
+		t.Fatalf("endpoints with other settings should not be translated: %v", out)
+	}
+}
+
+func TestSplitEndpointURL(t *testing.T) {
+	cases := map[string]struct {
+		scheme, host string
+		port         int
+		ok           bool
+	}{
+		"http://+:8080":           {"http", "+", 8080, true},
+		"https://api.example.com": {"https", "api.example.com", 443, true},
+		"http://[::1]:5000/":      {"http", "[::1]", 5000, true},
+		"http://[::]":             {"http", "[::]", 80, true},
+		"http://unix:/tmp/s":      {"", "", 0, false},
+		"localhost:5000":          {"", "", 0, false},
+	}
+	for u, want := range cases {
+		scheme, host, port, ok := splitEndpointURL(u)
+		if scheme != want.scheme || host != want.host || port != want.port || ok != want.ok {
+			t.Fatalf("%s: got %q %q %d %v", u, scheme, host, port, ok)
+		}
+	}
+}
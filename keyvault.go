@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// keyVaultPattern matches App Service and Functions Key Vault references
+var keyVaultPattern = regexp.MustCompile(`(?i)^\s*@Microsoft\.KeyVault\((.*)\)\s*$`)
+
+// keyVaultRef is a parsed @Microsoft.KeyVault(...) reference
+type keyVaultRef struct {
+	key     string
+	vault   string
+	secret  string
+	version string
+}
+
+// isKeyVaultRef reports whether value is a Key Vault reference. Such values are
+// resolved by the platform and passed through verbatim.
+func isKeyVaultRef(value string) bool {
+	return keyVaultPattern.MatchString(value)
+}
+
+// parseKeyVaultRef parses both the SecretUri=... and the VaultName=...;SecretName=...
+// forms of a Key Vault reference
+func parseKeyVaultRef(key, value string) (keyVaultRef, bool) {
+	m := keyVaultPattern.FindStringSubmatch(value)
+	if m == nil {
+		return keyVaultRef{}, false
+	}
+
+	ref := keyVaultRef{key: key}
+	for _, part := range strings.Split(m[1], ";") {
+		name, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "vaultname":
+			ref.vault = v
+		case "secretname":
+			ref.secret = v
+		case "secretversion":
+			ref.version = v
+		case "secreturi":
+			u, err := url.Parse(v)
+			if err != nil {
+				return keyVaultRef{}, false
+			}
+			ref.vault, _, _ = strings.Cut(u.Hostname(), ".")
+			segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+			if len(segments) >= 2 && strings.EqualFold(segments[0], "secrets") {
+				ref.secret = segments[1]
+			}
+			if len(segments) >= 3 {
+				ref.version = segments[2]
+			}
+		}
+	}
+	return ref, ref.vault != "" && ref.secret != ""
+}
+
+// keyVaultRefs returns the Key Vault references among vars, sorted by key
+func keyVaultRefs(vars map[string]string) []keyVaultRef {
+	var refs []keyVaultRef
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		if ref, ok := parseKeyVaultRef(k, vars[k]); ok {
+			refs = append(refs, ref)
+		} else if isKeyVaultRef(vars[k]) {
+			warnf("%s: malformed Key Vault reference %s", k, vars[k])
+		}
+	}
+	return refs
+}
+
+// writeKeyVaultSummary lists the vault secrets referenced by the configuration
+func writeKeyVaultSummary(w io.Writer, refs []keyVaultRef) error {
+	if *logFormat == "json" {
+		for _, r := range refs {
+			logEvent(slog.LevelInfo, "Key Vault reference", "key", r.key, "vault", r.vault, "secret", r.secret, "version", r.version)
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Key Vault references:")
+	for _, r := range refs {
+		version := r.version
+		if version == "" {
+			version = "latest"
+		}
+		fmt.Fprintf(tw, "  %s\t%s/%s\t%s\n", r.key, r.vault, r.secret, version)
+	}
+	return tw.Flush()
+}
+
+// keyVaultAPI is the part of the Key Vault secrets client used by push keyvault
+type keyVaultAPI interface {
+	GetSecret(ctx context.Context, name, version string, options *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error)
+	SetSecret(ctx context.Context, name string, parameters azsecrets.SetSecretParameters, options *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error)
+}
+
+// keyVaultSecretPattern matches valid Key Vault secret names
+var keyVaultSecretPattern = regexp.MustCompile(`^[0-9A-Za-z-]{1,127}$`)
+
+// keyVaultSecretName returns the secret of key with the -- separator, which the
+// Azure Key Vault configuration provider reads back as ":"
+func keyVaultSecretName(key string) string {
+	return strings.ReplaceAll(key, keySep, "--")
+}
+
+// runPushKeyVault implements push keyvault. It writes the variables matched by
+// -secret-keys as Key Vault secrets, skipping those whose value did not change.
+func runPushKeyVault(args []string) int {
+	vault := flag.String("vault", "", "Vault name, or its URL for sovereign clouds (required)")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push keyvault [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Only the keys matched by -secret-keys are written. Authentication uses the")
+		fmt.Fprintln(flag.CommandLine.Output(), "azidentity default credential chain, as for push azure.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// The appconfig type keeps ":" keys without name rules
+	r, code := renderOutput("push keyvault", "appconfig", args)
+	if r == nil {
+		return code
+	}
+
+	if *vault == "" {
+		errorf("-vault is required")
+		return exitUsage
+	}
+	if len(secretKeys) == 0 {
+		errorf("-secret-keys is required: only the matched keys are written to the vault")
+		return exitUsage
+	}
+
+	vaultURL := *vault
+	if !strings.Contains(vaultURL, "://") {
+		vaultURL = "https://" + vaultURL + ".vault.azure.net/"
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		logError(fmt.Errorf("azure credential: %w", err))
+		return exitUsage
+	}
+	client, err := azsecrets.NewClient(vaultURL, cred, nil)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	store := &keyVaultStore{client: client, vault: *vault}
+	if err := pushSecretStore(context.Background(), os.Stdout, store, r, *dryRun); err != nil {
+		logError(err)
+		return exitCode(err, exitIO)
+	}
+	return exitOK
+}
+
+// keyVaultStore is the secretStore of the secrets of a vault. Only the -secret-keys
+// are written, and only when their value differs from the current version of their
+// secret, so that no redundant version is created.
+type keyVaultStore struct {
+	client keyVaultAPI
+	vault  string
+}
+
+func (s *keyVaultStore) String() string {
+	return "Key Vault " + s.vault
+}
+
+func (s *keyVaultStore) entry(name, value string, secret bool) (*secretEntry, error) {
+	// References already point at a vault secret
+	if !secret || isKeyVaultRef(value) {
+		return nil, nil
+	}
+	secretName := keyVaultSecretName(name)
+	if !keyVaultSecretPattern.MatchString(secretName) {
+		return nil, fmt.Errorf("%s: expected letters, digits and - only", name)
+	}
+	return &secretEntry{name: secretName, value: value, secret: true}, nil
+}
+
+func (s *keyVaultStore) entries(ctx context.Context, names []string) (map[string]secretEntry, error) {
+	entries := make(map[string]secretEntry, len(names))
+	for _, name := range names {
+		resp, err := s.client.GetSecret(ctx, name, "", nil)
+		var rerr *azcore.ResponseError
+		if errors.As(err, &rerr) && rerr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get secret %s: %w", name, err)
+		}
+		entries[name] = secretEntry{name: name, value: *resp.Value, secret: true}
+	}
+	return entries, nil
+}
+
+func (s *keyVaultStore) write(ctx context.Context, changed []secretEntry, _ map[string]secretEntry) error {
+	for _, e := range changed {
+		value := e.value
+		if _, err := s.client.SetSecret(ctx, e.name, azsecrets.SetSecretParameters{Value: &value}, nil); err != nil {
+			return fmt.Errorf("set secret %s: %w", e.name, err)
+		}
+	}
+	return nil
+}
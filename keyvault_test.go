@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestParseKeyVaultRef(t *testing.T) {
+	cases := []struct {
+		value string
+		want  keyVaultRef
+	}{
+		{"@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/DbPassword/)", keyVaultRef{vault: "myvault", secret: "DbPassword"}},
+		{"@Microsoft.KeyVault(SecretUri=https://myvault.vault.azure.net/secrets/DbPassword/ec96f02080254f109c51a1f14cdb1931)", keyVaultRef{vault: "myvault", secret: "DbPassword", version: "ec96f02080254f109c51a1f14cdb1931"}},
+		{"@Microsoft.KeyVault(VaultName=myvault;SecretName=ApiKey)", keyVaultRef{vault: "myvault", secret: "ApiKey"}},
+	}
+
+	for _, c := range cases {
+		got, ok := parseKeyVaultRef("", c.value)
+		if !ok || got != c.want {
+			t.Fatalf("parseKeyVaultRef(%q): want %+v got %+v (%v)", c.value, c.want, got, ok)
+		}
+	}
+
+	if _, ok := parseKeyVaultRef("", "@Microsoft.KeyVault(VaultName=myvault)"); ok {
+		t.Fatalf("a reference without secret name should not parse")
+	}
+	if isKeyVaultRef("plain value") {
+		t.Fatalf("plain values are not references")
+	}
+}
+
+func TestKeyVaultRefPassthrough(t *testing.T) {
+	ref := "@Microsoft.KeyVault(VaultName=myvault;SecretName=ApiKey)"
+	list := []appsettingsenv.KV{{Name: "Api__Key", Value: ref}}
+
+	for _, outType := range appsettingsenv.Types() {
+		var buf bytes.Buffer
+		if err := appsettingsenv.Format(&buf, list, appsettingsenv.FormatOptions{Type: outType}); err != nil {
+			t.Fatalf("%s: write failed: %v", outType, err)
+		}
+		if !strings.Contains(buf.String(), ref) {
+			t.Fatalf("%s: reference not preserved:\n%s", outType, buf.String())
+		}
+	}
+
+	vars := map[string]string{"Api:Key": ref}
+	if got, err := resolvePlaceholders(vars); err != nil || got["Api:Key"] != ref {
+		t.Fatalf("placeholder resolution altered the reference: %v %v", got, err)
+	}
+}
+
+// fakeKeyVault is an in-memory vault counting the versions written per secret
+type fakeKeyVault struct {
+	secrets  map[string]string
+	versions map[string]int
+}
+
+func (f *fakeKeyVault) GetSecret(_ context.Context, name, _ string, _ *azsecrets.GetSecretOptions) (azsecrets.GetSecretResponse, error) {
+	value, ok := f.secrets[name]
+	if !ok {
+		return azsecrets.GetSecretResponse{}, &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "SecretNotFound"}
+	}
+	return azsecrets.GetSecretResponse{Secret: azsecrets.Secret{Value: &value}}, nil
+}
+
+func (f *fakeKeyVault) SetSecret(_ context.Context, name string, p azsecrets.SetSecretParameters, _ *azsecrets.SetSecretOptions) (azsecrets.SetSecretResponse, error) {
+	f.secrets[name] = *p.Value
+	f.versions[name]++
+	return azsecrets.SetSecretResponse{}, nil
+}
+
+func TestPushKeyVault(t *testing.T) {
+	fake := &fakeKeyVault{
+		secrets:  map[string]string{"ConnectionStrings--Db": "old", "ApiKey": "same"},
+		versions: map[string]int{},
+	}
+	r := &rendered{
+		named: map[string]string{
+			"ConnectionStrings:Db": "new",
+			"ApiKey":               "same",
+			"Jwt:Secret":           "s3cret",
+			"Ref":                  "@Microsoft.KeyVault(VaultName=v;SecretName=Ref)",
+			"Logging:Level":        "Warning",
+		},
+		secret: map[string]bool{"ConnectionStrings:Db": true, "ApiKey": true, "Jwt:Secret": true, "Ref": true},
+	}
+
+	store := &keyVaultStore{client: fake, vault: "kv"}
+	var out bytes.Buffer
+	if err := pushSecretStore(context.Background(), &out, store, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "changed ConnectionStrings--Db: \"(secret)\" -> \"(secret)\"\nadded   Jwt--Secret: \"(secret)\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if len(fake.versions) != 0 {
+		t.Fatalf("dry run should not write")
+	}
+
+	out.Reset()
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2 secret(s) written to Key Vault kv\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+	if fake.versions["ApiKey"] != 0 || fake.versions["Ref"] != 0 || fake.versions["Logging--Level"] != 0 {
+		t.Fatalf("unchanged, referenced and plain values should not be written: %v", fake.versions)
+	}
+	if fake.secrets["ConnectionStrings--Db"] != "new" || fake.secrets["Jwt--Secret"] != "s3cret" {
+		t.Fatalf("unexpected secrets: %v", fake.secrets)
+	}
+
+	out.Reset()
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err != nil || out.String() != "Key Vault kv up to date\n" {
+		t.Fatalf("second push should write nothing: %q, %v", out.String(), err)
+	}
+
+	r = &rendered{named: map[string]string{"Db_Password": "x"}, secret: map[string]bool{"Db_Password": true}}
+	if err := pushSecretStore(context.Background(), &out, store, r, false); err == nil {
+		t.Fatalf("invalid secret name should fail")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeClient reaches the API server of a kubeconfig context with client-go, scoped
+// to one namespace
+type kubeClient struct {
+	namespace string
+	clientset kubernetes.Interface
+}
+
+// newKubeClient builds a client from a kubeconfig file, with every authentication
+// kubectl supports: tokens, client certificates, exec and auth provider plugins. An
+// empty path falls back to $KUBECONFIG, ~/.kube/config and finally the in-cluster
+// service account. An empty contextName selects the current context and an empty
+// namespace the context's one.
+func newKubeClient(path, contextName, namespace string) (*kubeClient, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = path
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	overrides.Context.Namespace = namespace
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: %w", err)
+	}
+	if namespace, _, err = config.Namespace(); err != nil {
+		return nil, fmt.Errorf("kubeconfig: %w", err)
+	}
+	return kubeClientFor(restConfig, namespace)
+}
+
+// kubeClientFor builds a client of the API server of restConfig
+func kubeClientFor(restConfig *rest.Config, namespace string) (*kubeClient, error) {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.UserAgent = app + "/" + version
+	restConfig.Timeout = 30 * time.Second
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &kubeClient{namespace: namespace, clientset: clientset}, nil
+}
+
+// get reads a workload, ConfigMap or Secret of the client's namespace. kind is one of
+// workloadKinds or ConfigMap or Secret, in any case.
+func (c *kubeClient) get(ctx context.Context, kind, name string) (*kubeObject, error) {
+	var obj any
+	var err error
+	opts := metav1.GetOptions{}
+	apps, core := c.clientset.AppsV1(), c.clientset.CoreV1()
+	switch strings.ToLower(kind) {
+	case "deployment":
+		obj, err = apps.Deployments(c.namespace).Get(ctx, name, opts)
+	case "statefulset":
+		obj, err = apps.StatefulSets(c.namespace).Get(ctx, name, opts)
+	case "daemonset":
+		obj, err = apps.DaemonSets(c.namespace).Get(ctx, name, opts)
+	case "configmap":
+		obj, err = core.ConfigMaps(c.namespace).Get(ctx, name, opts)
+	case "secret":
+		obj, err = core.Secrets(c.namespace).Get(ctx, name, opts)
+	default:
+		return nil, fmt.Errorf("unsupported kind %s", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The typed object goes through its API JSON, which kubeObject decodes as well
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var o kubeObject
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+	if o.Kind == "" {
+		o.Kind = kind
+		if k := workloadKinds[strings.ToLower(kind)]; k != "" {
+			o.Kind = k
+		}
+	}
+	return &o, nil
+}
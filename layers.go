@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// layer is a single configuration source. Layers are applied in order, later
+// layers overriding the values of earlier ones.
+type layer struct {
+	source string
+	vars   map[string]string
+}
+
+// configurationLayers loads every source selected by the command line flags in the
+// precedence order of the .NET host: files, environment files, user secrets,
+// environment variables and finally the -set overrides.
+func configurationLayers(setVars map[string]string) ([]layer, error) {
+	layers, err := loadLayers(*file, *environment, keySep)
+	if err != nil {
+		return nil, err
+	}
+
+	// User secrets sit between the environment file and environment variables
+	if strings.EqualFold(*environment, "Development") {
+		secrets, err := loadUserSecrets(*userSecretsID, *file, keySep)
+		if err != nil {
+			return nil, fmt.Errorf("error processing user secrets: %w", err)
+		}
+		if secrets != nil {
+			layers = append(layers, layer{source: "user secrets", vars: secrets})
+		}
+	}
+
+	// Layer the process environment on top, like AddEnvironmentVariables()
+	if *overlayEnv || *overlayEnvPrefix != "" {
+		env := environmentLayer(mergeLayers(layers), os.Environ(), *overlayEnvPrefix, keySep)
+		layers = append(layers, layer{source: "environment", vars: env})
+	}
+
+	// Inline overrides win over everything else
+	if len(setVars) > 0 {
+		layers = append(layers, layer{source: "-set", vars: setVars})
+	}
+
+	return layers, nil
+}
+
+// loadFiles reads every file matching pattern, plus the environment files when env is
+// set, and returns the merged variables
+func loadFiles(pattern, env, sep string) (map[string]string, error) {
+	layers, err := loadLayers(pattern, env, sep)
+	if err != nil {
+		return nil, err
+	}
+	return mergeLayers(layers), nil
+}
+
+// loadLayers reads every file matching pattern as one layer each. When env is set,
+// each file's appsettings.{env}.json counterpart is layered right after it.
+func loadLayers(pattern, env, sep string) ([]layer, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate file pattern: %w", err)
+	}
+	if pattern == "-" {
+		files = []string{"-"}
+	}
+
+	if len(files) == 0 {
+		return nil, ioError(fmt.Errorf("no files matching pattern: %s", pattern))
+	}
+
+	// Environment files matched by the pattern are loaded after their base file instead,
+	// and only for the selected environment
+	matched := make(map[string]bool, len(files))
+	for _, f := range files {
+		matched[f] = true
+	}
+
+	var load []string
+	for _, f := range files {
+		if env == "" {
+			load = append(load, f)
+			continue
+		}
+
+		if base, ok := baseFile(f); ok && matched[base] {
+			continue
+		}
+
+		load = append(load, f)
+		if envFile := environmentFile(f, env); fileExists(envFile) {
+			load = append(load, envFile)
+		}
+	}
+
+	// Files are parsed concurrently and merged in order, as if read one by one
+	var layers []layer
+	var errs []error
+	for _, p := range parseFiles(load, sep) {
+		debug("parsed file", "file", p.name, "keys", len(p.vars))
+		p.record()
+		switch {
+		case p.err != nil:
+			errs = append(errs, fmt.Errorf("error processing %s: %w", p.name, p.err))
+		case *failOnEmpty && len(p.vars) == 0:
+			errs = append(errs, validationError(&locatedError{file: p.name, err: fmt.Errorf("%s holds no variables (-fail-on-empty)", p.name)}))
+		default:
+			layers = append(layers, layer{source: p.name, vars: p.vars})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return layers, nil
+}
+
+// parseFiles parses files on GOMAXPROCS workers at most, returning them in the order
+// given
+func parseFiles(files []string, sep string) []*parsedFile {
+	parsed := make([]*parsedFile, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), len(files)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				parsed[i] = parseFile(files[i], sep)
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return parsed
+}
+
+// environmentFile returns the environment-specific counterpart of filename,
+// e.g. appsettings.json -> appsettings.Production.json
+func environmentFile(filename, env string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + env + ext
+}
+
+// baseFile reverses environmentFile for any environment, reporting whether filename
+// may be environment-specific
+func baseFile(filename string) (string, bool) {
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	i := strings.LastIndexByte(stem, '.')
+	if i <= len(filename)-len(filepath.Base(filename)) {
+		return "", false
+	}
+	return stem[:i] + ext, true
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+// mergeLayers merges layers in order into a single set of variables
+func mergeLayers(layers []layer) map[string]string {
+	out := make(map[string]string)
+	for _, l := range layers {
+		mergeVariables(out, l.vars)
+	}
+	return out
+}
+
+// mergeVariables copies src into dst. As in IConfiguration, keys match
+// case-insensitively and the casing already present in dst is kept.
+func mergeVariables(dst, src map[string]string) {
+	index := make(map[string]string, len(dst))
+	for k := range dst {
+		index[strings.ToLower(k)] = k
+	}
+
+	for k, v := range src {
+		if existing, ok := index[strings.ToLower(k)]; ok {
+			dst[existing] = v
+			continue
+		}
+		dst[k] = v
+		index[strings.ToLower(k)] = k
+	}
+}
+
+// environmentLayer selects the environment variables (NAME=value) that apply over vars
+// the way the ASP.NET Core environment variable provider does: "__" in names stands
+// for the key separator, App Service connection string prefixes (SQLCONNSTR_, ...) map
+// to the ConnectionStrings section and names match case-insensitively. Without a prefix only keys
+// already present are overridden; with a prefix every variable carrying it is applied
+// with the prefix removed.
+func environmentLayer(vars map[string]string, environ []string, prefix, sep string) map[string]string {
+	index := make(map[string]string, len(vars))
+	for k := range vars {
+		index[strings.ToLower(k)] = k
+	}
+
+	out := make(map[string]string)
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+
+		if prefix != "" {
+			if len(name) <= len(prefix) || !strings.EqualFold(name[:len(prefix)], prefix) {
+				continue
+			}
+			name = name[len(prefix):]
+		}
+
+		key := strings.ReplaceAll(name, "__", sep)
+		for _, p := range connectionStringPrefixes {
+			if len(name) > len(p) && strings.EqualFold(name[:len(p)], p) {
+				key = "ConnectionStrings" + sep + name[len(p):]
+				break
+			}
+		}
+		if existing, ok := index[strings.ToLower(key)]; ok {
+			out[existing] = value
+			continue
+		}
+
+		if prefix != "" {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// parseOverrides parses key=value pairs. Keys may use either "__" or ":" as the
+// separator; they replace existing keys regardless of case once merged.
+func parseOverrides(pairs []string, sep string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid override %q: expected key=value", pair)
+		}
+
+		mergeVariables(out, map[string]string{normalizeKey(key, sep): value})
+	}
+	return out, nil
+}
+
+// normalizeKey rewrites a key given in "__" or ":" notation to use sep, in the NFC
+// form the keys of the files are normalized to
+func normalizeKey(key, sep string) string {
+	return norm.NFC.String(strings.ReplaceAll(strings.ReplaceAll(key, "__", sep), ":", sep))
+}
+
+// withSeparator returns vars with the internal key separator replaced by sep
+func withSeparator(vars map[string]string, sep string) map[string]string {
+	if sep == keySep {
+		return vars
+	}
+
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		out[strings.ReplaceAll(k, keySep, sep)] = v
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentLayer(t *testing.T) {
+	vars := map[string]string{
+		"Logging__LogLevel__Default": "Information",
+		"Api__Url":                   "http://localhost",
+	}
+	environ := []string{
+		"LOGGING__LOGLEVEL__DEFAULT=Debug",
+		"PATH=/usr/bin",
+		"=C:=C:\\",
+	}
+
+	mergeVariables(vars, environmentLayer(vars, environ, "", "__"))
+
+	if vars["Logging__LogLevel__Default"] != "Debug" {
+		t.Fatalf("expected override from environment, got %q", vars["Logging__LogLevel__Default"])
+	}
+	if _, ok := vars["PATH"]; ok {
+		t.Fatalf("unrelated environment variable should not be added without a prefix")
+	}
+	if len(vars) != 2 {
+		t.Fatalf("unexpected variables: %v", vars)
+	}
+}
+
+func TestEnvironmentLayer_Prefix(t *testing.T) {
+	vars := map[string]string{"Api:Url": "http://localhost"}
+	environ := []string{
+		"MYAPP_Api__Url=https://prod",
+		"MYAPP_Feature__Enabled=true",
+		"Api__Url=ignored",
+	}
+
+	mergeVariables(vars, environmentLayer(vars, environ, "MYAPP_", ":"))
+
+	if vars["Api:Url"] != "https://prod" {
+		t.Fatalf("prefixed override not applied: %v", vars)
+	}
+	if vars["Feature:Enabled"] != "true" {
+		t.Fatalf("prefixed variable not added with separator: %v", vars)
+	}
+}
+
+func TestParseOverrides(t *testing.T) {
+	vars := map[string]string{"Logging__LogLevel__Default": "Information"}
+
+	set, err := parseOverrides([]string{
+		"logging:loglevel:default=Debug",
+		"Api__Url=http://host?a=b",
+	}, "__")
+	if err != nil {
+		t.Fatalf("parseOverrides failed: %v", err)
+	}
+	mergeVariables(vars, set)
+
+	if vars["Logging__LogLevel__Default"] != "Debug" {
+		t.Fatalf("existing key not overridden: %v", vars)
+	}
+	if vars["Api__Url"] != "http://host?a=b" {
+		t.Fatalf("new key not added: %v", vars)
+	}
+	if len(vars) != 2 {
+		t.Fatalf("unexpected variables: %v", vars)
+	}
+
+	if _, err := parseOverrides([]string{"missing-value"}, "__"); err == nil {
+		t.Fatalf("expected error for override without '='")
+	}
+}
+
+func TestLoadFiles_Environment(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"appsettings.json":             `{"Logging": {"Level": "Information"}, "Api": "base"}`,
+		"appsettings.Development.json": `{"logging": {"level": "Debug"}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	vars, err := loadFiles(filepath.Join(dir, "appsettings*.json"), "Development", "__")
+	if err != nil {
+		t.Fatalf("loadFiles failed: %v", err)
+	}
+
+	if len(vars) != 2 || vars["Logging__Level"] != "Debug" || vars["Api"] != "base" {
+		t.Fatalf("environment file not layered over base: %v", vars)
+	}
+}
+
+func TestLoadFiles_OtherEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"appsettings.json":             `{"Api": "base"}`,
+		"appsettings.Development.json": `{"Api": "dev", "Debug": true}`,
+		"appsettings.Production.json":  `{"Api": "prod"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	vars, err := loadFiles(filepath.Join(dir, "appsettings*.json"), "Production", "__")
+	if err != nil {
+		t.Fatalf("loadFiles failed: %v", err)
+	}
+
+	if len(vars) != 1 || vars["Api"] != "prod" {
+		t.Fatalf("only the Production file should be layered over base: %v", vars)
+	}
+}
+
+func TestEnvironmentLayer_ConnectionStrings(t *testing.T) {
+	vars := map[string]string{"ConnectionStrings:Default": "Server=local"}
+	env := environmentLayer(vars, []string{"SQLCONNSTR_Default=Server=azure"}, "", ":")
+	if env["ConnectionStrings:Default"] != "Server=azure" {
+		t.Fatalf("connection string prefix not mapped: %v", env)
+	}
+}
+
+func TestLoadFiles_FailOnEmpty(t *testing.T) {
+	defer func(b bool) { *failOnEmpty = b }(*failOnEmpty)
+
+	fn := filepath.Join(t.TempDir(), "appsettings.json")
+	if err := os.WriteFile(fn, []byte(`{"Logging": {}}`), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	if _, err := loadFiles(fn, "", "__"); err != nil {
+		t.Fatalf("empty files are accepted by default: %v", err)
+	}
+
+	*failOnEmpty = true
+	_, err := loadFiles(fn, "", "__")
+	if exitCode(err, exitFailure) != exitValidation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+}
+
+func TestLoadLayers_Parallel(t *testing.T) {
+	defer func(s string) { *sortMode = s }(*sortMode)
+	var log strings.Builder
+	logOutput = &log
+	defer func() { logOutput = os.Stderr }()
+	clear(sourceOrder)
+	defer clear(sourceOrder)
+
+	// Files are merged and warned about in the order of their names, whichever
+	// worker parses them first
+	*sortMode = "source"
+	dir := t.TempDir()
+	var want strings.Builder
+	for i := range 100 {
+		name := filepath.Join(dir, fmt.Sprintf("appsettings.%03d.json", i))
+		content := fmt.Sprintf(`{"Z%d": 1, "Shared": %d, "Shared": %d}`, i, i, i)
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		fmt.Fprintf(&want, "warning: %s:1: duplicate key Shared, first defined on line 1\n", name)
+	}
+
+	layers, err := loadLayers(filepath.Join(dir, "*.json"), "", "__")
+	if err != nil {
+		t.Fatalf("loadLayers failed: %v", err)
+	}
+	if len(layers) != 100 || !strings.HasSuffix(layers[99].source, "appsettings.099.json") {
+		t.Fatalf("unexpected layers: %d", len(layers))
+	}
+	if vars := mergeLayers(layers); len(vars) != 101 || vars["Shared"] != "99" {
+		t.Fatalf("layers not merged in order: %v", vars)
+	}
+	if log.String() != want.String() {
+		t.Fatalf("warnings not in file order:\n%s", log.String())
+	}
+	if rank, _ := sourceRank("Z0"); rank != 0 {
+		t.Fatalf("source order not in file order: Z0 ranked %d", rank)
+	}
+	if rank, _ := sourceRank("Z99"); rank != 100 {
+		t.Fatalf("source order not in file order: Z99 ranked %d", rank)
+	}
+}
+
+func TestWithSeparator(t *testing.T) {
+	got := withSeparator(map[string]string{"Logging:LogLevel:Default": "x"}, "__")
+	if got["Logging__LogLevel__Default"] != "x" {
+		t.Fatalf("separator not applied: %v", got)
+	}
+}
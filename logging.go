@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var (
+	// logLevel is the lowest level reported, set from -v and -q
+	logLevel = slog.LevelInfo
+
+	// logOutput receives the diagnostics
+	logOutput io.Writer = os.Stderr
+
+	// logColor colors the level of text diagnostics
+	logColor bool
+
+	// warnings counts the problems reported by warnf, including silenced ones
+	warnings int
+)
+
+// setupLogging validates and applies the diagnostics flags
+func setupLogging() error {
+	*logFormat = strings.ToLower(strings.TrimSpace(*logFormat))
+	if *logFormat != "text" && *logFormat != "json" {
+		return fmt.Errorf("invalid log format: %q", *logFormat)
+	}
+	if err := checkErrorFormat(); err != nil {
+		return err
+	}
+
+	switch {
+	case *quiet:
+		logLevel = slog.LevelError
+	case *verbose:
+		logLevel = slog.LevelDebug
+	default:
+		logLevel = slog.LevelInfo
+	}
+
+	logColor = logOutput == io.Writer(os.Stderr) && useColor(os.Stderr)
+	return nil
+}
+
+// logEvent reports a diagnostic on stderr, as text or as a JSON record. attrs are
+// slog key-value pairs, appended as key=value in text.
+func logEvent(level slog.Level, msg string, attrs ...any) {
+	if level < logLevel {
+		return
+	}
+
+	if *errorFormat == "github" && level >= slog.LevelWarn {
+		writeAnnotation(level, msg, attrs)
+		return
+	}
+
+	if *logFormat == "json" {
+		logger := slog.New(slog.NewJSONHandler(logOutput, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		logger.Log(context.Background(), level, msg, attrs...)
+		return
+	}
+
+	var b strings.Builder
+	switch {
+	case level == slog.LevelWarn:
+		b.WriteString(colorize(logColor, colorYellow, "warning:") + " ")
+		b.WriteString(msg)
+	case level >= slog.LevelError:
+		b.WriteString(colorize(logColor, colorRed, msg))
+	default:
+		b.WriteString(msg)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", attrs[i], attrs[i+1])
+	}
+	fmt.Fprintln(logOutput, b.String())
+}
+
+// warnf reports a non-fatal problem
+func warnf(format string, args ...any) {
+	warnings++
+	logEvent(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// warnAt reports a non-fatal problem in a file, at a line when line is not 0
+func warnAt(file string, line int, format string, args ...any) {
+	warnings++
+	msg := fmt.Sprintf(format, args...)
+	if *errorFormat == "github" {
+		logEvent(slog.LevelWarn, msg, "file", file, "line", line)
+		return
+	}
+	if line > 0 {
+		file = fmt.Sprintf("%s:%d", file, line)
+	}
+	logEvent(slog.LevelWarn, file+": "+msg)
+}
+
+// errorf reports a fatal problem
+func errorf(format string, args ...any) {
+	logEvent(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// logError reports err as a fatal problem. As GitHub annotations, the errors joined
+// in err are reported one by one, at their position in a file when known.
+func logError(err error) {
+	if *errorFormat != "github" {
+		logEvent(slog.LevelError, err.Error())
+		return
+	}
+
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		var loc *locatedError
+		if errors.As(e, &loc) {
+			logEvent(slog.LevelError, e.Error(), "file", loc.file, "line", loc.line, "col", loc.col)
+		} else {
+			logEvent(slog.LevelError, e.Error())
+		}
+	}
+}
+
+// locatedError is an error at a position in a file. line and col are 0 when unknown.
+type locatedError struct {
+	file      string
+	line, col int
+	err       error
+}
+
+func (e *locatedError) Error() string { return e.err.Error() }
+func (e *locatedError) Unwrap() error { return e.err }
+
+// checkErrorFormat validates -error-format
+func checkErrorFormat() error {
+	*errorFormat = strings.ToLower(strings.TrimSpace(*errorFormat))
+	if *errorFormat != "text" && *errorFormat != "github" {
+		return fmt.Errorf("invalid error format: %q", *errorFormat)
+	}
+	return nil
+}
+
+// writeAnnotation writes a warning or an error as a GitHub Actions workflow command,
+// located with the file, line and col attributes when given
+func writeAnnotation(level slog.Level, msg string, attrs []any) {
+	command := "warning"
+	if level >= slog.LevelError {
+		command = "error"
+	}
+
+	var props []string
+	for i := 0; i+1 < len(attrs); i += 2 {
+		key, value := fmt.Sprint(attrs[i]), fmt.Sprint(attrs[i+1])
+		switch key {
+		case "file":
+			props = append(props, "file="+escapeProperty(value))
+		case "line", "col":
+			if value != "0" {
+				props = append(props, key+"="+value)
+			}
+		}
+	}
+
+	line := "::" + command
+	if len(props) > 0 {
+		line += " " + strings.Join(props, ",")
+	}
+	fmt.Fprintln(logOutput, line+"::"+escapeData(msg))
+}
+
+// escapeData escapes the message of a workflow command
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// infof reports progress shown unless -q is given
+func infof(format string, args ...any) {
+	logEvent(slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// debug reports details shown with -v
+func debug(msg string, attrs ...any) {
+	logEvent(slog.LevelDebug, msg, attrs...)
+}
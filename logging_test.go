@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestLogEvent(t *testing.T) {
+	defer func(w io.Writer, level slog.Level, format string, n int) {
+		logOutput, logLevel, *logFormat, warnings = w, level, format, n
+	}(logOutput, logLevel, *logFormat, warnings)
+
+	var buf bytes.Buffer
+	logOutput, logLevel, *logFormat, warnings = &buf, slog.LevelInfo, "text", 0
+
+	warnf("%s: empty object", "Section")
+	debug("processing file", "file", "appsettings.json")
+	if buf.String() != "warning: Section: empty object\n" || warnings != 1 {
+		t.Fatalf("unexpected text output: %q", buf.String())
+	}
+
+	buf.Reset()
+	logLevel, *logFormat = slog.LevelDebug, "json"
+	debug("processing file", "file", "appsettings.json")
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON record %q: %v", buf.String(), err)
+	}
+	if record["level"] != "DEBUG" || record["msg"] != "processing file" || record["file"] != "appsettings.json" {
+		t.Fatalf("unexpected record: %v", record)
+	}
+
+	buf.Reset()
+	logLevel = slog.LevelError
+	warnf("silenced")
+	if buf.Len() != 0 || warnings != 2 {
+		t.Fatalf("-q should silence warnings but still count them: %q", buf.String())
+	}
+}
+
+func TestErrorFormatGitHub(t *testing.T) {
+	defer func(w io.Writer, level slog.Level, format string, n int) {
+		logOutput, logLevel, *errorFormat, warnings = w, level, format, n
+	}(logOutput, logLevel, *errorFormat, warnings)
+
+	var buf bytes.Buffer
+	logOutput, logLevel, *errorFormat = &buf, slog.LevelInfo, "text"
+	warnAt("appsettings.json", 3, "duplicate key %s", "A")
+	if buf.String() != "warning: appsettings.json:3: duplicate key A\n" {
+		t.Fatalf("unexpected text output: %q", buf.String())
+	}
+
+	buf.Reset()
+	*errorFormat = "github"
+	warnAt("config/app,1.json", 3, "duplicate key %s", "A")
+	warnf("50%% done\nnext")
+	if buf.String() != "::warning file=config/app%2C1.json,line=3::duplicate key A\n::warning::50%25 done%0Anext\n" {
+		t.Fatalf("unexpected warning annotations: %q", buf.String())
+	}
+
+	buf.Reset()
+	logError(errors.Join(
+		parseError(&locatedError{file: "a.json", line: 2, col: 5, err: errors.New("syntax error")}),
+		errors.New("no files matching pattern"),
+	))
+	if buf.String() != "::error file=a.json,line=2,col=5::syntax error\n::error::no files matching pattern\n" {
+		t.Fatalf("unexpected error annotations: %q", buf.String())
+	}
+}
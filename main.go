@@ -2,15 +2,17 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"maps"
+	"io"
 	"os"
-	"path/filepath"
-	"sort"
+	"slices"
 	"strings"
+	"sync"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -19,218 +21,631 @@ var (
 	description = "Convert .NET appsettings.json file to Kubernetes, Docker, Docker-Compose and Bicep environment variables."
 	site        = "https://github.com/dassump/dotnet-appsettings-env"
 
-	file      = flag.String("file", "./appsettings.json", "Path to file appsettings.json (supports globbing)")
-	output    = flag.String("type", "k8s", "Output type: k8s|docker|compose|bicep")
-	separator = flag.String("separator", "__", "Separator character(s)")
+	file        = flag.String("file", "./appsettings.json", "Path to file appsettings.json (supports globbing, - reads the standard input)")
+	strictJSON  = flag.Bool("strict-json", false, "Parse the input files as strict RFC 8259 JSON: comments, a byte order mark, trailing commas and content after the document are errors")
+	schemaPath  = flag.String("schema", "", "Validate the merged configuration against this JSON Schema before conversion, failing with the violations located in the files")
+	verbose     = flag.Bool("v", false, "Verbose diagnostics: files processed and variables emitted")
+	quiet       = flag.Bool("q", false, "Only report errors")
+	logFormat   = flag.String("log-format", "text", "Diagnostics format on stderr: text|json")
+	errorFormat = flag.String("error-format", "text", "Errors and warnings format: text, or github for GitHub Actions annotations")
+	noColor     = flag.Bool("no-color", false, "Disable colors, also disabled by NO_COLOR or when the output is not a terminal")
+
+	maxNesting    = flag.Int("max-nesting", appsettingsenv.DefaultLimits.Nesting, "Fail on input files nesting objects and arrays more levels deep than this (0: no limit)")
+	maxKeys       = flag.Int("max-keys", appsettingsenv.DefaultLimits.Keys, "Fail on input files holding more keys, array elements included, than this (0: no limit)")
+	maxValueBytes = flag.Int64("max-value-bytes", appsettingsenv.DefaultLimits.ValueBytes, "Fail on input files whose key names and values take more bytes than this (0: no limit)")
+
+	configPath  = flag.String("config", "", "Tool configuration file (default: "+configFileName+" in the working directory or a parent)")
+	watch       = flag.Bool("watch", false, "Regenerate the -out files whenever the input files change")
+	interactive = flag.Bool("interactive", false, "Pick the keys to convert and the secret ones in a terminal UI, saved to "+configFileName)
+	check       = flag.Bool("check", false, "Compare the generated output with the existing -out files instead of writing them, exiting with 1 when they differ")
+	outPath     = flag.String("out", "", "Write the output atomically to this file instead of stdout ({type} is replaced by the output type)")
+	separator   = flag.String("separator", "", "Separator character(s) (default: __, or : for appconfig, user-secrets and launchsettings)")
+	prefix      = flag.String("prefix", "", "Prefix prepended to every variable name, e.g. MYAPP_")
+	keyCase     = flag.String("case", "preserve", "Variable name casing: preserve|upper|lower|screaming-snake")
+
+	arrayMode      = flag.String("array-mode", "indexed", "Array emission: indexed (one variable per element), json (single JSON value) or join")
+	arrayDelimiter = flag.String("array-delimiter", ",", "Delimiter used by -array-mode join")
+	maxDepth       = flag.Int("max-depth", 0, "Emit objects and arrays nested N levels deep as a single JSON value (0: unlimited)")
+	padIndex       = flag.Int("pad-index", 0, "Zero-pad array indices to this many digits, e.g. 2 for Items__07")
+	serilogMode    = flag.String("serilog", "", "Serilog section handling: json (emit it as one JSON-valued Serilog variable) or check (warn about array gaps and elements without Name left by filters)")
+	sortMode       = flag.String("sort", "name", "Order of the variables: name (case-insensitive, numbers by value) or source (as the keys appear in the files)")
+
+	typed      = flag.Bool("typed", false, "Emit numbers, booleans and nulls as native literals in typed outputs (user-secrets)")
+	boolFormat = flag.String("bool-format", "preserve", "Boolean values: preserve, lower (true/false) or int (1/0)")
+	emptyMode  = flag.String("empty", "drop", "Empty objects and arrays: drop them, emit them as empty values (emit) or warn about them (warn)")
+	nulls      = flag.String("nulls", "empty", "Emit JSON null values as empty strings (empty), the literal null (null) or skip them (skip)")
+
+	connStrType = flag.String("connstr-type", "", "Emit ConnectionStrings entries with the Azure App Service prefix: sql|sqlazure|mysql|custom")
+
+	environment       = flag.String("env", "", "Environment name; also loads appsettings.{env}.json (and user secrets for Development)")
+	userSecretsID     = flag.String("user-secrets-id", "", "UserSecretsId to use instead of discovering it from the project file")
+	resolveRefs       = flag.Bool("resolve-placeholders", false, "Expand ${Section:Key} and %SECTION__KEY% references to other keys inside values")
+	substituteEnvVars = flag.Bool("substitute-env", false, "Replace ${NAME} references inside values with environment variables of this process")
+	onlyOverrides     = flag.Bool("only-overrides", false, "Only output variables whose value differs from the base files")
+	kestrelURLs       = flag.Bool("kestrel-urls", false, "Replace the Kestrel endpoint URLs by an ASPNETCORE_URLS variable in environment outputs, when the endpoints hold nothing else")
+	featureFlagsOut   = flag.Bool("feature-flags", false, "Convert the FeatureManagement section to App Configuration feature flags (.appconfig.featureflag/<name> keys) in appconfig output and push appconfig")
+	hostVars          = flag.Bool("host-vars", false, "Also emit ASPNETCORE_ENVIRONMENT and DOTNET_ENVIRONMENT from -env, and ASPNETCORE_URLS from the Kestrel endpoints, in environment outputs")
+
+	resourceName   = flag.String("name", "appsettings", "Name of generated Kubernetes resources")
+	secretResource = flag.String("secret-name", "", "Name of the generated Secret (default: <name>-secrets)")
+	splitConfigMap = flag.Bool("split", false, "Shard the configmap output across ConfigMaps <name>-0, <name>-1, ... of at most 1 MiB of data each, listed in an envFrom comment")
+	checksum       = flag.Bool("checksum", false, "Annotate ConfigMap and Secret manifests with a checksum/config SHA-256 of their data, and the pod template in inject k8s and push deployment with that of the variables, so that pods restart when the configuration changes")
+	secretOut      = flag.String("secret-out", "", "File receiving the secret variables selected by -secret-keys ({type} is replaced by the output type)")
+	redact         = flag.Bool("redact", false, "Mask the values of secret-looking keys (password, token, key, connection strings, ...)")
+	redactWith     = flag.String("redact-with", "***", "Placeholder replacing redacted values")
+	detectSecrets  = flag.String("detect-secrets", "", "Look for credentials in values (connection strings with passwords, JWTs, AWS keys, private keys, random-looking keys): warn about those written in plain text, or classify them as secret for -secret-out and -redact: warn|classify")
+
+	separatorKeys = flag.String("separator-keys", "warn", "Keys containing the separator: warn, escape (replace it with _) or error")
+
+	sanitize        = flag.Bool("sanitize", false, "Replace characters the output type does not allow in names with underscores")
+	dualNotation    = flag.Bool("dual-notation", false, "Emit every variable under both its __ and : names, e.g. for user-secrets, appconfig and documentation")
+	keyVaultSummary = flag.Bool("keyvault-summary", false, "List the secrets referenced by @Microsoft.KeyVault(...) values on stderr")
+	strict          = flag.Bool("strict", false, "Fail instead of writing output when any warning was reported")
+	envBudget       = flag.Int("env-budget", 1<<20, "Warn when the variables of an environment output take more bytes than this as NAME=value strings (0: no check)")
+	summaryFormat   = flag.String("summary", "", "Print conversion statistics on stderr: text|json")
+	reportPath      = flag.String("report", "", "Write a JSON report of the keys each output leaves out, redacts, encrypts or moves to -secret-out, and why, to this file")
+	indent          = flag.Int("indent", 0, "Indentation width in spaces of YAML, JSON and Bicep outputs (default: 2, none for bicep)")
+	quoteStyle      = flag.String("quote", "always", "Quoting of k8s, configmap, compose and docker outputs: always|as-needed|single|double")
+	newline         = flag.String("newline", "lf", "Line endings of the output: lf|crlf")
+	stripNewlines   = flag.Bool("strip-newlines", false, "Remove the line breaks of multi-line values, for consumers that only read single-line values")
+	noHeader        = flag.Bool("no-header", false, "Omit the generated-file comment (tool version, sources, environment, hash) from the output")
+	canonical       = flag.Bool("canonical", false, "Canonical output for GitOps repositories: sorted by name, always quoted, LF line endings and no tool version in the header, stable across runs and releases")
+	failOnEmpty     = flag.Bool("fail-on-empty", false, "Fail when a file holds no variables or the filters leave none")
+
+	overlayEnv       = flag.Bool("overlay-env", false, "Overlay matching process environment variables on top of file values")
+	overlayEnvPrefix = flag.String("overlay-env-prefix", "", "Overlay every environment variable with this prefix, prefix removed (implies -overlay-env)")
 )
 
-var format = map[string]string{
-	"k8s":     "- name: %q\n  value: %q\n",
-	"docker":  "%s=%q\n",
-	"compose": "%s: %q\n",
-	"bicep":   "{\nname: '%s'\nvalue: '%s'\n}\n",
-}
+var (
+	// outputTypes collects the -type flags
+	outputTypes = typeList{types: []string{"k8s"}}
+
+	// overrides collects repeated -set key=value flags
+	overrides stringList
+
+	// includes and excludes collect the repeated key filter flags
+	includes stringList
+	excludes stringList
+
+	// secretKeys collects the patterns selecting secret variables
+	secretKeys stringList
+
+	// transformSpecs collects the -transform flags
+	transformSpecs stringList
+
+	// presetNames collects the -preset flags
+	presetNames stringList
+
+	// redactKeys collects explicit patterns of keys to redact
+	redactKeys stringList
+
+	// ageRecipients collects the -age-recipient public keys and files
+	ageRecipients stringList
+)
+
+// keySep joins the segments of flattened keys internally, as in IConfiguration.
+// It is replaced by the output separator when variables are emitted.
+const keySep = ":"
 
 func main() {
-	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "%s (%s)\n\n%s\n%s\n\n", app, version, description, site)
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
-		flag.PrintDefaults()
+	flag.Usage = usage
+	flag.Var(&outputTypes, "type", "Output types, comma-separated or repeated: k8s|configmap|docker|compose|bicep|appconfig|user-secrets|launchsettings")
+	flag.Var(&overrides, "set", "Override a value, key=value with __ or : notation (repeatable)")
+	flag.Var(&includes, "include", "Only output keys matching this glob, or regex with re: prefix (repeatable)")
+	flag.Var(&excludes, "exclude", "Skip keys matching this glob, or regex with re: prefix (repeatable)")
+	flag.Var(&transformSpecs, "transform", "Rewrite values: [pattern=]func(args) with base64|upper|lower|trim|prefix|suffix|replace|regex, or cmd:<command> (repeatable)")
+	flag.Var(&presetNames, "preset", "Exclude the sections of a preset: aspnet, or one defined in "+configFileName+" (repeatable)")
+	flag.Var(&redactKeys, "redact-keys", "Redact keys matching this glob/regex or the patterns in @file instead of the built-in list (repeatable, implies -redact)")
+	flag.Var(&secretKeys, "secret-keys", "Route keys matching this glob/regex, or the patterns listed in @file, to -secret-out (repeatable)")
+	flag.Var(&ageRecipients, "age-recipient", "Encrypt the values of secret keys (-secret-keys, or the secret-looking keys -redact masks) for this age public key, or the keys listed in @file, as age:<base64> values (repeatable)")
+
+	// kubectl runs plugins by file name, usage shows how they are invoked
+	if isKubectlPlugin(os.Args[0]) {
+		os.Args[0] = "kubectl appsettings"
+	}
+
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if c, ok := lookupCommand(args[0]); ok {
+			os.Exit(c.run(args[1:]))
+		}
+	}
+	os.Exit(runConvert(args))
+}
+
+// runConvert implements the convert subcommand, the default one
+func runConvert(args []string) int {
+	cv, code := prepareConversion(args)
+	if cv == nil {
+		return code
+	}
+	if *interactive {
+		return runInteractive(cv)
 	}
 
-	flag.Parse()
+	types := outputTypes.types
+	if *watch && *file == "-" {
+		errorf("-watch cannot read the standard input (-file -)")
+		return exitUsage
+	}
+	if *watch && *outPath == "" {
+		errorf("-watch requires -out")
+		return exitUsage
+	}
+	if *check && (*outPath == "" || *watch) {
+		errorf("-check requires -out and cannot be combined with -watch")
+		return exitUsage
+	}
+	if len(types) > 1 && !strings.Contains(*outPath, "{type}") {
+		errorf("several output types require -out with a {type} placeholder")
+		return exitUsage
+	}
+	if len(secretKeys) > 0 && *secretOut == "" {
+		errorf("-secret-keys requires -secret-out")
+		return exitUsage
+	}
+	if len(secretKeys) > 0 && len(types) > 1 && !strings.Contains(*secretOut, "{type}") {
+		errorf("several output types require -secret-out with a {type} placeholder")
+		return exitUsage
+	}
 
-	outType := strings.ToLower(strings.TrimSpace(*output))
-	if _, ok := format[outType]; !ok {
-		fmt.Fprintf(os.Stderr, "invalid output type: %q\n", *output)
-		os.Exit(2)
+	if err := convert(cv); err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
 	}
 
-	if len(*separator) < 1 {
-		fmt.Fprintln(os.Stderr, "separator cannot be an empty string")
-		os.Exit(2)
+	if *watch {
+		var outputs []string
+		for _, t := range types {
+			outputs = append(outputs, outputPath(*outPath, t), outputPath(*secretOut, t))
+		}
+		return watchFiles(outputs, func() error { return convert(cv) })
 	}
+	return exitOK
+}
 
-	files, err := filepath.Glob(*file)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to evaluate file pattern: %v\n", err)
-		os.Exit(1)
+// prepareConversion parses and checks the conversion flags shared by convert and
+// validate. It returns nil and the exit code when they are invalid.
+func prepareConversion(args []string) (*conversion, int) {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return nil, exitUsage
+	}
+	if err := applyToolConfig(flag.CommandLine); err != nil {
+		logError(err)
+		return nil, exitUsage
+	}
+	if err := setupLogging(); err != nil {
+		logError(err)
+		return nil, exitUsage
+	}
+	if flag.NArg() > 0 {
+		errorf("unexpected argument: %q", flag.Arg(0))
+		return nil, exitUsage
 	}
 
-	if len(files) == 0 {
-		fmt.Fprintf(os.Stderr, "no files matching pattern: %s\n", *file)
-		os.Exit(1)
+	types := outputTypes.types
+	if len(types) == 0 {
+		errorf("missing output type")
+		return nil, exitUsage
+	}
+	*keyCase = strings.ToLower(strings.TrimSpace(*keyCase))
+	if !caseModes[*keyCase] {
+		errorf("invalid case: %q", *keyCase)
+		return nil, exitUsage
 	}
 
-	// Aggregate variables across matching files
-	variables := make(map[string]string)
-	hadErr := false
-	for _, f := range files {
-		m, err := processFile(f, *separator)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "error processing %s: %v\n", f, err)
-			hadErr = true
-			continue
+	*arrayMode = strings.ToLower(strings.TrimSpace(*arrayMode))
+	if *arrayMode != "indexed" && *arrayMode != "json" && *arrayMode != "join" {
+		errorf("invalid array mode: %q", *arrayMode)
+		return nil, exitUsage
+	}
+
+	*separatorKeys = strings.ToLower(strings.TrimSpace(*separatorKeys))
+	if *separatorKeys != "warn" && *separatorKeys != "escape" && *separatorKeys != "error" {
+		errorf("invalid separator keys mode: %q", *separatorKeys)
+		return nil, exitUsage
+	}
+
+	if *maxDepth < 0 {
+		errorf("invalid max depth: %d", *maxDepth)
+		return nil, exitUsage
+	}
+
+	if *maxNesting < 0 || *maxKeys < 0 || *maxValueBytes < 0 {
+		errorf("-max-nesting, -max-keys and -max-value-bytes cannot be negative")
+		return nil, exitUsage
+	}
+
+	if *padIndex < 0 {
+		errorf("invalid pad index: %d", *padIndex)
+		return nil, exitUsage
+	}
+
+	*nulls = strings.ToLower(strings.TrimSpace(*nulls))
+	if *nulls != "empty" && *nulls != "null" && *nulls != "skip" {
+		errorf("invalid nulls mode: %q", *nulls)
+		return nil, exitUsage
+	}
+
+	*serilogMode = strings.ToLower(strings.TrimSpace(*serilogMode))
+	if !slices.Contains(serilogModes, *serilogMode) {
+		errorf("invalid serilog mode: %q", *serilogMode)
+		return nil, exitUsage
+	}
+
+	*sortMode = strings.ToLower(strings.TrimSpace(*sortMode))
+	if *sortMode != "name" && *sortMode != "source" {
+		errorf("invalid sort mode: %q", *sortMode)
+		return nil, exitUsage
+	}
+
+	*emptyMode = strings.ToLower(strings.TrimSpace(*emptyMode))
+	if *emptyMode != "drop" && *emptyMode != "emit" && *emptyMode != "warn" {
+		errorf("invalid empty mode: %q", *emptyMode)
+		return nil, exitUsage
+	}
+
+	*boolFormat = strings.ToLower(strings.TrimSpace(*boolFormat))
+	if *boolFormat != "preserve" && *boolFormat != "lower" && *boolFormat != "int" {
+		errorf("invalid bool format: %q", *boolFormat)
+		return nil, exitUsage
+	}
+
+	if *indent < 0 || *indent > 8 {
+		errorf("invalid indent: %d", *indent)
+		return nil, exitUsage
+	}
+
+	*quoteStyle = strings.ToLower(strings.TrimSpace(*quoteStyle))
+	if !slices.Contains(appsettingsenv.QuoteStyles, *quoteStyle) {
+		errorf("invalid quote style: %q", *quoteStyle)
+		return nil, exitUsage
+	}
+
+	*newline = strings.ToLower(strings.TrimSpace(*newline))
+	if *newline != "lf" && *newline != "crlf" {
+		errorf("invalid newline: %q", *newline)
+		return nil, exitUsage
+	}
+
+	if *canonical {
+		if err := applyCanonical(flag.CommandLine); err != nil {
+			logError(err)
+			return nil, exitUsage
 		}
-		maps.Copy(variables, m)
 	}
 
-	if hadErr {
-		os.Exit(1)
+	*summaryFormat = strings.ToLower(strings.TrimSpace(*summaryFormat))
+	if *summaryFormat != "" && *summaryFormat != "text" && *summaryFormat != "json" {
+		errorf("invalid summary format: %q", *summaryFormat)
+		return nil, exitUsage
 	}
 
-	// Sort keys case-insensitively
-	keys := make([]string, 0, len(variables))
-	for k := range variables {
-		keys = append(keys, k)
+	if err := checkDetectMode(); err != nil {
+		logError(err)
+		return nil, exitUsage
 	}
-	sort.Slice(keys, func(i, j int) bool {
-		return strings.ToLower(keys[i]) < strings.ToLower(keys[j])
-	})
 
-	// Print using requested format
-	fmtStr := format[outType]
-	for _, k := range keys {
-		fmt.Printf(fmtStr, k, variables[k])
+	*connStrType = strings.ToLower(strings.TrimSpace(*connStrType))
+	if _, ok := connectionStringPrefixes[*connStrType]; !ok && *connStrType != "" {
+		errorf("invalid connection string type: %q", *connStrType)
+		return nil, exitUsage
 	}
-}
 
-// processFile reads, cleans and parses a single JSON file and returns flattened variables
-func processFile(filename, sep string) (map[string]string, error) {
-	content, err := os.ReadFile(filename)
+	setVars, err := parseOverrides(overrides, keySep)
 	if err != nil {
-		return nil, fmt.Errorf("read failed: %w", err)
+		logError(err)
+		return nil, exitUsage
 	}
 
-	// Remove BOM if present
-	if len(content) >= 3 && content[0] == 0xEF && content[1] == 0xBB && content[2] == 0xBF {
-		content = content[3:]
+	secretPatterns, err := expandPatternFiles(secretKeys)
+	if err != nil {
+		logError(err)
+		return nil, exitUsage
 	}
 
-	// Remove JSON comments
-	content = removeJSONComments(content)
+	transforms, err := parseTransforms(transformSpecs)
+	if err != nil {
+		logError(err)
+		return nil, exitUsage
+	}
 
-	decoder := json.NewDecoder(bytes.NewReader(content))
-	decoder.UseNumber()
+	recipients, err := parseAgeRecipients(ageRecipients)
+	if err != nil {
+		logError(err)
+		return nil, exitUsage
+	}
+	if len(recipients) > 0 && *check {
+		warnf("-check always reports differences with -age-recipient, the ciphertext changes on every run")
+	}
 
-	var objs map[string]any
-	if err := decoder.Decode(&objs); err != nil {
-		// Provide contextual error for syntax errors
-		var synErr *json.SyntaxError
-		if errors.As(err, &synErr) {
-			offset := max(int(synErr.Offset), 0)
-			before := max(offset-60, 0)
-			after := offset + 60
-			if after > len(content) {
-				after = len(content)
-			}
+	cv := &conversion{setVars: setVars, transforms: transforms}
+	if *schemaPath != "" {
+		if cv.schema, err = compileSchema(*schemaPath); err != nil {
+			logError(err)
+			return nil, exitUsage
+		}
+	}
+	for _, t := range types {
+		c, err := newConverter(t, secretPatterns)
+		if err != nil {
+			logError(err)
+			return nil, exitUsage
+		}
+		c.recipients = recipients
+		if *typed && !c.format.Typed {
+			warnf("-typed has no effect on %s output", t)
+		}
+		if *featureFlagsOut && t != "appconfig" {
+			warnf("-feature-flags has no effect on %s output", t)
+		}
+		cv.converters = append(cv.converters, c)
+	}
+	return cv, 0
+}
 
-			// compute line and column
-			line := bytes.Count(content[:offset], []byte("\n")) + 1
-			prev := bytes.LastIndex(content[:offset], []byte("\n"))
-			col := offset - prev
+// writeOutput writes a rendered output to stdout or -out, and its secret part to -secret-out
+func writeOutput(r *rendered) error {
+	if *outPath != "" {
+		path := outputPath(*outPath, r.outType)
+		if err := writeFileAtomic(path, r.output, 0o644); err != nil {
+			return ioError(err)
+		}
+		debug("output written", "type", r.outType, "file", path)
+	} else if _, err := os.Stdout.Write(r.output); err != nil {
+		return ioError(err)
+	}
 
-			snippet := content[before:after]
-			return nil, fmt.Errorf("syntax error: %v in %s (line %d, column %d) ... %s", synErr, filename, line, col, snippet)
+	if r.secrets != nil {
+		if err := writeFileAtomic(outputPath(*secretOut, r.outType), r.secrets, 0o600); err != nil {
+			return ioError(err)
 		}
-		return nil, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return nil
+}
+
+// keySeparator returns the -separator value, or the default separator of the output type
+func keySeparator(outType string) (string, error) {
+	if *separator != "" {
+		return *separator, nil
 	}
 
-	out := make(map[string]string)
-	parser(objs, out, nil, sep)
-	return out, nil
+	if isFlagSet("separator") {
+		return "", errors.New("separator cannot be an empty string")
+	}
+
+	if f, ok := appsettingsenv.Lookup(outType); ok {
+		return f.Separator, nil
+	}
+	return "__", nil
 }
 
-// parser flattens nested JSON objects/arrays into environment-style variables using separator
-func parser(in map[string]any, out map[string]string, root []string, sep string) {
-	for key, value := range in {
-		keys := append(root, key)
-
-		switch v := value.(type) {
-		case []any:
-			for idx, item := range v {
-				switch item := item.(type) {
-				case []any:
-					parser(map[string]any{fmt.Sprint(idx): item}, out, keys, sep)
-				case map[string]any:
-					parser(item, out, append(keys, fmt.Sprint(idx)), sep)
-				default:
-					base := strings.Join(keys, sep)
-					out[fmt.Sprintf("%s%s%d", base, sep, idx)] = fmt.Sprint(item)
-				}
-			}
-		case map[string]any:
-			parser(v, out, keys, sep)
-		default:
-			out[strings.Join(keys, sep)] = fmt.Sprint(v)
+// isFlagSet reports whether a command line flag was given explicitly
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
 		}
+	})
+	return set
+}
+
+// stdin holds the standard input read by -file -, so that it can be loaded again
+var stdin = sync.OnceValues(func() ([]byte, error) { return io.ReadAll(os.Stdin) })
+
+// openInput opens a configuration file, or the standard input for "-"
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		data, err := stdin()
+		return io.NopCloser(bytes.NewReader(data)), err
 	}
+	return os.Open(filename)
+}
+
+// processFile reads, parses and flattens a single JSON file. The file is decoded
+// while it is read, duplicate keys and the order of the values reported on the way.
+func processFile(filename, sep string) (map[string]string, error) {
+	p := parseFile(filename, sep)
+	p.record()
+	return p.vars, p.err
 }
 
-// removeJSONComments removes single-line (//) and multi-line (/* */) comments from JSON content
-func removeJSONComments(content []byte) []byte {
-	buf := bytes.NewBuffer(make([]byte, 0, len(content)))
-	inString := false
-	escapeNext := false
-	inLineComment := false
-	inBlockComment := false
+// parsedFile is a file parsed by parseFile. Files are parsed concurrently, so what
+// they add to the literals, the source order and the warnings is kept until record
+// applies it in the order of the files.
+type parsedFile struct {
+	name     string
+	vars     map[string]string
+	err      error
+	literals [][2]string
+	source   []string
+	warnings []fileWarning
+}
 
-	for i := 0; i < len(content); i++ {
-		ch := content[i]
+// fileWarning is a warning about a parsed file, at a line when not 0
+type fileWarning struct {
+	line int
+	msg  string
+}
 
-		if inString {
-			buf.WriteByte(ch)
-			if escapeNext {
-				escapeNext = false
-				continue
-			}
-			if ch == '\\' {
-				escapeNext = true
-				continue
-			}
-			if ch == '"' {
-				inString = false
-			}
-			continue
-		}
+func (p *parsedFile) warn(line int, format string, args ...any) {
+	p.warnings = append(p.warnings, fileWarning{line: line, msg: fmt.Sprintf(format, args...)})
+}
 
-		if inLineComment {
-			if ch == '\n' {
-				inLineComment = false
-				buf.WriteByte(ch)
-			}
-			continue
+// record reports the warnings of the file and records its literals and source order
+func (p *parsedFile) record() {
+	for _, w := range p.warnings {
+		warnAt(p.name, w.line, "%s", w.msg)
+	}
+	for _, l := range p.literals {
+		recordLiteral(l[0], l[1])
+	}
+	for _, k := range p.source {
+		recordSource(k)
+	}
+}
+
+// parseFile reads, parses and flattens filename without touching shared state
+func parseFile(filename, sep string) *parsedFile {
+	p := &parsedFile{name: filename}
+	p.vars, p.err = p.parse(sep)
+	return p
+}
+
+func (p *parsedFile) parse(sep string) (map[string]string, error) {
+	filename := p.name
+	f, err := openInput(filename)
+	if err != nil {
+		return nil, ioError(fmt.Errorf("read failed: %w", err))
+	}
+	defer f.Close()
+
+	opts := flattenOptions(sep)
+	opts.Literal = func(key, value string) {
+		p.literals = append(p.literals, [2]string{key, value})
+	}
+	so := appsettingsenv.StreamOptions{
+		Strict:  *strictJSON,
+		Flatten: opts,
+		Limits:  parseLimits(),
+		// encoding/json keeps the last of repeated keys silently, .NET rejects the file
+		Duplicate: func(key string, line, firstLine int) {
+			p.warn(line, "duplicate key %s, first defined on line %d", key, firstLine)
+		},
+	}
+	// -sort source ranks the values as the file lists them
+	var index map[string]int
+	if *sortMode == "source" {
+		index = make(map[string]int)
+		rank := 0
+		so.Value = func(path string, _ int) {
+			index[path] = rank
+			rank++
+		}
+	}
+	r := &sourceFile{r: f}
+	doc, err := appsettingsenv.ParseStream(r, so)
+	if err != nil {
+		var synErr *appsettingsenv.SyntaxError
+		var limitErr *appsettingsenv.LimitError
+		switch {
+		case r.err != nil:
+			return nil, ioError(fmt.Errorf("read failed: %w", r.err))
+		case errors.As(err, &limitErr):
+			err := fmt.Errorf("%v in %s (see -%s)", limitErr, filename, limitFlags[limitErr.Limit])
+			return nil, parseError(&locatedError{file: filename, line: limitErr.Line, col: limitErr.Column, err: err})
+		case errors.As(err, &synErr):
+			err := fmt.Errorf("syntax error: %v in %s (line %d, column %d) ... %s", synErr.Err, filename, synErr.Line, synErr.Column, synErr.Snippet)
+			return nil, parseError(&locatedError{file: filename, line: synErr.Line, col: synErr.Column, err: err})
+		}
+		return nil, parseError(&locatedError{file: filename, err: err})
+	}
+	if index != nil {
+		for _, k := range appsettingsenv.KeysInOrder(doc, index, opts) {
+			p.source = append(p.source, norm.NFC.String(k))
 		}
+	}
+
+	doc = normalizeKeys(doc, func(a, b string) {
+		p.warn(0, "keys %+q and %+q only differ by Unicode normalization, only the last one is kept", a, b)
+	}).(map[string]any)
 
-		if inBlockComment {
-			if ch == '*' && i+1 < len(content) && content[i+1] == '/' {
-				inBlockComment = false
-				i++
+	out := appsettingsenv.Flatten(doc, opts)
+
+	// Empty objects and arrays produce no variables unless asked for
+	if *emptyMode != "drop" {
+		for _, e := range appsettingsenv.EmptyContainers(doc, opts) {
+			if *emptyMode == "warn" {
+				p.warn(0, "empty %s %s produces no variables", e.Kind, e.Key)
 				continue
 			}
-			continue
+			if _, ok := out[e.Key]; !ok {
+				out[e.Key] = ""
+			}
 		}
+	}
+	return out, nil
+}
 
-		if ch == '"' {
-			inString = true
-			buf.WriteByte(ch)
-			continue
-		}
+// sourceFile remembers the error of the reads of a configuration file, to tell
+// failures to read it from invalid content
+type sourceFile struct {
+	r   io.Reader
+	err error
+}
 
-		if ch == '/' && i+1 < len(content) && content[i+1] == '/' {
-			inLineComment = true
-			i++
-			continue
-		}
+func (f *sourceFile) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err != nil && err != io.EOF {
+		f.err = err
+	}
+	return n, err
+}
 
-		if ch == '/' && i+1 < len(content) && content[i+1] == '*' {
-			inBlockComment = true
-			i++
-			continue
-		}
+// flattenOptions returns the flattening flags as library options
+func flattenOptions(sep string) appsettingsenv.FlattenOptions {
+	return appsettingsenv.FlattenOptions{
+		Separator:      sep,
+		ArrayMode:      *arrayMode,
+		ArrayDelimiter: *arrayDelimiter,
+		MaxDepth:       *maxDepth,
+		PadIndex:       *padIndex,
+		Nulls:          *nulls,
+		Literal:        recordLiteral,
+	}
+}
+
+// parseLimits returns the limits of the input files, 0 disabling them
+func parseLimits() appsettingsenv.Limits {
+	return appsettingsenv.Limits{Nesting: noLimit(*maxNesting), Keys: noLimit(*maxKeys), ValueBytes: noLimit(*maxValueBytes)}
+}
+
+// noLimit maps the 0 of the limit flags to the negative limit of the library
+func noLimit[T int | int64](n T) T {
+	if n == 0 {
+		return -1
+	}
+	return n
+}
 
-		buf.WriteByte(ch)
+// limitFlags are the flags setting the limits of appsettingsenv.LimitError
+var limitFlags = map[string]string{"nesting": "max-nesting", "keys": "max-keys", "value bytes": "max-value-bytes"}
+
+// formatOptions returns the formatting flags as library options
+func formatOptions(outType string) appsettingsenv.FormatOptions {
+	return appsettingsenv.FormatOptions{
+		Type:       outType,
+		Name:       *resourceName,
+		SecretName: *secretResource,
+		Indent:     *indent,
+		Quote:      *quoteStyle,
+		SplitSize:  splitSize(),
+		Checksum:   *checksum,
 	}
+}
+
+// splitSize returns the shard size of -split
+func splitSize() int {
+	if *splitConfigMap {
+		return maxManifestData
+	}
+	return 0
+}
+
+// arrayIndex formats an array index as a key segment, zero-padded by -pad-index
+func arrayIndex(idx int) string {
+	return appsettingsenv.Index(idx, *padIndex)
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
 
-	return buf.Bytes()
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,25 +10,6 @@ import (
 	"testing"
 )
 
-func TestRemoveJSONComments(t *testing.T) {
-	src := []byte(`{
-  // line comment
-  "a": "value", /* block comment */
-  "b": 123
-}`)
-
-	cleaned := removeJSONComments(src)
-
-	var out map[string]any
-	if err := json.Unmarshal(cleaned, &out); err != nil {
-		t.Fatalf("cleaned JSON should unmarshal: %v\ncleaned: %s", err, string(cleaned))
-	}
-
-	if out["a"] != "value" {
-		t.Fatalf("expected a=value, got %v", out["a"])
-	}
-}
-
 func TestProcessFileAndParser(t *testing.T) {
 	dir := t.TempDir()
 	fn := filepath.Join(dir, "appsettings.json")
@@ -174,18 +156,77 @@ func TestProcessFile_LargeNestedJSON(t *testing.T) {
 	}
 }
 
-func TestRemoveJSONComments_CommentLikeInString(t *testing.T) {
-	src := []byte(`{"text":"contains // and /* not a comment */ and \\\"quotes\\\""}`)
-	cleaned := removeJSONComments(src)
-	var out map[string]any
-	if err := json.Unmarshal(cleaned, &out); err != nil {
-		t.Fatalf("unmarshal failed: %v", err)
+func TestProcessFile_Limits(t *testing.T) {
+	defer func(n int) { *maxKeys = n }(*maxKeys)
+
+	fn := filepath.Join(t.TempDir(), "appsettings.json")
+	if err := os.WriteFile(fn, []byte("{\n  \"A\": 1,\n  \"B\": [1, 2]\n}"), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	*maxKeys = 3
+	_, err := processFile(fn, "__")
+	var loc *locatedError
+	if exitCode(err, exitFailure) != exitParse || !errors.As(err, &loc) || loc.line != 3 ||
+		!strings.Contains(err.Error(), "more than 3 keys") || !strings.Contains(err.Error(), "(see -max-keys)") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	*maxKeys = 0
+	if vars, err := processFile(fn, "__"); err != nil || len(vars) != 3 {
+		t.Fatalf("0 should disable the limit: %v %v", vars, err)
+	}
+}
+
+func TestProcessFile_EmptyContainers(t *testing.T) {
+	defer func(mode string) { *emptyMode = mode }(*emptyMode)
+
+	fn := filepath.Join(t.TempDir(), "appsettings.json")
+	src := `{"Features": {}, "Hosts": [], "Nested": {"Rules": [{}]}, "Name": "api"}`
+	if err := os.WriteFile(fn, []byte(src), 0o644); err != nil {
+		t.Fatalf("write test file: %v", err)
+	}
+
+	*emptyMode = "drop"
+	vars, err := processFile(fn, "__")
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+	if len(vars) != 1 {
+		t.Fatalf("empty containers should be dropped: %v", vars)
+	}
+
+	*emptyMode = "emit"
+	vars, err = processFile(fn, "__")
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
+	}
+	for _, k := range []string{"Features", "Hosts", "Nested__Rules__0", "Name"} {
+		if _, ok := vars[k]; !ok {
+			t.Fatalf("missing sentinel key %q in %v", k, vars)
+		}
+	}
+	if len(vars) != 4 || vars["Features"] != "" {
+		t.Fatalf("unexpected variables: %v", vars)
+	}
+}
+
+func TestProcessFile_Stdin(t *testing.T) {
+	defer func(f func() ([]byte, error)) { stdin = f }(stdin)
+	stdin = func() ([]byte, error) { return []byte(`{"Logging": {"Level": "Debug"}}`), nil }
+
+	vars, err := processFile("-", "__")
+	if err != nil {
+		t.Fatalf("processFile failed: %v", err)
 	}
-	s, _ := out["text"].(string)
-	if !strings.Contains(s, "//") || !strings.Contains(s, "/*") {
-		t.Fatalf("string lost comment-like sequences: %q", s)
+	if vars["Logging__Level"] != "Debug" {
+		t.Fatalf("unexpected variables: %v", vars)
 	}
-	if !strings.Contains(s, "quotes") || !strings.Contains(s, `"`) {
-		t.Fatalf("escaped quotes missing or lost: %q", s)
+}
+
+func TestProcessFile_Directory(t *testing.T) {
+	_, err := processFile(t.TempDir(), "__")
+	if exitCode(err, exitFailure) != exitIO {
+		t.Fatalf("reading a directory should be an I/O error: %v", err)
 	}
 }
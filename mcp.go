@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// mcpVersions are the Model Context Protocol revisions the server speaks, latest first
+var mcpVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC 2.0 error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// mcpTool is a tool advertised by tools/list
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	Annotations map[string]any `json:"annotations,omitempty"`
+	call        func(params json.RawMessage) (toolResult, error)
+}
+
+// toolResult is the result of tools/call
+type toolResult struct {
+	Content           []textContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpFile is an appsettings document passed inline to a tool
+type mcpFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// filesSchema is the input schema of a list of layered appsettings documents
+var filesSchema = map[string]any{
+	"type":        "array",
+	"description": "appsettings.json documents in precedence order, later ones overriding earlier ones",
+	"minItems":    1,
+	"items": map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"name":    map[string]any{"type": "string", "description": "File name, used in messages"},
+			"content": map[string]any{"type": "string", "description": "JSON content, comments allowed"},
+		},
+		"required": []string{"content"},
+	},
+}
+
+// mcpTools lists the tools of the server. They only work on the documents passed in
+// their arguments and never touch the file system, the network or the flags.
+var mcpTools = []mcpTool{
+	{
+		Name:        "convert",
+		Description: "Convert appsettings.json documents to environment variables in an output type",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"files":      filesSchema,
+				"type":       map[string]any{"type": "string", "enum": appsettingsenv.Types(), "default": "k8s"},
+				"separator":  map[string]any{"type": "string", "description": "Key separator, defaults to the one of the type"},
+				"name":       map[string]any{"type": "string", "description": "Resource name of the manifest types", "default": "appsettings"},
+				"secretName": map[string]any{"type": "string", "description": "Secret name, defaults to <name>-secrets"},
+				"quote":      map[string]any{"type": "string", "enum": appsettingsenv.QuoteStyles, "default": "always"},
+			},
+			"required": []string{"files"},
+		},
+		call: callConvert,
+	},
+	{
+		Name:        "diff",
+		Description: "Compare two configurations and list the added, removed and changed keys",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"a":         filesSchema,
+				"b":         filesSchema,
+				"separator": map[string]any{"type": "string", "default": "__"},
+			},
+			"required": []string{"a", "b"},
+		},
+		call: callDiff,
+	},
+	{
+		Name:        "explain",
+		Description: "Show the value of a key in every document and which one wins",
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"files": filesSchema,
+				"key":   map[string]any{"type": "string", "description": "Key in __ or : notation, matched case-insensitively"},
+			},
+			"required": []string{"files", "key"},
+		},
+		call: callExplain,
+	},
+}
+
+func init() {
+	for i := range mcpTools {
+		mcpTools[i].Annotations = map[string]any{"readOnlyHint": true, "openWorldHint": false}
+	}
+}
+
+// runMCP implements the mcp subcommand: a Model Context Protocol server on stdio
+func runMCP(args []string) int {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	for _, f := range []string{"max-nesting", "max-keys", "max-value-bytes"} {
+		fs.Var(flag.Lookup(f).Value, f, flag.Lookup(f).Usage)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s mcp:\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Serve the convert, diff and explain tools over the Model Context Protocol on")
+		fmt.Fprintln(fs.Output(), "stdin and stdout. The tools take the documents inline and never read files.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *maxNesting < 0 || *maxKeys < 0 || *maxValueBytes < 0 {
+		errorf("-max-nesting, -max-keys and -max-value-bytes cannot be negative")
+		return exitUsage
+	}
+
+	if err := serveMCP(os.Stdin, os.Stdout); err != nil {
+		logError(err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// serveMCP answers the newline-delimited JSON-RPC messages read from r until it is closed
+func serveMCP(r io.Reader, w io.Writer) error {
+	in := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := in.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if resp := handleMCP(line); resp != nil {
+				if err := enc.Encode(resp); err != nil {
+					return err
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleMCP answers a single message, returning nil for notifications
+func handleMCP(msg []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{rpcParseError, err.Error()}}
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", ID: idOrNull(req.ID), Error: &rpcError{rpcInvalidRequest, "invalid request"}}
+	}
+
+	result, err := dispatchMCP(req.Method, req.Params)
+	if req.ID == nil {
+		return nil
+	}
+
+	resp := &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+	if err != nil {
+		var rerr *rpcError
+		if !errors.As(err, &rerr) {
+			rerr = &rpcError{rpcInvalidParams, err.Error()}
+		}
+		resp.Result, resp.Error = nil, rerr
+	}
+	return resp
+}
+
+func idOrNull(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+// dispatchMCP runs method and returns its result
+func dispatchMCP(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if len(params) > 0 {
+			if err := json.Unmarshal(params, &p); err != nil {
+				return nil, err
+			}
+		}
+		v := mcpVersions[0]
+		if slices.Contains(mcpVersions, p.ProtocolVersion) {
+			v = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": v,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": app, "version": version},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": mcpTools}, nil
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		for _, t := range mcpTools {
+			if t.Name == p.Name {
+				return callTool(t, p.Arguments)
+			}
+		}
+		return nil, fmt.Errorf("unknown tool: %q", p.Name)
+	}
+	return nil, &rpcError{rpcMethodNotFound, "method not found: " + method}
+}
+
+// callTool runs t. Invalid arguments are protocol errors; a failed conversion is a tool
+// result flagged with isError so the model can read and correct it.
+func callTool(t mcpTool, args json.RawMessage) (toolResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	res, err := t.call(args)
+	var rerr *rpcError
+	if errors.As(err, &rerr) {
+		return toolResult{}, err
+	}
+	if err != nil {
+		return toolResult{Content: []textContent{{"text", err.Error()}}, IsError: true}, nil
+	}
+	return res, nil
+}
+
+// decodeArgs decodes the tool arguments into v, rejecting unknown fields
+func decodeArgs(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(args))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &rpcError{rpcInvalidParams, "invalid arguments: " + err.Error()}
+	}
+	return nil
+}
+
+// mcpLayers parses files into configuration layers using the internal key separator
+func mcpLayers(field string, files []mcpFile) ([]layer, error) {
+	if len(files) == 0 {
+		return nil, &rpcError{rpcInvalidParams, fmt.Sprintf("invalid arguments: %s is empty", field)}
+	}
+
+	layers := make([]layer, 0, len(files))
+	for i, f := range files {
+		name := f.Name
+		if name == "" {
+			name = fmt.Sprintf("%s[%d]", field, i)
+		}
+		// The documents come from the client, the limits bound what they can take
+		doc, err := appsettingsenv.ParseStream(strings.NewReader(f.Content), appsettingsenv.StreamOptions{Limits: parseLimits()})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		vars := appsettingsenv.Flatten(doc, appsettingsenv.FlattenOptions{Separator: keySep})
+		layers = append(layers, layer{source: name, vars: vars})
+	}
+	return layers, nil
+}
+
+func callConvert(args json.RawMessage) (toolResult, error) {
+	var in struct {
+		Files      []mcpFile `json:"files"`
+		Type       string    `json:"type"`
+		Separator  string    `json:"separator"`
+		Name       string    `json:"name"`
+		SecretName string    `json:"secretName"`
+		Quote      string    `json:"quote"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return toolResult{}, err
+	}
+
+	opts := appsettingsenv.FormatOptions{Type: in.Type, Name: in.Name, SecretName: in.SecretName, Quote: in.Quote}
+	if opts.Type == "" {
+		opts.Type = "k8s"
+	}
+	info, ok := appsettingsenv.Lookup(opts.Type)
+	if !ok {
+		return toolResult{}, fmt.Errorf("invalid type: %q", in.Type)
+	}
+	sep := in.Separator
+	if sep == "" {
+		sep = info.Separator
+	}
+
+	layers, err := mcpLayers("files", in.Files)
+	if err != nil {
+		return toolResult{}, err
+	}
+
+	var b strings.Builder
+	vars := appsettingsenv.Sorted(withSeparator(mergeLayers(layers), sep))
+	if err := appsettingsenv.Format(&b, vars, opts); err != nil {
+		return toolResult{}, err
+	}
+	return toolResult{Content: []textContent{{"text", b.String()}}}, nil
+}
+
+func callDiff(args json.RawMessage) (toolResult, error) {
+	var in struct {
+		A         []mcpFile `json:"a"`
+		B         []mcpFile `json:"b"`
+		Separator string    `json:"separator"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return toolResult{}, err
+	}
+	if in.Separator == "" {
+		in.Separator = "__"
+	}
+
+	a, err := mcpLayers("a", in.A)
+	if err != nil {
+		return toolResult{}, err
+	}
+	b, err := mcpLayers("b", in.B)
+	if err != nil {
+		return toolResult{}, err
+	}
+
+	d := diffVariables(withSeparator(mergeLayers(a), in.Separator), withSeparator(mergeLayers(b), in.Separator))
+	var text strings.Builder
+	if err := writeDiff(&text, d, "json", "a", "b", false); err != nil {
+		return toolResult{}, err
+	}
+	return toolResult{Content: []textContent{{"text", text.String()}}, StructuredContent: d}, nil
+}
+
+// explainResult is the structured result of the explain tool
+type explainResult struct {
+	Key    string         `json:"key"`
+	Value  string         `json:"value"`
+	Source string         `json:"source"`
+	Layers []explainLayer `json:"layers"`
+}
+
+type explainLayer struct {
+	Source string `json:"source"`
+	Set    bool   `json:"set"`
+	Value  string `json:"value,omitempty"`
+}
+
+func callExplain(args json.RawMessage) (toolResult, error) {
+	var in struct {
+		Files []mcpFile `json:"files"`
+		Key   string    `json:"key"`
+	}
+	if err := decodeArgs(args, &in); err != nil {
+		return toolResult{}, err
+	}
+	if in.Key == "" {
+		return toolResult{}, &rpcError{rpcInvalidParams, "invalid arguments: key is empty"}
+	}
+
+	layers, err := mcpLayers("files", in.Files)
+	if err != nil {
+		return toolResult{}, err
+	}
+
+	key := normalizeKey(in.Key, keySep)
+	origins := explainKey(layers, key)
+	win := winner(origins)
+	if win < 0 {
+		return toolResult{}, fmt.Errorf("key not found in any layer: %s", in.Key)
+	}
+
+	// Merging keeps the casing of the first layer defining the key
+	res := explainResult{Value: origins[win].value, Source: origins[win].source}
+	for _, o := range origins {
+		if o.found && res.Key == "" {
+			res.Key = o.key
+		}
+		res.Layers = append(res.Layers, explainLayer{Source: o.source, Set: o.found, Value: o.value})
+	}
+
+	var text strings.Builder
+	if err := writeExplain(&text, key, keySep, origins); err != nil {
+		return toolResult{}, err
+	}
+	return toolResult{Content: []textContent{{"text", text.String()}}, StructuredContent: res}, nil
+}
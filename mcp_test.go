@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestServeMCP(t *testing.T) {
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"convert","arguments":{"type":"docker","quote":"as-needed","files":[{"content":"{\"Api\":{\"Url\":\"https://a\"}}"},{"content":"{\"api\":{\"url\":\"https://b\"}}"}]}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"convert","arguments":{"files":[{"name":"bad.json","content":"{"}]}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"convert","arguments":{"file":"/etc/passwd"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"unknown"}`,
+		`not json`,
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := serveMCP(strings.NewReader(in), &out); err != nil {
+		t.Fatalf("serveMCP failed: %v", err)
+	}
+
+	var responses []map[string]any
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r map[string]any
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		responses = append(responses, r)
+	}
+	if len(responses) != 7 {
+		t.Fatalf("want 7 responses (none for the notification), got %d", len(responses))
+	}
+
+	init := responses[0]["result"].(map[string]any)
+	if init["protocolVersion"] != "2024-11-05" {
+		t.Fatalf("protocol version should be negotiated: %v", init)
+	}
+
+	tools := responses[1]["result"].(map[string]any)["tools"].([]any)
+	if len(tools) != 3 {
+		t.Fatalf("want 3 tools, got %v", tools)
+	}
+
+	conv := responses[2]["result"].(map[string]any)
+	text := conv["content"].([]any)[0].(map[string]any)["text"]
+	if text != "Api__Url=https://b\n" || conv["isError"] != nil {
+		t.Fatalf("unexpected convert result: %v", conv)
+	}
+
+	bad := responses[3]["result"].(map[string]any)
+	if bad["isError"] != true || !strings.HasPrefix(bad["content"].([]any)[0].(map[string]any)["text"].(string), "bad.json: ") {
+		t.Fatalf("parse errors should be tool errors: %v", bad)
+	}
+
+	for i, code := range map[int]float64{4: rpcInvalidParams, 5: rpcMethodNotFound, 6: rpcParseError} {
+		e, ok := responses[i]["error"].(map[string]any)
+		if !ok || e["code"] != code {
+			t.Fatalf("response %d: want error %v, got %v", i, code, responses[i])
+		}
+	}
+}
+
+func TestMCPExplainAndDiff(t *testing.T) {
+	files := `[{"name":"appsettings.json","content":"{\"Logging\":{\"Level\":\"Information\"},\"A\":\"1\"}"},` +
+		`{"name":"appsettings.Production.json","content":"{\"logging\":{\"level\":\"Warning\"}}"}]`
+
+	res, err := callTool(mcpTools[2], json.RawMessage(`{"files":`+files+`,"key":"Logging:Level"}`))
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	ex := res.StructuredContent.(explainResult)
+	if ex.Key != "Logging:Level" || ex.Value != "Warning" || ex.Source != "appsettings.Production.json" || len(ex.Layers) != 2 {
+		t.Fatalf("unexpected explain result: %+v", ex)
+	}
+
+	res, err = callTool(mcpTools[1], json.RawMessage(`{"a":`+files+`,"b":[{"content":"{\"A\":\"2\",\"B\":\"3\"}"}]}`))
+	if err != nil {
+		t.Fatalf("diff failed: %v", err)
+	}
+	d := res.StructuredContent.(diffResult)
+	if d.Changed["A"].To != "2" || d.Added["B"] != "3" || d.Removed["Logging__Level"] != "Warning" {
+		t.Fatalf("unexpected diff result: %+v", d)
+	}
+}
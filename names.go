@@ -0,0 +1,145 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// connectionStringPrefixes maps the -connstr-type values to the App Service connection
+// string prefixes that the .NET environment variable provider recognizes
+var connectionStringPrefixes = map[string]string{
+	"sql":      "SQLCONNSTR_",
+	"sqlazure": "SQLAZURECONNSTR_",
+	"mysql":    "MYSQLCONNSTR_",
+	"custom":   "CUSTOMCONNSTR_",
+}
+
+// caseModes lists the accepted -case values
+var caseModes = map[string]bool{
+	"preserve":        true,
+	"upper":           true,
+	"lower":           true,
+	"screaming-snake": true,
+}
+
+// outputNames applies the naming flags (-case, -prefix) to keys joined with sep
+func outputNames(vars map[string]string, sep string) map[string]string {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		out[outputName(k, sep)] = v
+	}
+	return out
+}
+
+// notationNames returns the output names of a key joined with sep: its name, and
+// with -dual-notation its names in __ and : notation as well
+func notationNames(key, sep string) []string {
+	names := []string{outputName(key, sep)}
+	if !*dualNotation {
+		return names
+	}
+	for _, alt := range []string{"__", ":"} {
+		if name := outputName(strings.ReplaceAll(key, sep, alt), alt); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// outputName applies the naming flags to a single key joined with sep
+func outputName(key, sep string) string {
+	if name, ok := connectionStringName(key, sep, *connStrType); ok {
+		return name
+	}
+	return *prefix + caseKey(key, *keyCase, sep)
+}
+
+// connectionStringName returns the App Service name of a ConnectionStrings:<name> key,
+// e.g. SQLCONNSTR_Default, when a connection string type is selected
+func connectionStringName(key, sep, kind string) (string, bool) {
+	p := connectionStringPrefixes[kind]
+	if p == "" {
+		return "", false
+	}
+
+	section, name, ok := strings.Cut(key, sep)
+	if !ok || !strings.EqualFold(section, "ConnectionStrings") || name == "" || strings.Contains(name, sep) {
+		return "", false
+	}
+	return p + name, true
+}
+
+// caseKey applies a -case mode to a key. The key is transformed segment by segment
+// so that the separator itself is never altered.
+func caseKey(key, mode, sep string) string {
+	if mode == "" || mode == "preserve" {
+		return key
+	}
+
+	segments := strings.Split(key, sep)
+	for i, s := range segments {
+		switch mode {
+		case "upper":
+			segments[i] = strings.ToUpper(s)
+		case "lower":
+			segments[i] = strings.ToLower(s)
+		case "screaming-snake":
+			segments[i] = screamingSnake(s)
+		}
+	}
+	return strings.Join(segments, sep)
+}
+
+// screamingSnake converts a camel/Pascal case identifier to SCREAMING_SNAKE_CASE,
+// keeping acronyms together: "LogLevel" -> "LOG_LEVEL", "HTTPServer" -> "HTTP_SERVER".
+func screamingSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if r == '-' || r == '.' || r == ' ' {
+			r = '_'
+		}
+
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// normalizeKeys rewrites the object keys of value, at every depth, to the Unicode NFC
+// form. Keys typed in decomposed form, as some editors and macOS file systems write
+// them, would otherwise produce variables the application never reads under the
+// composed name its code uses. Keys that only differ by their form are reported with
+// collision, the last one in key order being kept.
+func normalizeKeys(value any, collision func(a, b string)) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		first := make(map[string]string, len(v))
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			nk := norm.NFC.String(k)
+			if prev, ok := first[nk]; ok {
+				collision(prev, k)
+			} else {
+				first[nk] = k
+			}
+			out[nk] = normalizeKeys(v[k], collision)
+		}
+		return out
+	case []any:
+		for i := range v {
+			v[i] = normalizeKeys(v[i], collision)
+		}
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func TestOutputNames(t *testing.T) {
+	defer func(p, c string) { *prefix, *keyCase = p, c }(*prefix, *keyCase)
+	*prefix, *keyCase = "MYAPP_", "upper"
+
+	got := outputNames(map[string]string{"Api__Url": "x", "Port": "80"}, "__")
+	if len(got) != 2 || got["MYAPP_API__URL"] != "x" || got["MYAPP_PORT"] != "80" {
+		t.Fatalf("unexpected output names: %v", got)
+	}
+}
+
+func TestCaseKey(t *testing.T) {
+	vars := map[string]string{
+		"Logging__LogLevel__Default":                    "a",
+		"Serilog__WriteTo__1__Args__fileSizeLimitBytes": "b",
+		"HTTPServer__max-connections":                   "c",
+	}
+
+	cases := map[string][]string{
+		"upper":           {"LOGGING__LOGLEVEL__DEFAULT", "SERILOG__WRITETO__1__ARGS__FILESIZELIMITBYTES", "HTTPSERVER__MAX-CONNECTIONS"},
+		"lower":           {"logging__loglevel__default", "serilog__writeto__1__args__filesizelimitbytes", "httpserver__max-connections"},
+		"screaming-snake": {"LOGGING__LOG_LEVEL__DEFAULT", "SERILOG__WRITE_TO__1__ARGS__FILE_SIZE_LIMIT_BYTES", "HTTP_SERVER__MAX_CONNECTIONS"},
+		"preserve":        {"Logging__LogLevel__Default", "Serilog__WriteTo__1__Args__fileSizeLimitBytes", "HTTPServer__max-connections"},
+	}
+
+	for mode, want := range cases {
+		got := make(map[string]bool)
+		for k := range vars {
+			got[caseKey(k, mode, "__")] = true
+		}
+		for _, k := range want {
+			if !got[k] {
+				t.Fatalf("%s: missing key %q in %v", mode, k, got)
+			}
+		}
+	}
+}
+
+func TestConnectionStringName(t *testing.T) {
+	cases := []struct {
+		key, kind, want string
+		ok              bool
+	}{
+		{"ConnectionStrings__Default", "sql", "SQLCONNSTR_Default", true},
+		{"connectionstrings__Reports", "mysql", "MYSQLCONNSTR_Reports", true},
+		{"ConnectionStrings__Default", "", "", false},
+		{"ConnectionStrings__Nested__Value", "custom", "", false},
+		{"Logging__Default", "sql", "", false},
+	}
+
+	for _, c := range cases {
+		got, ok := connectionStringName(c.key, "__", c.kind)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("%s/%s: want %q,%v got %q,%v", c.key, c.kind, c.want, c.ok, got, ok)
+		}
+	}
+}
+
+func TestNormalizeKeys(t *testing.T) {
+	// "e\u0301" is the decomposed form of "\u00e9"
+	doc := map[string]any{
+		"Cafe\u0301": map[string]any{"Me\u0301nu": "1"},
+		"Caf\u00e9":  "2",
+		"Items":      []any{map[string]any{"Nai\u0308ve": true}},
+	}
+	var collisions []string
+	got := normalizeKeys(doc, func(a, b string) { collisions = append(collisions, a+"|"+b) }).(map[string]any)
+
+	if got["Caf\u00e9"] != "2" || len(got) != 2 {
+		t.Fatalf("unexpected document: %#v", got)
+	}
+	if item := got["Items"].([]any)[0].(map[string]any); item["Na\u00efve"] != true {
+		t.Fatalf("array items should be normalized: %#v", item)
+	}
+	if len(collisions) != 1 || collisions[0] != "Cafe\u0301|Caf\u00e9" {
+		t.Fatalf("unexpected collisions: %q", collisions)
+	}
+	if got := normalizeKey("Cafe\u0301__Me\u0301nu", keySep); got != "Caf\u00e9:M\u00e9nu" {
+		t.Fatalf("normalizeKey: got %q", got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// outputPath expands the {type} placeholder of an -out template
+func outputPath(template, outType string) string {
+	return strings.ReplaceAll(template, "{type}", outType)
+}
+
+// canonicalVersion is the revision of the -canonical profile. The canonical output of
+// a configuration only changes when it is incremented, which the header records.
+const canonicalVersion = 1
+
+// canonicalFlags are the settings pinned by -canonical
+var canonicalFlags = []struct{ name, value string }{
+	{"sort", "name"},
+	{"quote", "always"},
+	{"newline", "lf"},
+}
+
+// applyCanonical sets the flags of the canonical profile, failing when one of them
+// was given another value on the command line or in the tool configuration
+func applyCanonical(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, c := range canonicalFlags {
+		f := fs.Lookup(c.name)
+		if set[c.name] && f.Value.String() != c.value {
+			return fmt.Errorf("-%s %s cannot be used with -canonical", c.name, f.Value)
+		}
+		if err := f.Value.Set(c.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withHeader prepends the generated-file header to body: the tool version, the
+// configuration sources, the environment and, unless omitted, the SHA-256 of body.
+// It holds no timestamp, so that unchanged inputs give identical files. The
+// canonical profile records its own revision instead of the tool version, and
+// writes the sources with forward slashes whatever the platform.
+func withHeader(body []byte, comment string, sources []string, hash bool) []byte {
+	if comment == "" {
+		return body
+	}
+
+	generator := app + " " + version
+	if *canonical {
+		generator = fmt.Sprintf("%s (canonical v%d)", app, canonicalVersion)
+		sources = slices.Clone(sources)
+		for i, s := range sources {
+			sources[i] = filepath.ToSlash(s)
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s Generated by %s from %s. Do not edit.\n", comment, generator, strings.Join(sources, ", "))
+	if *environment != "" {
+		fmt.Fprintf(&b, "%s Environment: %s\n", comment, *environment)
+	}
+	if hash {
+		fmt.Fprintf(&b, "%s SHA-256: %x\n", comment, sha256.Sum256(body))
+	}
+	b.Write(body)
+	return b.Bytes()
+}
+
+// crlf converts the LF line endings of data to CRLF
+func crlf(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
+
+// writeStdout calls write with the standard output behind a buffer, flushed when it
+// returns, so that reports written a line at a time are not a write per line
+func writeStdout(write func(w io.Writer) error) error {
+	bw := bufio.NewWriterSize(os.Stdout, 64<<10)
+	err := write(bw)
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// writeFileAtomic replaces path with data through a temporary file renamed over it,
+// so readers never observe a partially written file. Missing directories are created.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create output file: %w", err)
+	}
+	// Removing the renamed file fails harmlessly
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
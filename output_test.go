@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, outputPath("env/{type}.yaml", "k8s"))
+
+	if err := writeFileAtomic(path, []byte("first"), 0o644); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+	if err := writeFileAtomic(path, []byte("second"), 0o600); err != nil {
+		t.Fatalf("writeFileAtomic failed: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "second" {
+		t.Fatalf("unexpected content %q: %v", content, err)
+	}
+	if filepath.Base(path) != "k8s.yaml" {
+		t.Fatalf("unexpected path: %s", path)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("temporary files left behind: %v %v", entries, err)
+	}
+	if info, _ := entries[0].Info(); info.Mode().Perm() != 0o600 {
+		t.Fatalf("unexpected mode: %v", info.Mode())
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	defer func(env string) { *environment = env }(*environment)
+	*environment = "Production"
+
+	body := []byte("A=\"1\"\n")
+	got := string(withHeader(body, "#", []string{"appsettings.json", "appsettings.Production.json"}, true))
+	want := "# Generated by dotnet-appsettings-env dev from appsettings.json, appsettings.Production.json. Do not edit.\n" +
+		"# Environment: Production\n" +
+		fmt.Sprintf("# SHA-256: %x\n", sha256.Sum256(body)) + string(body)
+	if got != want {
+		t.Fatalf("unexpected header:\n%s", got)
+	}
+
+	if string(withHeader(body, "", nil, true)) != string(body) {
+		t.Fatalf("formats without comments should get no header")
+	}
+	if strings.Contains(string(withHeader(body, "#", nil, false)), "SHA-256") {
+		t.Fatalf("hash should be omitted")
+	}
+
+	defer func(c bool) { *canonical = c }(*canonical)
+	*canonical = true
+	got = string(withHeader(body, "#", []string{"appsettings.json"}, false))
+	if !strings.HasPrefix(got, "# Generated by dotnet-appsettings-env (canonical v1) from appsettings.json. Do not edit.\n") {
+		t.Fatalf("unexpected canonical header:\n%s", got)
+	}
+}
+
+func TestApplyCanonical(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	sort := fs.String("sort", "name", "")
+	quote := fs.String("quote", "always", "")
+	newline := fs.String("newline", "lf", "")
+
+	if err := fs.Parse([]string{"-quote", "always"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyCanonical(fs); err != nil || *sort != "name" || *quote != "always" || *newline != "lf" {
+		t.Fatalf("unexpected profile: %v %s %s %s", err, *sort, *quote, *newline)
+	}
+
+	fs.Set("newline", "crlf")
+	if err := applyCanonical(fs); err == nil || !strings.Contains(err.Error(), "-newline crlf") {
+		t.Fatalf("conflicting flag should fail: %v", err)
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	if got := string(crlf([]byte("A=1\nB=2\n"))); got != "A=1\r\nB=2\r\n" {
+		t.Fatalf("unexpected line endings: %q", got)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// target is a destination of the push and inject commands
+type target struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// pushTargets lists the destinations of push
+var pushTargets []target
+
+func init() {
+	pushTargets = []target{
+		{"k8s", "Server-side apply the ConfigMap and Secret to a Kubernetes namespace", runPushK8s},
+		{"deployment", "Patch the env of a Deployment container with the variables", runPushDeployment},
+		{"azure", "Merge the variables into App Service app settings or Container App env", runPushAzure},
+		{"appconfig", "Write the variables as Azure App Configuration key-values", runPushAppConfig},
+		{"ssm", "Write the variables as AWS Systems Manager parameters", runPushSSM},
+		{"keyvault", "Write the -secret-keys as Azure Key Vault secrets", runPushKeyVault},
+		{"github", "Write the variables as GitHub Actions secrets and variables", runPushGitHub},
+		{"gitlab", "Write the variables as GitLab CI/CD variables, secrets masked", runPushGitLab},
+		{"vault", "Merge the variables into a HashiCorp Vault KV v2 secret", runPushVault},
+		{"doppler", "Sync the variables to a Doppler project config", runPushDoppler},
+	}
+}
+
+// runPush implements the push subcommand, which writes the converted configuration
+// directly to a target system
+func runPush(args []string) int {
+	return runTarget("push", pushTargets, args)
+}
+
+// runTarget runs the target of command named by the first argument
+func runTarget(command string, targets []target, args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		targetUsage(command, targets)
+		return exitUsage
+	}
+
+	for _, t := range targets {
+		if t.name == args[0] {
+			return t.run(args[1:])
+		}
+	}
+	errorf("unknown %s target: %q", command, args[0])
+	return exitUsage
+}
+
+func targetUsage(command string, targets []target) {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage of %s %s <target> [flags]:\n\nTargets:\n", os.Args[0], command)
+	for _, t := range targets {
+		fmt.Fprintf(w, "  %-10s %s\n", t.name, t.summary)
+	}
+	fmt.Fprintf(w, "\nRun %s %s <target> -h for the flags of a target.\n", os.Args[0], command)
+}
+
+// runPushK8s implements push k8s. Like apply, it converts the configuration to a
+// ConfigMap and a Secret, which it applies with server-side apply.
+func runPushK8s(args []string) int {
+	namespace := flag.String("namespace", "", "Namespace (default: from the kubeconfig context)")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flag.String("context", "", "Kubeconfig context (default: current context)")
+	dryRun := flag.String("dry-run", "none", "none, client to print the ConfigMap and Secret, or server to have the API server validate them without persisting")
+	fieldManager := flag.String("field-manager", app, "Field manager owning the applied fields")
+	force := flag.Bool("force-conflicts", false, "Take over fields owned by another field manager")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push k8s [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	manifests, code := renderManifests("push k8s", args)
+	if manifests == nil {
+		return code
+	}
+
+	switch *dryRun {
+	case "none", "server":
+	case "client":
+		if err := writeStdout(func(w io.Writer) error { return writeManifests(w, manifests) }); err != nil {
+			logError(ioError(err))
+			return exitIO
+		}
+		return exitOK
+	default:
+		errorf("invalid -dry-run: %q (none, client or server)", *dryRun)
+		return exitUsage
+	}
+
+	client, err := newKubeClient(*kubeconfig, *kubeContext, *namespace)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	for _, m := range manifests {
+		var obj kubeObject
+		if err := yaml.Unmarshal(m, &obj); err != nil {
+			logError(fmt.Errorf("parse generated manifest: %w", err))
+			return exitFailure
+		}
+		if err := client.serverSideApply(ctx, &obj, *fieldManager, *force, *dryRun == "server"); err != nil {
+			logError(err)
+			return exitIO
+		}
+
+		result := "serverside-applied"
+		if *dryRun == "server" {
+			result += " (server dry run)"
+		}
+		fmt.Fprintf(os.Stdout, "%s/%s %s\n", strings.ToLower(obj.Kind), obj.Metadata.Name, result)
+	}
+	return exitOK
+}
+
+// runPushDeployment implements push deployment. It patches the env of a Deployment
+// container with the converted variables, referencing the -secret-keys from the Secret
+// written by push k8s instead of inlining them.
+func runPushDeployment(args []string) int {
+	deployment := flag.String("deployment", "", "Name of the Deployment to patch (required)")
+	containerName := flag.String("container", "", "Container name (default: the first container)")
+	namespace := flag.String("namespace", "", "Namespace (default: from the kubeconfig context)")
+	kubeconfig := flag.String("kubeconfig", "", "Path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	kubeContext := flag.String("context", "", "Kubeconfig context (default: current context)")
+	dryRun := flag.String("dry-run", "none", "none, client to print the patch, or server to have the API server validate it without persisting")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push deployment [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	r, code := renderOutput("push deployment", "k8s", args)
+	if r == nil {
+		return code
+	}
+
+	switch {
+	case *deployment == "":
+		errorf("-deployment is required")
+		return exitUsage
+	case *dryRun != "none" && *dryRun != "client" && *dryRun != "server":
+		errorf("invalid -dry-run: %q (none, client or server)", *dryRun)
+		return exitUsage
+	case *dryRun == "client" && *containerName == "":
+		errorf("-container is required with -dry-run=client")
+		return exitUsage
+	}
+
+	secretName := *secretResource
+	if secretName == "" {
+		secretName = *resourceName + "-secrets"
+	}
+
+	var client *kubeClient
+	ctx := context.Background()
+	if *dryRun != "client" {
+		var err error
+		if client, err = newKubeClient(*kubeconfig, *kubeContext, *namespace); err != nil {
+			logError(err)
+			return exitUsage
+		}
+		if *containerName == "" {
+			if *containerName, err = client.firstContainer(ctx, *deployment); err != nil {
+				logError(err)
+				return exitCode(err, exitIO)
+			}
+		}
+	}
+
+	patch, err := json.Marshal(envPatch(*containerName, r.named, r.secret, secretName))
+	if err != nil {
+		logError(err)
+		return exitFailure
+	}
+
+	if *dryRun == "client" {
+		if err := writeStdout(func(w io.Writer) error {
+			_, err := w.Write(append(patch, '\n'))
+			return err
+		}); err != nil {
+			logError(ioError(err))
+			return exitIO
+		}
+		return exitOK
+	}
+
+	if err := client.patchDeployment(ctx, *deployment, patch, *dryRun == "server"); err != nil {
+		logError(err)
+		return exitIO
+	}
+	result := "patched"
+	if *dryRun == "server" {
+		result += " (server dry run)"
+	}
+	fmt.Fprintf(os.Stdout, "deployment.apps/%s %s\n", *deployment, result)
+	return exitOK
+}
+
+// envPatch returns the strategic merge patch setting the variables in the env of
+// container. Entries merge by name, so other variables of the container are kept; the
+// field not used by an entry is nulled in case the existing entry set it. With
+// -checksum, the pod template is annotated with the templateChecksum of the variables.
+func envPatch(container string, named map[string]string, secret map[string]bool, secretName string) map[string]any {
+	env := make([]map[string]any, 0, len(named))
+	for _, kv := range appsettingsenv.Sorted(named) {
+		entry := map[string]any{"name": kv.Name}
+		if secret[kv.Name] {
+			entry["value"] = nil
+			entry["valueFrom"] = map[string]any{"secretKeyRef": map[string]any{"name": secretName, "key": kv.Name}}
+		} else {
+			entry["value"] = kv.Value
+			entry["valueFrom"] = nil
+		}
+		env = append(env, entry)
+	}
+
+	containers := []map[string]any{{"name": container, "env": env}}
+	template := map[string]any{"spec": map[string]any{"containers": containers}}
+	if *checksum {
+		sum := templateChecksum(named, secret)
+		template["metadata"] = map[string]any{"annotations": map[string]any{appsettingsenv.ChecksumAnnotation: sum}}
+	}
+	return map[string]any{"spec": map[string]any{"template": template}}
+}
+
+// checksumKeyEnv names the variable holding the key of the secret values in the
+// pod template checksum
+const checksumKeyEnv = "APPSETTINGS_CHECKSUM_KEY"
+
+// templateChecksum returns the checksum of the variables annotating a pod template.
+// Whoever can read the workload reads the annotation, while the secret values are
+// only readable from their Secret: a plain hash of them would let a dictionary of
+// guesses be checked against it, so they enter the checksum as their HMAC-SHA256
+// keyed with $APPSETTINGS_CHECKSUM_KEY. Without a key only their names do, and
+// rotating a secret does not roll the pods out.
+func templateChecksum(named map[string]string, secret map[string]bool) string {
+	key := os.Getenv(checksumKeyEnv)
+	vars := appsettingsenv.Sorted(named)
+	omitted := false
+	for i, kv := range vars {
+		if !secret[kv.Name] {
+			continue
+		}
+		if key == "" {
+			vars[i].Value, omitted = "", true
+			continue
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		io.WriteString(mac, kv.Value)
+		vars[i].Value = hex.EncodeToString(mac.Sum(nil))
+	}
+	if omitted {
+		warnf("%s is not set: the secret values are left out of the pod template checksum, so changing them does not restart the pods", checksumKeyEnv)
+	}
+	return appsettingsenv.Checksum(vars)
+}
+
+// firstContainer returns the name of the first container of a Deployment
+func (c *kubeClient) firstContainer(ctx context.Context, deployment string) (string, error) {
+	obj, err := c.clientset.AppsV1().Deployments(c.namespace).Get(ctx, deployment, metav1.GetOptions{})
+	if err != nil {
+		return "", ioError(fmt.Errorf("get deployment/%s: %w", deployment, err))
+	}
+	containers := obj.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		return "", fmt.Errorf("deployment/%s has no containers", deployment)
+	}
+	return containers[0].Name, nil
+}
+
+// patchDeployment sends a strategic merge patch to a Deployment
+func (c *kubeClient) patchDeployment(ctx context.Context, deployment string, patch []byte, dryRun bool) error {
+	opts := metav1.PatchOptions{}
+	if dryRun {
+		opts.DryRun = []string{metav1.DryRunAll}
+	}
+	if _, err := c.clientset.AppsV1().Deployments(c.namespace).Patch(ctx, deployment, types.StrategicMergePatchType, patch, opts); err != nil {
+		return fmt.Errorf("patch deployment/%s: %w", deployment, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func TestEnvPatch(t *testing.T) {
+	named := map[string]string{"Api__Url": "https://api", "Db__Password": "s3cret"}
+	patch := envPatch("web", named, map[string]bool{"Db__Password": true}, "api-secrets")
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatalf("marshal patch: %v", err)
+	}
+	want := `{"spec":{"template":{"spec":{"containers":[{"env":[` +
+		`{"name":"Api__Url","value":"https://api","valueFrom":null},` +
+		`{"name":"Db__Password","value":null,"valueFrom":{"secretKeyRef":{"key":"Db__Password","name":"api-secrets"}}}` +
+		`],"name":"web"}]}}}}`
+	if string(data) != want {
+		t.Fatalf("unexpected patch:\n%s\nwant:\n%s", data, want)
+	}
+
+	defer func(c bool) { *checksum = c }(*checksum)
+	*checksum = true
+	data, _ = json.Marshal(envPatch("web", named, nil, "api-secrets"))
+	sum := appsettingsenv.Checksum(appsettingsenv.Sorted(named))
+	if !strings.Contains(string(data), `"metadata":{"annotations":{"checksum/config":"`+sum+`"}}`) {
+		t.Fatalf("unexpected patch:\n%s", data)
+	}
+}
+
+func TestTemplateChecksum(t *testing.T) {
+	named := map[string]string{"Api__Url": "https://api", "Db__Password": "s3cret"}
+	secret := map[string]bool{"Db__Password": true}
+
+	t.Setenv(checksumKeyEnv, "")
+	sum := templateChecksum(named, secret)
+	if sum != appsettingsenv.Checksum([]appsettingsenv.KV{{Name: "Api__Url", Value: "https://api"}, {Name: "Db__Password"}}) {
+		t.Fatalf("without a key, secret values should be left out: %s", sum)
+	}
+	if sum == appsettingsenv.Checksum(appsettingsenv.Sorted(named)) {
+		t.Fatalf("secret values should not be hashed")
+	}
+
+	t.Setenv(checksumKeyEnv, "key")
+	keyed := templateChecksum(named, secret)
+	if keyed == sum || keyed == appsettingsenv.Checksum(appsettingsenv.Sorted(named)) {
+		t.Fatalf("secret values should enter the checksum keyed: %s", keyed)
+	}
+	if templateChecksum(named, secret) != keyed {
+		t.Fatalf("the checksum should be stable")
+	}
+	rotated := map[string]string{"Api__Url": "https://api", "Db__Password": "r0tated"}
+	if templateChecksum(rotated, secret) == keyed {
+		t.Fatalf("a changed secret should change the checksum")
+	}
+	t.Setenv(checksumKeyEnv, "other")
+	if templateChecksum(named, secret) == keyed {
+		t.Fatalf("the checksum should depend on the key")
+	}
+}
+
+func TestKubeClientPatchDeployment(t *testing.T) {
+	var patched []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/apis/apps/v1/namespaces/prod/deployments/api" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`{"kind":"Deployment","spec":{"template":{"spec":{"containers":[{"name":"web"},{"name":"sidecar"}]}}}}`))
+		case http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/strategic-merge-patch+json" || r.URL.Query().Get("dryRun") != "All" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			patched, _ = io.ReadAll(r.Body)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := testKubeClient(t, srv)
+	ctx := context.Background()
+	name, err := client.firstContainer(ctx, "api")
+	if err != nil || name != "web" {
+		t.Fatalf("want first container web, got %q, %v", name, err)
+	}
+	if _, err := client.firstContainer(ctx, "missing"); !apierrors.IsNotFound(err) {
+		t.Fatalf("missing deployment should be not found: %v", err)
+	}
+
+	if err := client.patchDeployment(ctx, "api", []byte(`{"spec":{}}`), true); err != nil {
+		t.Fatalf("patch deployment: %v", err)
+	}
+	if string(patched) != `{"spec":{}}` {
+		t.Fatalf("unexpected patch: %s", patched)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Actions of the -report entries
+const (
+	reportExcluded  = "excluded"
+	reportRedacted  = "redacted"
+	reportEncrypted = "encrypted"
+	reportSecret    = "secret"
+)
+
+// reportEntry is what an output did with a key, and why
+type reportEntry struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// outputReport lists the keys an output left out, redacted, encrypted or moved to
+// its secret output
+type outputReport struct {
+	Type string        `json:"type"`
+	Keys []reportEntry `json:"keys"`
+}
+
+// newReportEntry returns the entry of key, joined by sep, selected by f for action,
+// or else by -detect-secrets classify when its value looks like suspect
+func newReportEntry(key, sep, action string, f *keyFilter, suspect string) reportEntry {
+	reason := "value looks like " + suspect + " (-detect-secrets classify)"
+	if f != nil && f.match(key) {
+		reason = f.reason(key)
+	}
+	return reportEntry{Key: strings.ReplaceAll(key, sep, keySep), Action: action, Reason: reason}
+}
+
+// writeReport writes the -report of outputs to path as a JSON document
+func writeReport(path string, outputs []*rendered) error {
+	var report struct {
+		Outputs []outputReport `json:"outputs"`
+	}
+	for _, r := range outputs {
+		keys := r.report
+		if keys == nil {
+			keys = []reportEntry{}
+		}
+		report.Outputs = append(report.Outputs, outputReport{Type: r.outType, Keys: keys})
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0o644); err != nil {
+		return ioError(err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConverterRender_Report(t *testing.T) {
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+	defer func(p string, r bool, e stringList) { *reportPath, *redact, excludes = p, r, e }(*reportPath, *redact, excludes)
+	path := filepath.Join(t.TempDir(), "report.json")
+	*reportPath, *redact, excludes = path, true, stringList{"Logging:*"}
+
+	c, err := newConverter("docker", []string{"Db:*"})
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	r, err := c.render(map[string]string{"Logging:Level": "Debug", "Db:Password": "x", "Db:Host": "db", "Api:Url": "http://api"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := []reportEntry{
+		{Key: "Logging:Level", Action: reportExcluded, Reason: `matches the exclude pattern "Logging:*"`},
+		{Key: "Db:Host", Action: reportSecret, Reason: `matches "Db:*"`},
+		{Key: "Db:Password", Action: reportRedacted, Reason: `matches "re:(?i)(password|passwd|pwd|secret|token|credential|apikey|accesskey|privatekey|sas)"`},
+		{Key: "Db:Password", Action: reportSecret, Reason: `matches "Db:*"`},
+	}
+	if !slices.Equal(r.report, want) {
+		t.Fatalf("unexpected report:\n%v\nwant:\n%v", r.report, want)
+	}
+
+	if err := writeReport(path, []*rendered{r, {outType: "k8s"}}); err != nil {
+		t.Fatalf("writeReport failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	var got struct {
+		Outputs []outputReport `json:"outputs"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil || len(got.Outputs) != 2 || len(got.Outputs[0].Keys) != 4 || got.Outputs[1].Keys == nil {
+		t.Fatalf("unexpected report file (%v):\n%s", err, data)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// restClient performs the JSON requests of the REST APIs the push targets write to.
+// Each API sets its base URL and how requests are authenticated.
+type restClient struct {
+	// name names the API in errors
+	name string
+	// base is the URL request paths are relative to
+	base string
+	// authorize sets the credentials and the headers specific to the API, after the
+	// default ones
+	authorize func(ctx context.Context, req *http.Request) error
+	http      *http.Client
+}
+
+// newRESTClient returns a client of the API name at base
+func newRESTClient(name, base string, authorize func(ctx context.Context, req *http.Request) error) restClient {
+	return restClient{
+		name:      name,
+		base:      strings.TrimSuffix(base, "/"),
+		authorize: authorize,
+		http:      &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// apiError is a non-successful response of a REST API
+type apiError struct {
+	API     string
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s error (%d): %s", e.API, e.Code, e.Message)
+}
+
+// isStatus reports whether err is an API response with status code
+func isStatus(err error, code int) bool {
+	var aerr *apiError
+	return errors.As(err, &aerr) && aerr.Code == code
+}
+
+// do performs a request on path, which may carry a query. body is sent as JSON when
+// not nil and a successful response is decoded into out when out is not nil.
+func (c *restClient) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+"/"+strings.TrimPrefix(path, "/"), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", app+"/"+version)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.authorize != nil {
+		if err := c.authorize(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &apiError{API: c.name, Code: resp.StatusCode, Message: errorMessage(data)}
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// errorMessage returns the message of an error response, in the shapes of the APIs
+// written to, or the response itself
+func errorMessage(data []byte) string {
+	var body struct {
+		// GitHub and GitLab; GitLab validation failures hold an object of field errors
+		Message json.RawMessage `json:"message"`
+		// Doppler
+		Messages []string `json:"messages"`
+		// Vault
+		Errors []string `json:"errors"`
+	}
+	// Fields of other types are skipped, the others still decoded
+	_ = json.Unmarshal(data, &body)
+
+	switch {
+	case len(body.Message) > 0 && string(body.Message) != `""` && string(body.Message) != "null":
+		var s string
+		if json.Unmarshal(body.Message, &s) == nil {
+			return s
+		}
+		return string(body.Message)
+	case len(body.Messages) > 0:
+		return strings.Join(body.Messages, "; ")
+	case len(body.Errors) > 0:
+		return strings.Join(body.Errors, "; ")
+	}
+	return strings.TrimSpace(string(data))
+}
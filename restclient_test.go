@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRESTClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "Bad credentials"}`))
+			return
+		}
+		if r.URL.Path != "/v1/items" || r.URL.Query().Get("page") != "2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"name": "item"}`))
+	}))
+	defer srv.Close()
+
+	authorize := func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer token")
+		return nil
+	}
+	client := newRESTClient("Test API", srv.URL+"/v1/", authorize)
+	var out struct{ Name string }
+	if err := client.do(context.Background(), http.MethodGet, "/items?page=2", nil, &out); err != nil || out.Name != "item" {
+		t.Fatalf("unexpected response: %+v, %v", out, err)
+	}
+	if err := client.do(context.Background(), http.MethodGet, "missing", nil, nil); !isStatus(err, http.StatusNotFound) {
+		t.Fatalf("want a 404 error, got %v", err)
+	}
+
+	client.authorize = nil
+	err := client.do(context.Background(), http.MethodGet, "items", nil, nil)
+	if !isStatus(err, http.StatusUnauthorized) || err.Error() != "Test API error (401): Bad credentials" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestErrorMessage(t *testing.T) {
+	cases := map[string]string{
+		`{"message": "Not Found"}`:                   "Not Found",
+		`{"message": {"key": ["is invalid"]}}`:       `{"key": ["is invalid"]}`,
+		`{"messages": ["Invalid token", "Expired"]}`: "Invalid token; Expired",
+		`{"errors": ["permission denied"]}`:          "permission denied",
+		" upstream timeout\n":                        "upstream timeout",
+	}
+	for body, want := range cases {
+		if got := errorMessage([]byte(body)); got != want {
+			t.Fatalf("errorMessage(%q): want %q got %q", body, want, got)
+		}
+	}
+}
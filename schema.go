@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaDialect is the JSON Schema version gen schema writes
+const schemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// jsonSchema is the subset of JSON Schema gen schema writes
+type jsonSchema struct {
+	Schema     string                 `json:"$schema,omitempty"`
+	Title      string                 `json:"title,omitempty"`
+	Type       any                    `json:"type,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Items      *jsonSchema            `json:"items,omitempty"`
+	Enum       []string               `json:"enum,omitempty"`
+	Examples   []string               `json:"examples,omitempty"`
+}
+
+// schemaNode accumulates the values found at one place of the configuration of
+// every environment
+type schemaNode struct {
+	types map[string]bool
+	// count is the number of values seen, objects the number of them that were objects
+	count, objects int
+	properties     map[string]*schemaNode
+	// names maps the lower-cased property names to the casing first seen
+	names map[string]string
+	items *schemaNode
+	// values holds the distinct string values
+	values map[string]bool
+}
+
+func newSchemaNode() *schemaNode {
+	return &schemaNode{
+		types:      make(map[string]bool),
+		properties: make(map[string]*schemaNode),
+		names:      make(map[string]string),
+		values:     make(map[string]bool),
+	}
+}
+
+// add records a value of a configuration tree
+func (n *schemaNode) add(v any) {
+	n.count++
+	switch v := v.(type) {
+	case nil:
+		n.types["null"] = true
+	case bool:
+		n.types["boolean"] = true
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			n.types["integer"] = true
+		} else {
+			n.types["number"] = true
+		}
+	case string:
+		n.types["string"] = true
+		n.values[v] = true
+	case []any:
+		n.types["array"] = true
+		if n.items == nil {
+			n.items = newSchemaNode()
+		}
+		for _, item := range v {
+			n.items.add(item)
+		}
+	case map[string]any:
+		n.types["object"] = true
+		n.objects++
+		for k, item := range v {
+			name, ok := n.names[strings.ToLower(k)]
+			if !ok {
+				name = k
+				n.names[strings.ToLower(k)] = k
+				n.properties[k] = newSchemaNode()
+			}
+			n.properties[name].add(item)
+		}
+	}
+}
+
+// enumValue matches the words that make an enum hint, such as log levels and modes
+var enumValue = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]{0,31}$`)
+
+// schema returns the JSON Schema of the values seen at key. Properties present in
+// every object are required. String properties whose values differ between
+// environments get them as an enum when they are all words, as examples otherwise;
+// hints are left out for array items and for the keys secret matches.
+func (n *schemaNode) schema(key string, hints bool, secret func(string) bool) *jsonSchema {
+	s := &jsonSchema{}
+	var types []string
+	for t := range n.types {
+		// Integers are numbers
+		if t != "integer" || !n.types["number"] {
+			types = append(types, t)
+		}
+	}
+	slices.Sort(types)
+	switch len(types) {
+	case 0:
+	case 1:
+		s.Type = types[0]
+	default:
+		s.Type = types
+	}
+
+	if len(n.properties) > 0 {
+		s.Properties = make(map[string]*jsonSchema, len(n.properties))
+		for _, k := range sortedFold(n.properties) {
+			p := n.properties[k]
+			s.Properties[k] = p.schema(strings.TrimPrefix(key+keySep+k, keySep), hints, secret)
+			if p.count == n.objects {
+				s.Required = append(s.Required, k)
+			}
+		}
+	}
+	if n.items != nil && n.items.count > 0 {
+		s.Items = n.items.schema(key, false, secret)
+	}
+
+	if hints && len(n.values) > 1 && len(types) == 1 && types[0] == "string" && (secret == nil || !secret(key)) {
+		values := sortedFold(n.values)
+		if !slices.ContainsFunc(values, func(v string) bool { return !enumValue.MatchString(v) }) {
+			s.Enum = values
+		} else {
+			s.Examples = values
+		}
+	}
+	return s
+}
+
+// generateSchema infers the JSON Schema of configuration trees, one per environment
+func generateSchema(trees []map[string]any, title string, secret func(string) bool, indent int) ([]byte, error) {
+	root := newSchemaNode()
+	for _, t := range trees {
+		root.add(t)
+	}
+	s := root.schema("", len(trees) > 1, secret)
+	s.Schema, s.Title = schemaDialect, title
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", strings.Repeat(" ", indent))
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// discoverEnvironments returns the names of the environment files next to the files
+// matching pattern, such as Production for appsettings.Production.json, sorted
+func discoverEnvironments(pattern string) []string {
+	files, _ := filepath.Glob(pattern)
+	found := make(map[string]bool)
+	for _, f := range files {
+		ext := filepath.Ext(f)
+		stem := strings.TrimSuffix(f, ext)
+		matches, _ := filepath.Glob(globEscape(stem) + ".*" + ext)
+		for _, m := range matches {
+			env := strings.TrimSuffix(strings.TrimPrefix(m, stem+"."), ext)
+			if env != "" && !strings.Contains(env, ".") && !slices.ContainsFunc(files, func(f string) bool { return f == m }) {
+				found[env] = true
+			}
+		}
+	}
+	return sortedFold(found)
+}
+
+// globMeta matches the metacharacters of glob patterns
+var globMeta = regexp.MustCompile(`[*?\[\\]`)
+
+// globEscape escapes the metacharacters of a path used in a glob pattern
+func globEscape(path string) string {
+	return globMeta.ReplaceAllString(path, `\$0`)
+}
+
+// runGenSchema implements gen schema
+func runGenSchema(args []string) int {
+	envs := flag.String("envs", "", "Comma-separated environments to infer the schema from, with the base files (default: those of the appsettings.{env}.json files found)")
+
+	if code := parseGenFlags("gen schema", args); code != exitOK {
+		return code
+	}
+
+	// The base files alone are a variant too, the configuration of an unnamed environment
+	names := append([]string{""}, discoverEnvironments(*file)...)
+	if *envs != "" {
+		names = append([]string{""}, strings.Split(*envs, ",")...)
+	}
+
+	var trees []map[string]any
+	for _, env := range names {
+		layers, err := loadLayers(*file, strings.TrimSpace(env), keySep)
+		if err != nil {
+			logError(err)
+			return exitCode(err, exitFailure)
+		}
+		tree, _ := buildTree(mergeLayers(layers), keySep, isLiteral)
+		trees = append(trees, tree)
+	}
+	debug("schema environments", "environments", strings.Join(names, ","))
+
+	secret, err := newRedactFilter(true, redactKeys, keySep)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+	width := *indent
+	if width == 0 {
+		width = 2
+	}
+	out, err := generateSchema(trees, filepath.Base(*file), secret.match, width)
+	if err != nil {
+		logError(fmt.Errorf("encode schema: %w", err))
+		return exitFailure
+	}
+	if *newline == "crlf" {
+		out = crlf(out)
+	}
+	return writeGenerated(out)
+}
+
+// compileSchema compiles the JSON Schema file given to -schema. Its $ref to other
+// files resolve relative to it.
+func compileSchema(path string) (*jsonschema.Schema, error) {
+	s, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// schemaViolation is a value of the configuration the schema rejects
+type schemaViolation struct {
+	// key is the configuration key of the value, empty for the root
+	key     string
+	message string
+}
+
+// validateSchema validates the configuration against s. The violations are located
+// in the last layer that sets the value, at its line when that layer is a file, and
+// returned as validation errors.
+func validateSchema(s *jsonschema.Schema, layers []layer, variables map[string]string) error {
+	tree, _ := buildTree(variables, keySep, isLiteral)
+	err := s.Validate(tree)
+	var verr *jsonschema.ValidationError
+	if err == nil || !errors.As(err, &verr) {
+		return err
+	}
+
+	secret, err := newRedactFilter(true, redactKeys, keySep)
+	if err != nil {
+		return err
+	}
+	violations := schemaViolations(verr, secret.match)
+
+	lines := make(map[string]map[string]int)
+	errs := make([]error, 0, len(violations))
+	for _, v := range violations {
+		source, line := violationSource(layers, v.key, lines)
+		at := v.key
+		if at == "" {
+			at = "configuration root"
+		}
+		msg := fmt.Errorf("schema violation in %s: %s: %s", source, at, v.message)
+		if line > 0 {
+			msg = fmt.Errorf("schema violation in %s (line %d): %s: %s", source, line, at, v.message)
+		}
+		errs = append(errs, validationError(&locatedError{file: source, line: line, err: msg}))
+	}
+	return errors.Join(errs...)
+}
+
+// schemaViolations lists the failed keywords of a validation error, the leaves of
+// its tree, sorted by key. The messages of secret values only name the keyword, as
+// some quote the value.
+func schemaViolations(verr *jsonschema.ValidationError, secret func(key string) bool) []schemaViolation {
+	p := message.NewPrinter(language.English)
+	var out []schemaViolation
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, c := range e.Causes {
+				walk(c)
+			}
+			return
+		}
+		v := schemaViolation{key: strings.Join(e.InstanceLocation, keySep), message: e.ErrorKind.LocalizedString(p)}
+		if v.key != "" && secret(v.key) {
+			v.message = "value fails " + strings.Join(e.ErrorKind.KeywordPath(), "/")
+		}
+		out = append(out, v)
+	}
+	walk(verr)
+
+	slices.SortStableFunc(out, func(a, b schemaViolation) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.key), strings.ToLower(b.key)), strings.Compare(a.message, b.message))
+	})
+	return slices.CompactFunc(out, func(a, b schemaViolation) bool { return a == b })
+}
+
+// violationSource returns the last layer that sets key or a key under it, and the
+// first line of those keys when the layer is a file. The root belongs to the first
+// layer, where missing sections are usually added. lines caches the key lines of
+// the files read.
+func violationSource(layers []layer, key string, lines map[string]map[string]int) (string, int) {
+	if key == "" && len(layers) > 0 {
+		return layers[0].source, 0
+	}
+
+	section := strings.ToLower(key) + keySep
+	within := func(k string) bool {
+		return strings.EqualFold(k, key) || strings.HasPrefix(strings.ToLower(k), section)
+	}
+	sets := func(l layer) bool {
+		for k := range l.vars {
+			if within(k) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		if !sets(l) {
+			continue
+		}
+
+		if l.source != "-" && !fileExists(l.source) {
+			return l.source, 0
+		}
+		if lines[l.source] == nil {
+			lines[l.source] = keyLines(l.source)
+		}
+		first := 0
+		for k, line := range lines[l.source] {
+			if within(k) && line > 0 && (first == 0 || line < first) {
+				first = line
+			}
+		}
+		return l.source, first
+	}
+	return *file, 0
+}
+
+// keyLines returns the line of every flattened key of a configuration file, or nil
+// when it cannot be read again
+func keyLines(filename string) map[string]int {
+	f, err := openInput(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	opts := flattenOptions(keySep)
+	opts.Literal = nil
+	vars, err := appsettingsenv.Variables(filename, f, appsettingsenv.VariableOptions{Flatten: opts})
+	if err != nil {
+		return nil
+	}
+	lines := make(map[string]int, len(vars))
+	for _, v := range vars {
+		lines[v.Name] = v.SourceLine
+	}
+	return lines
+}
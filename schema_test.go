@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	trees := []map[string]any{
+		{"Logging": map[string]any{"Level": "Information"}, "Api": map[string]any{"Url": "https://dev", "Timeout": json.Number("30")}, "Db": map[string]any{"Password": "a"}},
+		{"Logging": map[string]any{"Level": "Warning"}, "Api": map[string]any{"Url": "https://prod", "Timeout": json.Number("2.5"), "Hosts": []any{"a", "b"}}, "Db": map[string]any{"Password": "b"}},
+		{"logging": map[string]any{"level": "Warning"}, "Api": map[string]any{"Url": "https://prod", "Timeout": json.Number("1")}, "Db": map[string]any{"Password": "c"}},
+	}
+	secret := func(key string) bool { return strings.HasSuffix(key, "Password") }
+	out, err := generateSchema(trees, "appsettings.json", secret, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s jsonSchema
+	if err := json.Unmarshal(out, &s); err != nil {
+		t.Fatalf("invalid schema: %v\n%s", err, out)
+	}
+	api := s.Properties["Api"]
+	if s.Schema != schemaDialect || !slices.Equal(s.Required, []string{"Api", "Db", "Logging"}) || !slices.Equal(api.Required, []string{"Timeout", "Url"}) {
+		t.Fatalf("unexpected required keys:\n%s", out)
+	}
+	if api.Properties["Timeout"].Type != "number" || api.Properties["Hosts"].Items.Type != "string" || api.Properties["Hosts"].Items.Examples != nil {
+		t.Fatalf("unexpected types:\n%s", out)
+	}
+	if !slices.Equal(s.Properties["Logging"].Properties["Level"].Enum, []string{"Information", "Warning"}) ||
+		!slices.Equal(api.Properties["Url"].Examples, []string{"https://dev", "https://prod"}) {
+		t.Fatalf("unexpected hints:\n%s", out)
+	}
+	if p := s.Properties["Db"].Properties["Password"]; p.Enum != nil || p.Examples != nil {
+		t.Fatalf("secret values should not be listed:\n%s", out)
+	}
+}
+
+func TestDiscoverEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"appsettings.json", "appsettings.Production.json", "appsettings.Development.json", "appsettings.Local.Backup.json", "other.Staging.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := discoverEnvironments(filepath.Join(dir, "appsettings.json")); !slices.Equal(got, []string{"Development", "Production"}) {
+		t.Fatalf("unexpected environments: %v", got)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"appsettings.json":            "{\n  \"Api\": {\n    \"Url\": \"http://api\",\n    \"Timeout\": 30\n  },\n  \"Db\": { \"Password\": \"hunter2\" }\n}\n",
+		"appsettings.Production.json": "{\n  \"Api\": {\n    \"Timeout\": \"slow\"\n  }\n}\n",
+		"schema.json": `{"type": "object", "required": ["Api", "Features"], "properties": {
+			"Api": {"properties": {"Timeout": {"type": "integer"}, "Url": {"type": "string"}}},
+			"Db": {"properties": {"Password": {"pattern": "^.{10,}$"}}}}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := filepath.Join(dir, "appsettings.json")
+
+	s, err := compileSchema(filepath.Join(dir, "schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers, err := loadLayers(base, "Production", keySep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers = append(layers, layer{source: "-set", vars: map[string]string{"Api:Url": "http://other"}})
+
+	err = validateSchema(s, layers, mergeLayers(layers))
+	if exitCode(err, exitFailure) != exitValidation {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	want := []string{
+		"schema violation in " + base + ": configuration root: missing property 'Features'",
+		"schema violation in " + filepath.Join(dir, "appsettings.Production.json") + " (line 3): Api:Timeout: got string, want integer",
+		"schema violation in " + base + " (line 6): Db:Password: value fails pattern",
+	}
+	if got := strings.Split(err.Error(), "\n"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected violations:\n%s", err)
+	}
+
+	if err := validateSchema(s, layers[:1], map[string]string{"Api:Timeout": "30", "Features:A": "true"}); err != nil {
+		t.Fatalf("valid configuration rejected: %v", err)
+	}
+
+	if _, err := compileSchema(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("missing schema should fail")
+	}
+}
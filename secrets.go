@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandPatternFiles replaces "@file" entries with the patterns listed in file, one
+// per line. Blank lines and lines starting with # are ignored.
+func expandPatternFiles(patterns []string) ([]string, error) {
+	var out []string
+	for _, p := range patterns {
+		name, ok := strings.CutPrefix(p, "@")
+		if !ok {
+			out = append(out, p)
+			continue
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("read pattern file: %w", err)
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			out = append(out, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("read pattern file %s: %w", name, err)
+		}
+	}
+	return out, nil
+}
+
+// defaultRedactPatterns select secret-looking keys when -redact is given without -redact-keys
+var defaultRedactPatterns = []string{
+	`re:(?i)(password|passwd|pwd|secret|token|credential|apikey|accesskey|privatekey|sas)`,
+	`re:(?i)key$`,
+	`re:(?i)connectionstring`,
+}
+
+// newRedactFilter returns the filter selecting keys to redact, or nil when redaction
+// is off. Explicit patterns replace the built-in list and imply redaction.
+func newRedactFilter(enabled bool, patterns []string, sep string) (*keyFilter, error) {
+	patterns, err := expandPatternFiles(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		if !enabled {
+			return nil, nil
+		}
+		patterns = defaultRedactPatterns
+	}
+	return newKeyFilter(patterns, nil, sep)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandPatternFiles(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "secret-keys.txt")
+	content := "# secret keys\nConnectionStrings__*\n\n  re:(?i)password  \n"
+	if err := os.WriteFile(fn, []byte(content), 0o644); err != nil {
+		t.Fatalf("write pattern file: %v", err)
+	}
+
+	got, err := expandPatternFiles([]string{"Api__Key", "@" + fn})
+	if err != nil {
+		t.Fatalf("expandPatternFiles failed: %v", err)
+	}
+
+	want := []string{"Api__Key", "ConnectionStrings__*", "re:(?i)password"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("want %v got %v", want, got)
+	}
+}
+
+func TestNewRedactFilter(t *testing.T) {
+	if f, err := newRedactFilter(false, nil, "__"); f != nil || err != nil {
+		t.Fatalf("redaction should be off by default, got %v, %v", f, err)
+	}
+
+	f, err := newRedactFilter(true, nil, "__")
+	if err != nil {
+		t.Fatalf("newRedactFilter failed: %v", err)
+	}
+
+	cases := map[string]bool{
+		"ConnectionStrings__Default":  true,
+		"Database__Password":          true,
+		"Jwt__SigningKey":             true,
+		"Auth__ClientSecret":          true,
+		"Storage__SasToken":           true,
+		"Logging__LogLevel__Default":  false,
+		"Api__Url":                    false,
+		"FeatureManagement__KeyBoard": false,
+	}
+	for key, want := range cases {
+		if got := f.match(key); got != want {
+			t.Fatalf("%s: want redacted=%v got %v", key, want, got)
+		}
+	}
+
+	f, err = newRedactFilter(false, []string{"Api__*"}, "__")
+	if err != nil {
+		t.Fatalf("newRedactFilter failed: %v", err)
+	}
+	if !f.match("Api__Url") || f.match("Database__Password") {
+		t.Fatalf("explicit patterns should replace the built-in list")
+	}
+}
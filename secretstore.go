@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// secretEntry is a variable as a secret store keeps it
+type secretEntry struct {
+	name  string
+	value string
+	// secret masks the value in the preview
+	secret bool
+	// attrs are the other properties the store keeps with the value, such as masking
+	// flags. An entry whose attrs change is rewritten even when its value does not.
+	attrs string
+	// writeOnly tells that the store never returns the value, so the entry is
+	// rewritten on every push
+	writeOnly bool
+}
+
+// secretStore is a hosted secret manager or CI/CD settings store holding flat
+// name/value pairs. A backend implements it to become a push target through
+// pushSecretStore, which takes care of diffing, previewing and writing only what
+// changed.
+type secretStore interface {
+	// String describes where the entries are written, for messages
+	String() string
+	// entry returns the entry under which the store keeps the variable name, secret
+	// telling whether it matches -secret-keys. It returns nil to skip the variable,
+	// and an error when the store cannot hold it.
+	entry(name, value string, secret bool) (*secretEntry, error)
+	// entries returns the current entries of the store among names, the names of the
+	// entries pushed. Stores listing all of their entries may return others too.
+	entries(ctx context.Context, names []string) (map[string]secretEntry, error)
+	// write creates or updates the changed entries, current being what entries returned
+	write(ctx context.Context, changed []secretEntry, current map[string]secretEntry) error
+}
+
+// pushSecretStore writes the variables of r to s. Secret values are masked in the
+// preview; entries of the store the configuration does not define are kept.
+func pushSecretStore(ctx context.Context, w io.Writer, s secretStore, r *rendered, dryRun bool) error {
+	var wanted []secretEntry
+	var names, invalid []string
+	for _, kv := range appsettingsenv.Sorted(r.named) {
+		e, err := s.entry(kv.Name, kv.Value, r.secret[kv.Name])
+		if err != nil {
+			invalid = append(invalid, err.Error())
+			continue
+		}
+		if e != nil {
+			wanted = append(wanted, *e)
+			names = append(names, e.name)
+		}
+	}
+	if len(invalid) > 0 {
+		return validationError(fmt.Errorf("variables %s cannot hold: %s", s, strings.Join(invalid, "; ")))
+	}
+
+	current, err := s.entries(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	currentValues := make(map[string]string, len(current))
+	for name, e := range current {
+		currentValues[name] = e.value
+	}
+	wantedValues := make(map[string]string, len(wanted))
+	secret := make(map[string]bool, len(wanted))
+	var changed []secretEntry
+	for _, e := range wanted {
+		wantedValues[e.name] = e.value
+		old, exists := current[e.name]
+		secret[e.name] = e.secret || old.secret
+		if !exists || old.value != e.value || old.attrs != e.attrs || old.writeOnly {
+			changed = append(changed, e)
+		}
+	}
+
+	d := diffVariables(currentValues, wantedValues)
+	clear(d.Removed)
+	// Entries rewritten with the same value are listed too
+	for _, e := range changed {
+		if old, ok := current[e.name]; ok && old.value == e.value {
+			d.Changed[e.name] = valueChange{From: e.value, To: e.value}
+		}
+	}
+	if done, err := writePreview(w, d, secret, s.String(), dryRun); done || err != nil {
+		return err
+	}
+
+	if err := s.write(ctx, changed, current); err != nil {
+		return err
+	}
+
+	secrets := 0
+	for _, e := range changed {
+		if e.secret {
+			secrets++
+		}
+	}
+	switch {
+	case secrets == len(changed):
+		_, err = fmt.Fprintf(w, "%d secret(s) written to %s\n", secrets, s)
+	case secrets == 0:
+		_, err = fmt.Fprintf(w, "%d variable(s) written to %s\n", len(changed), s)
+	default:
+		_, err = fmt.Fprintf(w, "%d secret(s) and %d variable(s) written to %s\n", secrets, len(changed)-secrets, s)
+	}
+	return err
+}
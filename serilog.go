@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// serilogModes are the accepted -serilog values, the empty default leaving the section alone
+var serilogModes = []string{"", "json", "check"}
+
+// serilogLists are the Serilog sections whose elements are found by their Name
+var serilogLists = []string{"WriteTo", "Enrich", "AuditTo", "Filter", "Destructure"}
+
+// serilogSection splits the variables of the Serilog section from the others. The
+// section variables are keyed relative to it, and name is the section key as written.
+func serilogSection(vars map[string]string, sep string) (section, others map[string]string, name string) {
+	section, others = make(map[string]string), make(map[string]string)
+	for k, v := range vars {
+		first, rest, ok := strings.Cut(k, sep)
+		if !ok || !strings.EqualFold(first, "Serilog") {
+			others[k] = v
+			continue
+		}
+		if name == "" || first < name {
+			name = first
+		}
+		section[rest] = v
+	}
+	return section, others, name
+}
+
+// serilogJSON implements -serilog json: the variables of the Serilog section, keyed
+// by canonical ":" keys, are replaced by a single Serilog variable holding the section
+// as JSON, so that reordering WriteTo or Enrich elements cannot leave stale indexed
+// variables behind.
+func serilogJSON(vars map[string]string) map[string]string {
+	section, out, name := serilogSection(vars, keySep)
+	if len(section) == 0 {
+		return vars
+	}
+
+	tree, conflicts := buildTree(section, keySep, func(k, v string) bool { return isLiteral(name+keySep+k, v) })
+	for _, c := range conflicts {
+		warnf("%s%s%s is both a value and a section, its value is left out of the Serilog JSON", name, keySep, c)
+	}
+	if _, ok := out[name]; ok {
+		warnf("%s is both a value and a section, the value is replaced by the Serilog JSON", name)
+	}
+	out[name] = appsettingsenv.EncodeJSON(tree)
+	return out
+}
+
+// serilogProblems implements -serilog check on variables keyed by sep, after
+// filtering: the arrays of the Serilog section must hold the indices 0 to n-1, and
+// the elements of its sink and enricher lists must keep their Name.
+func serilogProblems(vars map[string]string, sep string) []string {
+	section, _, name := serilogSection(vars, sep)
+	if len(section) == 0 {
+		return nil
+	}
+	tree, _ := buildTree(section, sep, nil)
+
+	var problems []string
+	// list is set for the sink and enricher lists
+	var walk func(node any, path string, list bool)
+	walk = func(node any, path string, list bool) {
+		var children map[string]any
+		switch n := node.(type) {
+		case []any:
+			children = make(map[string]any, len(n))
+			for i, item := range n {
+				children[strconv.Itoa(i)] = item
+			}
+		case map[string]any:
+			children = n
+			// Objects holding only indices are arrays with missing elements
+			if indices := arrayIndices(n); len(indices) > 0 && len(indices) == len(n) {
+				var missing []string
+				for i := 0; i < indices[len(indices)-1]; i++ {
+					if !slices.Contains(indices, i) {
+						missing = append(missing, strconv.Itoa(i))
+					}
+				}
+				problems = append(problems, fmt.Sprintf("%s: missing array index %s, indices must run from 0 without gaps", path, strings.Join(missing, ", ")))
+			}
+		default:
+			return
+		}
+
+		keys := make(map[string]string, len(children))
+		for k := range children {
+			keys[k] = ""
+		}
+		for _, k := range appsettingsenv.SortedKeys(keys) {
+			child := children[k]
+			if m, ok := child.(map[string]any); ok && list && !hasKeyFold(m, "Name") {
+				problems = append(problems, fmt.Sprintf("%s%s%s: element has no Name, which Serilog needs to find what to configure", path, sep, k))
+			}
+			walk(child, path+sep+k, path == name && slices.ContainsFunc(serilogLists, func(s string) bool { return strings.EqualFold(s, k) }))
+		}
+	}
+	walk(tree, name, false)
+	return problems
+}
+
+// hasKeyFold reports whether m has the key, ignoring case
+func hasKeyFold(m map[string]any, key string) bool {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
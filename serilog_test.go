@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSerilogJSON(t *testing.T) {
+	defer clear(literals)
+	recordLiteral("Serilog:WriteTo:1:Args:fileSizeLimitBytes", "1000")
+
+	vars := map[string]string{
+		"Serilog:Using:0":                           "Serilog.Sinks.Console",
+		"Serilog:WriteTo:0:Name":                    "Console",
+		"Serilog:WriteTo:1:Name":                    "File",
+		"Serilog:WriteTo:1:Args:fileSizeLimitBytes": "1000",
+		"Serilog:MinimumLevel:Override:System":      "Warning",
+		"SerilogExtras:Enabled":                     "true",
+	}
+	got := serilogJSON(vars)
+	want := `{"MinimumLevel":{"Override":{"System":"Warning"}},"Using":["Serilog.Sinks.Console"],` +
+		`"WriteTo":[{"Name":"Console"},{"Args":{"fileSizeLimitBytes":1000},"Name":"File"}]}`
+	if len(got) != 2 || got["Serilog"] != want || got["SerilogExtras:Enabled"] != "true" {
+		t.Fatalf("unexpected variables: %v", got)
+	}
+
+	if got := serilogJSON(map[string]string{"A": "1"}); len(got) != 1 || got["A"] != "1" {
+		t.Fatalf("variables without Serilog should be unchanged: %v", got)
+	}
+}
+
+func TestSerilogProblems(t *testing.T) {
+	vars := map[string]string{
+		"Serilog__WriteTo__0__Name":            "Console",
+		"Serilog__WriteTo__2__Args__serverUrl": "http://seq",
+		"Serilog__WriteTo__3__Name":            "File",
+		"Serilog__Enrich__0":                   "FromLogContext",
+		"Serilog__Properties__Tags__1":         "b",
+		"Serilog__MinimumLevel__Default":       "Information",
+	}
+	got := strings.Join(serilogProblems(vars, "__"), "\n")
+	want := "Serilog__Properties__Tags: missing array index 0, indices must run from 0 without gaps\n" +
+		"Serilog__WriteTo: missing array index 1, indices must run from 0 without gaps\n" +
+		"Serilog__WriteTo__2: element has no Name, which Serilog needs to find what to configure"
+	if got != want {
+		t.Fatalf("unexpected problems:\n%s\nwant:\n%s", got, want)
+	}
+
+	vars = map[string]string{"Serilog__WriteTo__0__Name": "Console", "Serilog__WriteTo__1__Name": "File"}
+	if got := serilogProblems(vars, "__"); len(got) != 0 {
+		t.Fatalf("unexpected problems: %v", got)
+	}
+}
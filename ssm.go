@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// ssmAPI is the part of the SSM client used by push ssm
+type ssmAPI interface {
+	ssm.GetParametersByPathAPIClient
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	AddTagsToResource(ctx context.Context, params *ssm.AddTagsToResourceInput, optFns ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error)
+}
+
+// ssmParameter is a parameter to write
+type ssmParameter struct {
+	name   string
+	value  string
+	secure bool
+	// exists is set when the parameter is already in the store
+	exists bool
+}
+
+// runPushSSM implements push ssm. It writes every variable as a parameter under
+// -path, the -secret-keys as SecureString, after printing what changes.
+func runPushSSM(args []string) int {
+	prefix := flag.String("path", "", "Path prefix of the parameters, e.g. /myapp/production (required)")
+	region := flag.String("region", "", "AWS region (default: from the AWS configuration)")
+	kmsKey := flag.String("kms-key-id", "", "KMS key encrypting the SecureString parameters (default: the account's aws/ssm key)")
+	batch := flag.Int("batch-size", 10, "Number of parameters written concurrently")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+	var tags stringList
+	flag.Var(&tags, "tag", "Tag applied to the written parameters as key=value (repeatable)")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push ssm [flags]:\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Keys map to parameter names with / as separator, as read by the")
+		fmt.Fprintln(flag.CommandLine.Output(), "Amazon.Extensions.Configuration.SystemsManager provider. Credentials and region")
+		fmt.Fprintln(flag.CommandLine.Output(), "come from the AWS SDK default chain (AWS_* variables, profiles, SSO, roles).")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+
+	// The appconfig type keeps ":" keys without name rules
+	r, code := renderOutput("push ssm", "appconfig", args)
+	if r == nil {
+		return code
+	}
+
+	if !strings.HasPrefix(*prefix, "/") {
+		errorf("-path is required and must start with /")
+		return exitUsage
+	}
+	if *batch < 1 {
+		errorf("-batch-size must be at least 1")
+		return exitUsage
+	}
+	ssmTags, err := parseTags(tags)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	var opts []func(*config.LoadOptions) error
+	if *region != "" {
+		opts = append(opts, config.WithRegion(*region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		logError(fmt.Errorf("aws configuration: %w", err))
+		return exitUsage
+	}
+
+	p := &ssmPush{client: ssm.NewFromConfig(cfg), prefix: *prefix, kmsKey: *kmsKey, tags: ssmTags, batch: *batch}
+	if err := p.push(ctx, os.Stdout, r, *dryRun); err != nil {
+		logError(err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// parseTags parses key=value pairs
+func parseTags(pairs []string) ([]types.Tag, error) {
+	tags := make([]types.Tag, 0, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid -tag %q: expected key=value", pair)
+		}
+		tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return tags, nil
+}
+
+// ssmPush writes variables to Parameter Store
+type ssmPush struct {
+	client ssmAPI
+	prefix string
+	kmsKey string
+	tags   []types.Tag
+	batch  int
+}
+
+// parameterName returns the parameter of variable name: the key segments become
+// path segments under the prefix
+func (p *ssmPush) parameterName(name string) string {
+	return path.Join(p.prefix, strings.ReplaceAll(name, keySep, "/"))
+}
+
+func (p *ssmPush) push(ctx context.Context, w io.Writer, r *rendered, dryRun bool) error {
+	current, err := p.existing(ctx)
+	if err != nil {
+		return err
+	}
+
+	var params []ssmParameter
+	wanted := make(map[string]string, len(r.named))
+	secret := make(map[string]bool)
+	for _, kv := range appsettingsenv.Sorted(r.named) {
+		// Parameter Store rejects empty values
+		if kv.Value == "" {
+			warnf("%s: empty value, parameter skipped", kv.Name)
+			continue
+		}
+		name := p.parameterName(kv.Name)
+		wanted[name] = kv.Value
+		secret[name] = r.secret[kv.Name]
+
+		old, exists := current[name]
+		if !exists || old != kv.Value {
+			params = append(params, ssmParameter{name: name, value: kv.Value, secure: r.secret[kv.Name], exists: exists})
+		}
+	}
+
+	// Parameters of the path that the configuration does not define are kept
+	d := diffVariables(current, wanted)
+	clear(d.Removed)
+	if done, err := writePreview(w, d, secret, "parameters under "+p.prefix, dryRun); done || err != nil {
+		return err
+	}
+
+	var errs []error
+	for start := 0; start < len(params); start += p.batch {
+		batch := params[start:min(start+p.batch, len(params))]
+		batchErrs := make([]error, len(batch))
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				batchErrs[i] = p.put(ctx, batch[i])
+			}()
+		}
+		wg.Wait()
+		errs = append(errs, batchErrs...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%d parameter(s) written under %s\n", len(params), p.prefix)
+	return err
+}
+
+// existing returns the decrypted values of the parameters under the prefix
+func (p *ssmPush) existing(ctx context.Context) (map[string]string, error) {
+	values := make(map[string]string)
+	pages := ssm.NewGetParametersByPathPaginator(p.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(p.prefix),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("get parameters under %s: %w", p.prefix, err)
+		}
+		for _, param := range page.Parameters {
+			values[aws.ToString(param.Name)] = aws.ToString(param.Value)
+		}
+	}
+	return values, nil
+}
+
+// put writes one parameter. Tags cannot be given when overwriting, so existing
+// parameters are tagged separately.
+func (p *ssmPush) put(ctx context.Context, param ssmParameter) error {
+	in := &ssm.PutParameterInput{
+		Name:      aws.String(param.name),
+		Value:     aws.String(param.value),
+		Type:      types.ParameterTypeString,
+		Overwrite: aws.Bool(param.exists),
+	}
+	if param.secure {
+		in.Type = types.ParameterTypeSecureString
+		if p.kmsKey != "" {
+			in.KeyId = aws.String(p.kmsKey)
+		}
+	}
+	if !param.exists && len(p.tags) > 0 {
+		in.Tags = p.tags
+	}
+
+	if _, err := p.client.PutParameter(ctx, in); err != nil {
+		return fmt.Errorf("put parameter %s: %w", param.name, err)
+	}
+
+	if param.exists && len(p.tags) > 0 {
+		_, err := p.client.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+			ResourceType: types.ResourceTypeForTaggingParameter,
+			ResourceId:   aws.String(param.name),
+			Tags:         p.tags,
+		})
+		if err != nil {
+			return fmt.Errorf("tag parameter %s: %w", param.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// fakeSSM is an in-memory Parameter Store
+type fakeSSM struct {
+	mu     sync.Mutex
+	params map[string]types.Parameter
+	tags   map[string][]types.Tag
+}
+
+func (f *fakeSSM) GetParametersByPath(_ context.Context, in *ssm.GetParametersByPathInput, _ ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	out := &ssm.GetParametersByPathOutput{}
+	for name, p := range f.params {
+		if strings.HasPrefix(name, aws.ToString(in.Path)+"/") {
+			out.Parameters = append(out.Parameters, p)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSSM) PutParameter(_ context.Context, in *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := aws.ToString(in.Name)
+	if _, ok := f.params[name]; ok && !aws.ToBool(in.Overwrite) {
+		return nil, &types.ParameterAlreadyExists{}
+	}
+	if aws.ToBool(in.Overwrite) && len(in.Tags) > 0 {
+		return nil, &types.InvalidParameters{}
+	}
+	f.params[name] = types.Parameter{Name: in.Name, Value: in.Value, Type: in.Type}
+	if len(in.Tags) > 0 {
+		f.tags[name] = in.Tags
+	}
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeSSM) AddTagsToResource(_ context.Context, in *ssm.AddTagsToResourceInput, _ ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tags[aws.ToString(in.ResourceId)] = in.Tags
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func TestSSMPush(t *testing.T) {
+	fake := &fakeSSM{
+		params: map[string]types.Parameter{
+			"/api/prod/Logging/Level": {Name: aws.String("/api/prod/Logging/Level"), Value: aws.String("Information")},
+			"/api/prod/Unchanged":     {Name: aws.String("/api/prod/Unchanged"), Value: aws.String("same")},
+			"/api/prod/Other":         {Name: aws.String("/api/prod/Other"), Value: aws.String("kept")},
+		},
+		tags: map[string][]types.Tag{},
+	}
+	tags, err := parseTags([]string{"team=web"})
+	if err != nil {
+		t.Fatalf("parseTags: %v", err)
+	}
+	p := &ssmPush{client: fake, prefix: "/api/prod", tags: tags, batch: 2}
+
+	r := &rendered{
+		named:  map[string]string{"Logging:Level": "Warning", "Unchanged": "same", "Db:Password": "s3cret", "Empty": ""},
+		secret: map[string]bool{"Db:Password": true},
+	}
+
+	var out bytes.Buffer
+	if err := p.push(context.Background(), &out, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "added   /api/prod/Db/Password: \"(secret)\"\n" +
+		"changed /api/prod/Logging/Level: \"Information\" -> \"Warning\"\n"
+	if out.String() != want {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+	if aws.ToString(fake.params["/api/prod/Logging/Level"].Value) != "Information" {
+		t.Fatalf("dry run should not write")
+	}
+
+	out.Reset()
+	if err := p.push(context.Background(), &out, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2 parameter(s) written under /api/prod\n") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+
+	secret := fake.params["/api/prod/Db/Password"]
+	if secret.Type != types.ParameterTypeSecureString || aws.ToString(secret.Value) != "s3cret" {
+		t.Fatalf("secret should be a SecureString: %+v", secret)
+	}
+	if fake.params["/api/prod/Logging/Level"].Type != types.ParameterTypeString {
+		t.Fatalf("plain values should be String parameters")
+	}
+	if _, ok := fake.params["/api/prod/Empty"]; ok {
+		t.Fatalf("empty values should be skipped")
+	}
+	for _, name := range []string{"/api/prod/Db/Password", "/api/prod/Logging/Level"} {
+		if len(fake.tags[name]) != 1 {
+			t.Fatalf("%s should be tagged: %v", name, fake.tags)
+		}
+	}
+	if _, ok := fake.tags["/api/prod/Unchanged"]; ok {
+		t.Fatalf("unchanged parameters should not be written")
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	if _, err := parseTags([]string{"=x"}); err == nil {
+		t.Fatalf("empty tag key should fail")
+	}
+	tags, err := parseTags([]string{"env=prod=1"})
+	if err != nil || aws.ToString(tags[0].Value) != "prod=1" {
+		t.Fatalf("unexpected tags: %v, %v", tags, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// summary holds the statistics of a conversion printed by -summary
+type summary struct {
+	Files int `json:"files"`
+	// Variables is the number of variables emitted by output type
+	Variables        map[string]int `json:"variables"`
+	MaxDepth         int            `json:"maxDepth"`
+	Bytes            int            `json:"bytes"`
+	EnvBytes         int            `json:"envBytes"`
+	SuspectedSecrets int            `json:"suspectedSecrets"`
+	Warnings         int            `json:"warnings"`
+}
+
+// newSummary computes the statistics of the variables loaded from layers and the
+// outputs rendered from them. Suspected secrets are the keys emitted by the first
+// output that match the built-in -redact patterns, and EnvBytes is the largest
+// environment block of the environment outputs.
+func newSummary(layers []layer, variables map[string]string, c *converter, outputs []*rendered) (*summary, error) {
+	s := &summary{Variables: make(map[string]int, len(outputs)), Warnings: warnings}
+	for _, l := range layers {
+		if l.source != "environment" && l.source != "-set" {
+			s.Files++
+		}
+	}
+	for k := range variables {
+		s.MaxDepth = max(s.MaxDepth, strings.Count(k, keySep)+1)
+	}
+	for _, r := range outputs {
+		s.Variables[r.outType] = len(r.named)
+		s.Bytes += len(r.output) + len(r.secrets)
+		if envTargets[r.outType] {
+			s.EnvBytes = max(s.EnvBytes, envSize(appsettingsenv.Sorted(r.named)))
+		}
+	}
+
+	suspect, err := newKeyFilter(defaultRedactPatterns, nil, c.sep)
+	if err != nil {
+		return nil, err
+	}
+	for k := range c.filter.apply(withSeparator(variables, c.sep)) {
+		if suspect.match(k) {
+			s.SuspectedSecrets++
+		}
+	}
+	return s, nil
+}
+
+// write prints the summary as a table, or as a JSON object for format json
+func (s *summary) write(w io.Writer, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(s)
+	}
+
+	var counts []string
+	for _, t := range slices.Sorted(maps.Keys(s.Variables)) {
+		counts = append(counts, fmt.Sprintf("%s=%d", t, s.Variables[t]))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "summary:")
+	fmt.Fprintf(tw, "  files processed\t%d\n", s.Files)
+	fmt.Fprintf(tw, "  variables emitted\t%s\n", strings.Join(counts, " "))
+	fmt.Fprintf(tw, "  max depth\t%d\n", s.MaxDepth)
+	fmt.Fprintf(tw, "  payload bytes\t%d\n", s.Bytes)
+	fmt.Fprintf(tw, "  environment bytes\t%d\n", s.EnvBytes)
+	fmt.Fprintf(tw, "  suspected secrets\t%d\n", s.SuspectedSecrets)
+	fmt.Fprintf(tw, "  warnings\t%d\n", s.Warnings)
+	return tw.Flush()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	variables := map[string]string{"Api:Url": "http://api", "Db:Password": "s3cret", "Logging:LogLevel:Default": "Debug"}
+	layers := []layer{
+		{source: "appsettings.json", vars: variables},
+		{source: "appsettings.Production.json"},
+		{source: "-set"},
+	}
+
+	c, err := newConverter("docker", nil)
+	if err != nil {
+		t.Fatalf("newConverter failed: %v", err)
+	}
+	r, err := c.render(variables)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	s, err := newSummary(layers, variables, c, []*rendered{r})
+	if err != nil {
+		t.Fatalf("newSummary failed: %v", err)
+	}
+	if s.Files != 2 || s.Variables["docker"] != 3 || s.MaxDepth != 3 || s.Bytes != len(r.output) || s.SuspectedSecrets != 1 ||
+		s.EnvBytes != len("Api__Url=http://api\x00Db__Password=s3cret\x00Logging__LogLevel__Default=Debug\x00") {
+		t.Fatalf("unexpected summary: %+v", s)
+	}
+
+	var buf bytes.Buffer
+	if err := s.write(&buf, "json"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"variables":{"docker":3}`) || !strings.Contains(buf.String(), `"suspectedSecrets":1`) {
+		t.Fatalf("unexpected JSON summary: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := s.write(&buf, "text"); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "variables emitted  docker=3\n") {
+		t.Fatalf("unexpected text summary:\n%s", buf.String())
+	}
+}
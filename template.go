@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// templateOptions configure generateTemplate
+type templateOptions struct {
+	// sep joins the segments of the placeholder names
+	sep string
+	// blank replaces the values by empty strings instead of placeholders
+	blank bool
+	// only, when set, selects the keys whose value is replaced, the others are kept
+	only   func(key string) bool
+	indent int
+}
+
+// generateTemplate writes tree as an appsettings.json document whose values are
+// replaced by ${NAME} placeholders, NAME being the environment variable of the key,
+// or by empty strings. Keys follow the order of the files when it was recorded, and
+// otherwise sort by name.
+func generateTemplate(tree map[string]any, o templateOptions) []byte {
+	var b bytes.Buffer
+	writeTemplate(&b, tree, "", 0, o)
+	b.WriteByte('\n')
+	return b.Bytes()
+}
+
+// writeTemplate writes the value of key at the given depth
+func writeTemplate(b *bytes.Buffer, value any, key string, depth int, o templateOptions) {
+	open := func(c byte, n int) bool {
+		b.WriteByte(c)
+		return n > 0
+	}
+	newline := func(depth int) {
+		b.WriteByte('\n')
+		b.WriteString(strings.Repeat(" ", depth*o.indent))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		keys := templateOrder(v, key)
+		if open('{', len(keys)) {
+			for i, k := range keys {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				newline(depth + 1)
+				b.Write(jsonValue(k))
+				b.WriteString(": ")
+				writeTemplate(b, v[k], childKey(key, k), depth+1, o)
+			}
+			newline(depth)
+		}
+		b.WriteByte('}')
+	case []any:
+		if open('[', len(v)) {
+			for i, e := range v {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				newline(depth + 1)
+				writeTemplate(b, e, childKey(key, strconv.Itoa(i)), depth+1, o)
+			}
+			newline(depth)
+		}
+		b.WriteByte(']')
+	default:
+		switch {
+		case o.only != nil && !o.only(key):
+			b.Write(jsonValue(v))
+		case o.blank:
+			b.WriteString(`""`)
+		default:
+			b.Write(jsonValue("${" + strings.ReplaceAll(key, keySep, o.sep) + "}"))
+		}
+	}
+}
+
+// childKey returns the key of segment in the section at key, the root when empty
+func childKey(key, segment string) string {
+	if key == "" {
+		return segment
+	}
+	return key + keySep + segment
+}
+
+// jsonValue encodes v as JSON without escaping HTML characters
+func jsonValue(v any) []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// templateOrder returns the keys of the section at key in the order the files list
+// them, each one at the rank of its first value, then by name
+func templateOrder(section map[string]any, key string) []string {
+	keys := sortedFold(section)
+	ranks := make(map[string]int, len(keys))
+	for _, k := range keys {
+		ranks[k] = firstRank(section[k], childKey(key, k))
+	}
+	slices.SortStableFunc(keys, func(a, b string) int { return cmp.Compare(ranks[a], ranks[b]) })
+	return keys
+}
+
+// firstRank returns the lowest source rank of the values at or under key
+func firstRank(value any, key string) int {
+	switch v := value.(type) {
+	case map[string]any:
+		rank := math.MaxInt
+		for k, c := range v {
+			rank = min(rank, firstRank(c, childKey(key, k)))
+		}
+		return rank
+	case []any:
+		rank := math.MaxInt
+		for i, c := range v {
+			rank = min(rank, firstRank(c, childKey(key, strconv.Itoa(i))))
+		}
+		return rank
+	}
+	if rank, ok := sourceRank(key); ok {
+		return rank
+	}
+	return math.MaxInt
+}
+
+// runGenTemplate implements gen template
+func runGenTemplate(args []string) int {
+	blank := flag.Bool("blank", false, "Replace the values with empty strings instead of ${NAME} placeholders")
+	secretsOnly := flag.Bool("secrets-only", false, "Only replace the values of secret keys (see -redact-keys), keeping the others")
+
+	// The template follows the order of the files unless -sort says otherwise
+	*sortMode = "source"
+	tree, _, code := loadTree("gen template", args)
+	if tree == nil {
+		return code
+	}
+
+	o := templateOptions{sep: *separator, blank: *blank, indent: *indent}
+	if o.sep == "" {
+		o.sep = "__"
+	}
+	if o.indent == 0 {
+		o.indent = 2
+	}
+	if *secretsOnly {
+		secret, err := newRedactFilter(true, redactKeys, keySep)
+		if err != nil {
+			logError(err)
+			return exitUsage
+		}
+		o.only = secret.match
+	}
+
+	out := generateTemplate(tree, o)
+	if *newline == "crlf" {
+		out = crlf(out)
+	}
+	return writeGenerated(out)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerateTemplate(t *testing.T) {
+	clear(sourceOrder)
+	defer clear(sourceOrder)
+	for _, k := range []string{"Logging:Level", "Db:Password", "Api:Hosts:0", "Api:Hosts:1", "Api:Timeout"} {
+		recordSource(k)
+	}
+	tree := map[string]any{
+		"Api":     map[string]any{"Timeout": json.Number("30"), "Hosts": []any{"a", map[string]any{"Url": "<b>"}}},
+		"Db":      map[string]any{"Password": "s3cret"},
+		"Logging": map[string]any{"Level": "Information"},
+		"Empty":   map[string]any{},
+	}
+
+	got := string(generateTemplate(tree, templateOptions{sep: "__", indent: 2}))
+	want := `{
+  "Logging": {
+    "Level": "${Logging__Level}"
+  },
+  "Db": {
+    "Password": "${Db__Password}"
+  },
+  "Api": {
+    "Hosts": [
+      "${Api__Hosts__0}",
+      {
+        "Url": "${Api__Hosts__1__Url}"
+      }
+    ],
+    "Timeout": "${Api__Timeout}"
+  },
+  "Empty": {}
+}
+`
+	if got != want {
+		t.Fatalf("unexpected template:\n%s\nwant:\n%s", got, want)
+	}
+
+	secret := func(key string) bool { return strings.HasSuffix(key, "Password") }
+	got = string(generateTemplate(tree, templateOptions{sep: ":", indent: 1, only: secret}))
+	if !strings.Contains(got, `"Password": "${Db:Password}"`) || !strings.Contains(got, `"Timeout": 30`) || !strings.Contains(got, `"Url": "<b>"`) {
+		t.Fatalf("only secret values should be replaced:\n%s", got)
+	}
+
+	got = string(generateTemplate(tree, templateOptions{blank: true, indent: 2, only: secret}))
+	if !strings.Contains(got, `"Password": ""`) || !strings.Contains(got, `"Level": "Information"`) {
+		t.Fatalf("secret values should be blank:\n%s", got)
+	}
+}
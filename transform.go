@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// transform rewrites variables before they are named and formatted
+type transform interface {
+	apply(vars map[string]string) (map[string]string, error)
+}
+
+// valueTransform applies fn to the values of the keys matching pattern
+type valueTransform struct {
+	pattern *regexp.Regexp
+	fn      func(string) string
+}
+
+func (t valueTransform) apply(vars map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(vars))
+	for k, v := range vars {
+		if t.pattern == nil || t.pattern.MatchString(k) {
+			v = t.fn(v)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// commandTransform pipes every variable through an external command. The command
+// reads a JSON object of keys (":" notation) and values on stdin and prints the
+// resulting object, which replaces the variables.
+type commandTransform struct {
+	args []string
+}
+
+func (t commandTransform) apply(vars map[string]string) (map[string]string, error) {
+	in, err := json.Marshal(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(t.args[0], t.args[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("transform %s: %w", t.args[0], err)
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("transform %s: output is not a JSON object of strings: %w", t.args[0], err)
+	}
+	return result, nil
+}
+
+// transformFuncs are the functions of the -transform expression language
+var transformFuncs = map[string]func(args []string) (func(string) string, error){
+	"base64": noArgs(func(v string) string { return base64.StdEncoding.EncodeToString([]byte(v)) }),
+	"upper":  noArgs(strings.ToUpper),
+	"lower":  noArgs(strings.ToLower),
+	"trim":   noArgs(strings.TrimSpace),
+	"prefix": oneArg(func(arg, v string) string { return arg + v }),
+	"suffix": oneArg(func(arg, v string) string { return v + arg }),
+	"replace": func(args []string) (func(string) string, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("replace expects 2 arguments, got %d", len(args))
+		}
+		return func(v string) string { return strings.ReplaceAll(v, args[0], args[1]) }, nil
+	},
+	"regex": func(args []string) (func(string) string, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("regex expects 2 arguments, got %d", len(args))
+		}
+		re, err := regexp.Compile(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(v string) string { return re.ReplaceAllString(v, args[1]) }, nil
+	},
+}
+
+func noArgs(fn func(string) string) func([]string) (func(string) string, error) {
+	return func(args []string) (func(string) string, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("expects no arguments, got %d", len(args))
+		}
+		return fn, nil
+	}
+}
+
+func oneArg(fn func(arg, v string) string) func([]string) (func(string) string, error) {
+	return func(args []string) (func(string) string, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expects 1 argument, got %d", len(args))
+		}
+		return func(v string) string { return fn(args[0], v) }, nil
+	}
+}
+
+// parseTransform parses a -transform value: "cmd:<command> [args]" runs an external
+// command, otherwise "[<pattern>=]<func>[(<args>)]" applies a function to the values
+// of the keys matching the filter pattern, or to every value without a pattern.
+func parseTransform(spec string) (transform, error) {
+	if command, ok := strings.CutPrefix(spec, "cmd:"); ok {
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("invalid transform %q: missing command", spec)
+		}
+		return commandTransform{args: args}, nil
+	}
+
+	var t valueTransform
+	expr := spec
+	if eq := strings.Index(spec, "="); eq >= 0 && (strings.Index(spec, "(") < 0 || eq < strings.Index(spec, "(")) {
+		re, err := compilePattern(spec[:eq], keySep)
+		if err != nil {
+			return nil, err
+		}
+		t.pattern, expr = re, spec[eq+1:]
+	}
+
+	name, args := expr, []string(nil)
+	if open := strings.Index(expr, "("); open >= 0 {
+		if !strings.HasSuffix(expr, ")") {
+			return nil, fmt.Errorf("invalid transform %q: missing )", spec)
+		}
+		name, args = expr[:open], splitArgs(expr[open+1:len(expr)-1])
+	}
+
+	build, ok := transformFuncs[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("invalid transform %q: unknown function %q", spec, name)
+	}
+	fn, err := build(args)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform %q: %s %w", spec, name, err)
+	}
+	t.fn = fn
+	return t, nil
+}
+
+// splitArgs splits comma-separated arguments, "\," standing for a literal comma
+func splitArgs(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var args []string
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == ',':
+			b.WriteByte(',')
+			i++
+		case s[i] == ',':
+			args = append(args, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return append(args, b.String())
+}
+
+// parseTransforms parses every -transform value
+func parseTransforms(specs []string) ([]transform, error) {
+	out := make([]transform, 0, len(specs))
+	for _, spec := range specs {
+		t, err := parseTransform(spec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// applyTransforms applies transforms in order
+func applyTransforms(vars map[string]string, transforms []transform) (map[string]string, error) {
+	for _, t := range transforms {
+		var err error
+		if vars, err = t.apply(vars); err != nil {
+			return nil, err
+		}
+	}
+	return vars, nil
+}
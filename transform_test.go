@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestValueTransforms(t *testing.T) {
+	vars := map[string]string{
+		"ConnectionStrings:Default": "Server=localhost;Database=app",
+		"Secrets:Token":             "abc",
+		"Name":                      " app ",
+	}
+
+	transforms, err := parseTransforms([]string{
+		"ConnectionStrings:*=replace(localhost,db.internal)",
+		"Secrets__*=base64",
+		"Name=trim",
+		"Name=suffix(\\,v2)",
+	})
+	if err != nil {
+		t.Fatalf("parseTransforms failed: %v", err)
+	}
+
+	got, err := applyTransforms(vars, transforms)
+	if err != nil {
+		t.Fatalf("applyTransforms failed: %v", err)
+	}
+	want := map[string]string{
+		"ConnectionStrings:Default": "Server=db.internal;Database=app",
+		"Secrets:Token":             "YWJj",
+		"Name":                      "app,v2",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("key %q want %q got %q", k, v, got[k])
+		}
+	}
+
+	for _, spec := range []string{"unknown", "replace(a)", "upper(x)", "regex([,x)", "cmd:", "prefix(x"} {
+		if _, err := parseTransform(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestCommandTransform(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+
+	script := filepath.Join(t.TempDir(), "transform.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nsed 's/localhost/db.internal/g'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := parseTransform("cmd:" + script)
+	if err != nil {
+		t.Fatalf("parseTransform failed: %v", err)
+	}
+	got, err := tr.apply(map[string]string{"Db:Host": "localhost"})
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+	if got["Db:Host"] != "db.internal" {
+		t.Fatalf("unexpected output: %v", got)
+	}
+}
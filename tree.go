@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// buildTree rebuilds the nested configuration of flattened variables whose keys are
+// joined by sep, as the .NET configuration binder sees it. Objects whose children
+// are the indices 0 to n-1 become arrays, and the values literal reports as JSON
+// literals become numbers, booleans and nulls. Sections match case-insensitively.
+// conflicts lists the keys that are both a value and a section, whose value is
+// dropped.
+func buildTree(vars map[string]string, sep string, literal func(key, value string) bool) (tree map[string]any, conflicts []string) {
+	tree = make(map[string]any)
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		segments := strings.Split(k, sep)
+		node := tree
+		for i, s := range segments[:len(segments)-1] {
+			s = foldKey(node, s)
+			child, ok := node[s].(map[string]any)
+			if !ok {
+				if _, isValue := node[s]; isValue {
+					conflicts = append(conflicts, strings.Join(segments[:i+1], sep))
+				}
+				child = make(map[string]any)
+				node[s] = child
+			}
+			node = child
+		}
+
+		last := foldKey(node, segments[len(segments)-1])
+		if _, isSection := node[last].(map[string]any); isSection {
+			conflicts = append(conflicts, k)
+			continue
+		}
+		node[last] = treeValue(vars[k], literal != nil && literal(k, vars[k]))
+	}
+	return toArrays(tree).(map[string]any), conflicts
+}
+
+// foldKey returns the key of node matching key case-insensitively, as sections
+// match in .NET configuration, or key itself
+func foldKey(node map[string]any, key string) string {
+	if _, ok := node[key]; ok {
+		return key
+	}
+	for k := range node {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+	return key
+}
+
+// treeValue returns value as a JSON value, decoding it when it was a JSON literal
+func treeValue(value string, literal bool) any {
+	if !literal {
+		return value
+	}
+	switch value {
+	case "null":
+		return nil
+	case "true", "false":
+		return value == "true"
+	}
+	return json.Number(value)
+}
+
+// toArrays replaces the objects of node whose keys are the indices 0 to n-1 by arrays
+func toArrays(node any) any {
+	m, ok := node.(map[string]any)
+	if !ok {
+		return node
+	}
+	for k, v := range m {
+		m[k] = toArrays(v)
+	}
+
+	indices := arrayIndices(m)
+	if len(m) == 0 || len(indices) != len(m) || indices[len(indices)-1] != len(m)-1 {
+		return m
+	}
+	items := make([]any, len(m))
+	for k, v := range m {
+		idx, _ := strconv.Atoi(k)
+		items[idx] = v
+	}
+	return items
+}
+
+// arrayIndices returns the keys of m that are array indices, in order. Zero-padded
+// indices, as written by -pad-index, count.
+func arrayIndices(m map[string]any) []int {
+	var indices []int
+	for k := range m {
+		if k == "" || strings.Trim(k, "0123456789") != "" {
+			continue
+		}
+		idx, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		indices = append(indices, idx)
+	}
+	slices.Sort(indices)
+	return slices.Compact(indices)
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestBuildTree(t *testing.T) {
+	vars := map[string]string{"A:0": "x", "A:1": "y", "B:00": "p", "B:02": "q", "C": "v", "C:D": "w", "E": "true"}
+	tree, conflicts := buildTree(vars, ":", func(k, v string) bool { return k == "E" })
+	got := appsettingsenv.EncodeJSON(tree)
+	if got != `{"A":["x","y"],"B":{"00":"p","02":"q"},"C":{"D":"w"},"E":true}` || len(conflicts) != 1 || conflicts[0] != "C" {
+		t.Fatalf("unexpected tree: %s %v", got, conflicts)
+	}
+}
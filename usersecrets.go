@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var userSecretsIDPattern = regexp.MustCompile(`<UserSecretsId>\s*([^<\s]+)\s*</UserSecretsId>`)
+
+// loadUserSecrets returns the flattened secrets.json of the project owning the files
+// matched by pattern. A missing UserSecretsId or secrets file is not an error, as the
+// .NET host treats user secrets as optional.
+func loadUserSecrets(id, pattern, sep string) (map[string]string, error) {
+	if id == "" {
+		id = discoverUserSecretsID(pattern)
+		if id == "" {
+			return nil, nil
+		}
+	}
+
+	path, err := userSecretsPath(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fileExists(path) {
+		return nil, nil
+	}
+
+	secrets, err := processFile(path, sep)
+	if err != nil {
+		return nil, err
+	}
+
+	// secrets.json is usually flat with "Section:Key" names
+	out := make(map[string]string, len(secrets))
+	for k, v := range secrets {
+		out[strings.ReplaceAll(k, ":", sep)] = v
+	}
+	return out, nil
+}
+
+// discoverUserSecretsID looks for a UserSecretsId in the project files next to the
+// files matched by pattern
+func discoverUserSecretsID(pattern string) string {
+	files, _ := filepath.Glob(pattern)
+	seen := make(map[string]bool)
+	for _, f := range files {
+		dir := filepath.Dir(f)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+
+		projects, _ := filepath.Glob(filepath.Join(dir, "*.*proj"))
+		for _, p := range projects {
+			content, err := os.ReadFile(p)
+			if err != nil {
+				continue
+			}
+			if m := userSecretsIDPattern.FindSubmatch(content); m != nil {
+				return string(m[1])
+			}
+		}
+	}
+	return ""
+}
+
+// userSecretsPath returns the location of secrets.json used by the .NET secret manager
+func userSecretsPath(id string) (string, error) {
+	if strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid UserSecretsId: %q", id)
+	}
+
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", fmt.Errorf("APPDATA is not set")
+		}
+		return filepath.Join(appData, "Microsoft", "UserSecrets", id, "secrets.json"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".microsoft", "usersecrets", id, "secrets.json"), nil
+}
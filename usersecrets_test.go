@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLoadUserSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("secrets location is resolved from APPDATA on windows")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	project := t.TempDir()
+	csproj := `<Project Sdk="Microsoft.NET.Sdk.Web">
+  <PropertyGroup>
+    <UserSecretsId> 7f2c1b9e-demo </UserSecretsId>
+  </PropertyGroup>
+</Project>`
+	if err := os.WriteFile(filepath.Join(project, "Api.csproj"), []byte(csproj), 0o644); err != nil {
+		t.Fatalf("write project: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "appsettings.json"), []byte(`{}`), 0o644); err != nil {
+		t.Fatalf("write appsettings: %v", err)
+	}
+
+	secretsDir := filepath.Join(home, ".microsoft", "usersecrets", "7f2c1b9e-demo")
+	if err := os.MkdirAll(secretsDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	secrets := `{"ConnectionStrings:Default": "Server=dev", "Api": {"Key": "secret"}}`
+	if err := os.WriteFile(filepath.Join(secretsDir, "secrets.json"), []byte(secrets), 0o644); err != nil {
+		t.Fatalf("write secrets: %v", err)
+	}
+
+	vars, err := loadUserSecrets("", filepath.Join(project, "appsettings.json"), "__")
+	if err != nil {
+		t.Fatalf("loadUserSecrets failed: %v", err)
+	}
+
+	if vars["ConnectionStrings__Default"] != "Server=dev" {
+		t.Fatalf("colon key not converted: %v", vars)
+	}
+	if vars["Api__Key"] != "secret" {
+		t.Fatalf("nested key missing: %v", vars)
+	}
+}
+
+func TestLoadUserSecrets_NoProject(t *testing.T) {
+	dir := t.TempDir()
+	vars, err := loadUserSecrets("", filepath.Join(dir, "appsettings.json"), "__")
+	if err != nil || vars != nil {
+		t.Fatalf("expected no secrets without a project, got %v, %v", vars, err)
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// runValidate implements the validate subcommand. It runs the conversion with every
+// check but writes nothing, returning 0 when no problem was reported, 4 when warnings
+// were, and otherwise the code of the error.
+func runValidate(args []string) int {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s validate [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	cv, code := prepareConversion(args)
+	if cv == nil {
+		return code
+	}
+
+	outputs, err := cv.render()
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+
+	if warnings > 0 {
+		errorf("%d problem(s) found", warnings)
+		return exitValidation
+	}
+	for _, r := range outputs {
+		infof("%s: %d variable(s) valid", r.outType, len(r.named))
+	}
+	return exitOK
+}
+
+// Value limits of the output targets
+const (
+	// maxEnvValue is the longest environment variable value Windows accepts, in
+	// UTF-16 code units
+	maxEnvValue = 32767
+	// maxAppConfigValue is the Azure App Configuration key-value size limit, in bytes
+	// of the key and value
+	maxAppConfigValue = 10 * 1024
+	// maxManifestData is the data size limit of a Kubernetes ConfigMap or Secret
+	maxManifestData = 1 << 20
+	// argMax is the usual Linux limit of the arguments and environment of a process,
+	// a quarter of the default 8 MiB stack
+	argMax = 2 << 20
+)
+
+// singleLineTargets are read by tools that cannot represent values spanning several
+// lines: docker run --env-file takes the escaped line breaks of docker output literally
+var singleLineTargets = map[string]bool{"docker": true}
+
+// envTargets end up as process environment variables
+var envTargets = map[string]bool{
+	"k8s": true, "configmap": true, "docker": true, "compose": true, "bicep": true, "launchsettings": true,
+}
+
+// valueProblems lists why the value of name cannot be emitted safely for the output
+// type. Limits are counted in the units of the target, not in characters: a value of
+// non-ASCII text takes up to three bytes per character in App Configuration.
+func valueProblems(outType, name, value string) []string {
+	var problems []string
+	if !utf8.ValidString(value) {
+		problems = append(problems, "contains invalid UTF-8")
+	}
+	if envTargets[outType] && strings.ContainsRune(value, 0) {
+		problems = append(problems, "contains a NUL byte, which environment variables cannot hold")
+	}
+	if singleLineTargets[outType] && strings.ContainsAny(value, "\r\n") {
+		problems = append(problems, "contains a newline, which "+outType+" values cannot hold (see -strip-newlines)")
+	}
+	if envTargets[outType] && utf16Len(value) > maxEnvValue {
+		problems = append(problems, "is longer than the environment variable limit of 32767 UTF-16 characters")
+	}
+	if outType == "appconfig" && len(name)+len(value) > maxAppConfigValue {
+		problems = append(problems, fmt.Sprintf("is larger than the 10KB App Configuration limit with its key (%d bytes)", len(name)+len(value)))
+	}
+	return problems
+}
+
+// validateManifestSizes warns when the ConfigMap, unless split, or the Secret of the
+// configmap output would hold more data than Kubernetes accepts, and returns the
+// number of problems found. secrets tells whether the secret variables go to a Secret.
+func validateManifestSizes(outType string, vars []appsettingsenv.KV, secrets bool) int {
+	if outType != "configmap" {
+		return 0
+	}
+
+	var data, secretData []appsettingsenv.KV
+	for _, v := range vars {
+		if secrets && v.Secret {
+			secretData = append(secretData, v)
+		} else {
+			data = append(data, v)
+		}
+	}
+
+	count := 0
+	if size := appsettingsenv.DataSize(data); size > maxManifestData && !*splitConfigMap {
+		warnf("ConfigMap data is %d bytes, over the 1 MiB Kubernetes limit (see -split)", size)
+		count++
+	}
+	if size := appsettingsenv.DataSize(secretData); size > maxManifestData {
+		warnf("Secret data is %d bytes, over the 1 MiB Kubernetes limit", size)
+		count++
+	}
+	return count
+}
+
+// envSize returns the bytes vars take in the environment block of a process: a
+// NUL-terminated NAME=value string each
+func envSize(vars []appsettingsenv.KV) int {
+	size := 0
+	for _, v := range vars {
+		size += len(v.Name) + len(v.Value) + 2
+	}
+	return size
+}
+
+// validateEnvSize warns when the variables of an environment output take more than
+// budget bytes, approaching the ARG_MAX limit past which the container process fails
+// to start, and returns the number of problems found. A budget of 0 disables it.
+func validateEnvSize(outType string, vars []appsettingsenv.KV, budget int) int {
+	if !envTargets[outType] || budget <= 0 {
+		return 0
+	}
+	size := envSize(vars)
+	if size <= budget {
+		return 0
+	}
+	if size > argMax {
+		warnf("%d variable(s) take %d bytes of environment, over the 2 MiB ARG_MAX of Linux: the process will fail to start", len(vars), size)
+	} else {
+		warnf("%d variable(s) take %d bytes of environment, over the -env-budget of %d bytes (Linux ARG_MAX is usually 2 MiB, shared with the image environment and arguments)", len(vars), size, budget)
+	}
+	return 1
+}
+
+// utf16Len returns the length of s in UTF-16 code units, the unit of Windows string
+// limits
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// validateValues warns about every value unfit for the output type and returns the
+// number of problems found
+func validateValues(outType string, vars []appsettingsenv.KV) int {
+	count := 0
+	for _, v := range vars {
+		for _, p := range valueProblems(outType, v.Name, v.Value) {
+			warnf("%s: value %s", v.Name, p)
+			count++
+		}
+	}
+	return count
+}
+
+// nameRule describes the variable names an output type accepts
+type nameRule struct {
+	description string
+	// char reports whether r is allowed in a name, first whether it may start one
+	char func(r rune, first bool) bool
+}
+
+func isLetter(r rune) bool { return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' }
+func isDigit(r rune) bool  { return r >= '0' && r <= '9' }
+
+var (
+	// identifierRule is the POSIX shell and dotenv rule
+	identifierRule = nameRule{
+		description: "letters, digits and underscores, not starting with a digit",
+		char: func(r rune, first bool) bool {
+			return isLetter(r) || r == '_' || isDigit(r) && !first
+		},
+	}
+
+	// kubeEnvRule is the Kubernetes container env var name rule
+	kubeEnvRule = nameRule{
+		description: "letters, digits, '-', '.' and '_', not starting with a digit",
+		char: func(r rune, first bool) bool {
+			return isLetter(r) || r == '_' || r == '-' || r == '.' || isDigit(r) && !first
+		},
+	}
+
+	// kubeKeyRule is the ConfigMap and Secret data key rule
+	kubeKeyRule = nameRule{
+		description: "letters, digits, '-', '.' and '_'",
+		char: func(r rune, first bool) bool {
+			return isLetter(r) || isDigit(r) || r == '_' || r == '-' || r == '.'
+		},
+	}
+)
+
+// nameRules holds the naming rule of each output type that restricts names
+var nameRules = map[string]nameRule{
+	"k8s":       kubeEnvRule,
+	"configmap": kubeKeyRule,
+	"docker":    identifierRule,
+	"compose":   identifierRule,
+	"bicep":     identifierRule,
+}
+
+// validName reports whether name satisfies rule
+func validName(name string, rule nameRule) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if !rule.char(r, i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// sanitizeName replaces every character rule rejects with an underscore
+func sanitizeName(name string, rule nameRule) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case rule.char(r, i == 0):
+			b.WriteRune(r)
+		case i == 0 && rule.char(r, false):
+			// e.g. a leading digit: keep it behind an underscore
+			b.WriteRune('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// checkName validates name against the rule of the output type. Invalid names are
+// reported, or with -sanitize replaced and recorded in renamed.
+func checkName(outType, name string, renamed map[string]string) string {
+	rule, ok := nameRules[outType]
+	if !ok || validName(name, rule) {
+		return name
+	}
+
+	if !*sanitize {
+		// Letters of other scripts look valid, say which character is not
+		if i := strings.IndexFunc(name, func(r rune) bool { return r >= utf8.RuneSelf }); i >= 0 {
+			r, _ := utf8.DecodeRuneInString(name[i:])
+			warnf("%s: invalid %s variable name, expected ASCII %s, got %q (see -sanitize)", name, outType, rule.description, r)
+			return name
+		}
+		warnf("%s: invalid %s variable name, expected %s (see -sanitize)", name, outType, rule.description)
+		return name
+	}
+
+	clean := sanitizeName(name, rule)
+	renamed[name] = clean
+	// A renamed variable no longer matches what the application reads
+	if *strict {
+		warnf("%s: %s variable name sanitized to %s", name, outType, clean)
+	}
+	return clean
+}
+
+// writeRenamed prints the -sanitize mapping table
+func writeRenamed(w io.Writer, renamed map[string]string) {
+	if len(renamed) == 0 || logLevel > slog.LevelInfo {
+		return
+	}
+
+	if *logFormat == "json" {
+		for _, name := range appsettingsenv.SortedKeys(renamed) {
+			logEvent(slog.LevelInfo, "sanitized name", "from", name, "to", renamed[name])
+		}
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "sanitized names:")
+	for _, name := range appsettingsenv.SortedKeys(renamed) {
+		fmt.Fprintf(tw, "  %s\t-> %s\n", name, renamed[name])
+	}
+	tw.Flush()
+}
+
+// caseCollisions groups the names of vars that differ only by case
+func caseCollisions(vars map[string]string) [][]string {
+	groups := make(map[string][]string)
+	for _, name := range appsettingsenv.SortedKeys(vars) {
+		lower := strings.ToLower(name)
+		groups[lower] = append(groups[lower], name)
+	}
+
+	var out [][]string
+	for _, name := range appsettingsenv.SortedKeys(vars) {
+		if g := groups[strings.ToLower(name)]; len(g) > 1 && g[0] == name {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// separatorConflicts returns the keys of vars that do not survive a round trip
+// through sep, e.g. the JSON key "Feature__Enabled" which .NET would read back as
+// two segments from the environment variable Section__Feature__Enabled
+func separatorConflicts(vars map[string]string, sep string) []string {
+	if sep == keySep {
+		return nil
+	}
+
+	var out []string
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		if strings.ReplaceAll(strings.ReplaceAll(k, keySep, sep), sep, keySep) != k {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// escapeSeparator replaces sep inside the segments of key with a single
+// underscore, trimming underscores that would merge with sep
+func escapeSeparator(key, sep string) string {
+	segments := strings.Split(key, keySep)
+	for i, s := range segments {
+		s = strings.ReplaceAll(s, sep, "_")
+		if strings.HasPrefix(sep, "_") || strings.HasSuffix(sep, "_") {
+			s = strings.Trim(s, "_")
+		}
+		segments[i] = s
+	}
+	return strings.Join(segments, keySep)
+}
+
+// checkSeparatorKeys applies -separator-keys to the keys containing the separator
+func checkSeparatorKeys(vars map[string]string, sep string) (map[string]string, error) {
+	conflicts := separatorConflicts(vars, sep)
+	if len(conflicts) == 0 {
+		return vars, nil
+	}
+
+	switch *separatorKeys {
+	case "error":
+		return nil, validationError(fmt.Errorf("keys containing the separator %q: %s", sep, strings.Join(conflicts, ", ")))
+	case "escape":
+		out := make(map[string]string, len(vars))
+		for k, v := range vars {
+			out[k] = v
+		}
+		for _, k := range conflicts {
+			escaped := escapeSeparator(k, sep)
+			delete(out, k)
+			mergeVariables(out, map[string]string{escaped: vars[k]})
+			warnf("%s: key contains the separator %q, emitted as %s", k, sep, strings.ReplaceAll(escaped, keySep, sep))
+		}
+		return out, nil
+	default:
+		for _, k := range conflicts {
+			warnf("%s: key contains the separator %q and will not read back as the same key in .NET", k, sep)
+		}
+		return vars, nil
+	}
+}
+
+// reservedNames are host and runtime variables that break containers in confusing
+// ways when overwritten by configuration
+var reservedNames = map[string]bool{
+	"PATH": true, "HOME": true, "HOSTNAME": true, "USER": true, "SHELL": true, "PWD": true,
+	"LANG": true, "TERM": true, "TMPDIR": true, "TEMP": true, "TMP": true, "LD_LIBRARY_PATH": true,
+	"ASPNETCORE_URLS": true, "ASPNETCORE_ENVIRONMENT": true, "ASPNETCORE_HTTP_PORTS": true,
+	"ASPNETCORE_HTTPS_PORTS": true, "DOTNET_ENVIRONMENT": true, "DOTNET_RUNNING_IN_CONTAINER": true,
+	"DOTNET_ROOT": true, "KUBERNETES_SERVICE_HOST": true, "KUBERNETES_SERVICE_PORT": true,
+}
+
+// reservedCollisions returns the names of vars that overwrite reserved variables
+// when the output type ends up in a process environment
+func reservedCollisions(outType string, vars map[string]string) []string {
+	if !envTargets[outType] {
+		return nil
+	}
+
+	var out []string
+	for _, name := range appsettingsenv.SortedKeys(vars) {
+		if reservedNames[strings.ToUpper(name)] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
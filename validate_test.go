@@ -0,0 +1,195 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestValueProblems(t *testing.T) {
+	cases := []struct {
+		outType, value string
+		problems       int
+	}{
+		{"docker", "plain", 0},
+		{"docker", "line1\nline2", 1},
+		{"k8s", "line1\nline2", 0},
+		{"compose", "line1\nline2", 0},
+		{"bicep", "line1\nline2", 0},
+		{"compose", "bad\xffbyte", 1},
+		{"k8s", "nul\x00byte", 1},
+		{"user-secrets", "nul\x00byte", 0},
+		{"docker", strings.Repeat("x", maxEnvValue+1), 1},
+		{"appconfig", strings.Repeat("x", maxAppConfigValue+1), 1},
+		// Limits count UTF-16 units on Windows and bytes in App Configuration
+		{"docker", strings.Repeat("é", 20000), 0},
+		{"appconfig", strings.Repeat("é", 6000), 1},
+	}
+
+	for _, c := range cases {
+		if got := valueProblems(c.outType, "Key", c.value); len(got) != c.problems {
+			t.Fatalf("valueProblems(%q, %.20q): want %d problems got %v", c.outType, c.value, c.problems, got)
+		}
+	}
+}
+
+func TestValidateManifestSizes(t *testing.T) {
+	defer func(s bool, n int) { *splitConfigMap, warnings = s, n }(*splitConfigMap, warnings)
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = io.Discard
+
+	big := strings.Repeat("x", maxManifestData/2)
+	vars := []appsettingsenv.KV{{Name: "A", Value: big}, {Name: "B", Value: big}, {Name: "C", Value: "s", Secret: true}}
+	if n := validateManifestSizes("configmap", vars, true); n != 1 {
+		t.Fatalf("oversized ConfigMap: want 1 problem got %d", n)
+	}
+	if n := validateManifestSizes("k8s", vars, true); n != 0 {
+		t.Fatalf("k8s output holds no ConfigMap: got %d problems", n)
+	}
+	*splitConfigMap = true
+	if n := validateManifestSizes("configmap", vars, true); n != 0 {
+		t.Fatalf("-split ConfigMaps: got %d problems", n)
+	}
+}
+
+func TestValidateEnvSize(t *testing.T) {
+	defer func(n int) { warnings = n }(warnings)
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = io.Discard
+
+	vars := []appsettingsenv.KV{{Name: "A", Value: "1234"}, {Name: "B", Value: "5678"}}
+	if envSize(vars) != 14 {
+		t.Fatalf("unexpected size: %d", envSize(vars))
+	}
+	cases := []struct {
+		outType  string
+		budget   int
+		problems int
+	}{
+		{"docker", 14, 0},
+		{"docker", 13, 1},
+		{"docker", 0, 0},
+		{"appconfig", 1, 0},
+	}
+	for _, c := range cases {
+		if n := validateEnvSize(c.outType, vars, c.budget); n != c.problems {
+			t.Fatalf("%s with budget %d: want %d problems got %d", c.outType, c.budget, c.problems, n)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	cases := []struct {
+		rule       nameRule
+		name, want string
+	}{
+		{identifierRule, "Logging__LogLevel__Default", "Logging__LogLevel__Default"},
+		{identifierRule, "My.App__Log-Level", "My_App__Log_Level"},
+		{identifierRule, "2fa__Enabled", "_2fa__Enabled"},
+		{identifierRule, "Feature Flags", "Feature_Flags"},
+		{kubeEnvRule, "My.App__Log-Level", "My.App__Log-Level"},
+		{kubeKeyRule, "Path/To:Key", "Path_To_Key"},
+	}
+
+	for _, c := range cases {
+		got := sanitizeName(c.name, c.rule)
+		if got != c.want {
+			t.Fatalf("sanitizeName(%q): want %q got %q", c.name, c.want, got)
+		}
+		if !validName(got, c.rule) {
+			t.Fatalf("sanitized name %q is not valid", got)
+		}
+	}
+}
+
+func TestCheckNameStrict(t *testing.T) {
+	defer func(s, st bool, n int) { *sanitize, *strict, warnings = s, st, n }(*sanitize, *strict, warnings)
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = io.Discard
+
+	*sanitize, *strict, warnings = true, false, 0
+	renamed := map[string]string{}
+	if got := checkName("docker", "My.App", renamed); got != "My_App" || warnings != 0 {
+		t.Fatalf("sanitize: got %q with %d warnings", got, warnings)
+	}
+
+	*strict = true
+	checkName("docker", "My.App", renamed)
+	if warnings != 1 {
+		t.Fatalf("sanitized names should count as warnings under -strict, got %d", warnings)
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	vars := map[string]string{"Api__Url": "a", "API__Url": "b", "api__url": "c", "Other": "d"}
+	got := caseCollisions(vars)
+	if len(got) != 1 || strings.Join(got[0], " ") != "API__Url Api__Url api__url" {
+		t.Fatalf("unexpected collisions: %v", got)
+	}
+}
+
+func TestSeparatorConflicts(t *testing.T) {
+	vars := map[string]string{
+		"Section:Feature__Enabled": "true",
+		"Section:Trailing_:Key":    "x",
+		"Section:Plain":            "y",
+	}
+
+	got := separatorConflicts(vars, "__")
+	if strings.Join(got, " ") != "Section:Feature__Enabled Section:Trailing_:Key" {
+		t.Fatalf("unexpected conflicts: %v", got)
+	}
+	if got := separatorConflicts(vars, ":"); len(got) != 0 {
+		t.Fatalf("the internal separator never conflicts: %v", got)
+	}
+
+	for _, k := range got {
+		if escaped := escapeSeparator(k, "__"); len(separatorConflicts(map[string]string{escaped: ""}, "__")) != 0 {
+			t.Fatalf("escaped key %q still conflicts", escaped)
+		}
+	}
+	if escaped := escapeSeparator("Section:Feature__Enabled", "__"); escaped != "Section:Feature_Enabled" {
+		t.Fatalf("unexpected escaped key: %q", escaped)
+	}
+}
+
+func TestReservedCollisions(t *testing.T) {
+	vars := map[string]string{"Path": "/app", "ASPNETCORE_URLS": "http://+:80", "Api__Path": "/v1"}
+	if got := reservedCollisions("k8s", vars); strings.Join(got, " ") != "ASPNETCORE_URLS Path" {
+		t.Fatalf("unexpected collisions: %v", got)
+	}
+	if got := reservedCollisions("appconfig", vars); len(got) != 0 {
+		t.Fatalf("appconfig keys are not environment variables: %v", got)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	defer func(f string) { *file = f }(*file)
+	defer func(w io.Writer) { logOutput = w }(logOutput)
+	logOutput = io.Discard
+
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	duplicate := filepath.Join(dir, "duplicate.json")
+	bad := filepath.Join(dir, "bad.json")
+	for name, content := range map[string]string{
+		valid:     `{"Logging": {"Level": "Debug"}}`,
+		duplicate: `{"Logging": {"Level": "Debug", "level": "Information"}}`,
+		bad:       `{"Logging": `,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatalf("write test file: %v", err)
+		}
+	}
+
+	cases := map[string]int{valid: exitOK, duplicate: exitValidation, bad: exitParse}
+	for name, want := range cases {
+		if got := runValidate([]string{"-file", name}); got != want {
+			t.Fatalf("%s: want exit code %d got %d", filepath.Base(name), want, got)
+		}
+	}
+}
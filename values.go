@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+// formatBool normalizes boolean values according to -bool-format: "lower" emits
+// true/false, "int" emits 1/0 and "preserve" leaves values untouched.
+func formatBool(value, mode string) string {
+	if mode == "" || mode == "preserve" {
+		return value
+	}
+
+	var b bool
+	switch {
+	case strings.EqualFold(value, "true"):
+		b = true
+	case strings.EqualFold(value, "false"):
+		b = false
+	default:
+		return value
+	}
+
+	if mode == "int" {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// removeNewlines implements -strip-newlines: the line breaks of value are removed,
+// joining its lines
+func removeNewlines(value string) string {
+	return strings.NewReplacer("\r\n", "", "\n", "", "\r", "").Replace(value)
+}
+
+// literals records the flattened keys whose value was written as a JSON literal
+// (number, boolean or null), lower-cased and paired with that value, so typed outputs can emit them unquoted.
+var literals = make(map[string]bool)
+
+// recordLiteral remembers that key held the JSON literal value
+func recordLiteral(key, value string) {
+	literals[strings.ToLower(key)+"\x00"+value] = true
+}
+
+// isLiteral reports whether value is the JSON literal a configuration file gave key.
+// Values replaced by a later layer, e.g. an environment variable, are strings again.
+func isLiteral(key, value string) bool {
+	return literals[strings.ToLower(key)+"\x00"+value]
+}
+
+// sourceOrder records the rank of the flattened keys, lower-cased, in the order they
+// first appear in the configuration files, for -sort source
+var sourceOrder = make(map[string]int)
+
+// recordSource appends key to sourceOrder unless an earlier file holds it
+func recordSource(key string) {
+	key = strings.ToLower(key)
+	if _, ok := sourceOrder[key]; !ok {
+		sourceOrder[key] = len(sourceOrder)
+	}
+}
+
+// sourceRank returns the rank of key in sourceOrder
+func sourceRank(key string) (int, bool) {
+	rank, ok := sourceOrder[strings.ToLower(key)]
+	return rank, ok
+}
+
+// placeholderPattern matches ${Section:Key} and %SECTION__KEY% references
+var placeholderPattern = regexp.MustCompile(`\$\{([^{}]+)\}|%([^%\s]+)%`)
+
+// resolvePlaceholders expands references to other keys inside values. Keys match
+// case-insensitively in either notation; references to unknown keys are kept as is
+// and a reference cycle is an error.
+func resolvePlaceholders(vars map[string]string) (map[string]string, error) {
+	index := make(map[string]string, len(vars))
+	for k := range vars {
+		index[strings.ToLower(k)] = k
+	}
+
+	resolved := make(map[string]string, len(vars))
+	visiting := make(map[string]bool)
+
+	var resolve func(key string, path []string) (string, error)
+	resolve = func(key string, path []string) (string, error) {
+		if v, ok := resolved[key]; ok {
+			return v, nil
+		}
+		if visiting[key] {
+			return "", validationError(fmt.Errorf("placeholder cycle: %s", strings.Join(append(path, key), " -> ")))
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+
+		// Key Vault references are passed through verbatim
+		if isKeyVaultRef(vars[key]) {
+			resolved[key] = vars[key]
+			return vars[key], nil
+		}
+
+		var err error
+		value := placeholderPattern.ReplaceAllStringFunc(vars[key], func(ref string) string {
+			m := placeholderPattern.FindStringSubmatch(ref)
+			name := normalizeKey(m[1]+m[2], keySep)
+			target, ok := index[strings.ToLower(name)]
+			if !ok || err != nil {
+				return ref
+			}
+			var v string
+			v, err = resolve(target, append(path, key))
+			return v
+		})
+		if err != nil {
+			return "", err
+		}
+		resolved[key] = value
+		return value, nil
+	}
+
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		if _, err := resolve(k, nil); err != nil {
+			return nil, err
+		}
+	}
+	return resolved, nil
+}
+
+// envPattern matches ${NAME} references to environment variables
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// substituteEnv replaces ${NAME} references inside values with the value of the
+// environment variable NAME as returned by lookup. Unset variables are kept and reported.
+func substituteEnv(vars map[string]string, lookup func(string) (string, bool)) map[string]string {
+	out := make(map[string]string, len(vars))
+	for _, k := range appsettingsenv.SortedKeys(vars) {
+		if isKeyVaultRef(vars[k]) {
+			out[k] = vars[k]
+			continue
+		}
+		out[k] = envPattern.ReplaceAllStringFunc(vars[k], func(ref string) string {
+			name := envPattern.FindStringSubmatch(ref)[1]
+			if v, ok := lookup(name); ok {
+				return v
+			}
+			warnf("%s: environment variable %s is not set", k, name)
+			return ref
+		})
+	}
+	return out
+}
+
+// expandValues applies -resolve-placeholders and then -substitute-env to merged
+// variables, so references to configuration keys win over environment variables
+func expandValues(vars map[string]string) (map[string]string, error) {
+	if *resolveRefs {
+		var err error
+		if vars, err = resolvePlaceholders(vars); err != nil {
+			return nil, err
+		}
+	}
+	if *substituteEnvVars {
+		vars = substituteEnv(vars, os.LookupEnv)
+	}
+	return vars, nil
+}
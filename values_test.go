@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func TestFormatBool(t *testing.T) {
+	cases := []struct{ value, mode, want string }{
+		{"True", "lower", "true"},
+		{"FALSE", "lower", "false"},
+		{"true", "int", "1"},
+		{"False", "int", "0"},
+		{"True", "preserve", "True"},
+		{"Trueish", "lower", "Trueish"},
+		{"1", "lower", "1"},
+	}
+
+	for _, c := range cases {
+		if got := formatBool(c.value, c.mode); got != c.want {
+			t.Fatalf("formatBool(%q, %q): want %q got %q", c.value, c.mode, c.want, got)
+		}
+	}
+}
+
+func TestRemoveNewlines(t *testing.T) {
+	if got := removeNewlines("-----BEGIN KEY-----\r\nMIIB\nAB==\r-----END KEY-----"); got != "-----BEGIN KEY-----MIIBAB==-----END KEY-----" {
+		t.Fatalf("unexpected value: %q", got)
+	}
+}
+
+func TestParser_RecordsLiterals(t *testing.T) {
+	in := map[string]any{"Port": json.Number("8080"), "Debug": true, "Name": "8080", "Hosts": []any{json.Number("1")}}
+	appsettingsenv.Flatten(in, flattenOptions(":"))
+
+	if !isLiteral("port", "8080") || !isLiteral("Debug", "true") || !isLiteral("Hosts:0", "1") {
+		t.Fatalf("numbers and booleans should be recorded as literals")
+	}
+	if isLiteral("Name", "8080") {
+		t.Fatalf("strings must not be recorded as literals")
+	}
+	if isLiteral("Port", "9090") {
+		t.Fatalf("an overridden value must not be a literal")
+	}
+}
+
+func TestResolvePlaceholders(t *testing.T) {
+	vars := map[string]string{
+		"Db:Host":                     "sql",
+		"Db:Name":                     "app",
+		"ConnectionStrings:Default":   "Server=${db:host};Database=%DB__NAME%",
+		"ConnectionStrings:Reporting": "${ConnectionStrings:Default};ReadOnly=true",
+		"Discount":                    "50%",
+		"Unknown":                     "${Not:There}",
+	}
+
+	got, err := resolvePlaceholders(vars)
+	if err != nil {
+		t.Fatalf("resolvePlaceholders failed: %v", err)
+	}
+	if got["ConnectionStrings:Default"] != "Server=sql;Database=app" {
+		t.Fatalf("unexpected value: %q", got["ConnectionStrings:Default"])
+	}
+	if got["ConnectionStrings:Reporting"] != "Server=sql;Database=app;ReadOnly=true" {
+		t.Fatalf("nested placeholders not resolved: %q", got["ConnectionStrings:Reporting"])
+	}
+	if got["Discount"] != "50%" || got["Unknown"] != "${Not:There}" {
+		t.Fatalf("values without known references must be kept: %v", got)
+	}
+
+	cyclic := map[string]string{"A": "${B}", "B": "x${A}"}
+	if _, err := resolvePlaceholders(cyclic); err == nil {
+		t.Fatalf("expected cycle error")
+	}
+}
+
+func TestSubstituteEnv(t *testing.T) {
+	env := map[string]string{"DB_PASSWORD": "s3cret"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	got := substituteEnv(map[string]string{
+		"Db:Password": "${DB_PASSWORD}",
+		"Db:User":     "${DB_USER}",
+		"Section":     "${Db:Password}",
+	}, lookup)
+
+	if got["Db:Password"] != "s3cret" {
+		t.Fatalf("unexpected value: %q", got["Db:Password"])
+	}
+	if got["Db:User"] != "${DB_USER}" || got["Section"] != "${Db:Password}" {
+		t.Fatalf("unset variables and key references must be kept: %v", got)
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// vaultClient is a HashiCorp Vault client of the KV v2 secrets engine
+type vaultClient struct {
+	restClient
+	token string
+}
+
+// newVaultClient builds a client for addr, trusting the VAULT_CACERT certificate
+// authority when set
+func newVaultClient(addr, namespace string) (*vaultClient, error) {
+	if addr == "" {
+		return nil, errors.New("-addr is required when VAULT_ADDR is not set")
+	}
+
+	c := &vaultClient{}
+	c.restClient = newRESTClient("Vault API", addr+"/v1", func(_ context.Context, req *http.Request) error {
+		if c.token != "" {
+			req.Header.Set("X-Vault-Token", c.token)
+		}
+		if namespace != "" {
+			req.Header.Set("X-Vault-Namespace", namespace)
+		}
+		return nil
+	})
+
+	if caFile := os.Getenv("VAULT_CACERT"); caFile != "" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_CACERT: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("VAULT_CACERT: no certificates found")
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		c.http.Transport = transport
+	}
+	return c, nil
+}
+
+// loginAppRole exchanges an AppRole role and secret ID for a token
+func (c *vaultClient) loginAppRole(ctx context.Context, mount, roleID, secretID string) error {
+	var resp struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": roleID, "secret_id": secretID}
+	if err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", body, &resp); err != nil {
+		return fmt.Errorf("approle login: %w", err)
+	}
+	c.token = resp.Auth.ClientToken
+	return nil
+}
+
+// readKV returns the data and version of the latest version of a KV v2 secret. A
+// missing secret has version 0 and a deleted one no data.
+func (c *vaultClient) readKV(ctx context.Context, mount, path string) (map[string]string, int, error) {
+	var resp struct {
+		Data struct {
+			Data     map[string]any `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+	err := c.do(ctx, http.MethodGet, mount+"/data/"+path, nil, &resp)
+	if isStatus(err, http.StatusNotFound) {
+		// A deleted latest version still counts for check-and-set
+		var meta struct {
+			Data struct {
+				CurrentVersion int `json:"current_version"`
+			} `json:"data"`
+		}
+		if c.do(ctx, http.MethodGet, mount+"/metadata/"+path, nil, &meta) != nil {
+			meta.Data.CurrentVersion = 0
+		}
+		return map[string]string{}, meta.Data.CurrentVersion, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("read %s/%s: %w", mount, path, err)
+	}
+
+	data := make(map[string]string, len(resp.Data.Data))
+	for k, v := range resp.Data.Data {
+		if s, ok := v.(string); ok {
+			data[k] = s
+		} else {
+			data[k] = fmt.Sprint(v)
+		}
+	}
+	return data, resp.Data.Metadata.Version, nil
+}
+
+// writeKV writes a new version of a KV v2 secret. A cas of 0 or more only succeeds
+// when the current version is cas; -1 writes unconditionally.
+func (c *vaultClient) writeKV(ctx context.Context, mount, path string, data map[string]string, cas int) (int, error) {
+	body := map[string]any{"data": data}
+	if cas >= 0 {
+		body["options"] = map[string]any{"cas": cas}
+	}
+
+	var resp struct {
+		Data struct {
+			Version int `json:"version"`
+		} `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodPost, mount+"/data/"+path, body, &resp); err != nil {
+		return 0, fmt.Errorf("write %s/%s: %w", mount, path, err)
+	}
+	return resp.Data.Version, nil
+}
+
+// runPushVault implements push vault. It merges the variables into a KV v2 secret,
+// after printing what changes.
+func runPushVault(args []string) int {
+	addr := flag.String("addr", "", "Vault address (default: $VAULT_ADDR)")
+	namespace := flag.String("vault-namespace", "", "Vault Enterprise namespace (default: $VAULT_NAMESPACE)")
+	mount := flag.String("mount", "secret", "Mount path of the KV v2 secrets engine")
+	secretPath := flag.String("path", "", "Path of the secret in the mount, e.g. myapp/production (required)")
+	roleID := flag.String("role-id", "", "AppRole role ID, to log in instead of using $VAULT_TOKEN")
+	secretID := flag.String("secret-id", "", "AppRole secret ID (default: $VAULT_SECRET_ID)")
+	approleMount := flag.String("approle-mount", "approle", "Mount path of the AppRole auth method")
+	cas := flag.Bool("cas", false, "Check-and-set: fail instead of overwriting a version written since the secret was read")
+	dryRun := flag.Bool("dry-run", false, "Only print the changes")
+
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s push vault [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	// The appconfig type keeps ":" keys without name rules
+	r, code := renderOutput("push vault", "appconfig", args)
+	if r == nil {
+		return code
+	}
+
+	*secretPath = strings.Trim(*secretPath, "/")
+	*mount = strings.Trim(*mount, "/")
+	if *secretPath == "" {
+		errorf("-path is required")
+		return exitUsage
+	}
+
+	if *addr == "" {
+		*addr = os.Getenv("VAULT_ADDR")
+	}
+	if *namespace == "" {
+		*namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	client, err := newVaultClient(*addr, *namespace)
+	if err != nil {
+		logError(err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	if *roleID != "" {
+		if *secretID == "" {
+			*secretID = os.Getenv("VAULT_SECRET_ID")
+		}
+		if err := client.loginAppRole(ctx, *approleMount, *roleID, *secretID); err != nil {
+			logError(err)
+			return exitIO
+		}
+	} else if client.token = os.Getenv("VAULT_TOKEN"); client.token == "" {
+		errorf("VAULT_TOKEN is not set; set it or log in with -role-id")
+		return exitUsage
+	}
+
+	store := &vaultStore{client: client, mount: *mount, path: *secretPath, cas: *cas}
+	if err := pushSecretStore(ctx, os.Stdout, store, r, *dryRun); err != nil {
+		logError(err)
+		return exitIO
+	}
+	return exitOK
+}
+
+// vaultStore is the secretStore of a KV v2 secret, whose keys are the variables
+type vaultStore struct {
+	client *vaultClient
+	mount  string
+	path   string
+	// cas makes the write fail when the secret changed since it was read
+	cas bool
+	// version is the version of the secret read by entries
+	version int
+}
+
+func (s *vaultStore) String() string {
+	return "Vault secret " + s.mount + "/" + s.path
+}
+
+// entry keeps the name of the variable: a KV secret has no naming rules, and all of
+// its keys are secret
+func (s *vaultStore) entry(name, value string, _ bool) (*secretEntry, error) {
+	return &secretEntry{name: name, value: value, secret: true}, nil
+}
+
+// entries returns the keys of the latest version of the secret
+func (s *vaultStore) entries(ctx context.Context, _ []string) (map[string]secretEntry, error) {
+	data, version, err := s.client.readKV(ctx, s.mount, s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.version = version
+	entries := make(map[string]secretEntry, len(data))
+	for k, v := range data {
+		entries[k] = secretEntry{name: k, value: v, secret: true}
+	}
+	return entries, nil
+}
+
+// write writes a new version of the secret, the changed keys merged into the
+// current ones
+func (s *vaultStore) write(ctx context.Context, changed []secretEntry, current map[string]secretEntry) error {
+	data := make(map[string]string, len(current)+len(changed))
+	for k, e := range current {
+		data[k] = e.value
+	}
+	for _, e := range changed {
+		data[e.name] = e.value
+	}
+
+	checkAndSet := -1
+	if s.cas {
+		checkAndSet = s.version
+	}
+	version, err := s.client.writeKV(ctx, s.mount, s.path, data, checkAndSet)
+	if err != nil {
+		return err
+	}
+	infof("%s/%s is at version %d", s.mount, s.path, version)
+	return nil
+}
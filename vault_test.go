@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVaultPushKV(t *testing.T) {
+	version := 3
+	stored := map[string]any{"Existing": "kept", "Logging:Level": "Information"}
+	var lastCAS any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/auth/approle/login" && r.Method == http.MethodPost:
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "role" || body["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors": ["invalid role or secret ID"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth": {"client_token": "s.token"}}`))
+		case r.Header.Get("X-Vault-Token") != "s.token" || r.Header.Get("X-Vault-Namespace") != "team":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+		case r.URL.Path == "/v1/kv/data/api/prod" && r.Method == http.MethodGet:
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": stored, "metadata": map[string]any{"version": version}}})
+		case r.URL.Path == "/v1/kv/data/api/prod" && r.Method == http.MethodPost:
+			var body struct {
+				Data    map[string]any `json:"data"`
+				Options map[string]any `json:"options"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			lastCAS = body.Options["cas"]
+			if lastCAS != nil && int(lastCAS.(float64)) != version {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"errors": ["check-and-set parameter did not match the current version"]}`))
+				return
+			}
+			stored, version = body.Data, version+1
+			json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"version": version}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer srv.Close()
+
+	client, err := newVaultClient(srv.URL, "team")
+	if err != nil {
+		t.Fatalf("newVaultClient: %v", err)
+	}
+	ctx := context.Background()
+	if err := client.loginAppRole(ctx, "approle", "role", "wrong"); err == nil || !strings.Contains(err.Error(), "invalid role") {
+		t.Fatalf("bad secret ID should fail: %v", err)
+	}
+	if err := client.loginAppRole(ctx, "approle", "role", "secret"); err != nil {
+		t.Fatalf("approle login: %v", err)
+	}
+
+	r := &rendered{named: map[string]string{"Logging:Level": "Warning", "Db:Password": "s3cret"}}
+	store := &vaultStore{client: client, mount: "kv", path: "api/prod", cas: true}
+	var out bytes.Buffer
+	if err := pushSecretStore(ctx, &out, store, r, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	want := "added   Db:Password: \"(secret)\"\nchanged Logging:Level: \"(secret)\" -> \"(secret)\"\n"
+	if out.String() != want || version != 3 {
+		t.Fatalf("unexpected preview:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := pushSecretStore(ctx, &out, store, r, false); err != nil {
+		t.Fatalf("push: %v", err)
+	}
+	if lastCAS != float64(3) || stored["Existing"] != "kept" || stored["Db:Password"] != "s3cret" {
+		t.Fatalf("unexpected write: cas %v, data %v", lastCAS, stored)
+	}
+	if !strings.HasSuffix(out.String(), "2 secret(s) written to Vault secret kv/api/prod\n") || version != 4 {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+
+	// Nothing changed since the last push
+	out.Reset()
+	if err := pushSecretStore(ctx, &out, store, r, false); err != nil || out.String() != "Vault secret kv/api/prod up to date\n" {
+		t.Fatalf("unchanged secret should not be written: %q, %v", out.String(), err)
+	}
+
+	// A missing secret is created with cas 0
+	data, v, err := client.readKV(ctx, "kv", "api/other")
+	if err != nil || v != 0 || len(data) != 0 {
+		t.Fatalf("missing secret: %v, %d, %v", data, v, err)
+	}
+}
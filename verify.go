@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runVerify implements the verify subcommand. It renders every output type, reads the
+// variables back as the .NET configuration providers would and compares the result
+// with the configuration, returning 4 when any value is lost or changed.
+func runVerify(args []string) int {
+	flag.CommandLine.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s verify [flags]:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	cv, code := prepareConversion(args)
+	if cv == nil {
+		return code
+	}
+
+	outputs, err := cv.render()
+	if err != nil {
+		logError(err)
+		return exitCode(err, exitFailure)
+	}
+
+	lossy := 0
+	for i, r := range outputs {
+		differences := verifyOutput(cv.converters[i], cv.variables, r)
+		for _, d := range differences {
+			warnf("%s: %s", r.outType, d)
+		}
+		if len(differences) > 0 {
+			lossy++
+			continue
+		}
+		infof("%s: %d variable(s) read back as the configuration", r.outType, len(r.named))
+	}
+	if lossy > 0 {
+		errorf("%d output(s) do not reproduce the configuration", lossy)
+		return exitValidation
+	}
+	return exitOK
+}
+
+// verifyOutput rebuilds the configuration tree that .NET reads from the variables of
+// r and compares it with the tree of the variables the converter rendered, keyed by
+// canonical ":" keys, leaving out the keys its filters drop. Rewrites that .NET reads
+// back as the same configuration, such as the host variables, feature flags and
+// translated Kestrel endpoints, are not differences.
+func verifyOutput(c *converter, variables map[string]string, r *rendered) []string {
+	source := make(map[string]string, len(variables))
+	for k, v := range variables {
+		if c.filter.match(strings.ReplaceAll(k, keySep, c.sep)) && !rewritten(c.outType, k) {
+			source[k] = v
+		}
+	}
+
+	// Encrypted values are unwrapped at deployment, they are compared as the
+	// configuration holds them
+	read := make(map[string]string, len(r.named))
+	for name, v := range r.named {
+		key, ok := readBackKey(name, c.sep)
+		if !ok {
+			continue
+		}
+		if r.encrypted[name] {
+			v = foldValue(source, key)
+		}
+		read[key] = v
+	}
+
+	// Differences never show the values of secret-looking keys
+	secret, err := newRedactFilter(true, redactKeys, keySep)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	want, _ := buildTree(source, keySep, isLiteral)
+	got, conflicts := buildTree(read, keySep, nil)
+	var differences []string
+	for _, k := range conflicts {
+		differences = append(differences, fmt.Sprintf("%s is both a value and a section once read back", k))
+	}
+	compareTrees(want, got, "", secret.match, func(format string, args ...any) {
+		differences = append(differences, fmt.Sprintf(format, args...))
+	})
+	return differences
+}
+
+// foldValue returns the value of key in variables, matched case-insensitively
+func foldValue(variables map[string]string, key string) string {
+	if v, ok := variables[key]; ok {
+		return v
+	}
+	for k, v := range variables {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// rewritten reports whether -feature-flags or -kestrel-urls replace key in outType
+// output by variables that .NET reads back in its place
+func rewritten(outType, key string) bool {
+	section, rest, _ := strings.Cut(key, keySep)
+	switch {
+	case *featureFlagsOut && outType == "appconfig" && strings.EqualFold(section, featureManagementSection):
+		return true
+	case *kestrelURLs && envTargets[outType] && strings.EqualFold(section, "Kestrel"):
+		endpoints, _, _ := strings.Cut(rest, keySep)
+		return strings.EqualFold(endpoints, "Endpoints")
+	}
+	return false
+}
+
+// hostVariableNames are the variables -host-vars adds, read by the host and not by
+// the application configuration
+var hostVariableNames = map[string]bool{"ASPNETCORE_ENVIRONMENT": true, "DOTNET_ENVIRONMENT": true, "ASPNETCORE_URLS": true}
+
+// readBackKey returns the configuration key .NET reads from the variable called
+// name, joined by sep: without the -prefix, which AddEnvironmentVariables(prefix)
+// removes, and with the App Service connection string prefixes mapped back to the
+// ConnectionStrings section. Host variables and feature flags are not keys.
+func readBackKey(name, sep string) (string, bool) {
+	if *hostVars && hostVariableNames[name] || strings.HasPrefix(name, appConfigReserved) {
+		return "", false
+	}
+	for _, p := range connectionStringPrefixes {
+		if rest, ok := strings.CutPrefix(name, p); ok && *connStrType != "" {
+			return "ConnectionStrings" + keySep + rest, true
+		}
+	}
+	if len(name) >= len(*prefix) && strings.EqualFold(name[:len(*prefix)], *prefix) {
+		name = name[len(*prefix):]
+	}
+	return strings.ReplaceAll(name, sep, keySep), true
+}
+
+// compareTrees reports where got, the tree read back, differs from want. Keys match
+// case-insensitively, as in .NET configuration, and values compare as the strings
+// .NET reads: booleans in any case and nulls as empty strings. The values of the keys
+// secret matches are not reported.
+func compareTrees(want, got any, key string, secret func(key string) bool, report func(format string, args ...any)) {
+	wantSection, isSection := sectionOf(want)
+	gotSection, gotIsSection := sectionOf(got)
+	switch {
+	case isSection && gotIsSection:
+		seen := make(map[string]bool)
+		for _, k := range sortedFold(wantSection) {
+			match := foldKey(gotSection, k)
+			child := childKey(key, k)
+			if _, ok := gotSection[match]; !ok {
+				report("%s is missing", child)
+				continue
+			}
+			seen[match] = true
+			compareTrees(wantSection[k], gotSection[match], child, secret, report)
+		}
+		for _, k := range sortedFold(gotSection) {
+			if !seen[k] {
+				report("%s is not in the configuration", childKey(key, k))
+			}
+		}
+	case isSection:
+		report("section %s reads back as a value", key)
+	case gotIsSection:
+		report("%s reads back as a section", key)
+	default:
+		value, _ := got.(string)
+		switch {
+		case sameValue(want, value):
+		case secret(key):
+			report("%s: the value read back differs", key)
+		default:
+			report("%s: %s reads back as %q", key, jsonValue(want), value)
+		}
+	}
+}
+
+// sectionOf returns the children of a section, arrays being sections keyed by index
+func sectionOf(node any) (map[string]any, bool) {
+	switch n := node.(type) {
+	case map[string]any:
+		return n, true
+	case []any:
+		m := make(map[string]any, len(n))
+		for i, v := range n {
+			m[strconv.Itoa(i)] = v
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// sameValue reports whether .NET reads value as the JSON value want
+func sameValue(want any, value string) bool {
+	switch w := want.(type) {
+	case bool:
+		return strings.EqualFold(value, strconv.FormatBool(w))
+	case nil:
+		return value == ""
+	case json.Number:
+		return value == w.String()
+	case string:
+		return value == w
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestVerifyOutput(t *testing.T) {
+	logOutput = io.Discard
+	defer func() { logOutput = os.Stderr }()
+	defer func(b string, r bool) { *boolFormat, *redact = b, r }(*boolFormat, *redact)
+	clear(literals)
+	defer clear(literals)
+	recordLiteral("Api:Enabled", "true")
+	recordLiteral("Api:Timeout", "30")
+
+	vars := map[string]string{
+		"Api:Url":     "http://api",
+		"Api:Enabled": "true",
+		"Api:Timeout": "30",
+		"Api:a__b":    "x",
+		"Hosts:0":     "a",
+		"Hosts:1":     "b",
+		"Db:Password": "s3cret",
+	}
+	verify := func(outType string) []string {
+		c, err := newConverter(outType, nil)
+		if err != nil {
+			t.Fatalf("newConverter failed: %v", err)
+		}
+		r, err := c.render(vars)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		return verifyOutput(c, vars, r)
+	}
+
+	if got := verify("user-secrets"); len(got) != 0 {
+		t.Fatalf("user-secrets should read back as the configuration: %v", got)
+	}
+	// The separator inside a key splits it into sections
+	if got := verify("docker"); !slices.Equal(got, []string{"Api:a__b is missing", "Api:a is not in the configuration"}) {
+		t.Fatalf("unexpected differences: %v", got)
+	}
+
+	// Coerced values are reported, redacted ones without their value
+	*boolFormat, *redact = "int", true
+	delete(vars, "Api:a__b")
+	want := []string{`Api:Enabled: true reads back as "1"`, "Db:Password: the value read back differs"}
+	if got := verify("docker"); !slices.Equal(got, want) {
+		t.Fatalf("unexpected differences: %v", got)
+	}
+}
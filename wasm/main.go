@@ -0,0 +1,87 @@
+//go:build js && wasm
+
+// Command wasm exposes the conversion to JavaScript as appsettingsEnv.convert, so
+// that a web page converts appsettings.json with the same code as the command.
+//
+//	const { output, error } = appsettingsEnv.convert(json, { type: "k8s", name: "api" })
+package main
+
+import (
+	"strings"
+	"syscall/js"
+
+	"github.com/dassump/dotnet-appsettings-env/appsettingsenv"
+)
+
+func main() {
+	types := make([]any, 0)
+	for _, t := range appsettingsenv.Types() {
+		types = append(types, t)
+	}
+
+	js.Global().Set("appsettingsEnv", js.ValueOf(map[string]any{
+		"convert": js.FuncOf(convert),
+		"types":   types,
+	}))
+
+	// Keep the functions callable
+	select {}
+}
+
+// convert converts the JSON text of its first argument with the options of its
+// second one, returning {output} or {error}
+func convert(this js.Value, args []js.Value) any {
+	if len(args) == 0 || args[0].Type() != js.TypeString {
+		return result("", "convert expects the appsettings.json content as first argument")
+	}
+
+	var opts appsettingsenv.Options
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		o := args[1]
+		opts.Format = appsettingsenv.FormatOptions{
+			Type:       stringOption(o, "type"),
+			Name:       stringOption(o, "name"),
+			SecretName: stringOption(o, "secretName"),
+			Indent:     intOption(o, "indent"),
+			Quote:      stringOption(o, "quote"),
+		}
+		opts.Flatten = appsettingsenv.FlattenOptions{
+			Separator:      stringOption(o, "separator"),
+			ArrayMode:      stringOption(o, "arrayMode"),
+			ArrayDelimiter: stringOption(o, "arrayDelimiter"),
+			MaxDepth:       intOption(o, "maxDepth"),
+			PadIndex:       intOption(o, "padIndex"),
+			Nulls:          stringOption(o, "nulls"),
+		}
+		if opts.Flatten.ArrayMode == "join" && o.Get("arrayDelimiter").IsUndefined() {
+			opts.Flatten.ArrayDelimiter = ","
+		}
+	}
+
+	var out strings.Builder
+	if err := appsettingsenv.Convert(strings.NewReader(args[0].String()), &out, opts); err != nil {
+		return result("", err.Error())
+	}
+	return result(out.String(), "")
+}
+
+func result(output, err string) any {
+	if err != "" {
+		return js.ValueOf(map[string]any{"error": err})
+	}
+	return js.ValueOf(map[string]any{"output": output})
+}
+
+func stringOption(o js.Value, name string) string {
+	if v := o.Get(name); v.Type() == js.TypeString {
+		return v.String()
+	}
+	return ""
+}
+
+func intOption(o js.Value, name string) int {
+	if v := o.Get(name); v.Type() == js.TypeNumber {
+		return v.Int()
+	}
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups the bursts of events editors produce when saving a file
+const watchDebounce = 200 * time.Millisecond
+
+// watchFiles runs regenerate whenever a JSON file next to the -file pattern or its
+// matches changes, until interrupted. Directories are watched rather than files so
+// that editors replacing files on save are noticed. Changes to the outputs are ignored.
+func watchFiles(outputs []string, regenerate func() error) int {
+	ignore := make(map[string]bool, len(outputs))
+	for _, o := range outputs {
+		if abs, err := filepath.Abs(o); err == nil {
+			ignore[abs] = true
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logError(err)
+		return exitIO
+	}
+	defer watcher.Close()
+
+	dirs := watchDirs(*file)
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			errorf("watch %s: %v", dir, err)
+			return exitIO
+		}
+	}
+	infof("watching %s for changes", strings.Join(dirs, ", "))
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return exitOK
+			}
+			abs, _ := filepath.Abs(event.Name)
+			if watchedFile(event.Name) && !ignore[abs] && event.Op != fsnotify.Chmod {
+				timer = time.After(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return exitOK
+			}
+			logError(err)
+		case <-timer:
+			timer = nil
+			if err := regenerate(); err != nil {
+				logError(err)
+				continue
+			}
+			infof("regenerated at %s", time.Now().Format(time.TimeOnly))
+		case <-interrupt:
+			return exitOK
+		}
+	}
+}
+
+// watchDirs returns the directories holding the pattern and its matched files
+func watchDirs(pattern string) []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	add := func(dir string) {
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	// The directory part of the pattern may itself be a glob
+	parents, _ := filepath.Glob(filepath.Dir(pattern))
+	for _, dir := range parents {
+		add(dir)
+	}
+	files, _ := filepath.Glob(pattern)
+	for _, f := range files {
+		add(filepath.Dir(f))
+	}
+	return dirs
+}
+
+// watchedFile reports whether a change to name can affect the output, ignoring the
+// temporary files of atomic writes
+func watchedFile(name string) bool {
+	base := filepath.Base(name)
+	return strings.EqualFold(filepath.Ext(base), ".json") && !strings.HasPrefix(base, ".")
+}
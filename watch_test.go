@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchDirs(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"api", "worker"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := watchDirs(filepath.Join(dir, "*", "appsettings.json"))
+	if len(got) != 2 || got[0] != filepath.Join(dir, "api") || got[1] != filepath.Join(dir, "worker") {
+		t.Fatalf("unexpected directories: %v", got)
+	}
+
+	for name, want := range map[string]bool{
+		"appsettings.Production.json":      true,
+		"APPSETTINGS.JSON":                 true,
+		".appsettings.json.1234.tmp":       false,
+		"appsettings.json~":                false,
+		filepath.Join(dir, "secrets.json"): true,
+	} {
+		if got := watchedFile(name); got != want {
+			t.Fatalf("watchedFile(%q): want %v got %v", name, want, got)
+		}
+	}
+}